@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// abReservoirSize bounds the latencies kept per target for the
+// significance test
+const abReservoirSize = 100000
+
+// ABStats compares the two targets of an -ab run. PValue is the two-sided
+// p-value of a Mann-Whitney U test on the latencies of successful
+// requests; Significant is set when it is below 0.05, and Faster then
+// names the target with the lower median latency. MeanDifference is B's
+// mean latency minus A's, with its 95% confidence interval.
+type ABStats struct {
+	A                  ABTargetStats `json:"a"`
+	B                  ABTargetStats `json:"b"`
+	U                  float64       `json:"u"`
+	Z                  float64       `json:"z"`
+	PValue             float64       `json:"pValue"`
+	Significant        bool          `json:"significant"`
+	Faster             string        `json:"faster,omitempty"`
+	MeanDifference     float64       `json:"meanDifferenceMs"`
+	MeanDifferenceLow  float64       `json:"meanDifferenceLowMs"`
+	MeanDifferenceHigh float64       `json:"meanDifferenceHighMs"`
+}
+
+// ABTargetStats are the numbers of one -ab target
+type ABTargetStats struct {
+	URI               string  `json:"uri"`
+	Requests          int64   `json:"requests"`
+	Errors            int64   `json:"errors"`
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	ErrorRate         float64 `json:"errorRate"`
+	Average           float64 `json:"averageMs"`
+	P50               float64 `json:"p50Ms"`
+	P90               float64 `json:"p90Ms"`
+	P99               float64 `json:"p99Ms"`
+}
+
+// abGenerator splits the connections evenly between two targets: even
+// workers send to A and odd ones to B. Requests not tied to a worker
+// alternate.
+type abGenerator struct {
+	specs [2]requestSpec
+	next  uint64
+}
+
+func newABGenerator(method, body, a, b string) *abGenerator {
+	return &abGenerator{specs: [2]requestSpec{
+		{Method: method, URL: a, Body: body, Operation: "A", Path: a},
+		{Method: method, URL: b, Body: body, Operation: "B", Path: b},
+	}}
+}
+
+func (g *abGenerator) Next(ctx context.Context) (*http.Request, error) {
+	i := workerFrom(ctx)
+	if i < 0 {
+		i = int(atomic.AddUint64(&g.next, 1) - 1)
+	}
+	return g.specs[i%2].newRequest(ctx)
+}
+
+// operations lists the two targets for the per-operation statistics
+func (g *abGenerator) operations() []requestSpec {
+	return g.specs[:]
+}
+
+// abTracker keeps a uniform sample of the successful latencies of each
+// target. It is safe for concurrent use.
+type abTracker struct {
+	mu      sync.Mutex
+	samples map[string][]float64
+	seen    map[string]int64
+}
+
+func newABTracker() *abTracker {
+	return &abTracker{samples: make(map[string][]float64), seen: make(map[string]int64)}
+}
+
+func (t *abTracker) record(target string, latency float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen[target]++
+	if len(t.samples[target]) < abReservoirSize {
+		t.samples[target] = append(t.samples[target], latency)
+	} else if i := rand.Int63n(t.seen[target]); i < abReservoirSize {
+		t.samples[target][i] = latency
+	}
+}
+
+// summary compares the targets using the per-operation statistics of the
+// run, which has lasted elapsed seconds
+func (t *abTracker) summary(operations []OperationStats, elapsed float64) *ABStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &ABStats{}
+	for _, op := range operations {
+		target := &stats.A
+		if op.Operation == "B" {
+			target = &stats.B
+		} else if op.Operation != "A" {
+			continue
+		}
+		*target = ABTargetStats{URI: op.Path, Requests: op.Requests, Errors: op.Errors, Average: op.Latency.Average}
+		if elapsed > 0 {
+			target.RequestsPerSecond = float64(op.Requests) / elapsed
+		}
+		if op.Requests > 0 {
+			target.ErrorRate = float64(op.Errors) / float64(op.Requests) * 100
+		}
+	}
+
+	a, b := t.samples["A"], t.samples["B"]
+	sort.Float64s(a)
+	sort.Float64s(b)
+	stats.A.P50, stats.A.P90, stats.A.P99 = samplePercentile(a, 50), samplePercentile(a, 90), samplePercentile(a, 99)
+	stats.B.P50, stats.B.P90, stats.B.P99 = samplePercentile(b, 50), samplePercentile(b, 90), samplePercentile(b, 99)
+
+	stats.U, stats.Z, stats.PValue = mannWhitney(a, b)
+	meanA, sdA := meanStdDev(a)
+	meanB, sdB := meanStdDev(b)
+	if s, ok := welchTest(meanA, sdA, int64(len(a)), meanB, sdB, int64(len(b))); ok {
+		stats.MeanDifference, stats.MeanDifferenceLow, stats.MeanDifferenceHigh = s.diff, s.low, s.high
+	}
+	stats.Significant = len(a) > 0 && len(b) > 0 && stats.PValue < 0.05
+	if stats.Significant {
+		stats.Faster = "A"
+		if stats.B.P50 < stats.A.P50 {
+			stats.Faster = "B"
+		}
+	}
+	return stats
+}
+
+// meanStdDev returns the mean and sample standard deviation of values
+func meanStdDev(values []float64) (mean, sd float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// samplePercentile returns the nearest-rank percentile of sorted values
+func samplePercentile(sorted []float64, percentile float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// mannWhitney runs a two-sided Mann-Whitney U test on two sorted samples
+// using the normal approximation with tie and continuity corrections. It
+// returns the U statistic of a, the z score and the p-value.
+func mannWhitney(a, b []float64) (u, z, p float64) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 0, 0, 1
+	}
+
+	// Rank the merged samples, averaging the ranks of ties
+	var rankSumA, tieTerm float64
+	i, j := 0, 0
+	rank := 1.0
+	for i < len(a) || j < len(b) {
+		var value float64
+		if j >= len(b) || (i < len(a) && a[i] <= b[j]) {
+			value = a[i]
+		} else {
+			value = b[j]
+		}
+		inA := 0
+		for i < len(a) && a[i] == value {
+			inA++
+			i++
+		}
+		inB := 0
+		for j < len(b) && b[j] == value {
+			inB++
+			j++
+		}
+		ties := float64(inA + inB)
+		rankSumA += float64(inA) * (rank + (ties-1)/2)
+		tieTerm += ties*ties*ties - ties
+		rank += ties
+	}
+
+	u = rankSumA - n1*(n1+1)/2
+	n := n1 + n2
+	mean := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return u, 0, 1
+	}
+	diff := u - mean
+	switch {
+	case diff > 0.5:
+		diff -= 0.5
+	case diff < -0.5:
+		diff += 0.5
+	default:
+		diff = 0
+	}
+	z = diff / sigma
+	p = math.Erfc(math.Abs(z) / math.Sqrt2)
+	return u, z, p
+}
+
+// displayABStats prints the side-by-side comparison of an -ab run
+func displayABStats(result BenchmarkResult) {
+	ab := result.AB
+	if ab == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nA/B Comparison:"))
+	fmt.Printf("A: %s\nB: %s\n", ab.A.URI, ab.B.URI)
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Metric", "A", "B", "B vs A")
+	table.Append([]string{"Requests", fmt.Sprintf("%d", ab.A.Requests), fmt.Sprintf("%d", ab.B.Requests), percentChange(float64(ab.A.Requests), float64(ab.B.Requests))})
+	table.Append([]string{"Requests/sec", fmt.Sprintf("%.2f", ab.A.RequestsPerSecond), fmt.Sprintf("%.2f", ab.B.RequestsPerSecond), percentChange(ab.A.RequestsPerSecond, ab.B.RequestsPerSecond)})
+	table.Append([]string{"Error Rate", fmt.Sprintf("%.2f%%", ab.A.ErrorRate), fmt.Sprintf("%.2f%%", ab.B.ErrorRate), fmt.Sprintf("%+.2f pts", ab.B.ErrorRate-ab.A.ErrorRate)})
+	for _, row := range []struct {
+		name string
+		a, b float64
+	}{
+		{"Average Latency", ab.A.Average, ab.B.Average},
+		{"Median Latency", ab.A.P50, ab.B.P50},
+		{"90th Percentile", ab.A.P90, ab.B.P90},
+		{"99th Percentile", ab.A.P99, ab.B.P99},
+	} {
+		table.Append([]string{row.name, formatLatency(row.a), formatLatency(row.b), percentChange(row.a, row.b)})
+	}
+	table.Render()
+
+	verdict := "no significant latency difference"
+	if ab.Significant {
+		verdict = colorize(chalk.Yellow, fmt.Sprintf("%s has significantly lower latency", ab.Faster))
+	}
+	fmt.Printf("Mann-Whitney U test: p = %.4g, %s\n", ab.PValue, verdict)
+	fmt.Printf("Mean latency difference (B - A): %s, 95%% confidence interval [%s, %s]\n", formatLatencyDelta(ab.MeanDifference), formatLatencyDelta(ab.MeanDifferenceLow), formatLatencyDelta(ab.MeanDifferenceHigh))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestMannWhitney checks U, z and the p-value of the normal approximation
+// with tie and continuity corrections
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []float64
+		u, z, p float64
+	}{
+		{"separated", []float64{1, 2, 3}, []float64{4, 5, 6}, 0, -1.745743, 0.080856},
+		{"ties", []float64{1, 2, 2, 3}, []float64{2, 3, 4, 5}, 2.5, -1.488351, 0.136658},
+		{"overlapping", []float64{1.1, 2.2, 3.3, 4.4, 5.5, 6.6, 7.7, 8.8}, []float64{3, 5, 7, 9, 11, 13}, 12, -1.484644, 0.137638},
+		{"reversed", []float64{4, 5, 6}, []float64{1, 2, 3}, 9, 1.745743, 0.080856},
+		{"identical", []float64{1, 2, 3}, []float64{1, 2, 3}, 4.5, 0, 1},
+		{"empty", nil, []float64{1, 2}, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		u, z, p := mannWhitney(tt.a, tt.b)
+		if u != tt.u || math.Abs(z-tt.z) > 1e-6 || math.Abs(p-tt.p) > 1e-6 {
+			t.Errorf("%s: got U %g, z %.6f, p %.6f; want %g, %.6f, %.6f", tt.name, u, z, p, tt.u, tt.z, tt.p)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// adaptiveWindow is how often -adaptive looks at the errors and latency
+// of the responses and changes the rate
+const adaptiveWindow = time.Second
+
+// adaptiveDecrease is the share of the rate kept after a window that
+// crossed a threshold, and adaptiveIncrease the share of the starting rate
+// added after one that didn't. The rate doesn't go below adaptiveFloor of
+// the starting rate.
+const (
+	adaptiveDecrease = 0.7
+	adaptiveIncrease = 0.05
+	adaptiveFloor    = 0.01
+)
+
+// AdaptiveStats describes the rate of -adaptive runs, lowered when a
+// second's error rate or p99 latency crossed a threshold and raised slowly
+// after the seconds that didn't (AIMD). Once the rate was first lowered,
+// it saws around what the target sustains: EquilibriumRate is the average
+// rate since, and EquilibriumThroughput the successful requests per
+// second. Ceiling is the average rate the decreases were made at.
+type AdaptiveStats struct {
+	StartRate             float64 `json:"startRate"`
+	MaxErrorRate          float64 `json:"maxErrorRate"`
+	MaxP99                float64 `json:"maxP99Ms,omitempty"`
+	Windows               int64   `json:"windows"`
+	Decreases             int64   `json:"decreases"`
+	Increases             int64   `json:"increases"`
+	FinalRate             float64 `json:"finalRate"`
+	Ceiling               float64 `json:"ceiling,omitempty"`
+	EquilibriumRate       float64 `json:"equilibriumRate,omitempty"`
+	EquilibriumThroughput float64 `json:"equilibriumThroughput,omitempty"`
+}
+
+// adaptiveController changes the rate of a run from the responses of
+// every window
+type adaptiveController struct {
+	maxErrorRate float64 // percent
+	maxP99       float64 // milliseconds, 0 for none
+	startRate    float64
+
+	mu       sync.Mutex
+	requests int64
+	failed   int64
+	latency  *histogram
+
+	stats        AdaptiveStats
+	ceilingSum   float64
+	rateSum      float64
+	successSum   float64
+	equilibrium  int64 // windows since the first decrease
+	windowStart  time.Time
+	windowPaused bool
+}
+
+func newAdaptiveController(startRate, maxErrorRate float64, maxP99 time.Duration) *adaptiveController {
+	return &adaptiveController{
+		maxErrorRate: maxErrorRate,
+		maxP99:       float64(maxP99.Microseconds()) / 1000,
+		startRate:    startRate,
+		latency:      newLatencyHistogram(),
+	}
+}
+
+// record adds a response of the current window. Throttled responses count
+// as failed: they are the target pushing back.
+func (a *adaptiveController) record(latency float64, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests++
+	if failed {
+		a.failed++
+		return
+	}
+	a.latency.record(int64(latency * 1000))
+}
+
+// run changes the rate of control after every window until stop is
+// closed. Windows in which the run was paused are left out.
+func (a *adaptiveController) run(control *runControl, stop <-chan struct{}) {
+	ticker := time.NewTicker(adaptiveWindow)
+	defer ticker.Stop()
+	a.mu.Lock()
+	a.windowStart = time.Now()
+	a.mu.Unlock()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if control.isPaused() {
+				a.mu.Lock()
+				a.windowPaused = true
+				a.mu.Unlock()
+				continue
+			}
+			if rate, ok := a.next(now, control); ok {
+				control.adaptRate(rate)
+			}
+		}
+	}
+}
+
+// next closes the window at now and returns the rate to use, false to
+// keep the current one
+func (a *adaptiveController) next(now time.Time, control *runControl) (float64, bool) {
+	_, rate := control.settings()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	requests, failed := a.requests, a.failed
+	p99 := float64(a.latency.valueAtPercentile(99)) / 1000
+	seconds := now.Sub(a.windowStart).Seconds()
+	paused := a.windowPaused
+	a.requests, a.failed, a.windowStart, a.windowPaused = 0, 0, now, false
+	a.latency.reset()
+	// Without responses there is nothing to go by, as after a pause
+	if requests == 0 || paused || rate == 0 {
+		return 0, false
+	}
+
+	a.stats.Windows++
+	if a.stats.Decreases > 0 {
+		a.equilibrium++
+		a.rateSum += rate
+		a.successSum += float64(requests-failed) / seconds
+	}
+	crossed := float64(failed)/float64(requests)*100 > a.maxErrorRate ||
+		a.maxP99 > 0 && requests > failed && p99 > a.maxP99
+	if crossed {
+		a.stats.Decreases++
+		a.ceilingSum += rate
+		return max(rate*adaptiveDecrease, a.startRate*adaptiveFloor), true
+	}
+	a.stats.Increases++
+	return rate + a.startRate*adaptiveIncrease, true
+}
+
+// summary returns the stats of the run, which ended at the final rate
+func (a *adaptiveController) summary(finalRate float64) *AdaptiveStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.stats
+	stats.StartRate = a.startRate
+	stats.MaxErrorRate = a.maxErrorRate
+	stats.MaxP99 = a.maxP99
+	stats.FinalRate = finalRate
+	if stats.Decreases > 0 {
+		stats.Ceiling = a.ceilingSum / float64(stats.Decreases)
+	}
+	if a.equilibrium > 0 {
+		stats.EquilibriumRate = a.rateSum / float64(a.equilibrium)
+		stats.EquilibriumThroughput = a.successSum / float64(a.equilibrium)
+	}
+	return &stats
+}
+
+// displayAdaptiveStats prints the -adaptive table
+func displayAdaptiveStats(result BenchmarkResult) {
+	s := result.Adaptive
+	if s == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nAdaptive Rate:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	thresholds := fmt.Sprintf("errors above %g%%", s.MaxErrorRate)
+	if s.MaxP99 > 0 {
+		thresholds += ", p99 above " + formatLatency(s.MaxP99)
+	}
+	table.Header("Metric", "Value")
+	table.Append([]string{"Thresholds", thresholds})
+	table.Append([]string{"Start Rate", fmt.Sprintf("%.1f/sec", s.StartRate)})
+	table.Append([]string{"Seconds", fmt.Sprintf("%d (%d decreases, %d increases)", s.Windows, s.Decreases, s.Increases)})
+	table.Append([]string{"Final Rate", fmt.Sprintf("%.1f/sec", s.FinalRate)})
+	if s.Decreases > 0 {
+		table.Append([]string{"Backed Off At", fmt.Sprintf("%.1f/sec avg", s.Ceiling)})
+	}
+	if s.EquilibriumRate > 0 {
+		table.Append([]string{"Equilibrium Rate", fmt.Sprintf("%.1f/sec", s.EquilibriumRate)})
+		table.Append([]string{"Equilibrium Throughput", fmt.Sprintf("%.1f successful requests/sec", s.EquilibriumThroughput)})
+	}
+	table.Render()
+	if s.Decreases == 0 {
+		fmt.Println("No second crossed a threshold: the target sustained every rate tried.")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// autocannonPercentiles are the percentiles the Node.js autocannon
+// reports, with the key suffix it uses for each
+var autocannonPercentiles = []struct {
+	key        string
+	percentile float64
+}{
+	{"p0_001", 0.001}, {"p0_01", 0.01}, {"p0_1", 0.1}, {"p1", 1}, {"p2_5", 2.5},
+	{"p10", 10}, {"p25", 25}, {"p50", 50}, {"p75", 75}, {"p90", 90},
+	{"p97_5", 97.5}, {"p99", 99}, {"p99_9", 99.9}, {"p99_99", 99.99}, {"p99_999", 99.999},
+}
+
+// autocannonResult mirrors the JSON result of the Node.js autocannon, so
+// its report processing scripts and autocannon-compare work unchanged
+type autocannonResult struct {
+	Title           string                 `json:"title,omitempty"`
+	URL             string                 `json:"url"`
+	SocketPath      *string                `json:"socketPath"`
+	Connections     int                    `json:"connections"`
+	SampleInt       int                    `json:"sampleInt"`
+	Pipelining      int                    `json:"pipelining"`
+	Workers         int                    `json:"workers"`
+	Duration        float64                `json:"duration"`
+	Samples         int64                  `json:"samples"`
+	Start           time.Time              `json:"start"`
+	Finish          time.Time              `json:"finish"`
+	Errors          int64                  `json:"errors"`
+	Timeouts        int64                  `json:"timeouts"`
+	Mismatches      int64                  `json:"mismatches"`
+	Non2xx          int64                  `json:"non2xx"`
+	Resets          int64                  `json:"resets"`
+	Status1xx       int64                  `json:"1xx"`
+	Status2xx       int64                  `json:"2xx"`
+	Status3xx       int64                  `json:"3xx"`
+	Status4xx       int64                  `json:"4xx"`
+	Status5xx       int64                  `json:"5xx"`
+	StatusCodeStats map[string]statusCount `json:"statusCodeStats"`
+	Latency         map[string]float64     `json:"latency"`
+	Requests        map[string]float64     `json:"requests"`
+	Throughput      map[string]float64     `json:"throughput"`
+}
+
+type statusCount struct {
+	Count int64 `json:"count"`
+}
+
+// toAutocannon converts a result to the Node.js autocannon format.
+// Latencies are in milliseconds; requests and throughput are per-second
+// samples of the request count and bytes read.
+func toAutocannon(result BenchmarkResult) autocannonResult {
+	out := autocannonResult{
+		URL:             result.URI,
+		Connections:     result.Connections,
+		SampleInt:       1000,
+		Pipelining:      1,
+		Duration:        result.elapsed.Seconds(),
+		Start:           result.Timestamp,
+		Finish:          result.Timestamp.Add(result.elapsed),
+		Errors:          result.Requests.Failed,
+		Timeouts:        result.Requests.Timeouts,
+		StatusCodeStats: make(map[string]statusCount),
+	}
+	if title, ok := result.Tags["title"]; ok {
+		out.Title = title
+	}
+
+	for code, count := range result.StatusCodeCounts {
+		out.StatusCodeStats[fmt.Sprintf("%d", code)] = statusCount{Count: count}
+		switch code / 100 {
+		case 1:
+			out.Status1xx += count
+		case 2:
+			out.Status2xx += count
+		case 3:
+			out.Status3xx += count
+		case 4:
+			out.Status4xx += count
+		case 5:
+			out.Status5xx += count
+		}
+		if code/100 != 2 {
+			out.Non2xx += count
+		}
+	}
+
+	// Latencies are recorded in microseconds
+	out.Latency = autocannonHistogram(result.latencyHist, 1000)
+	out.Latency["totalCount"] = float64(result.latencyHist.totalCount)
+
+	out.Requests = autocannonHistogram(result.requestSamples, 1)
+	out.Requests["total"] = float64(result.Requests.Total)
+	out.Requests["sent"] = float64(result.Requests.Total)
+
+	out.Throughput = autocannonHistogram(result.throughputSamples, 1)
+	out.Throughput["total"] = float64(result.Throughput.BytesRead)
+
+	out.Samples = result.requestSamples.totalCount
+	return out
+}
+
+// autocannonHistogram summarizes h with the Node.js autocannon keys,
+// dividing every value by scale
+func autocannonHistogram(h *histogram, scale float64) map[string]float64 {
+	mean := math.Round(h.mean()/scale*100) / 100
+
+	stats := map[string]float64{
+		"average": mean,
+		"mean":    mean,
+		"stddev":  math.Round(h.stdDev()/scale*100) / 100,
+		"min":     float64(h.min) / scale,
+		"max":     float64(h.max) / scale,
+	}
+	for _, p := range autocannonPercentiles {
+		stats[p.key] = float64(h.valueAtPercentile(p.percentile)) / scale
+	}
+	return stats
+}
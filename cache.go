@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// maxCachedValidators bounds the URLs whose validators are remembered
+const maxCachedValidators = 10000
+
+// CacheStats describes -conditional runs: how many requests carried
+// validators from an earlier response, how many were answered with 304
+// Not Modified, and the latency of 304 and full responses
+type CacheStats struct {
+	Conditional        int64          `json:"conditional"`
+	NotModified        int64          `json:"notModified"`
+	NotModifiedRate    float64        `json:"notModifiedRate"`
+	NotModifiedLatency LatencySummary `json:"notModifiedLatency"`
+	FullLatency        LatencySummary `json:"fullLatency"`
+}
+
+// cacheValidators are the validators of the last full response for a URL
+type cacheValidators struct {
+	etag         string
+	lastModified string
+}
+
+// conditionalTracker remembers validators per URL, turns requests into
+// conditional ones and collects CacheStats. It is safe for concurrent use.
+type conditionalTracker struct {
+	mu          sync.Mutex
+	validators  map[string]cacheValidators
+	conditional int64
+	notModified int64
+
+	notModifiedLatency latencyTracker
+	fullLatency        latencyTracker
+}
+
+func newConditionalTracker() *conditionalTracker {
+	return &conditionalTracker{validators: make(map[string]cacheValidators)}
+}
+
+// prepare adds If-None-Match and If-Modified-Since to GET and HEAD
+// requests for URLs with remembered validators. It reports whether the
+// request was made conditional.
+func (t *conditionalTracker) prepare(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	t.mu.Lock()
+	v, ok := t.validators[req.URL.String()]
+	if ok {
+		t.conditional++
+	}
+	t.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+	return true
+}
+
+// record remembers the validators of a full response and times it
+func (t *conditionalTracker) record(req *http.Request, resp *http.Response, latency float64) {
+	if resp.StatusCode == http.StatusNotModified {
+		t.mu.Lock()
+		t.notModified++
+		t.mu.Unlock()
+		t.notModifiedLatency.record(latency)
+		return
+	}
+	t.fullLatency.record(latency)
+
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	v := cacheValidators{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	if v.etag == "" && v.lastModified == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	url := req.URL.String()
+	if _, ok := t.validators[url]; ok || len(t.validators) < maxCachedValidators {
+		t.validators[url] = v
+	}
+}
+
+func (t *conditionalTracker) summary() *CacheStats {
+	t.mu.Lock()
+	stats := &CacheStats{Conditional: t.conditional, NotModified: t.notModified}
+	t.mu.Unlock()
+	if stats.Conditional > 0 {
+		stats.NotModifiedRate = float64(stats.NotModified) / float64(stats.Conditional) * 100
+	}
+	stats.NotModifiedLatency = t.notModifiedLatency.summary()
+	stats.FullLatency = t.fullLatency.summary()
+	return stats
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// maxCapturedValues bounds the distinct values kept per captured header,
+// so a header like Date cannot grow the result without limit
+const maxCapturedValues = 100
+
+// maxDistinctValues bounds the values remembered for the distinct count
+const maxDistinctValues = 10000
+
+// otherValues collects the values beyond maxCapturedValues
+const otherValues = "(other)"
+
+// HeaderStats is the value distribution of a captured response header.
+// Missing counts responses without the header; Distinct counts the values
+// seen, including those folded into "(other)", up to 10000.
+type HeaderStats struct {
+	Values   map[string]int64 `json:"values"`
+	Missing  int64            `json:"missing"`
+	Distinct int              `json:"distinct"`
+}
+
+// headerCapture collects HeaderStats for the -capture-header headers. It
+// is safe for concurrent use.
+type headerCapture struct {
+	mu      sync.Mutex
+	headers []string
+	values  map[string]map[string]int64
+	seen    map[string]map[string]bool
+	missing map[string]int64
+
+	// normalize, if set, reduces a header's value before it is counted
+	normalize func(name, value string) string
+}
+
+func newHeaderCapture(headers []string) *headerCapture {
+	c := &headerCapture{
+		values:  make(map[string]map[string]int64),
+		seen:    make(map[string]map[string]bool),
+		missing: make(map[string]int64),
+	}
+	for _, header := range headers {
+		header = http.CanonicalHeaderKey(header)
+		c.headers = append(c.headers, header)
+		c.values[header] = make(map[string]int64)
+		c.seen[header] = make(map[string]bool)
+	}
+	return c
+}
+
+func (c *headerCapture) record(header http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range c.headers {
+		value := header.Get(name)
+		if value != "" && c.normalize != nil {
+			value = c.normalize(name, value)
+		}
+		if value == "" {
+			c.missing[name]++
+			continue
+		}
+		if seen := c.seen[name]; len(seen) < maxDistinctValues {
+			seen[value] = true
+		}
+		values := c.values[name]
+		if _, ok := values[value]; !ok && len(values) >= maxCapturedValues {
+			value = otherValues
+		}
+		values[value]++
+	}
+}
+
+func (c *headerCapture) summary() map[string]HeaderStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]HeaderStats, len(c.headers))
+	for _, name := range c.headers {
+		s := HeaderStats{Values: make(map[string]int64), Missing: c.missing[name], Distinct: len(c.seen[name])}
+		for value, count := range c.values[name] {
+			s.Values[value] = count
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+// headerListFlag collects repeatable, comma separated header names
+type headerListFlag []string
+
+func (h *headerListFlag) String() string {
+	return strings.Join(*h, ",")
+}
+
+func (h *headerListFlag) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*h = append(*h, name)
+		}
+	}
+	return nil
+}
+
+// displayHeaderStats prints the value distribution of each captured header
+func displayHeaderStats(result BenchmarkResult) {
+	displayHeaderTable("Response Headers", result.Headers)
+}
+
+// displayHeaderTable prints the value distributions of headers under a
+// title, most common values first
+func displayHeaderTable(title string, headers map[string]HeaderStats) {
+	if len(headers) == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\n"+title+":"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignLeft,
+				},
+				ColumnAligns: []tw.Align{tw.AlignLeft, tw.AlignLeft, tw.AlignRight, tw.AlignRight},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Header", "Value", "Count", "Percentage")
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := headers[name]
+		total := stats.Missing
+		values := make([]string, 0, len(stats.Values))
+		for value, count := range stats.Values {
+			values = append(values, value)
+			total += count
+		}
+		// Most common first
+		sort.Slice(values, func(i, j int) bool {
+			if stats.Values[values[i]] != stats.Values[values[j]] {
+				return stats.Values[values[i]] > stats.Values[values[j]]
+			}
+			return values[i] < values[j]
+		})
+
+		label := fmt.Sprintf("%s (%d distinct)", name, stats.Distinct)
+		row := func(value string, count int64) {
+			table.Append([]string{label, value, fmt.Sprintf("%d", count), fmt.Sprintf("%.2f%%", float64(count)/float64(total)*100)})
+			label = ""
+		}
+		for _, value := range values {
+			row(value, stats.Values[value])
+		}
+		if stats.Missing > 0 {
+			row("(missing)", stats.Missing)
+		}
+	}
+
+	table.Render()
+}
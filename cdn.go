@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// cdnStates are the cache states of -cdn, in display order
+var cdnStates = []string{"hit", "stale", "revalidated", "miss", "bypass", "unknown"}
+
+// cacheState classifies a response by its cache headers. CF-Cache-Status
+// (Cloudflare), X-Cache (CloudFront, Fastly, Varnish, Squid) and
+// X-Cache-Status (nginx) are tried in that order; of a list such as
+// Fastly's "MISS, HIT" the last entry counts, as it comes from the layer
+// closest to the client. Without any of them, an Age above 0 is a hit
+// and an Age of 0 a miss.
+func cacheState(h http.Header) string {
+	for _, name := range []string{"CF-Cache-Status", "X-Cache", "X-Cache-Status"} {
+		value := h.Get(name)
+		if value == "" {
+			continue
+		}
+		if i := strings.LastIndex(value, ","); i >= 0 {
+			value = value[i+1:]
+		}
+		value = strings.ToUpper(value)
+		switch {
+		case strings.Contains(value, "REFRESH") || strings.Contains(value, "REVALIDATED"):
+			return "revalidated"
+		case strings.Contains(value, "STALE") || strings.Contains(value, "UPDATING"):
+			return "stale"
+		case strings.Contains(value, "HIT"):
+			return "hit"
+		case strings.Contains(value, "MISS") || strings.Contains(value, "EXPIRED"):
+			return "miss"
+		case strings.Contains(value, "PASS") || strings.Contains(value, "DYNAMIC"):
+			return "bypass"
+		}
+	}
+	if age, err := strconv.Atoi(strings.TrimSpace(h.Get("Age"))); err == nil {
+		if age > 0 {
+			return "hit"
+		}
+		return "miss"
+	}
+	return "unknown"
+}
+
+// CDNStats describes -cdn runs: the share of responses in each cache
+// state and their latency. HitRatio is the share of hits, stale and
+// revalidated responses included, among the responses whose state is
+// known.
+type CDNStats struct {
+	HitRatio          float64         `json:"hitRatio"`
+	States            []CDNStateStats `json:"states"`
+	AverageAgeSeconds float64         `json:"averageAgeSeconds,omitempty"`
+}
+
+// CDNStateStats counts the responses in a cache state
+type CDNStateStats struct {
+	State      string       `json:"state"`
+	Responses  int64        `json:"responses"`
+	Percentage float64      `json:"percentage"`
+	Latency    PhaseLatency `json:"latency"`
+}
+
+// cdnTracker collects CDNStats from concurrent workers
+type cdnTracker struct {
+	mu       sync.Mutex
+	states   map[string]*histogram
+	counts   map[string]int64
+	ages     int64
+	totalAge int64
+}
+
+func newCDNTracker() *cdnTracker {
+	return &cdnTracker{states: make(map[string]*histogram), counts: make(map[string]int64)}
+}
+
+// record classifies a response that took latency ms
+func (t *cdnTracker) record(h http.Header, latency float64) {
+	state := cacheState(h)
+	age, ageErr := strconv.ParseInt(strings.TrimSpace(h.Get("Age")), 10, 64)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hist, ok := t.states[state]
+	if !ok {
+		hist = newLatencyHistogram()
+		t.states[state] = hist
+	}
+	hist.record(int64(latency * 1000))
+	t.counts[state]++
+	if ageErr == nil && age >= 0 {
+		t.ages++
+		t.totalAge += age
+	}
+}
+
+func (t *cdnTracker) summary() *CDNStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total int64
+	for _, count := range t.counts {
+		total += count
+	}
+	stats := &CDNStats{}
+	for _, state := range cdnStates {
+		count := t.counts[state]
+		if count == 0 {
+			continue
+		}
+		stats.States = append(stats.States, CDNStateStats{
+			State:      state,
+			Responses:  count,
+			Percentage: float64(count) / float64(total) * 100,
+			Latency:    phaseLatency(t.states[state]),
+		})
+	}
+	if known := total - t.counts["unknown"]; known > 0 {
+		hits := t.counts["hit"] + t.counts["stale"] + t.counts["revalidated"]
+		stats.HitRatio = float64(hits) / float64(known) * 100
+	}
+	if t.ages > 0 {
+		stats.AverageAgeSeconds = float64(t.totalAge) / float64(t.ages)
+	}
+	return stats
+}
+
+// displayCDNStats prints the -cdn table
+func displayCDNStats(result BenchmarkResult) {
+	if result.CDN == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nCache States:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	// One column per state, as in the -latency-phases table
+	header := []string{""}
+	responses := []string{"Responses"}
+	percentages := []string{"Percentage"}
+	for _, s := range result.CDN.States {
+		header = append(header, s.State)
+		responses = append(responses, fmt.Sprintf("%d", s.Responses))
+		percentages = append(percentages, fmt.Sprintf("%.2f%%", s.Percentage))
+	}
+	table.Header(header)
+	table.Append(responses)
+	table.Append(percentages)
+	for _, stat := range []struct {
+		name  string
+		value func(PhaseLatency) float64
+	}{
+		{"Average", func(l PhaseLatency) float64 { return l.Average }},
+		{"Median", func(l PhaseLatency) float64 { return l.P50 }},
+		{"90th Percentile", func(l PhaseLatency) float64 { return l.P90 }},
+		{"99th Percentile", func(l PhaseLatency) float64 { return l.P99 }},
+		{"Max", func(l PhaseLatency) float64 { return l.Max }},
+	} {
+		row := []string{stat.name}
+		for _, s := range result.CDN.States {
+			row = append(row, formatLatency(stat.value(s.Latency)))
+		}
+		table.Append(row)
+	}
+	table.Render()
+
+	fmt.Printf("Hit ratio: %.2f%%", result.CDN.HitRatio)
+	if result.CDN.AverageAgeSeconds > 0 {
+		fmt.Printf(", average Age %.1f s", result.CDN.AverageAgeSeconds)
+	}
+	fmt.Println()
+}
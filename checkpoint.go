@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// writeCheckpoint writes an intermediate result to path, first rotating
+// older checkpoints to path.1 ... path.<keep> so a crash or a corrupt
+// write never loses more than one checkpoint interval of data.
+func writeCheckpoint(result BenchmarkResult, path string, keep int) error {
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, jsonData, 0644); err != nil {
+		return err
+	}
+
+	rotateCheckpoints(path, keep)
+	return os.Rename(tmp, path)
+}
+
+// rotateCheckpoints shifts path.N to path.N+1, dropping the oldest
+func rotateCheckpoints(path string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	os.Remove(fmt.Sprintf("%s.%d", path, keep))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	os.Rename(path, path+".1")
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// scenarioCheck is a check of a scenario step's responses. Every
+// condition that is set must hold for a response to pass. Unlike
+// -expect, a failed check doesn't fail the request; checks are counted
+// and weighted against the scenario's threshold instead.
+type scenarioCheck struct {
+	Name       string  `json:"name"`
+	Status     int     `json:"status"`
+	Body       string  `json:"body"`
+	MaxLatency float64 `json:"maxLatencyMs"`
+	Weight     float64 `json:"weight"`
+
+	passed int64
+	failed int64
+}
+
+// describe names a check after its conditions
+func (c *scenarioCheck) describe() string {
+	var conditions []string
+	if c.Status != 0 {
+		conditions = append(conditions, fmt.Sprintf("status %d", c.Status))
+	}
+	if c.Body != "" {
+		conditions = append(conditions, fmt.Sprintf("body contains %q", c.Body))
+	}
+	if c.MaxLatency > 0 {
+		conditions = append(conditions, fmt.Sprintf("latency <= %g ms", c.MaxLatency))
+	}
+	return strings.Join(conditions, ", ")
+}
+
+func (c *scenarioCheck) check(status int, body []byte, latency float64) bool {
+	return (c.Status == 0 || status == c.Status) &&
+		(c.Body == "" || bytes.Contains(body, []byte(c.Body))) &&
+		(c.MaxLatency <= 0 || latency <= c.MaxLatency)
+}
+
+// checkTracker runs the checks of a scenario's steps on every complete
+// response. It is safe for concurrent use.
+type checkTracker struct {
+	threshold float64
+	steps     []string
+	checks    map[string][]*scenarioCheck // by step
+}
+
+// newCheckTracker validates the checks of a scenario file and fills in
+// their defaults. Responses are checked by their step's name, so a step
+// with checks must have a name of its own. It returns nil if no step has
+// checks.
+func newCheckTracker(steps []scenarioStep, threshold float64) (*checkTracker, error) {
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("threshold must be between 0 and 1")
+	}
+	t := &checkTracker{threshold: threshold, checks: make(map[string][]*scenarioCheck)}
+	named := make(map[string]int)
+	for _, step := range steps {
+		named[step.Name]++
+	}
+	for _, step := range steps {
+		if len(step.Checks) == 0 {
+			continue
+		}
+		if named[step.Name] > 1 {
+			return nil, fmt.Errorf("step %q has checks, but %d steps have that name; give them names of their own", step.Name, named[step.Name])
+		}
+		checks := make([]*scenarioCheck, len(step.Checks))
+		for i := range step.Checks {
+			c := step.Checks[i]
+			if c.Status == 0 && c.Body == "" && c.MaxLatency <= 0 {
+				return nil, fmt.Errorf("step %q: check %d has no status, body or maxLatencyMs", step.Name, i+1)
+			}
+			if c.Weight < 0 {
+				return nil, fmt.Errorf("step %q: check %d has a negative weight", step.Name, i+1)
+			}
+			if c.Weight == 0 {
+				c.Weight = 1
+			}
+			if c.Name == "" {
+				c.Name = c.describe()
+			}
+			checks[i] = &c
+		}
+		t.steps = append(t.steps, step.Name)
+		t.checks[step.Name] = checks
+	}
+	if len(t.steps) == 0 {
+		if threshold > 0 {
+			return nil, fmt.Errorf("threshold is set but no step has checks")
+		}
+		return nil, nil
+	}
+	return t, nil
+}
+
+// record runs the checks of the request's step on its response
+func (t *checkTracker) record(op operationInfo, status int, body []byte, latency float64) {
+	for _, c := range t.checks[op.Name] {
+		if c.check(status, body, latency) {
+			atomic.AddInt64(&c.passed, 1)
+		} else {
+			atomic.AddInt64(&c.failed, 1)
+		}
+	}
+}
+
+// recordFailed fails every check of the request's step, for a request
+// that got no complete response
+func (t *checkTracker) recordFailed(op operationInfo) {
+	for _, c := range t.checks[op.Name] {
+		atomic.AddInt64(&c.failed, 1)
+	}
+}
+
+// CheckStats is the outcome of a scenario's checks. Pass rates are
+// weighted by the checks' weights; with a threshold, the scenario passes
+// when the overall pass rate reaches it.
+type CheckStats struct {
+	PassRate  float64          `json:"passRate"`
+	Threshold float64          `json:"threshold,omitempty"`
+	Passed    bool             `json:"passed"`
+	Steps     []StepCheckStats `json:"steps"`
+}
+
+// StepCheckStats is the outcome of one step's checks
+type StepCheckStats struct {
+	Step     string        `json:"step"`
+	PassRate float64       `json:"passRate"`
+	Checks   []CheckResult `json:"checks"`
+}
+
+// CheckResult counts the responses that passed and failed a check
+type CheckResult struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	Passed int64   `json:"passed"`
+	Failed int64   `json:"failed"`
+}
+
+func (t *checkTracker) summary() *CheckStats {
+	stats := &CheckStats{Threshold: t.threshold}
+	var passed, total float64
+	for _, step := range t.steps {
+		s := StepCheckStats{Step: step}
+		var stepPassed, stepTotal float64
+		for _, c := range t.checks[step] {
+			result := CheckResult{Name: c.Name, Weight: c.Weight, Passed: atomic.LoadInt64(&c.passed), Failed: atomic.LoadInt64(&c.failed)}
+			s.Checks = append(s.Checks, result)
+			stepPassed += c.Weight * float64(result.Passed)
+			stepTotal += c.Weight * float64(result.Passed+result.Failed)
+		}
+		if stepTotal > 0 {
+			s.PassRate = stepPassed / stepTotal
+		}
+		stats.Steps = append(stats.Steps, s)
+		passed += stepPassed
+		total += stepTotal
+	}
+	if total > 0 {
+		stats.PassRate = passed / total
+	}
+	stats.Passed = t.threshold == 0 || total > 0 && stats.PassRate >= t.threshold
+	return stats
+}
+
+// displayCheckStats prints the pass rates of the scenario checks
+func displayCheckStats(result BenchmarkResult) {
+	checks := result.Checks
+	if checks == nil {
+		return
+	}
+	color := chalk.Green
+	if !checks.Passed {
+		color = chalk.Red
+	}
+	fmt.Println(colorize(color, "\nScenario Checks:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Step", "Check", "Weight", "Passed", "Failed", "Pass Rate")
+	for _, step := range checks.Steps {
+		for _, c := range step.Checks {
+			rate := "-"
+			if n := c.Passed + c.Failed; n > 0 {
+				rate = fmt.Sprintf("%.2f%%", float64(c.Passed)/float64(n)*100)
+			}
+			table.Append([]string{step.Step, c.Name, fmt.Sprintf("%g", c.Weight),
+				fmt.Sprintf("%d", c.Passed), fmt.Sprintf("%d", c.Failed), rate})
+		}
+		table.Append([]string{step.Step, "(weighted)", "", "", "", fmt.Sprintf("%.2f%%", step.PassRate*100)})
+	}
+	table.Render()
+
+	verdict := "PASS"
+	if !checks.Passed {
+		verdict = "FAIL"
+	}
+	if checks.Threshold > 0 {
+		fmt.Printf("Overall: %.2f%% of weighted checks passed, threshold %.2f%%: %s\n",
+			checks.PassRate*100, checks.Threshold*100, colorize(color, verdict))
+	} else {
+		fmt.Printf("Overall: %.2f%% of weighted checks passed\n", checks.PassRate*100)
+	}
+}
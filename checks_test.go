@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestChecksFailedRequests runs a scenario against a server that drops
+// the connections of one step: its requests fail every check, and the
+// threshold verdict with them
+func TestChecksFailedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/drop" {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	scenarioFile := `{"threshold": 0.9, "steps": [
+		{"name": "ok", "path": "/ok", "checks": [{"status": 200}]},
+		{"name": "drop", "path": "/drop", "checks": [{"status": 200}, {"maxLatencyMs": 10000, "weight": 2}]}
+	]}`
+	if err := os.WriteFile(path, []byte(scenarioFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scenario, err := loadScenario(path, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := runBenchmark(BenchmarkConfig{
+		URI:         server.URL,
+		Connections: 2,
+		Duration:    1,
+		Timeout:     10,
+		Method:      "GET",
+		Model:       "closed",
+		Generator:   scenario,
+		Checks:      scenario.checks,
+	})
+
+	if result.Requests.Failed == 0 {
+		t.Fatal("no request failed")
+	}
+	checks := result.Checks
+	if checks == nil || len(checks.Steps) != 2 {
+		t.Fatalf("got checks %+v", checks)
+	}
+	ok, drop := checks.Steps[0], checks.Steps[1]
+	if c := ok.Checks[0]; c.Passed == 0 || c.Failed != 0 {
+		t.Errorf("step ok: %d passed, %d failed; want only passes", c.Passed, c.Failed)
+	}
+	for _, c := range drop.Checks {
+		if c.Passed != 0 || c.Failed == 0 {
+			t.Errorf("step drop, check %s: %d passed, %d failed; want only failures", c.Name, c.Passed, c.Failed)
+		}
+	}
+	if drop.PassRate != 0 || checks.PassRate >= 0.9 || checks.Passed {
+		t.Errorf("got pass rates %.2f and %.2f overall, passed %v; want the threshold missed", drop.PassRate, checks.PassRate, checks.Passed)
+	}
+}
+
+// TestChecksDuplicateStepNames rejects checks on steps that share a name,
+// whose counts would be merged
+func TestChecksDuplicateStepNames(t *testing.T) {
+	steps := []scenarioStep{
+		{Name: "login", Checks: []scenarioCheck{{Status: 200}}},
+		{Name: "login"},
+	}
+	if _, err := newCheckTracker(steps, 0); err == nil || !strings.Contains(err.Error(), `"login"`) {
+		t.Errorf("got error %v, want one naming the step", err)
+	}
+	steps[1].Name = "login again"
+	if _, err := newCheckTracker(steps, 0); err != nil {
+		t.Errorf("unique names: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// chunkGap is the pause after which data read from a response body is a
+// new chunk: reads returning sooner drain what already arrived with the
+// previous one
+const chunkGap = 100 * time.Microsecond
+
+// ChunkStats describes how the bodies of -chunks runs arrived: Streamed
+// responses came in more than one chunk, and InterChunk is the time
+// between the end of a chunk and the start of the next, as when
+// benchmarking token streaming or progressive rendering.
+type ChunkStats struct {
+	Responses   int64        `json:"responses"`
+	Streamed    int64        `json:"streamed"`
+	Chunks      int64        `json:"chunks"`
+	PerResponse ChunkCounts  `json:"perResponse"`
+	InterChunk  PhaseLatency `json:"interChunk"`
+}
+
+// ChunkCounts is the distribution of the chunks per response
+type ChunkCounts struct {
+	Average float64 `json:"average"`
+	P50     int64   `json:"p50"`
+	P99     int64   `json:"p99"`
+	Max     int64   `json:"max"`
+}
+
+// chunkReader times the chunks of a response body as it is read
+type chunkReader struct {
+	r      io.Reader
+	last   time.Time
+	chunks int64
+	gaps   []int64 // microseconds
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		now := time.Now()
+		if c.chunks == 0 {
+			c.chunks = 1
+		} else if gap := now.Sub(c.last); gap >= chunkGap {
+			c.chunks++
+			c.gaps = append(c.gaps, gap.Microseconds())
+		}
+		c.last = now
+	}
+	return n, err
+}
+
+// chunkTracker collects ChunkStats from concurrent workers
+type chunkTracker struct {
+	mu         sync.Mutex
+	responses  int64
+	streamed   int64
+	chunks     int64
+	counts     *histogram
+	interChunk *histogram
+}
+
+func newChunkTracker() *chunkTracker {
+	return &chunkTracker{counts: newCountHistogram(), interChunk: newLatencyHistogram()}
+}
+
+// reader wraps a response body to time its chunks
+func (t *chunkTracker) reader(body io.Reader) *chunkReader {
+	return &chunkReader{r: body}
+}
+
+// record adds a response whose body was read in full through c
+func (t *chunkTracker) record(c *chunkReader) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses++
+	t.chunks += c.chunks
+	if c.chunks > 1 {
+		t.streamed++
+	}
+	t.counts.record(c.chunks)
+	for _, gap := range c.gaps {
+		t.interChunk.record(gap)
+	}
+}
+
+func (t *chunkTracker) summary() *ChunkStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &ChunkStats{
+		Responses: t.responses,
+		Streamed:  t.streamed,
+		Chunks:    t.chunks,
+		PerResponse: ChunkCounts{
+			Average: t.counts.mean(),
+			P50:     t.counts.valueAtPercentile(50),
+			P99:     t.counts.valueAtPercentile(99),
+			Max:     t.counts.max,
+		},
+		InterChunk: phaseLatency(t.interChunk),
+	}
+}
+
+// displayChunkStats prints the -chunks table
+func displayChunkStats(result BenchmarkResult) {
+	c := result.Chunks
+	if c == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nStreamed Responses:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Metric", "Value")
+	table.Append([]string{"Responses", fmt.Sprintf("%d", c.Responses)})
+	streamed := "0"
+	if c.Responses > 0 {
+		streamed = fmt.Sprintf("%d (%.2f%%)", c.Streamed, float64(c.Streamed)/float64(c.Responses)*100)
+	}
+	table.Append([]string{"  In Several Chunks", streamed})
+	table.Append([]string{"Chunks", fmt.Sprintf("%d", c.Chunks)})
+	table.Append([]string{"Chunks per Response", fmt.Sprintf("%.1f avg, %d median, %d p99, %d max", c.PerResponse.Average, c.PerResponse.P50, c.PerResponse.P99, c.PerResponse.Max)})
+	if c.Streamed > 0 {
+		table.Append([]string{"Inter-Chunk Average", formatLatency(c.InterChunk.Average)})
+		table.Append([]string{"Inter-Chunk Median", formatLatency(c.InterChunk.P50)})
+		table.Append([]string{"Inter-Chunk 90th Percentile", formatLatency(c.InterChunk.P90)})
+		table.Append([]string{"Inter-Chunk 99th Percentile", formatLatency(c.InterChunk.P99)})
+		table.Append([]string{"Inter-Chunk Max", formatLatency(c.InterChunk.Max)})
+	}
+	table.Render()
+}
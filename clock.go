@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// processStart anchors the wall-clock timestamps of the process.
+// Latencies and elapsed times are differences of monotonic clock
+// readings, which a step of the system clock (NTP, a resumed VM) does not
+// move. Exported timestamps are the anchor's wall-clock time plus the
+// monotonic time since, so they stay in order and as far apart as the
+// latencies say, whatever the system clock does during a run.
+var processStart = time.Now()
+
+// wallTime returns the wall-clock time of t, a time.Now reading of this
+// process, on the anchored clock. Times without a monotonic reading, such
+// as parsed ones, are returned as they are.
+func wallTime(t time.Time) time.Time {
+	return processStart.Add(t.Sub(processStart))
+}
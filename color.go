@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mattn/go-isatty"
+	"github.com/ttacon/chalk"
+)
+
+// colorEnabled controls whether console output is colored. It is off when
+// NO_COLOR is set (https://no-color.org), -no-color is passed, TERM is
+// "dumb", or stdout is not a terminal that understands ANSI escapes.
+// stderrColorEnabled is the same for stderr, which may be a terminal
+// while stdout is redirected, or the other way around.
+var (
+	colorEnabled       = true
+	stderrColorEnabled = true
+)
+
+// configureColor decides whether to color output for this run
+func configureColor(noColor bool) {
+	_, noColorEnv := os.LookupEnv("NO_COLOR")
+	allowed := !noColor && !noColorEnv && os.Getenv("TERM") != "dumb"
+	colorEnabled = allowed && supportsColor(os.Stdout)
+	stderrColorEnabled = allowed && supportsColor(os.Stderr)
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// supportsColor reports whether f is a terminal that renders ANSI escape
+// sequences, turning them on first where the console needs it (Windows)
+func supportsColor(f *os.File) bool {
+	return isTerminal(f) && enableANSI(f)
+}
+
+// colorize wraps text in the given color when coloring is enabled
+func colorize(color chalk.Color, text string) string {
+	if !colorEnabled {
+		return text
+	}
+	return color.Color(text)
+}
+
+// colorizeStderr is colorize for text written to stderr
+func colorizeStderr(color chalk.Color, text string) string {
+	if !stderrColorEnabled {
+		return text
+	}
+	return color.Color(text)
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/sys/windows"
+)
+
+// setConsoleOutputCP is not wrapped by x/sys/windows
+var setConsoleOutputCP = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetConsoleOutputCP")
+
+// enableANSI turns on virtual terminal processing for the Windows console
+// f, so color escapes are rendered instead of printed, and switches it to
+// UTF-8 for the tables' box drawing characters. Consoles older than
+// Windows 10 cannot do this and get plain output. Cygwin and MSYS
+// terminals render escapes on their own.
+func enableANSI(f *os.File) bool {
+	if isatty.IsCygwinTerminal(f.Fd()) {
+		return true
+	}
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+	if err := windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		return false
+	}
+	if setConsoleOutputCP.Find() == nil {
+		setConsoleOutputCP.Call(65001)
+	}
+	return true
+}
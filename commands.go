@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of the CLI, with its own flag set
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands lists the subcommands in the order they are shown in the usage
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "Run a benchmark (the default when no subcommand is given)", runBenchmarkCommand},
+		{"replay", "Replay an access log against a uri: replay -uri URI [run flags] access.log", runReplayCommand},
+		{"flood", "Measure how many new connections per second a target accepts", runFlood},
+		{"record", "Record traffic through a proxy into a log for replay", runRecord},
+		{"compare", "Compare two result files", runCompare},
+		{"merge", "Merge the result files of generators that ran at the same time", runMerge},
+		{"report", "Show the result tables of a result file, or print a Grafana dashboard (report grafana)", runReport},
+		{"serve", "Accept benchmark runs over HTTP", runServe},
+		{"schedule", "Run a benchmark on an interval and notify on regressions", runSchedule},
+		{"query", "List runs in a results store", runQuery},
+		{"convert", "Upgrade result files to the current schema", runConvert},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		runBenchmarkCommand(os.Args[1:])
+		return
+	}
+
+	name := os.Args[1]
+	if name == "help" {
+		printCommands()
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(os.Args[2:])
+			return
+		}
+	}
+	fmt.Printf("Unknown command %q.\n\n", name)
+	printCommands()
+	os.Exit(exitConfigError)
+}
+
+// printCommands prints the list of subcommands
+func printCommands() {
+	fmt.Println("Usage: autocannon <command> [flags]")
+	fmt.Println("\nCommands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Println("\nRun \"autocannon <command> -h\" for the flags of a command.")
+}
+
+// runReplayCommand implements the "replay" subcommand: a run whose
+// requests come from the access log given as the last argument
+func runReplayCommand(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[len(args)-1], "-") {
+		fmt.Println("Usage: autocannon replay -uri URI [run flags] access.log")
+		os.Exit(exitConfigError)
+	}
+	logFile := args[len(args)-1]
+	runBenchmarkCommand(append(args[:len(args)-1:len(args)-1], "-replay", logFile))
+}
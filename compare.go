@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// runCompare implements the "compare" subcommand, showing the change in
+// the headline numbers from a baseline result to a candidate, with a
+// significance test of the throughput and latency changes
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	latencyUnitFlag := fs.String("latency-unit", "ms", "Show latencies in us, ms or s")
+	precision := fs.Int("precision", 2, "Show latencies with this many decimals")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon compare [flags] baseline.json candidate.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureColor(*noColor)
+
+	if err := configureLatencyFormat(*latencyUnitFlag, *precision); err != nil {
+		fmt.Printf("Invalid latency format: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+	baseline, err := readResultFile(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", fs.Arg(0), err)
+		os.Exit(exitConfigError)
+	}
+	candidate, err := readResultFile(fs.Arg(1))
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", fs.Arg(1), err)
+		os.Exit(exitConfigError)
+	}
+
+	displayComparison(baseline, candidate)
+}
+
+// readResultFile reads a result JSON file of any schema version
+func readResultFile(path string) (BenchmarkResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BenchmarkResult{}, err
+	}
+	return decodeResult(data)
+}
+
+func displayComparison(baseline, candidate BenchmarkResult) {
+	fmt.Println(colorize(chalk.Green, "\nComparison:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Metric", "Baseline", "Candidate", "Change", "Confidence Interval", "Verdict")
+
+	// Throughput is compared over the per-second request counts and
+	// latency over all requests
+	var rpsTest, latencyTest *significance
+	if baseline.Variability != nil && candidate.Variability != nil {
+		if s, ok := welchTest(
+			baseline.Throughput.RequestsPerSecond, baseline.Variability.RequestsPerSecondStdDev, baseline.Variability.Seconds,
+			candidate.Throughput.RequestsPerSecond, candidate.Variability.RequestsPerSecondStdDev, candidate.Variability.Seconds,
+		); ok {
+			rpsTest = &s
+		}
+		if s, ok := welchTest(
+			baseline.Latency.Average, baseline.Variability.LatencyStdDev, baseline.Requests.Total,
+			candidate.Latency.Average, candidate.Variability.LatencyStdDev, candidate.Requests.Total,
+		); ok {
+			latencyTest = &s
+		}
+	}
+
+	rows := []struct {
+		name   string
+		format func(float64) string
+		before float64
+		after  float64
+		test   *significance
+	}{
+		{"Requests", formatFloat("%.0f"), float64(baseline.Requests.Total), float64(candidate.Requests.Total), nil},
+		{"Requests/sec", formatFloat("%.2f"), baseline.Throughput.RequestsPerSecond, candidate.Throughput.RequestsPerSecond, rpsTest},
+		{"Avg Latency", formatLatency, baseline.Latency.Average, candidate.Latency.Average, latencyTest},
+		{"Min Latency", formatLatency, baseline.Latency.Min, candidate.Latency.Min, nil},
+		{"Max Latency", formatLatency, baseline.Latency.Max, candidate.Latency.Max, nil},
+		{"Error Rate", formatFloat("%.2f%%"), baseline.Requests.ErrorRate, candidate.Requests.ErrorRate, nil},
+		{"Bytes Read", formatFloat("%.0f"), float64(baseline.Throughput.BytesRead), float64(candidate.Throughput.BytesRead), nil},
+	}
+	for _, row := range rows {
+		interval, verdict := "", ""
+		if row.test != nil {
+			interval = fmt.Sprintf("[%s, %s]", percentChange(row.before, row.before+row.test.low), percentChange(row.before, row.before+row.test.high))
+			verdict = fmt.Sprintf("noise (p=%.2g)", row.test.p)
+			if row.test.significant() {
+				verdict = colorize(chalk.Yellow, fmt.Sprintf("significant (p=%.2g)", row.test.p))
+			}
+		}
+		table.Append([]string{
+			row.name,
+			row.format(row.before),
+			row.format(row.after),
+			percentChange(row.before, row.after),
+			interval,
+			verdict,
+		})
+	}
+
+	table.Render()
+	if rpsTest == nil || latencyTest == nil {
+		fmt.Println("Significance needs the variability recorded by current versions in both result files.")
+	}
+}
+
+// formatFloat returns a formatter for a plain number
+func formatFloat(format string) func(float64) string {
+	return func(v float64) string {
+		return fmt.Sprintf(format, v)
+	}
+}
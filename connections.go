@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// ConnectionStats holds the totals for a single connection (worker)
+type ConnectionStats struct {
+	ID             int     `json:"id"`
+	Requests       int64   `json:"requests"`
+	Errors         int64   `json:"errors"`
+	AverageLatency float64 `json:"averageLatencyMs"`
+}
+
+// connectionTracker accumulates ConnectionStats. Each tracker is only
+// written by its own worker and read once all workers have finished.
+type connectionTracker struct {
+	id           int
+	requests     int64
+	errors       int64
+	totalLatency float64
+
+	// portExhausted is set when the last request found no free local port
+	portExhausted bool
+
+	// retryAfter is the Retry-After of the last response, a 429 the
+	// connection waits out before its next request with -retry-after
+	retryAfter time.Duration
+
+	// client is a virtual user's own client, with its cookie jar; nil
+	// uses the shared one
+	client *http.Client
+
+	// identity is the worker's client certificate, nil without
+	// -client-certs
+	identity *clientIdentity
+}
+
+func connectionSummaries(trackers []*connectionTracker) []ConnectionStats {
+	stats := make([]ConnectionStats, len(trackers))
+	for i, t := range trackers {
+		stats[i] = ConnectionStats{ID: i, Requests: t.requests, Errors: t.errors}
+		if t.requests > 0 {
+			stats[i].AverageLatency = t.totalLatency / float64(t.requests)
+		}
+	}
+	return stats
+}
+
+// displayConnectionStats prints the per-connection table, including each
+// connection's share of all requests so uneven distribution stands out
+func displayConnectionStats(result BenchmarkResult) {
+	fmt.Println(colorize(chalk.Green, "\nPer-Connection Statistics:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Connection", "Requests", "Share", "Errors", "Avg Latency")
+
+	for _, c := range result.PerConnection {
+		share := 0.0
+		if result.Requests.Total > 0 {
+			share = float64(c.Requests) / float64(result.Requests.Total) * 100
+		}
+		table.Append([]string{
+			fmt.Sprintf("%d", c.ID),
+			fmt.Sprintf("%d", c.Requests),
+			fmt.Sprintf("%.2f%%", share),
+			fmt.Sprintf("%d", c.Errors),
+			formatLatency(c.AverageLatency),
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ConnectStats describes the connections opened during a run and the
+// time spent setting them up. Reused counts requests sent on a connection
+// that had already served a request. ReconnectEvery is the
+// -reconnect-every setting. Families counts the connections opened over
+// ipv4 and ipv6; Fallbacks counts those that ended up on a different
+// family than the first one tried, as Happy Eyeballs does when the
+// preferred family is slow or broken.
+type ConnectStats struct {
+	ReconnectEvery int              `json:"reconnectEvery,omitempty"`
+	Opened         int64            `json:"opened"`
+	Reused         int64            `json:"reused"`
+	Dial           LatencySummary   `json:"dial"`
+	TLSHandshake   *LatencySummary  `json:"tlsHandshake,omitempty"`
+	TLSResumption  *ResumptionStats `json:"tlsResumption,omitempty"`
+	Families       map[string]int64 `json:"families"`
+	Fallbacks      int64            `json:"fallbacks"`
+	HappyEyeballs  bool             `json:"happyEyeballs"`
+	ConnectRate    float64          `json:"connectRate,omitempty"`
+	RampUpSeconds  float64          `json:"rampUpSeconds,omitempty"`
+}
+
+// ResumptionStats splits the TLS handshakes into full ones and those that
+// resumed an earlier session from a session ticket. Disabled is set when
+// resumption was turned off with -no-tls-resumption.
+type ResumptionStats struct {
+	Disabled bool           `json:"disabled,omitempty"`
+	Full     LatencySummary `json:"full"`
+	Resumed  LatencySummary `json:"resumed"`
+}
+
+// connectTracker collects ConnectStats from concurrent workers
+type connectTracker struct {
+	mu        sync.Mutex
+	reused    int64
+	families  map[string]int64
+	fallbacks int64
+	dial      latencyTracker
+	tls       latencyTracker
+
+	tlsFull    latencyTracker
+	tlsResumed latencyTracker
+}
+
+// hooks adds the connection setup callbacks to a request's trace
+func (t *connectTracker) hooks(trace *httptrace.ClientTrace) {
+	// Dual-stack dialing may connect to several addresses at once
+	var mu sync.Mutex
+	dialStarts := make(map[string]time.Time)
+	firstFamily := ""
+	trace.ConnectStart = func(network, addr string) {
+		mu.Lock()
+		dialStarts[network+" "+addr] = time.Now()
+		if firstFamily == "" {
+			firstFamily = addressFamily(addr)
+		}
+		mu.Unlock()
+	}
+	trace.ConnectDone = func(network, addr string, err error) {
+		mu.Lock()
+		start, ok := dialStarts[network+" "+addr]
+		first := firstFamily
+		mu.Unlock()
+		if !ok || err != nil {
+			return
+		}
+		t.dial.record(float64(time.Since(start).Microseconds()) / 1000)
+
+		family := addressFamily(addr)
+		t.mu.Lock()
+		if t.families == nil {
+			t.families = make(map[string]int64)
+		}
+		t.families[family]++
+		if family != first {
+			t.fallbacks++
+		}
+		t.mu.Unlock()
+	}
+
+	var tlsStart time.Time
+	trace.TLSHandshakeStart = func() {
+		tlsStart = time.Now()
+	}
+	trace.TLSHandshakeDone = func(state tls.ConnectionState, err error) {
+		if err != nil {
+			return
+		}
+		ms := float64(time.Since(tlsStart).Microseconds()) / 1000
+		t.tls.record(ms)
+		if state.DidResume {
+			t.tlsResumed.record(ms)
+		} else {
+			t.tlsFull.record(ms)
+		}
+	}
+
+	trace.GotConn = func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			t.mu.Lock()
+			t.reused++
+			t.mu.Unlock()
+		}
+	}
+}
+
+func (t *connectTracker) summary(reconnectEvery int, resumptionDisabled, happyEyeballs bool) *ConnectStats {
+	t.mu.Lock()
+	stats := &ConnectStats{
+		ReconnectEvery: reconnectEvery,
+		Reused:         t.reused,
+		Families:       make(map[string]int64),
+		Fallbacks:      t.fallbacks,
+		HappyEyeballs:  happyEyeballs,
+	}
+	for family, count := range t.families {
+		stats.Families[family] = count
+	}
+	t.mu.Unlock()
+	stats.Dial = t.dial.summary()
+	stats.Opened = stats.Dial.Count
+	if handshakes := t.tls.summary(); handshakes.Count > 0 {
+		stats.TLSHandshake = &handshakes
+		stats.TLSResumption = &ResumptionStats{
+			Disabled: resumptionDisabled,
+			Full:     t.tlsFull.summary(),
+			Resumed:  t.tlsResumed.summary(),
+		}
+	}
+	return stats
+}
+
+// addressFamily returns ipv4 or ipv6 for a dialed host:port address
+func addressFamily(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runControl pauses and resumes a run, and tunes its connections and
+// rate (see tune.go), from the keyboard or the control API. While the run
+// is paused, connections stay open but no requests are sent, and the
+// paused time doesn't count toward the duration or the statistics. It is
+// safe for concurrent use.
+type runControl struct {
+	mu      sync.Mutex
+	running bool
+	start   time.Time
+	paused  bool
+	since   time.Time     // when the current pause started
+	total   time.Duration // of the pauses that ended
+	pauses  int
+
+	// The tunable settings of the run, 0 when they can't be changed
+	duration    time.Duration
+	connections int
+	rate        float64
+	tuning      []TuningChange
+
+	// wake is closed and replaced on every change, for the workers that
+	// wait for one
+	wake chan struct{}
+
+	// changes wakes up the run on every change, so it can stop and
+	// restart the clock of its duration and apply the new settings
+	changes chan struct{}
+}
+
+func newRunControl() *runControl {
+	return &runControl{wake: make(chan struct{}), changes: make(chan struct{}, 1)}
+}
+
+// begin allows pausing and tuning once the measurement has started at
+// start, with the duration, connections and rate that can be tuned (0
+// for those that can't)
+func (c *runControl) begin(start time.Time, duration time.Duration, connections int, rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = true
+	c.start = start
+	c.duration = duration
+	c.connections = connections
+	c.rate = rate
+}
+
+// end ends a pause that is still going on and returns the total paused
+// time of the run. The run can't be paused after it ended.
+func (c *runControl) end() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.total += time.Since(c.since)
+		c.paused = false
+	}
+	c.running = false
+	return c.total
+}
+
+func (c *runControl) pause() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return errors.New("the run isn't in progress")
+	}
+	if c.paused {
+		return errors.New("the run is already paused")
+	}
+	c.paused = true
+	c.since = time.Now()
+	c.pauses++
+	c.notify()
+	fmt.Fprintln(os.Stderr, "Paused, no requests are sent until the run is resumed")
+	return nil
+}
+
+func (c *runControl) resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return errors.New("the run isn't in progress")
+	}
+	if !c.paused {
+		return errors.New("the run isn't paused")
+	}
+	pause := time.Since(c.since)
+	c.total += pause
+	c.paused = false
+	c.notify()
+	fmt.Fprintf(os.Stderr, "Resumed after %.1f s\n", pause.Seconds())
+	return nil
+}
+
+// notify signals a change without blocking; the run and the waiting
+// workers look at the current state when they wake up. c.mu must be
+// held.
+func (c *runControl) notify() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
+
+// isPaused reports whether the run is paused
+func (c *runControl) isPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// pausedFor returns the paused time of the run up to now
+func (c *runControl) pausedFor(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return c.total + now.Sub(c.since)
+	}
+	return c.total
+}
+
+// wait blocks while the run is paused. It returns false if stop is
+// closed first.
+func (c *runControl) wait(stop <-chan struct{}) bool {
+	return c.waitTurn(-1, stop)
+}
+
+// waitTurn blocks while the run is paused or the worker is beyond the
+// tuned number of connections. It returns false if stop is closed first.
+func (c *runControl) waitTurn(worker int, stop <-chan struct{}) bool {
+	for {
+		c.mu.Lock()
+		held := c.paused || c.connections > 0 && worker >= c.connections
+		wake := c.wake
+		c.mu.Unlock()
+		if !held {
+			return true
+		}
+		select {
+		case <-wake:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// PauseStats describes the pauses of a run, whose time is excluded from
+// its duration and statistics
+type PauseStats struct {
+	Pauses  int     `json:"pauses"`
+	Seconds float64 `json:"seconds"`
+}
+
+// summary returns the pause statistics, nil if the run was never paused
+func (c *runControl) summary() *PauseStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pauses == 0 {
+		return nil
+	}
+	return &PauseStats{Pauses: c.pauses, Seconds: c.total.Seconds()}
+}
+
+// readKeyboard runs the commands typed in a terminal, one per line (see
+// command). A run paused from the terminal says how to resume it.
+func (c *runControl) readKeyboard(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if err := c.command(line); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't change the run: %v\n", err)
+			continue
+		}
+		if strings.TrimSpace(line) == "" && c.isPaused() {
+			fmt.Fprintln(os.Stderr, "Press Enter to resume")
+		}
+	}
+}
+
+// controlStatus is the state of the run in control API responses
+type controlStatus struct {
+	Paused        bool    `json:"paused"`
+	Pauses        int     `json:"pauses"`
+	PausedSeconds float64 `json:"pausedSeconds"`
+	Duration      float64 `json:"durationSeconds,omitempty"`
+	Connections   int     `json:"connections,omitempty"`
+	Rate          float64 `json:"rate,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// handler serves the control API: GET /status, POST /pause, POST
+// /resume and, with tuning, POST /tune (with connections and/or rate as
+// query or form values) and POST /extend (by a duration, 30s by
+// default), which all respond with the status of the run. A change the
+// run can't make, such as pausing a paused run, is a 409 Conflict.
+// Requests browsers send from other sites are refused (see sameOrigin).
+func (c *runControl) handler(tuning bool) http.Handler {
+	respond := func(w http.ResponseWriter, err error) {
+		c.mu.Lock()
+		status := controlStatus{Paused: c.paused, Pauses: c.pauses, Duration: c.duration.Seconds(), Connections: c.connections, Rate: c.rate}
+		c.mu.Unlock()
+		status.PausedSeconds = c.pausedFor(time.Now()).Seconds()
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			status.Error = err.Error()
+			w.WriteHeader(http.StatusConflict)
+		}
+		json.NewEncoder(w).Encode(status)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil)
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, c.pause())
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, c.resume())
+	})
+	if !tuning {
+		return sameOrigin(mux)
+	}
+	mux.HandleFunc("POST /tune", func(w http.ResponseWriter, r *http.Request) {
+		connections, rate := r.FormValue("connections"), r.FormValue("rate")
+		if connections == "" && rate == "" {
+			http.Error(w, "expected connections or rate", http.StatusBadRequest)
+			return
+		}
+		var n int
+		var perSecond float64
+		var err error
+		if connections != "" {
+			if n, err = strconv.Atoi(connections); err != nil {
+				http.Error(w, fmt.Sprintf("invalid connections %q", connections), http.StatusBadRequest)
+				return
+			}
+		}
+		if rate != "" {
+			if perSecond, err = strconv.ParseFloat(rate, 64); err != nil {
+				http.Error(w, fmt.Sprintf("invalid rate %q", rate), http.StatusBadRequest)
+				return
+			}
+		}
+		if connections != "" {
+			err = c.setConnections(n)
+		}
+		if err == nil && rate != "" {
+			err = c.setRate(perSecond)
+		}
+		respond(w, err)
+	})
+	mux.HandleFunc("POST /extend", func(w http.ResponseWriter, r *http.Request) {
+		by := extendStep
+		if value := r.FormValue("by"); value != "" {
+			var err error
+			if by, err = time.ParseDuration(value); err != nil {
+				http.Error(w, fmt.Sprintf("invalid duration %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		respond(w, c.extend(by))
+	})
+	return sameOrigin(mux)
+}
+
+// sameOrigin refuses the requests a browser sends on behalf of another
+// site, such as a form posted or a fetch made by any page the user has
+// open, which could otherwise change a run. Browsers mark them with
+// Sec-Fetch-Site or Origin; clients such as curl send neither.
+func sameOrigin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch site := r.Header.Get("Sec-Fetch-Site"); {
+		case site != "" && site != "same-origin" && site != "none":
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		case site == "":
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+					http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+					return
+				}
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveControl serves the control API on addr for the rest of the
+// process
+func (c *runControl) serveControl(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go http.Serve(listener, c.handler(true))
+	fmt.Fprintf(os.Stderr, "Control API on http://%s/ (POST /pause, POST /resume, POST /tune, POST /extend, GET /status)\n", listener.Addr())
+	return nil
+}
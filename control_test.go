@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestControlCrossOrigin checks that browsers can't change a run from
+// other sites, while same-origin pages and clients without the browser
+// headers can
+func TestControlCrossOrigin(t *testing.T) {
+	control := newRunControl()
+	control.begin(time.Now(), time.Minute, 10, 100)
+	server := httptest.NewServer(control.handler(true))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		header  map[string]string
+		status  int
+		changed bool
+	}{
+		{"cross-site form", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, http.StatusForbidden, false},
+		{"same-site", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden, false},
+		{"other origin", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden, false},
+		{"null origin", map[string]string{"Origin": "null"}, http.StatusForbidden, false},
+		{"same origin", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": server.URL}, http.StatusOK, true},
+		{"origin of the server", map[string]string{"Origin": server.URL}, http.StatusOK, true},
+		{"curl", nil, http.StatusOK, true},
+	}
+	for i, tt := range tests {
+		connections := 11 + i
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/tune", strings.NewReader("connections="+strconv.Itoa(connections)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for name, value := range tt.header {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		got, _ := control.settings()
+		if resp.StatusCode != tt.status || (got == connections) != tt.changed {
+			t.Errorf("%s: status %d with %d connections, want %d, changed %v", tt.name, resp.StatusCode, got, tt.status, tt.changed)
+		}
+	}
+}
+
+// TestControlWithoutTuning checks that the handler of the web UI can only
+// pause and resume
+func TestControlWithoutTuning(t *testing.T) {
+	control := newRunControl()
+	control.begin(time.Now(), time.Minute, 10, 100)
+	server := httptest.NewServer(control.handler(false))
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/pause", http.StatusOK},
+		{"/resume", http.StatusOK},
+		{"/tune?rate=1", http.StatusNotFound},
+		{"/extend", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(server.URL+tt.path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("POST %s: status %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// digestAuth answers HTTP Digest challenges (RFC 7616) with the account
+// of -digest-auth, user:password. Every worker keeps the last challenge
+// it was sent and counts its uses of the nonce, so only its first request,
+// and those rejected with a stale nonce, are sent twice. It is safe for
+// concurrent use.
+type digestAuth struct {
+	user     string
+	password string
+
+	mu     sync.Mutex
+	states map[int]*digestState // per worker; -1 for requests not tied to one
+
+	challenges int64
+	stale      int64
+	failed     int64
+	latency    latencyTracker
+}
+
+// digestState is a worker's challenge and nonce count
+type digestState struct {
+	challenge digestChallenge
+	nc        uint32
+}
+
+// digestChallenge is the chosen Digest challenge of a WWW-Authenticate
+// header
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string // as sent, e.g. "SHA-256-sess"
+	hash      func() hash.Hash
+	session   bool
+	qop       string // "auth", "auth-int" or "" for RFC 2069 servers
+	userhash  bool
+	stale     bool
+}
+
+// digestHashes are the hash functions of the supported algorithms, the
+// preferred last
+var digestHashes = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"MD5", md5.New},
+	{"SHA-256", sha256.New},
+	{"SHA-512-256", sha512.New512_256},
+}
+
+// parseDigestAuth parses user:password
+func parseDigestAuth(s string) (*digestAuth, error) {
+	user, password, ok := strings.Cut(s, ":")
+	if !ok || user == "" {
+		return nil, errors.New("expected user:password")
+	}
+	return &digestAuth{user: user, password: password, states: make(map[int]*digestState)}, nil
+}
+
+func (d *digestAuth) summary() *AuthStats {
+	return &AuthStats{
+		Scheme:     "Digest",
+		Handshakes: atomic.LoadInt64(&d.challenges),
+		Failed:     atomic.LoadInt64(&d.failed),
+		Stale:      atomic.LoadInt64(&d.stale),
+		Latency:    d.latency.summary(),
+	}
+}
+
+// do sends req with client, authorized with the worker's last challenge.
+// A Digest challenge in a 401 is answered by sending the request again,
+// when its body can be sent twice.
+func (d *digestAuth) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return client.Do(req)
+	}
+	worker := workerFrom(req.Context())
+	start := time.Now()
+	first := req.Clone(req.Context())
+	authorized, err := d.authorize(first, worker)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge, ok := parseDigestChallenge(resp.Header)
+	if !ok {
+		if authorized {
+			atomic.AddInt64(&d.failed, 1)
+		}
+		return resp, nil
+	}
+	// A rejected answer to a challenge that is not stale means the
+	// account is wrong: asking again would fail the same way
+	if authorized && !challenge.stale {
+		atomic.AddInt64(&d.failed, 1)
+		return resp, nil
+	}
+	if challenge.stale {
+		atomic.AddInt64(&d.stale, 1)
+	}
+	retry, err := rewind(req)
+	if err != nil {
+		return resp, nil
+	}
+	discard(resp)
+	atomic.AddInt64(&d.challenges, 1)
+	d.mu.Lock()
+	d.states[worker] = &digestState{challenge: challenge}
+	d.mu.Unlock()
+	if _, err := d.authorize(retry, worker); err != nil {
+		return nil, err
+	}
+	resp, err = client.Do(retry)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		atomic.AddInt64(&d.failed, 1)
+	}
+	d.latency.record(float64(time.Since(start).Microseconds()) / 1000)
+	return resp, nil
+}
+
+// authorize sets the Authorization header of req answering the worker's
+// last challenge, and reports whether it had one
+func (d *digestAuth) authorize(req *http.Request, worker int) (bool, error) {
+	d.mu.Lock()
+	state, ok := d.states[worker]
+	var nc uint32
+	if ok {
+		state.nc++
+		nc = state.nc
+	}
+	d.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	c := state.challenge
+
+	cnonce := make([]byte, 16)
+	rand.Read(cnonce)
+	clientNonce := hex.EncodeToString(cnonce)
+	count := fmt.Sprintf("%08x", nc)
+	uri := req.URL.RequestURI()
+
+	var body []byte
+	if c.qop == "auth-int" && req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return true, err
+		}
+		body, err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return true, err
+		}
+	}
+	response := c.response(d.user, d.password, req.Method, uri, count, clientNonce, body)
+
+	username := d.user
+	if c.userhash {
+		username = c.h(d.user + ":" + c.realm)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%q, realm=%q, uri=%q, algorithm=%s, nonce=%q`, username, c.realm, uri, c.algorithm, c.nonce)
+	if c.qop != "" {
+		fmt.Fprintf(&b, `, nc=%s, cnonce=%q, qop=%s`, count, clientNonce, c.qop)
+	}
+	fmt.Fprintf(&b, `, response=%q`, response)
+	if c.opaque != "" {
+		fmt.Fprintf(&b, `, opaque=%q`, c.opaque)
+	}
+	if c.userhash {
+		b.WriteString(", userhash=true")
+	}
+	req.Header.Set("Authorization", b.String())
+	return true, nil
+}
+
+// h returns the hex digest of s with the challenge's hash function
+func (c digestChallenge) h(s string) string {
+	sum := c.hash()
+	io.WriteString(sum, s)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// response computes the response parameter answering the challenge for
+// a request, with nonce count count and client nonce cnonce. body is only
+// used with qop auth-int.
+func (c digestChallenge) response(user, password, method, uri, count, cnonce string, body []byte) string {
+	ha1 := c.h(user + ":" + c.realm + ":" + password)
+	if c.session {
+		ha1 = c.h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	a2 := method + ":" + uri
+	if c.qop == "auth-int" {
+		a2 += ":" + c.h(string(body))
+	}
+	if c.qop == "" {
+		return c.h(ha1 + ":" + c.nonce + ":" + c.h(a2))
+	}
+	return c.h(ha1 + ":" + c.nonce + ":" + count + ":" + cnonce + ":" + c.qop + ":" + c.h(a2))
+}
+
+// parseDigestChallenge returns the Digest challenge of a response with
+// the strongest algorithm supported, when it has one
+func parseDigestChallenge(header http.Header) (digestChallenge, bool) {
+	var best digestChallenge
+	rank := -1
+	for _, value := range header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		c := digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+			userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		name, session := strings.CutSuffix(strings.ToUpper(c.algorithm), "-SESS")
+		c.session = session
+		i := -1
+		for j, alg := range digestHashes {
+			if alg.name == name {
+				i, c.hash = j, alg.hash
+			}
+		}
+		if i < 0 || c.nonce == "" {
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			for _, option := range strings.Split(qop, ",") {
+				switch strings.TrimSpace(option) {
+				case "auth":
+					c.qop = "auth"
+				case "auth-int":
+					if c.qop == "" {
+						c.qop = "auth-int"
+					}
+				}
+			}
+			if c.qop == "" {
+				continue
+			}
+		}
+		if i > rank {
+			best, rank = c, i
+		}
+	}
+	return best, rank >= 0
+}
+
+// parseAuthParams parses the comma-separated name=value parameters of a
+// challenge, values quoted or not
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " \t")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[name] = value.String()
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDigestResponse checks the response parameter against the examples
+// of RFC 7616 section 3.9 and RFC 2617 section 3.5, and that of RFC 2069
+func TestDigestResponse(t *testing.T) {
+	rfc7616 := digestChallenge{
+		realm: "http-auth@example.org",
+		nonce: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		qop:   "auth",
+	}
+	const cnonce7616 = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	rfc2617 := digestChallenge{
+		realm: "testrealm@host.com",
+		nonce: "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+		qop:   "auth",
+		hash:  md5.New,
+	}
+	rfc2069 := rfc2617
+	rfc2069.qop = ""
+
+	tests := []struct {
+		name      string
+		challenge digestChallenge
+		hash      func() hash.Hash
+		user      string
+		password  string
+		uri       string
+		cnonce    string
+		want      string
+	}{
+		{"RFC 7616 MD5", rfc7616, md5.New, "Mufasa", "Circle of Life", "/dir/index.html", cnonce7616, "8ca523f5e9506fed4657c9700eebdbec"},
+		{"RFC 7616 SHA-256", rfc7616, sha256.New, "Mufasa", "Circle of Life", "/dir/index.html", cnonce7616, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+		{"RFC 2617", rfc2617, md5.New, "Mufasa", "Circle Of Life", "/dir/index.html", "0a4f113b", "6629fae49393a05397450978507c4ef1"},
+		{"RFC 2069", rfc2069, md5.New, "Mufasa", "CircleOfLife", "/dir/index.html", "", "1949323746fe6a43ef61f9606e7febea"},
+	}
+	for _, tt := range tests {
+		c := tt.challenge
+		c.hash = tt.hash
+		if got := c.response(tt.user, tt.password, http.MethodGet, tt.uri, "00000001", tt.cnonce, nil); got != tt.want {
+			t.Errorf("%s: response %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestDigestUserhash checks the hashed username of the RFC 7616 section
+// 3.9.2 example, as corrected by erratum 4897
+func TestDigestUserhash(t *testing.T) {
+	c := digestChallenge{realm: "api@example.org", hash: sha512.New512_256}
+	if got, want := c.h("Jäsøn Doe:"+c.realm), "793263caabb707a56211940d90411ea4a575adeccb7e360aeb624ed06ece9b0b"; got != want {
+		t.Errorf("userhash %s, want %s", got, want)
+	}
+}
+
+// TestParseDigestChallenge checks the parameters and the choice of the
+// strongest supported algorithm
+func TestParseDigestChallenge(t *testing.T) {
+	header := http.Header{}
+	header.Add("WWW-Authenticate", `Basic realm="x"`)
+	header.Add("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+	header.Add("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256-sess, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", stale=TRUE, userhash=true`)
+	header.Add("WWW-Authenticate", `Digest realm="x", algorithm=SHA-1, nonce="n"`)
+
+	c, ok := parseDigestChallenge(header)
+	if !ok {
+		t.Fatal("no challenge parsed")
+	}
+	if c.algorithm != "SHA-256-sess" || !c.session || c.qop != "auth" || !c.stale || !c.userhash {
+		t.Errorf("got algorithm %s, session %v, qop %q, stale %v, userhash %v", c.algorithm, c.session, c.qop, c.stale, c.userhash)
+	}
+	if c.realm != "http-auth@example.org" || c.nonce != "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v" || c.opaque != "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS" {
+		t.Errorf("got realm %q, nonce %q, opaque %q", c.realm, c.nonce, c.opaque)
+	}
+
+	for _, value := range []string{
+		`Basic realm="x"`,
+		`Digest realm="x", algorithm=SHA-1, nonce="n"`,
+		`Digest realm="x"`,
+		`Digest realm="x", nonce="n", qop="unknown"`,
+	} {
+		if _, ok := parseDigestChallenge(http.Header{"Www-Authenticate": {value}}); ok {
+			t.Errorf("parsed a challenge from %s", value)
+		}
+	}
+}
+
+// TestParseAuthParams checks quoted, escaped and bare values
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="a \"quoted\", realm", nonce=abc ,qop="auth,auth-int",Stale=false`)
+	want := map[string]string{"realm": `a "quoted", realm`, "nonce": "abc", "qop": "auth,auth-int", "stale": "false"}
+	if fmt.Sprint(params) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", params, want)
+	}
+}
+
+// TestDigestDo answers a server's challenges, checking the Authorization
+// headers it receives as RFC 7616 servers would
+func TestDigestDo(t *testing.T) {
+	const user, password, realm, nonce = "Mufasa", "Circle of Life", "http-auth@example.org", "abc123"
+	var challenged int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+		h := func(s string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(s))) }
+		want := h(h(user+":"+realm+":"+password) + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + h(r.Method+":"+r.URL.RequestURI()))
+		if params["response"] != want || params["username"] != user || params["uri"] != r.URL.RequestURI() {
+			challenged++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth", algorithm=SHA-256, nonce=%q`, realm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, params["nc"])
+	}))
+	defer server.Close()
+
+	d, err := parseDigestAuth(user + ":" + password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wantNC := range []string{"00000001", "00000002", "00000003"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/dir/index.html?x=1", nil)
+		resp, err := d.do(server.Client(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != wantNC {
+			t.Errorf("request %d: status %d with nonce count %q, want 200 with %s", i, resp.StatusCode, body, wantNC)
+		}
+	}
+	if stats := d.summary(); challenged != 1 || stats.Handshakes != 1 || stats.Failed != 0 {
+		t.Errorf("got %d challenges, %d handshakes and %d failures; want one challenge", challenged, stats.Handshakes, stats.Failed)
+	}
+}
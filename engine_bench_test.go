@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The benchmarks below measure the engine itself against an in-process
+// server, so changes to the hot path can be checked for regressions of
+// the load generator's own throughput, allocations and overhead:
+//
+//	go test -run '^$' -bench Engine -benchmem -count 10 > new.txt
+//	benchstat old.txt new.txt
+//
+// One benchmark op is one request.
+
+// countedGenerator sends the same request a fixed number of times, then
+// ends the run
+type countedGenerator struct {
+	spec      requestSpec
+	remaining int64
+}
+
+func (g *countedGenerator) Next(ctx context.Context) (*http.Request, error) {
+	if atomic.AddInt64(&g.remaining, -1) < 0 {
+		return nil, io.EOF
+	}
+	return g.spec.newRequest(ctx)
+}
+
+// okHandler answers every request at once with a small body
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+// runEngine sends b.N requests over the given number of connections to an
+// in-process server and reports the engine's request rate
+func runEngine(b *testing.B, connections int, handler http.Handler) (BenchmarkResult, time.Duration) {
+	b.Helper()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	config := BenchmarkConfig{
+		URI:         server.URL,
+		Connections: connections,
+		Timeout:     10,
+		Method:      "GET",
+		Model:       "closed",
+		Generator:   &countedGenerator{spec: requestSpec{Method: "GET", URL: server.URL}, remaining: int64(b.N)},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	result := runBenchmark(config)
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	if result.Requests.Failed > 0 {
+		b.Fatalf("%d of %d requests failed", result.Requests.Failed, result.Requests.Total)
+	}
+	b.ReportMetric(float64(result.Requests.Total)/elapsed.Seconds(), "req/s")
+	return result, elapsed
+}
+
+// BenchmarkEngine measures the maximum request rate and the allocations
+// per request at several concurrency levels
+func BenchmarkEngine(b *testing.B) {
+	for _, connections := range []int{1, 10, 100} {
+		b.Run("connections="+strconv.Itoa(connections), func(b *testing.B) {
+			runEngine(b, connections, okHandler)
+		})
+	}
+}
+
+// BenchmarkEngineOverhead measures, on a single connection, the latency
+// the engine records and the time per request it spends outside of it
+// (building the request, reading the body, bookkeeping). The overhead
+// bounds the request rate a single connection can reach.
+func BenchmarkEngineOverhead(b *testing.B) {
+	result, elapsed := runEngine(b, 1, okHandler)
+	if result.Requests.Total == 0 {
+		return
+	}
+	perRequest := float64(elapsed.Microseconds()) / float64(result.Requests.Total)
+	latency := result.Latency.Average * 1000
+	b.ReportMetric(latency, "latency-µs")
+	b.ReportMetric(perRequest-latency, "overhead-µs")
+}
+
+// BenchmarkBaselineClient sends the same requests with a bare
+// http.Client on one connection, as the lower bound for the engine
+func BenchmarkBaselineClient(b *testing.B) {
+	server := httptest.NewServer(okHandler)
+	defer server.Close()
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "req/s")
+}
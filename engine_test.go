@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestLatencyAverageWithFailures checks that the average latency covers
+// every request: three in four requests fail -expect 200 slowly, the others succeed
+// at once, so an average over the successful ones only would exceed the
+// maximum
+func TestLatencyAverageWithFailures(t *testing.T) {
+	var served int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&served, 1)%4 != 0 {
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	result := runBenchmark(BenchmarkConfig{
+		URI:         server.URL,
+		Connections: 1,
+		Timeout:     10,
+		Method:      "GET",
+		Model:       "closed",
+		Validators:  []Validator{statusValidator{expected: http.StatusOK}},
+		Generator:   &countedGenerator{spec: requestSpec{Method: "GET", URL: server.URL}, remaining: 40},
+	})
+
+	if result.Requests.Total != 40 || result.Requests.Failed != 30 {
+		t.Fatalf("got %d requests, %d failed; want 40, 30 failed", result.Requests.Total, result.Requests.Failed)
+	}
+	if result.Latency.Average < result.Latency.Min || result.Latency.Average > result.Latency.Max {
+		t.Errorf("average latency %.2f ms is outside of min %.2f ms and max %.2f ms", result.Latency.Average, result.Latency.Min, result.Latency.Max)
+	}
+	// 30 requests took at least 20 ms each
+	if min := 30 * 20.0 / 40; result.Latency.Average < min {
+		t.Errorf("average latency %.2f ms, want at least %.2f ms", result.Latency.Average, min)
+	}
+}
+
+// TestLatencyAllFailed checks that a run whose requests all failed still
+// reports their latency, percentiles and histogram
+func TestLatencyAllFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	result := runBenchmark(BenchmarkConfig{
+		URI:         server.URL,
+		Connections: 2,
+		Timeout:     10,
+		Method:      "GET",
+		Model:       "closed",
+		Percentiles: []float64{50, 99},
+		Validators:  []Validator{statusValidator{expected: http.StatusOK}},
+		Generator:   &countedGenerator{spec: requestSpec{Method: "GET", URL: server.URL}, remaining: 10},
+	})
+
+	if result.Requests.Successful != 0 {
+		t.Fatalf("got %d successful requests, want 0", result.Requests.Successful)
+	}
+	if result.Latency.Average < 5 || result.Latency.Max < result.Latency.Average {
+		t.Errorf("got average %.2f ms and max %.2f ms, want an average of at least 5 ms", result.Latency.Average, result.Latency.Max)
+	}
+	if len(result.Latency.Percentiles) != 2 || result.Latency.Percentiles[0].Value < 5 {
+		t.Errorf("got percentiles %+v, want p50 and p99 of at least 5 ms", result.Latency.Percentiles)
+	}
+	if result.Latency.Histogram == "" {
+		t.Error("got no latency histogram")
+	}
+}
+
+// TestConnectTimeouts checks that a request that times out in the TLS
+// handshake counts as a connect timeout, and one that times out waiting
+// for the headers as a header timeout
+func TestConnectTimeouts(t *testing.T) {
+	// Accepts connections but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	for _, test := range []struct {
+		uri             string
+		connect, header int64
+	}{
+		{"https://" + listener.Addr().String(), 1, 0},
+		{slow.URL, 0, 1},
+	} {
+		result := runBenchmark(BenchmarkConfig{
+			URI:         test.uri,
+			Connections: 1,
+			Timeout:     1,
+			Method:      "GET",
+			Model:       "closed",
+			Generator:   &countedGenerator{spec: requestSpec{Method: "GET", URL: test.uri}, remaining: 1},
+		})
+		if result.Requests.Timeouts != 1 || result.Requests.ConnectTimeouts != test.connect || result.Requests.HeaderTimeouts != test.header {
+			t.Errorf("%s: got %d timeouts, %d connect and %d header; want 1, %d connect and %d header", test.uri,
+				result.Requests.Timeouts, result.Requests.ConnectTimeouts, result.Requests.HeaderTimeouts, test.connect, test.header)
+		}
+	}
+}
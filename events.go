@@ -0,0 +1,41 @@
+package main
+
+import "time"
+
+// EventHandler receives progress events from runBenchmark, so output,
+// alerting or custom UIs can be built on top of the engine without
+// changing it. Set BenchmarkConfig.Handlers to subscribe.
+//
+// OnRequestDone and OnError are called from the request goroutines and
+// must be safe for concurrent use; they should return quickly, as they
+// delay the next request. OnTick and OnFinish are called from a single
+// goroutine.
+type EventHandler interface {
+	// OnTick is called once per second with the progress so far
+	OnTick(Tick)
+	// OnRequestDone is called for every request, successful or not
+	OnRequestDone(Sample)
+	// OnError is called for every failed request, after OnRequestDone
+	OnError(Sample)
+	// OnFinish is called once with the final result
+	OnFinish(BenchmarkResult)
+}
+
+// Tick is the per-second progress report passed to OnTick
+type Tick struct {
+	Elapsed       time.Duration
+	Requests      int64 // completed in the last second
+	BytesRead     int64 // read in the last second
+	TotalRequests int64
+	Failed        int64
+	Tuning        []TuningChange // made since the last tick
+}
+
+// NopEventHandler implements EventHandler with no-ops, for embedding in
+// handlers that only need some of the events
+type NopEventHandler struct{}
+
+func (NopEventHandler) OnTick(Tick)              {}
+func (NopEventHandler) OnRequestDone(Sample)     {}
+func (NopEventHandler) OnError(Sample)           {}
+func (NopEventHandler) OnFinish(BenchmarkResult) {}
@@ -0,0 +1,27 @@
+package main
+
+// Exit codes, so scripts can tell why a run failed without parsing its
+// output
+const (
+	exitOK              = 0 // the run completed and every assertion passed
+	exitAssertionFailed = 1 // a response assertion (-expect, -expect-body) failed, or the scenario checks missed their threshold
+	exitConfigError     = 2 // invalid flags or input files, as for flag parse errors
+	exitUnreachable     = 3 // no response was received from the target
+	exitInterrupted     = 4 // the run was stopped by SIGINT or SIGTERM
+	exitSetupFailed     = 5 // a -hooks setup request failed, so the run didn't start
+)
+
+// exitCode picks the exit code for a finished run
+func exitCode(result BenchmarkResult) int {
+	switch {
+	case result.Interrupted:
+		return exitInterrupted
+	case len(result.StatusCodeCounts) == 0:
+		return exitUnreachable
+	case result.Validation != nil && result.Validation.Failed > 0:
+		return exitAssertionFailed
+	case result.Checks != nil && !result.Checks.Passed:
+		return exitAssertionFailed
+	}
+	return exitOK
+}
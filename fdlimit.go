@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// fileReserve is the number of open files allowed for besides the
+// connections: stdio, output files, the results store and DNS lookups
+const fileReserve = 64
+
+// checkFileLimit makes sure the open file limit allows the connections a
+// run will open, raising the soft limit when it is too low. Running out
+// of file descriptors mid-run shows up as a burst of failed requests that
+// look like server errors. It returns false when the run should not
+// start; an estimated count (the open model) only warns.
+func checkFileLimit(connections uint64, estimated, force bool) bool {
+	want := connections + fileReserve
+	soft, hard, err := raiseFileLimit(want)
+	if err != nil {
+		return true
+	}
+	logger.Info("file limit", "soft", soft, "hard", hard, "wanted", want)
+	if soft >= want {
+		return true
+	}
+
+	if estimated {
+		fmt.Fprintf(os.Stderr, "Warning: the open file limit (%d) may be too low for up to %d concurrent requests; raise it with ulimit -n or lower -rate or -max-pending.\n", soft, connections)
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "The open file limit (%d, hard limit %d) is too low for %d connections: each needs a file descriptor.\n", soft, hard, connections)
+	fmt.Fprintf(os.Stderr, "Raise it with ulimit -n %d (the hard limit is set in /etc/security/limits.conf, or with LimitNOFILE for a systemd service), use fewer -clients, or pass -force to run anyway.\n", want)
+	return force
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// FieldStats is the distribution of a numeric field of the JSON
+// responses, from -json-field. Missing counts the responses without it.
+// Percentiles are kept at three decimals, with negative values counted
+// as 0. Latency is the average latency of the responses with the field,
+// and Correlation the Pearson correlation of the field with it.
+type FieldStats struct {
+	Field       string   `json:"field"`
+	Count       int64    `json:"count"`
+	Missing     int64    `json:"missing"`
+	Average     float64  `json:"average"`
+	P50         float64  `json:"p50"`
+	P90         float64  `json:"p90"`
+	P99         float64  `json:"p99"`
+	Min         float64  `json:"min"`
+	Max         float64  `json:"max"`
+	Latency     float64  `json:"latencyMs"`
+	Correlation *float64 `json:"correlation,omitempty"`
+}
+
+// arrayIndex matches the [n] array indices of a field path
+var arrayIndex = regexp.MustCompile(`\[(\d+)\]`)
+
+// jsonField is a numeric field of the JSON responses
+type jsonField struct {
+	name string
+	path []string
+}
+
+// parseJSONField parses a path such as $.processingTimeMs,
+// $.items.length or $.results[0].score. The leading $ is optional, and
+// a final "length" is the length of an array, string or object that has
+// no such key.
+func parseJSONField(s string) (jsonField, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	path = arrayIndex.ReplaceAllString(path, ".$1")
+	if path == "" {
+		return jsonField{}, fmt.Errorf("%q has no field", s)
+	}
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return jsonField{}, fmt.Errorf("%q has an empty key", s)
+		}
+	}
+	return jsonField{name: s, path: keys}, nil
+}
+
+// value returns the field of a decoded response as a number. Numeric
+// strings count.
+func (f jsonField) value(v any) (float64, bool) {
+	for _, key := range f.path {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok && key == "length" {
+				return float64(len(node)), true
+			}
+			v = next
+		case []any:
+			if key == "length" {
+				return float64(len(node)), true
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return 0, false
+			}
+			v = node[i]
+		case string:
+			if key == "length" {
+				return float64(len([]rune(node))), true
+			}
+			return 0, false
+		default:
+			return 0, false
+		}
+	}
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case string:
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// fieldSeries accumulates the values of one field
+type fieldSeries struct {
+	hist     *histogram // thousandths
+	count    int64
+	missing  int64
+	min, max float64
+	// sums for the mean and the correlation with latency
+	sum, sumSquares     float64
+	latency, latSquares float64
+	products            float64
+}
+
+// fieldTracker collects FieldStats from concurrent workers
+type fieldTracker struct {
+	fields []jsonField
+	mu     sync.Mutex
+	series []*fieldSeries
+}
+
+func newFieldTracker(fields []jsonField) *fieldTracker {
+	t := &fieldTracker{fields: fields}
+	for range fields {
+		t.series = append(t.series, &fieldSeries{hist: newCountHistogram()})
+	}
+	return t
+}
+
+// record adds the fields of a complete response body of the given
+// latency
+func (t *fieldTracker) record(body []byte, latency float64) {
+	var v any
+	decoded := json.Unmarshal(body, &v) == nil
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, field := range t.fields {
+		s := t.series[i]
+		value, ok := 0.0, false
+		if decoded {
+			value, ok = field.value(v)
+		}
+		if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+			s.missing++
+			continue
+		}
+		if s.count == 0 || value < s.min {
+			s.min = value
+		}
+		if s.count == 0 || value > s.max {
+			s.max = value
+		}
+		s.count++
+		s.hist.record(int64(math.Round(value * 1000)))
+		s.sum += value
+		s.sumSquares += value * value
+		s.latency += latency
+		s.latSquares += latency * latency
+		s.products += value * latency
+	}
+}
+
+func (t *fieldTracker) summary() []FieldStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make([]FieldStats, len(t.fields))
+	for i, field := range t.fields {
+		s := t.series[i]
+		stats[i] = FieldStats{Field: field.name, Count: s.count, Missing: s.missing}
+		if s.count == 0 {
+			continue
+		}
+		n := float64(s.count)
+		stats[i].Average = s.sum / n
+		stats[i].P50 = float64(s.hist.valueAtPercentile(50)) / 1000
+		stats[i].P90 = float64(s.hist.valueAtPercentile(90)) / 1000
+		stats[i].P99 = float64(s.hist.valueAtPercentile(99)) / 1000
+		stats[i].Min = s.min
+		stats[i].Max = s.max
+		stats[i].Latency = s.latency / n
+		covariance := s.products/n - stats[i].Average*stats[i].Latency
+		deviation := math.Sqrt(s.sumSquares/n-stats[i].Average*stats[i].Average) * math.Sqrt(s.latSquares/n-stats[i].Latency*stats[i].Latency)
+		if s.count > 1 && deviation > 0 {
+			r := math.Max(-1, math.Min(1, covariance/deviation))
+			stats[i].Correlation = &r
+		}
+	}
+	return stats
+}
+
+// formatFieldValue prints a field value with up to three decimals
+func formatFieldValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// displayFieldStats prints the -json-field table
+func displayFieldStats(result BenchmarkResult) {
+	if len(result.Fields) == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nJSON Fields:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Field", "Found", "Average", "Median", "Tail", "Max", "Latency", "Correlation")
+	for _, f := range result.Fields {
+		found := fmt.Sprintf("%d", f.Count)
+		if f.Missing > 0 {
+			found = fmt.Sprintf("%d (%d missing)", f.Count, f.Missing)
+		}
+		if f.Count == 0 {
+			table.Append([]string{f.Field, found, "-", "-", "-", "-", "-", "-"})
+			continue
+		}
+		correlation := "-"
+		if f.Correlation != nil {
+			correlation = fmt.Sprintf("%+.2f", *f.Correlation)
+		}
+		table.Append([]string{f.Field, found, formatFieldValue(f.Average), formatFieldValue(f.P50), formatFieldValue(f.P99), formatFieldValue(f.Max), formatLatency(f.Latency), correlation})
+	}
+	table.Render()
+	fmt.Println("Tail is the 99th percentile; Latency the average latency of the responses with the field, and Correlation its correlation with the field.")
+}
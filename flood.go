@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// FloodResult is the outcome of a flood run: how many new connections per
+// second the target accepted. Accept is the time to establish a TCP
+// connection, TLS the handshake after it, and Request the time from
+// writing the optional request to its response headers. Failures counts
+// the connections that failed, by class. PerSecond has the connections
+// completed in every second of the run.
+type FloodResult struct {
+	Target         string           `json:"target"`
+	Duration       float64          `json:"durationSeconds"`
+	Concurrency    int              `json:"concurrency"`
+	Rate           float64          `json:"rate,omitempty"`
+	Request        bool             `json:"request"`
+	Attempted      int64            `json:"attempted"`
+	Completed      int64            `json:"completed"`
+	PerSec         float64          `json:"connectionsPerSecond"`
+	PerSecond      []int64          `json:"perSecond"`
+	Accept         FloodLatency     `json:"accept"`
+	TLS            *FloodLatency    `json:"tls,omitempty"`
+	RequestLatency *FloodLatency    `json:"requestLatency,omitempty"`
+	Statuses       map[string]int64 `json:"statuses,omitempty"`
+	Failures       map[string]int64 `json:"failures,omitempty"`
+}
+
+// FloodLatency summarizes a flood timing, in milliseconds
+type FloodLatency struct {
+	Average float64 `json:"averageMs"`
+	P50     float64 `json:"p50Ms"`
+	P90     float64 `json:"p90Ms"`
+	P99     float64 `json:"p99Ms"`
+	Max     float64 `json:"maxMs"`
+}
+
+// floodTracker collects a flood's timings and failures from concurrent
+// workers
+type floodTracker struct {
+	start     time.Time
+	end       time.Time
+	attempted int64
+	completed int64
+
+	mu        sync.Mutex
+	accept    *histogram
+	tls       *histogram
+	request   *histogram
+	statuses  map[int]int64
+	failures  map[string]int64
+	perSecond []int64
+}
+
+func newFloodTracker() *floodTracker {
+	return &floodTracker{
+		accept:   newLatencyHistogram(),
+		tls:      newLatencyHistogram(),
+		request:  newLatencyHistogram(),
+		statuses: make(map[int]int64),
+		failures: make(map[string]int64),
+	}
+}
+
+// floodSummary summarizes a histogram of microseconds, or returns nil if
+// it is empty
+func floodSummary(h *histogram) *FloodLatency {
+	if h.totalCount == 0 {
+		return nil
+	}
+	ms := func(p float64) float64 { return float64(h.valueAtPercentile(p)) / 1000 }
+	return &FloodLatency{Average: h.mean() / 1000, P50: ms(50), P90: ms(90), P99: ms(99), Max: ms(100)}
+}
+
+// classifyFloodError reduces a flood connection's error to a failure
+// class: refused, reset, closed, port_exhaustion, a timeout of the
+// failing phase (connect, tls or request), or the phase itself
+func classifyFloodError(err error, phase string) string {
+	switch {
+	case isPortExhaustion(err):
+		return "port_exhaustion"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "reset"
+	case os.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return phase + "_timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "closed"
+	}
+	return phase
+}
+
+// runFlood implements the "flood" subcommand: open connections to a
+// target as fast as it accepts them, or at a fixed rate, optionally send
+// one request on each, and close them
+func runFlood(args []string) {
+	fs := flag.NewFlagSet("flood", flag.ExitOnError)
+	uri := fs.String("uri", "", "The target: an http:// or https:// uri, or tcp://host:port for bare connections (Required)")
+	concurrency := fs.Int("clients", 50, "Number of connections being opened at once")
+	duration := fs.Int("duration", 10, "The number of seconds to run the flood")
+	rate := fs.Float64("rate", 0, "Open this many connections per second (0 opens them as fast as the target accepts them)")
+	sendRequest := fs.Bool("request", false, "Send one GET request for the uri on every connection and read its response before closing")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of every connection, from dialing to closing")
+	reset := fs.Bool("reset", false, "Close connections with a TCP reset (SO_LINGER 0), so they do not hold local ports in TIME_WAIT")
+	output := fs.String("output", "", "Output file to write the result as JSON")
+	jsonOut := fs.Bool("json", false, "Print the result JSON to stdout instead of tables")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	fs.Parse(args)
+	configureColor(*noColor)
+
+	if *uri == "" {
+		fmt.Println("Usage: autocannon flood -uri URI [flags]")
+		os.Exit(exitConfigError)
+	}
+	target, err := url.Parse(*uri)
+	if err != nil || target.Host == "" || (target.Scheme != "http" && target.Scheme != "https" && target.Scheme != "tcp") {
+		fmt.Printf("Invalid -uri %q: expected http://, https:// or tcp://host:port.\n", *uri)
+		os.Exit(exitConfigError)
+	}
+	if target.Scheme == "tcp" && *sendRequest {
+		fmt.Println("-request needs an http:// or https:// uri.")
+		os.Exit(exitConfigError)
+	}
+	if *concurrency < 1 || *duration < 1 || *rate < 0 || *timeout <= 0 {
+		fmt.Println("-clients, -duration and -timeout must be positive, and -rate cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	addr := target.Host
+	if target.Port() == "" {
+		port := "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(target.Hostname(), port)
+	}
+	path := target.RequestURI()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*duration)*time.Second)
+	defer cancel()
+
+	var pacer *limiter
+	if *rate > 0 {
+		arrival, _ := parseArrival("constant", *rate)
+		pacer = newLimiter(arrival, 1, 0)
+	}
+	if !*jsonOut {
+		fmt.Printf("Flooding %s with new connections for %d seconds (%d at once", addr, *duration, *concurrency)
+		if *rate > 0 {
+			fmt.Printf(", %g per second", *rate)
+		}
+		fmt.Println(")...")
+	}
+
+	t := newFloodTracker()
+	t.start = time.Now()
+	t.end, _ = ctx.Deadline()
+	dialer := &net.Dialer{}
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !t.over(ctx) {
+				if pacer != nil {
+					if _, ok := pacer.wait(ctx.Done()); !ok {
+						return
+					}
+				}
+				t.connect(ctx, dialer, target, addr, path, *sendRequest, *timeout, *reset)
+			}
+		}()
+	}
+	wg.Wait()
+	result := t.result(target.Redacted(), *concurrency, *rate, *sendRequest)
+
+	if *output != "" {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *output, err)
+		}
+	}
+	if *jsonOut {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		displayFloodResult(result)
+	}
+	if failed := result.Failures["port_exhaustion"]; failed > 0 {
+		warnPortExhaustion(newPortExhaustionStats(failed, 0))
+	}
+	if result.Completed == 0 {
+		os.Exit(exitUnreachable)
+	}
+}
+
+// connect opens, and closes, one connection of the flood. Connections
+// cut short by the end of the run are not counted.
+func (t *floodTracker) connect(ctx context.Context, dialer *net.Dialer, target *url.URL, addr, path string, sendRequest bool, timeout time.Duration, reset bool) {
+	connCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	fail := func(err error, phase string) {
+		if t.over(ctx) {
+			return
+		}
+		atomic.AddInt64(&t.attempted, 1)
+		t.mu.Lock()
+		t.failures[classifyFloodError(err, phase)]++
+		t.mu.Unlock()
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(connCtx, "tcp", addr)
+	if err != nil {
+		fail(err, "connect")
+		return
+	}
+	accepted := time.Since(start)
+	defer func() {
+		if tcp, ok := conn.(*net.TCPConn); ok && reset {
+			tcp.SetLinger(0)
+		}
+		conn.Close()
+	}()
+	if deadline, ok := connCtx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var handshake time.Duration
+	if target.Scheme == "https" {
+		handshakeStart := time.Now()
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: target.Hostname(), NextProtos: []string{"http/1.1"}})
+		if err := tlsConn.HandshakeContext(connCtx); err != nil {
+			fail(err, "tls")
+			return
+		}
+		handshake = time.Since(handshakeStart)
+		conn = tlsConn
+	}
+
+	var requestTime time.Duration
+	status := 0
+	if sendRequest {
+		req := &http.Request{
+			Method: http.MethodGet,
+			URL:    &url.URL{Opaque: path},
+			Host:   target.Host,
+			Header: http.Header{"User-Agent": {defaultUserAgent()}},
+			Close:  true,
+		}
+		requestStart := time.Now()
+		if err := req.Write(conn); err != nil {
+			fail(err, "request")
+			return
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			fail(err, "request")
+			return
+		}
+		requestTime = time.Since(requestStart)
+		status = resp.StatusCode
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if t.over(ctx) {
+		return
+	}
+
+	atomic.AddInt64(&t.attempted, 1)
+	atomic.AddInt64(&t.completed, 1)
+	second := int(time.Since(t.start) / time.Second)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.accept.record(accepted.Microseconds())
+	if handshake > 0 {
+		t.tls.record(handshake.Microseconds())
+	}
+	if sendRequest {
+		t.request.record(requestTime.Microseconds())
+		t.statuses[status]++
+	}
+	for len(t.perSecond) <= second {
+		t.perSecond = append(t.perSecond, 0)
+	}
+	t.perSecond[second]++
+}
+
+// over reports whether the run has ended. Dials fail at the end of the
+// run's deadline before its context reports it.
+func (t *floodTracker) over(ctx context.Context) bool {
+	return ctx.Err() != nil || !time.Now().Before(t.end)
+}
+
+func (t *floodTracker) result(target string, concurrency int, rate float64, sendRequest bool) FloodResult {
+	elapsed := time.Since(t.start).Seconds()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := FloodResult{
+		Target:         target,
+		Duration:       elapsed,
+		Concurrency:    concurrency,
+		Rate:           rate,
+		Request:        sendRequest,
+		Attempted:      atomic.LoadInt64(&t.attempted),
+		Completed:      atomic.LoadInt64(&t.completed),
+		PerSecond:      t.perSecond,
+		TLS:            floodSummary(t.tls),
+		RequestLatency: floodSummary(t.request),
+	}
+	if elapsed > 0 {
+		r.PerSec = float64(r.Completed) / elapsed
+	}
+	if accept := floodSummary(t.accept); accept != nil {
+		r.Accept = *accept
+	}
+	if len(t.statuses) > 0 {
+		r.Statuses = make(map[string]int64, len(t.statuses))
+		for status, n := range t.statuses {
+			r.Statuses[fmt.Sprint(status)] = n
+		}
+	}
+	if len(t.failures) > 0 {
+		r.Failures = t.failures
+	}
+	return r
+}
+
+// displayFloodResult prints the tables of a flood run
+func displayFloodResult(r FloodResult) {
+	newTable := func() *tablewriter.Table {
+		return tablewriter.NewTable(os.Stdout,
+			tablewriter.WithConfig(tablewriter.Config{
+				Row: tw.CellConfig{
+					Formatting: tw.CellFormatting{
+						Alignment: tw.AlignRight,
+					},
+				},
+				Header: tw.CellConfig{
+					Formatting: tw.CellFormatting{
+						Alignment: tw.AlignCenter,
+					},
+				},
+			}),
+		)
+	}
+
+	fmt.Println(colorize(chalk.Green, "\nConnection Flood Results:"))
+	table := newTable()
+	table.Header("Metric", "Value")
+	table.Append([]string{"Connections Attempted", fmt.Sprintf("%d", r.Attempted)})
+	table.Append([]string{"Connections Completed", fmt.Sprintf("%d", r.Completed)})
+	failed := r.Attempted - r.Completed
+	failRate := 0.0
+	if r.Attempted > 0 {
+		failRate = float64(failed) / float64(r.Attempted) * 100
+	}
+	table.Append([]string{"Connections Failed", fmt.Sprintf("%d (%.2f%%)", failed, failRate)})
+	table.Append([]string{"Connections/sec", fmt.Sprintf("%.2f", r.PerSec)})
+	if len(r.PerSecond) > 0 {
+		// The last second is usually cut short by the end of the run
+		seconds := r.PerSecond
+		if len(seconds) > 1 {
+			seconds = seconds[:len(seconds)-1]
+		}
+		sorted := append([]int64(nil), seconds...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		table.Append([]string{"  Slowest / Fastest Second", fmt.Sprintf("%d / %d", sorted[0], sorted[len(sorted)-1])})
+	}
+	table.Render()
+
+	fmt.Println(colorize(chalk.Green, "\nTimings:"))
+	timings := newTable()
+	// The tail is the 99th percentile; header formatting mangles digits
+	timings.Header("Phase", "Average", "Median", "Tail", "Max")
+	add := func(name string, l *FloodLatency) {
+		if l == nil {
+			return
+		}
+		timings.Append([]string{name, formatLatency(l.Average), formatLatency(l.P50), formatLatency(l.P99), formatLatency(l.Max)})
+	}
+	if r.Completed > 0 {
+		add("Accept", &r.Accept)
+	}
+	add("TLS Handshake", r.TLS)
+	add("Request", r.RequestLatency)
+	timings.Render()
+
+	if len(r.Statuses) > 0 {
+		fmt.Println(colorize(chalk.Green, "\nStatus Codes:"))
+		statuses := newTable()
+		statuses.Header("Status", "Count")
+		codes := make([]string, 0, len(r.Statuses))
+		for code := range r.Statuses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			statuses.Append([]string{code, fmt.Sprintf("%d", r.Statuses[code])})
+		}
+		statuses.Render()
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Println(colorize(chalk.Green, "\nFailures:"))
+		failures := newTable()
+		failures.Header("Class", "Count", "Share")
+		classes := make([]string, 0, len(r.Failures))
+		for class := range r.Failures {
+			classes = append(classes, class)
+		}
+		sort.Slice(classes, func(i, j int) bool { return r.Failures[classes[i]] > r.Failures[classes[j]] })
+		for _, class := range classes {
+			failures.Append([]string{class, fmt.Sprintf("%d", r.Failures[class]), fmt.Sprintf("%.2f%%", float64(r.Failures[class])/float64(r.Attempted)*100)})
+		}
+		failures.Render()
+	}
+}
@@ -0,0 +1,766 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// RequestGenerator builds the requests the workers send. Next is called
+// concurrently by all workers and must be safe for concurrent use. The
+// context carries the calling worker (see workerFrom) and should be
+// attached to the request. Returning io.EOF ends the run; returning
+// errWorkerDone stops the calling worker, and the run once every worker
+// has stopped.
+type RequestGenerator interface {
+	Next(ctx context.Context) (*http.Request, error)
+}
+
+// errWorkerDone is returned by a RequestGenerator that has no more
+// requests for the calling worker, but may have for others
+var errWorkerDone = errors.New("no more requests for this worker")
+
+type generatorContextKey int
+
+const (
+	workerKey generatorContextKey = iota
+	operationKey
+	requestIDKey
+	feedRowKey
+	scenarioStepKey
+)
+
+// withWorker records the id of the worker that will send the request, or
+// -1 when requests are not tied to a worker
+func withWorker(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, workerKey, id)
+}
+
+// workerFrom returns the worker id recorded by withWorker
+func workerFrom(ctx context.Context) int {
+	if id, ok := ctx.Value(workerKey).(int); ok {
+		return id
+	}
+	return -1
+}
+
+// withRequestID records the unique id of the request about to be built
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// requestIDFrom returns the id recorded by withRequestID
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withFeedRow records the feed row a request was rendered from
+func withFeedRow(ctx context.Context, row map[string]string) context.Context {
+	return context.WithValue(ctx, feedRowKey, row)
+}
+
+// feedRowFrom returns the row recorded by withFeedRow, or nil
+func feedRowFrom(ctx context.Context) map[string]string {
+	row, _ := ctx.Value(feedRowKey).(map[string]string)
+	return row
+}
+
+// withScenarioStep records the index of the scenario step a request was
+// rendered from
+func withScenarioStep(ctx context.Context, step int) context.Context {
+	return context.WithValue(ctx, scenarioStepKey, step)
+}
+
+// scenarioStepFrom returns the step recorded by withScenarioStep
+func scenarioStepFrom(ctx context.Context) (int, bool) {
+	step, ok := ctx.Value(scenarioStepKey).(int)
+	return step, ok
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// operationInfo names the operation a request belongs to, for the
+// per-operation statistics
+type operationInfo struct {
+	Name string
+	Path string
+}
+
+// withOperation tags a request context with its operation
+func withOperation(ctx context.Context, name, path string) context.Context {
+	return context.WithValue(ctx, operationKey, operationInfo{Name: name, Path: path})
+}
+
+// operationFrom returns the operation recorded by withOperation
+func operationFrom(ctx context.Context) (operationInfo, bool) {
+	op, ok := ctx.Value(operationKey).(operationInfo)
+	return op, ok
+}
+
+// newRequest builds the request described by the spec
+func (spec requestSpec) newRequest(ctx context.Context) (*http.Request, error) {
+	if spec.Operation != "" {
+		ctx = withOperation(ctx, spec.Operation, spec.Path)
+	}
+	var body io.Reader
+	if spec.Body != "" {
+		body = strings.NewReader(spec.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, spec.Method, spec.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range spec.Header {
+		req.Header[key] = values
+	}
+	if spec.ContentType != "" {
+		req.Header.Set("Content-Type", spec.ContentType)
+	}
+	if spec.UserAgent != "" {
+		req.Header.Set("User-Agent", spec.UserAgent)
+	}
+	return req, nil
+}
+
+// staticGenerator sends the same request every time
+type staticGenerator struct {
+	spec requestSpec
+}
+
+func (g staticGenerator) Next(ctx context.Context) (*http.Request, error) {
+	return g.spec.newRequest(ctx)
+}
+
+// templateFuncs are available in request templates
+var templateFuncs = template.FuncMap{
+	"randInt": func(min, max int) int {
+		if max <= min {
+			return min
+		}
+		return min + mathrand.Intn(max-min+1)
+	},
+	"uuid": newUUID,
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	},
+	"unix": func() int64 {
+		return time.Now().Unix()
+	},
+}
+
+// isTemplate reports whether s uses template actions
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// templateShorthands are spelled like functions, but stand for fields of
+// templateData
+var templateShorthands = strings.NewReplacer(
+	"{{workerID}}", "{{.Worker}}",
+	"{{requestID}}", "{{.RequestID}}",
+)
+
+// parseTemplate parses a request template, expanding the shorthands
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(templateShorthands.Replace(text))
+}
+
+// requestTemplate is a requestSpec whose URL, body and header values are
+// text/template templates. Besides templateFuncs, templates can use
+// {{.Seq}} (a run-wide request counter), {{.Worker}}, and the columns
+// of the current feed row as {{.Row.column}}. {{.RequestID}} is the
+// request's unique id, as sent in X-Request-ID with -request-id;
+// {{workerID}} and {{requestID}} are shorthands. Scenario steps can also
+// use the variables their virtual user extracted as {{.Vars.name}}.
+type requestTemplate struct {
+	spec   requestSpec
+	url    *template.Template
+	body   *template.Template
+	header map[string][]*template.Template
+}
+
+// templateData is the data a request template is executed with
+type templateData struct {
+	Seq       int64
+	Worker    int
+	RequestID string
+	Row       map[string]string
+	Vars      map[string]string
+}
+
+func parseRequestTemplate(spec requestSpec) (*requestTemplate, error) {
+	t := &requestTemplate{spec: spec, header: make(map[string][]*template.Template)}
+	var err error
+	if t.url, err = parseTemplate("url", spec.URL); err != nil {
+		return nil, err
+	}
+	if t.body, err = parseTemplate("body", spec.Body); err != nil {
+		return nil, err
+	}
+	for key, values := range spec.Header {
+		for _, value := range values {
+			tmpl, err := parseTemplate(key, value)
+			if err != nil {
+				return nil, err
+			}
+			t.header[key] = append(t.header[key], tmpl)
+		}
+	}
+	return t, nil
+}
+
+// render executes the templates into a concrete requestSpec
+func (t *requestTemplate) render(data templateData) (requestSpec, error) {
+	spec := t.spec
+	var b strings.Builder
+	if err := t.url.Execute(&b, data); err != nil {
+		return spec, err
+	}
+	spec.URL = b.String()
+
+	b.Reset()
+	if err := t.body.Execute(&b, data); err != nil {
+		return spec, err
+	}
+	spec.Body = b.String()
+
+	spec.Header = make(http.Header, len(t.header))
+	for key, templates := range t.header {
+		for _, tmpl := range templates {
+			b.Reset()
+			if err := tmpl.Execute(&b, data); err != nil {
+				return spec, err
+			}
+			spec.Header.Add(key, b.String())
+		}
+	}
+	return spec, nil
+}
+
+// templateGenerator renders a request template for every request, with
+// the next feed row when a feed is given
+type templateGenerator struct {
+	tmpl *requestTemplate
+	feed *feed
+	seq  int64
+}
+
+func newTemplateGenerator(spec requestSpec, f *feed) (*templateGenerator, error) {
+	tmpl, err := parseRequestTemplate(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &templateGenerator{tmpl: tmpl, feed: f}, nil
+}
+
+func (g *templateGenerator) Next(ctx context.Context) (*http.Request, error) {
+	data := templateData{Seq: atomic.AddInt64(&g.seq, 1), Worker: workerFrom(ctx), RequestID: requestIDFrom(ctx)}
+	if g.feed != nil {
+		row, err := g.feed.next(data.Worker)
+		if err != nil {
+			return nil, err
+		}
+		data.Row = row
+		ctx = withFeedRow(ctx, data.Row)
+	}
+	spec, err := g.tmpl.render(data)
+	if err != nil {
+		return nil, err
+	}
+	if g.feed != nil && g.feed.overrides {
+		spec = overrideRequest(spec, data.Row)
+	}
+	return spec.newRequest(ctx)
+}
+
+// feed supplies rows from a CSV file with a header row to request
+// templates, cycling back to the first row at the end, or stopping there
+// with once set. With partitions, the workers are split into that many
+// groups with disjoint rows, so no row is used by two workers.
+type feed struct {
+	mu   sync.Mutex
+	rows []map[string]string
+	pos  int
+
+	// overrides is set when the feed has override columns, see
+	// overrideRequest
+	overrides bool
+
+	once       bool
+	partitions int
+	positions  map[int]int // per partition
+
+	// consumed counts the rows handed out, and used the distinct ones
+	consumed int64
+	used     []bool
+	distinct int
+}
+
+// FeedStats describes the use of the -feed rows: Consumed counts the
+// rows requests were rendered from, and Used the distinct ones, which
+// with -feed-end stop are the same. Exhausted is set when -feed-end stop
+// ran out of rows.
+type FeedStats struct {
+	Rows      int   `json:"rows"`
+	Consumed  int64 `json:"consumed"`
+	Used      int   `json:"used"`
+	Exhausted bool  `json:"exhausted"`
+}
+
+// overrideColumn starts the names of the feed columns that override the
+// request rather than fill templates
+const overrideColumn = "@"
+
+// checkOverrideColumn rejects an override column other than @method,
+// @path, @body, @name or @header:Name
+func checkOverrideColumn(column string) error {
+	switch column {
+	case "@method", "@path", "@body", "@name":
+		return nil
+	}
+	if name, ok := strings.CutPrefix(column, "@header:"); ok && strings.TrimSpace(name) != "" {
+		return nil
+	}
+	return fmt.Errorf("unknown override column %q, expected @method, @path, @body, @name or @header:Name", column)
+}
+
+// overrideRequest applies the override columns of a feed row to a
+// rendered request: @method, @path (relative to -uri, as scenario paths,
+// or a full URL), @body, @header:Name and @name, which reports the
+// request as an operation. Their values are sent as they are, and empty
+// cells leave the request unchanged.
+func overrideRequest(spec requestSpec, row map[string]string) requestSpec {
+	for column, value := range row {
+		if value == "" || !strings.HasPrefix(column, overrideColumn) {
+			continue
+		}
+		switch column {
+		case "@method":
+			spec.Method = value
+		case "@path":
+			spec.URL = replayURL(spec.URL, value)
+			spec.Path = value
+		case "@body":
+			spec.Body = value
+		case "@name":
+			spec.Operation = value
+		default:
+			if spec.Header == nil {
+				spec.Header = make(http.Header)
+			}
+			spec.Header.Set(strings.TrimSpace(strings.TrimPrefix(column, "@header:")), value)
+		}
+	}
+	return spec
+}
+
+func loadFeed(path string) (*feed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s needs a header row and at least one data row", path)
+	}
+
+	header := records[0]
+	f := &feed{}
+	for _, column := range header {
+		if strings.HasPrefix(column, overrideColumn) {
+			if err := checkOverrideColumn(column); err != nil {
+				return nil, err
+			}
+			f.overrides = true
+		}
+	}
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		f.rows = append(f.rows, row)
+	}
+	return f, nil
+}
+
+// shard keeps the rows of shard i of n (from 1), every nth row, so
+// generators given different shards of a file never send the same row
+func (f *feed) shard(i, n int) error {
+	var rows []map[string]string
+	for j := i - 1; j < len(f.rows); j += n {
+		rows = append(rows, f.rows[j])
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("shard %d/%d has no rows of %d", i, n, len(f.rows))
+	}
+	f.rows = rows
+	return nil
+}
+
+// partition splits the rows between n groups of workers: worker w uses
+// every nth row from row w%n
+func (f *feed) partition(n int) error {
+	if n > len(f.rows) {
+		return fmt.Errorf("%d rows can't be split between %d workers", len(f.rows), n)
+	}
+	f.partitions = n
+	f.positions = make(map[int]int, n)
+	return nil
+}
+
+// parseFeedShard parses a -feed-shard i/n
+func parseFeedShard(s string) (int, int, error) {
+	a, b, ok := strings.Cut(s, "/")
+	i, err1 := strconv.Atoi(a)
+	n, err2 := strconv.Atoi(b)
+	if !ok || err1 != nil || err2 != nil || n < 1 || i < 1 || i > n {
+		return 0, 0, fmt.Errorf("expected i/n with 1 <= i <= n, e.g. 2/4, got %q", s)
+	}
+	return i, n, nil
+}
+
+// next returns the next row for a worker (-1 for requests not tied to
+// one). At the end of the rows, a feed used once returns io.EOF, or
+// errWorkerDone when the worker's partition ran out.
+func (f *feed) next(worker int) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.partitions == 0 || worker < 0 {
+		if f.pos == len(f.rows) {
+			if f.once {
+				return nil, io.EOF
+			}
+			f.pos = 0
+		}
+		f.take(f.pos)
+		f.pos++
+		return f.rows[f.pos-1], nil
+	}
+	part := worker % f.partitions
+	size := (len(f.rows) - part + f.partitions - 1) / f.partitions
+	i := f.positions[part]
+	if i == size {
+		if f.once {
+			return nil, errWorkerDone
+		}
+		i = 0
+	}
+	f.positions[part] = i + 1
+	f.take(part + i*f.partitions)
+	return f.rows[part+i*f.partitions], nil
+}
+
+// take counts row i as consumed; f.mu is held
+func (f *feed) take(i int) {
+	if f.used == nil {
+		f.used = make([]bool, len(f.rows))
+	}
+	f.consumed++
+	if !f.used[i] {
+		f.used[i] = true
+		f.distinct++
+	}
+}
+
+func (f *feed) summary() *FeedStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &FeedStats{
+		Rows:      len(f.rows),
+		Consumed:  f.consumed,
+		Used:      f.distinct,
+		Exhausted: f.once && f.distinct == len(f.rows),
+	}
+}
+
+// scenarioStep is one request of a scenario file
+type scenarioStep struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+	Checks  []scenarioCheck   `json:"checks"`
+
+	// Extract maps variable names to where their value is taken from in
+	// the response, see parseExtraction
+	Extract   map[string]string `json:"extract"`
+	ThinkTime string            `json:"thinkTime"`
+}
+
+// scenarioGenerator sends a fixed sequence of requests, in order, on each
+// connection, or virtual user. Steps are templates, and are reported as
+// operations. With a feed, each pass through the steps uses the next feed
+// row. Each worker keeps the variables its steps extract from responses,
+// and pauses for the steps' think time. Setup and teardown steps are sent
+// once per worker around its loop, outside of the statistics. A file
+// with a weighted mix of scenarios assigns each worker one of them (see
+// mix.go); steps holds the steps of all of them.
+type scenarioGenerator struct {
+	steps    []scenarioRequest
+	setup    []scenarioRequest
+	teardown []scenarioRequest
+	mix      []*scenarioShare
+	mixed    bool // the file defines named scenarios
+	feed     *feed
+	seq      int64
+	checks   *checkTracker // nil without checks
+
+	mu       sync.Mutex
+	assigned map[int]*scenarioShare    // scenario per worker
+	position map[int]int               // next step of its scenario per worker
+	last     map[int]int               // last step sent per worker
+	rows     map[int]map[string]string // feed row per worker
+	vars     map[int]map[string]string // extracted variables per worker
+}
+
+// scenarioRequest is a parsed scenario step
+type scenarioRequest struct {
+	tmpl    *requestTemplate
+	extract []extraction
+	think   thinkTime
+}
+
+// loadScenario reads a scenario file: {"steps": [{"name", "method",
+// "path", "body", "headers", "checks", "extract", "thinkTime"}, ...],
+// "setup": [...], "teardown": [...], "threshold", "thinkTime"}, with
+// paths relative to baseURI. A step's thinkTime overrides the file's.
+// Instead of steps, a file can define a weighted mix of "scenarios", each
+// {"name", "weight", "steps", "thinkTime"}, whose step names are prefixed
+// with the scenario's.
+func loadScenario(path, baseURI string, f *feed) (*scenarioGenerator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Steps     []scenarioStep  `json:"steps"`
+		Scenarios []scenarioEntry `json:"scenarios"`
+		Setup     []scenarioStep  `json:"setup"`
+		Teardown  []scenarioStep  `json:"teardown"`
+		Threshold float64         `json:"threshold"`
+		ThinkTime string          `json:"thinkTime"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	entries := file.Scenarios
+	switch {
+	case len(entries) > 0 && len(file.Steps) > 0:
+		return nil, fmt.Errorf("%s defines both steps and scenarios", path)
+	case len(entries) == 0 && len(file.Steps) == 0:
+		return nil, fmt.Errorf("%s defines no steps", path)
+	case len(entries) == 0:
+		entries = []scenarioEntry{{Weight: 1, Steps: file.Steps}}
+	}
+
+	defaultThink, err := parseThinkTime(file.ThinkTime)
+	if err != nil {
+		return nil, fmt.Errorf("thinkTime: %v", err)
+	}
+	g := &scenarioGenerator{
+		mixed:    len(file.Scenarios) > 0,
+		feed:     f,
+		assigned: make(map[int]*scenarioShare),
+		position: make(map[int]int),
+		last:     make(map[int]int),
+		rows:     make(map[int]map[string]string),
+		vars:     make(map[int]map[string]string),
+	}
+	var measured []scenarioStep
+	for _, entry := range entries {
+		share, err := g.addScenario(entry, baseURI, defaultThink)
+		if err != nil {
+			return nil, err
+		}
+		measured = append(measured, entry.Steps...)
+		g.mix = append(g.mix, share)
+	}
+	if g.setup, err = parseScenarioSteps(file.Setup, "setup ", "", baseURI, defaultThink); err != nil {
+		return nil, err
+	}
+	if g.teardown, err = parseScenarioSteps(file.Teardown, "teardown ", "", baseURI, defaultThink); err != nil {
+		return nil, err
+	}
+	if g.checks, err = newCheckTracker(measured, file.Threshold); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// parseScenarioSteps fills in the defaults of steps, naming those without
+// a name after their position with the label (e.g. "setup ") and
+// prefixing all names with the scenario's, and parses them. Only the
+// measured steps, without a label, can have checks.
+func parseScenarioSteps(steps []scenarioStep, label, scenario, baseURI string, defaultThink thinkTime) ([]scenarioRequest, error) {
+	var requests []scenarioRequest
+	for i := range steps {
+		step := &steps[i]
+		if step.Method == "" {
+			step.Method = "GET"
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%s%d %s %s", label, i+1, step.Method, step.Path)
+		}
+		if scenario != "" {
+			step.Name = scenario + ": " + step.Name
+		}
+		if label != "" && len(step.Checks) > 0 {
+			return nil, fmt.Errorf("step %q: checks only apply to the measured steps", step.Name)
+		}
+		request, err := parseScenarioStep(*step, baseURI, defaultThink)
+		if err != nil {
+			return nil, fmt.Errorf("step %q: %v", step.Name, err)
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// parseScenarioStep parses a step whose defaults are filled in
+func parseScenarioStep(step scenarioStep, baseURI string, defaultThink thinkTime) (scenarioRequest, error) {
+	spec := requestSpec{
+		Method:    strings.ToUpper(step.Method),
+		URL:       replayURL(baseURI, step.Path),
+		Body:      step.Body,
+		Header:    http.Header{},
+		Operation: step.Name,
+		Path:      step.Path,
+	}
+	for key, value := range step.Headers {
+		spec.Header.Set(key, value)
+	}
+	tmpl, err := parseRequestTemplate(spec)
+	if err != nil {
+		return scenarioRequest{}, err
+	}
+	request := scenarioRequest{tmpl: tmpl, think: defaultThink}
+	if step.ThinkTime != "" {
+		if request.think, err = parseThinkTime(step.ThinkTime); err != nil {
+			return scenarioRequest{}, fmt.Errorf("thinkTime: %v", err)
+		}
+	}
+	for name, source := range step.Extract {
+		e, err := parseExtraction(name, source)
+		if err != nil {
+			return scenarioRequest{}, err
+		}
+		request.extract = append(request.extract, e)
+	}
+	return request, nil
+}
+
+// operations lists the scenario steps for the per-operation statistics
+func (g *scenarioGenerator) operations() []requestSpec {
+	specs := make([]requestSpec, len(g.steps))
+	for i, step := range g.steps {
+		specs[i] = step.tmpl.spec
+	}
+	return specs
+}
+
+func (g *scenarioGenerator) Next(ctx context.Context) (*http.Request, error) {
+	worker := workerFrom(ctx)
+	g.mu.Lock()
+	position := g.position[worker]
+	// A worker that logged in during setup stays the same user
+	if position == 0 && g.feed != nil && (len(g.setup) == 0 || g.rows[worker] == nil) {
+		row, err := g.feed.next(worker)
+		if err != nil {
+			g.mu.Unlock()
+			return nil, err
+		}
+		g.rows[worker] = row
+	}
+	share := g.scenarioOf(worker, position)
+	g.position[worker] = (position + 1) % share.count
+	step := share.first + position
+	g.last[worker] = step
+	g.mu.Unlock()
+
+	return g.render(ctx, worker, g.steps[step], step)
+}
+
+// render builds a step's request with the worker's feed row and variables
+func (g *scenarioGenerator) render(ctx context.Context, worker int, step scenarioRequest, position int) (*http.Request, error) {
+	g.mu.Lock()
+	row := g.rows[worker]
+	vars := make(map[string]string, len(g.vars[worker]))
+	for name, value := range g.vars[worker] {
+		vars[name] = value
+	}
+	g.mu.Unlock()
+
+	data := templateData{Seq: atomic.AddInt64(&g.seq, 1), Worker: worker, RequestID: requestIDFrom(ctx), Row: row, Vars: vars}
+	spec, err := step.tmpl.render(data)
+	if err != nil {
+		return nil, err
+	}
+	if row != nil {
+		ctx = withFeedRow(ctx, row)
+	}
+	return spec.newRequest(withScenarioStep(ctx, position))
+}
+
+// observe extracts the variables of the request's step from its response
+func (g *scenarioGenerator) observe(req *http.Request, header http.Header, body []byte) {
+	step, ok := scenarioStepFrom(req.Context())
+	if !ok {
+		return
+	}
+	g.extract(workerFrom(req.Context()), g.steps[step], header, body)
+}
+
+// extract stores the variables a step extracts from its response
+func (g *scenarioGenerator) extract(worker int, step scenarioRequest, header http.Header, body []byte) {
+	if len(step.extract) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, e := range step.extract {
+		if value, ok := e.extract(header, body); ok {
+			if g.vars[worker] == nil {
+				g.vars[worker] = make(map[string]string)
+			}
+			g.vars[worker][e.name] = value
+		}
+	}
+}
+
+// thinkTime returns the pause after the step the worker sent last
+func (g *scenarioGenerator) thinkTime(worker int) time.Duration {
+	g.mu.Lock()
+	step := g.last[worker]
+	g.mu.Unlock()
+	return g.steps[step].think.next()
+}
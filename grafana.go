@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// grafanaPanel describes one time series panel of the generated dashboard
+type grafanaPanel struct {
+	title   string
+	unit    string
+	targets []grafanaTarget
+}
+
+// grafanaTarget is a query of a panel with its legend
+type grafanaTarget struct {
+	expr   string
+	legend string
+}
+
+// runGrafanaReport implements "report grafana", printing a Grafana
+// dashboard for the metrics exported with -prometheus-file or -statsd
+func runGrafanaReport(args []string) {
+	fs := flag.NewFlagSet("report grafana", flag.ExitOnError)
+	datasource := fs.String("datasource", "prometheus", "The metrics to chart: prometheus (-prometheus-file) or graphite (-statsd through a StatsD Graphite backend)")
+	statsdPrefix := fs.String("statsd-prefix", "autocannon", "The -statsd-prefix of the runs, for the graphite datasource")
+	title := fs.String("title", "autocannon", "The dashboard title")
+	output := fs.String("output", "", "Write the dashboard to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon report grafana [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var panels []grafanaPanel
+	var templating []map[string]any
+	switch *datasource {
+	case "prometheus":
+		panels = prometheusPanels()
+		templating = []map[string]any{{
+			"name":       "uri",
+			"label":      "URI",
+			"type":       "query",
+			"datasource": map[string]any{"type": "prometheus", "uid": "${datasource}"},
+			"query":      "label_values(autocannon_requests_per_second, uri)",
+			"refresh":    2,
+			"multi":      true,
+			"includeAll": true,
+			"current":    map[string]any{"text": "All", "value": "$__all"},
+		}}
+	case "graphite":
+		panels = graphitePanels(*statsdPrefix)
+	default:
+		fmt.Printf("Unknown datasource %q, expected prometheus or graphite.\n", *datasource)
+		os.Exit(exitConfigError)
+	}
+
+	dashboard := grafanaDashboard(*title, *datasource, panels, templating)
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding dashboard: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", *output, err)
+		os.Exit(exitConfigError)
+	}
+	fmt.Fprintf(os.Stderr, "Dashboard written to %s\n", *output)
+}
+
+// prometheusPanels chart the per-run gauges of the -prometheus-file
+// textfile, so each point is one run
+func prometheusPanels() []grafanaPanel {
+	filter := `{uri=~"$uri"}`
+	return []grafanaPanel{
+		{"Requests/sec", "reqps", []grafanaTarget{{"autocannon_requests_per_second" + filter, "{{method}} {{uri}}"}}},
+		{"Latency", "ms", []grafanaTarget{{`autocannon_latency_milliseconds{uri=~"$uri",stat=~"average|p.+"}`, "{{stat}} {{uri}}"}}},
+		{"Error Rate", "percent", []grafanaTarget{{"autocannon_error_rate" + filter, "{{method}} {{uri}}"}}},
+		{"Requests by Outcome", "short", []grafanaTarget{{"autocannon_requests" + filter, "{{outcome}} {{uri}}"}}},
+		{"Responses by Status Code", "short", []grafanaTarget{{"autocannon_responses" + filter, "{{code}} {{uri}}"}}},
+		{"Data Transferred", "bytes", []grafanaTarget{
+			{"autocannon_bytes_read" + filter, "read {{uri}}"},
+			{"autocannon_bytes_written" + filter, "written {{uri}}"},
+		}},
+	}
+}
+
+// graphitePanels chart the live -statsd metrics with the names the StatsD
+// Graphite backend gives them: counters as per-second rates under stats.
+// and timers under stats.timers.
+func graphitePanels(prefix string) []grafanaPanel {
+	// Node of the status code in stats.<prefix>.status.<code>
+	codeNode := strings.Count(prefix, ".") + 3
+	return []grafanaPanel{
+		{"Requests/sec", "reqps", []grafanaTarget{{fmt.Sprintf("alias(stats.%s.requests, 'requests')", prefix), ""}}},
+		{"Latency", "ms", []grafanaTarget{
+			{fmt.Sprintf("alias(stats.timers.%s.latency.mean, 'mean')", prefix), ""},
+			{fmt.Sprintf("alias(stats.timers.%s.latency.upper_90, 'p90')", prefix), ""},
+			{fmt.Sprintf("alias(stats.timers.%s.latency.upper, 'max')", prefix), ""},
+		}},
+		{"Errors/sec", "reqps", []grafanaTarget{{fmt.Sprintf("alias(stats.%s.errors, 'errors')", prefix), ""}}},
+		{"Responses/sec by Status Code", "reqps", []grafanaTarget{{fmt.Sprintf("aliasByNode(stats.%s.status.*, %d)", prefix, codeNode), ""}}},
+	}
+}
+
+// grafanaDashboard lays out the panels two per row in an importable
+// dashboard with a datasource variable
+func grafanaDashboard(title, datasource string, panels []grafanaPanel, templating []map[string]any) map[string]any {
+	ds := map[string]any{"type": datasource, "uid": "${datasource}"}
+	var list []any
+	for i, p := range panels {
+		var targets []any
+		for j, t := range p.targets {
+			target := map[string]any{"refId": string(rune('A' + j)), "datasource": ds}
+			if datasource == "prometheus" {
+				target["expr"] = t.expr
+				target["legendFormat"] = t.legend
+			} else {
+				target["target"] = t.expr
+			}
+			targets = append(targets, target)
+		}
+		list = append(list, map[string]any{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      p.title,
+			"datasource": ds,
+			"gridPos":    map[string]any{"x": (i % 2) * 12, "y": (i / 2) * 8, "w": 12, "h": 8},
+			"fieldConfig": map[string]any{
+				"defaults":  map[string]any{"unit": p.unit},
+				"overrides": []any{},
+			},
+			"targets": targets,
+		})
+	}
+
+	variables := []any{map[string]any{
+		"name":  "datasource",
+		"label": "Data source",
+		"type":  "datasource",
+		"query": datasource,
+	}}
+	for _, v := range templating {
+		variables = append(variables, v)
+	}
+
+	return map[string]any{
+		"title":         title,
+		"tags":          []string{"autocannon"},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"time":          map[string]any{"from": "now-24h", "to": "now"},
+		"refresh":       "30s",
+		"templating":    map[string]any{"list": variables},
+		"panels":        list,
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// HdrHistogram V2 encoding cookies (the 0x10 marks zigzag LEB128 counts)
+const (
+	hdrEncodingCookie           = 0x1c849303 | 0x10
+	hdrCompressedEncodingCookie = 0x1c849304 | 0x10
+)
+
+// hdrValueUnitRatio converts recorded microseconds into the milliseconds
+// written to HdrHistogram files
+const hdrValueUnitRatio = 1000.0
+
+// encodeCompressed returns the histogram in the compressed V2 encoding used
+// by HdrHistogram interval logs
+func (h *histogram) encodeCompressed() ([]byte, error) {
+	// Counts up to the highest recorded value, with runs of zeros written
+	// as a single negative run length
+	var payload []byte
+	limit := int64(0)
+	if h.totalCount > 0 {
+		limit = h.countsIndexFor(h.max) + 1
+	}
+	for i := int64(0); i < limit; {
+		count := h.counts[i]
+		i++
+		if count == 0 {
+			zeros := int64(1)
+			for i < limit && h.counts[i] == 0 {
+				zeros++
+				i++
+			}
+			if zeros > 1 {
+				payload = appendZigZag(payload, -zeros)
+				continue
+			}
+		}
+		payload = appendZigZag(payload, count)
+	}
+
+	var encoded bytes.Buffer
+	binary.Write(&encoded, binary.BigEndian, int32(hdrEncodingCookie))
+	binary.Write(&encoded, binary.BigEndian, int32(len(payload)))
+	binary.Write(&encoded, binary.BigEndian, int32(0)) // normalizing index offset
+	binary.Write(&encoded, binary.BigEndian, int32(h.significantFigures))
+	binary.Write(&encoded, binary.BigEndian, h.lowestTrackable)
+	binary.Write(&encoded, binary.BigEndian, h.highestTrackable)
+	binary.Write(&encoded, binary.BigEndian, float64(1)) // integer to double conversion ratio
+	encoded.Write(payload)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(encoded.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, int32(hdrCompressedEncodingCookie))
+	binary.Write(&out, binary.BigEndian, int32(compressed.Len()))
+	out.Write(compressed.Bytes())
+	return out.Bytes(), nil
+}
+
+// decodeCompressed reads a histogram in the compressed V2 encoding of
+// encodeCompressed
+func decodeCompressed(data []byte) (*histogram, error) {
+	var header struct {
+		Cookie int32
+		Length int32
+	}
+	reader := bytes.NewReader(data)
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Cookie != hdrCompressedEncodingCookie {
+		return nil, fmt.Errorf("not a compressed HdrHistogram")
+	}
+	zr, err := zlib.NewReader(io.LimitReader(reader, int64(header.Length)))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var encoded struct {
+		Cookie             int32
+		PayloadLength      int32
+		NormalizingOffset  int32
+		SignificantFigures int32
+		Lowest             int64
+		Highest            int64
+		Ratio              float64
+	}
+	if err := binary.Read(zr, binary.BigEndian, &encoded); err != nil {
+		return nil, err
+	}
+	if encoded.Cookie != hdrEncodingCookie || encoded.Lowest != 1 || encoded.NormalizingOffset != 0 ||
+		encoded.SignificantFigures < 1 || encoded.SignificantFigures > 5 || encoded.Highest < 2 {
+		return nil, fmt.Errorf("unsupported HdrHistogram encoding")
+	}
+	payload := make([]byte, encoded.PayloadLength)
+	if _, err := io.ReadFull(zr, payload); err != nil {
+		return nil, err
+	}
+
+	h := newHistogram(encoded.Highest, int64(encoded.SignificantFigures))
+	index := int64(0)
+	for len(payload) > 0 {
+		v, n := readZigZag(payload)
+		if n == 0 {
+			return nil, fmt.Errorf("truncated HdrHistogram payload")
+		}
+		payload = payload[n:]
+		if v < 0 {
+			index -= v
+			continue
+		}
+		if index >= int64(len(h.counts)) {
+			return nil, fmt.Errorf("HdrHistogram payload exceeds its range")
+		}
+		if v > 0 {
+			value := h.valueAt(index)
+			if h.totalCount == 0 {
+				h.min = value
+			}
+			h.max = h.highestEquivalent(value)
+			h.counts[index] = v
+			h.totalCount += v
+		}
+		index++
+	}
+	return h, nil
+}
+
+// readZigZag reads a zigzag LEB128 varint of appendZigZag, returning the
+// number of bytes read, 0 when buf is too short
+func readZigZag(buf []byte) (int64, int) {
+	var u uint64
+	for i := 0; i < 9 && i < len(buf); i++ {
+		if i == 8 {
+			u |= uint64(buf[i]) << 56
+			return int64(u>>1) ^ -int64(u&1), 9
+		}
+		u |= uint64(buf[i]&0x7f) << (7 * i)
+		if buf[i]&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), i + 1
+		}
+	}
+	return 0, 0
+}
+
+// appendZigZag appends v as a zigzag LEB128 varint of at most 9 bytes
+func appendZigZag(buf []byte, v int64) []byte {
+	u := uint64((v << 1) ^ (v >> 63))
+	for i := 0; i < 8; i++ {
+		if u>>7 == 0 {
+			return append(buf, byte(u))
+		}
+		buf = append(buf, byte(u&0x7f|0x80))
+		u >>= 7
+	}
+	return append(buf, byte(u))
+}
+
+// hdrLogWriter writes an HdrHistogram interval log (.hlog)
+type hdrLogWriter struct {
+	file  *os.File
+	start time.Time
+}
+
+func newHdrLogWriter(path string, start time.Time) (*hdrLogWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	startSeconds := float64(start.UnixNano()) / 1e9
+	fmt.Fprintf(file, "#[Histogram log format version 1.3]\n")
+	fmt.Fprintf(file, "#[StartTime: %.3f (seconds since epoch), %s]\n", startSeconds, start.Format(time.UnixDate))
+	fmt.Fprintf(file, "#[BaseTime: %.3f (seconds since epoch)]\n", startSeconds)
+	fmt.Fprintf(file, "\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n")
+	return &hdrLogWriter{file: file, start: start}, nil
+}
+
+// writeInterval appends one interval histogram covering [from, to)
+func (w *hdrLogWriter) writeInterval(h *histogram, from, to time.Time) error {
+	encoded, err := h.encodeCompressed()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w.file, "%.3f,%.3f,%.3f,%s\n",
+		from.Sub(w.start).Seconds(), to.Sub(from).Seconds(),
+		float64(h.max)/hdrValueUnitRatio, base64.StdEncoding.EncodeToString(encoded))
+	return err
+}
+
+func (w *hdrLogWriter) Close() error {
+	return w.file.Close()
+}
+
+// writePercentileDistribution writes the histogram in HdrHistogram's
+// percentile distribution format (.hgrm), with values in milliseconds
+func (h *histogram) writePercentileDistribution(w io.Writer) {
+	const ticksPerHalfDistance = 5
+
+	fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+	if h.totalCount > 0 {
+		level := 0.0
+		var cumulative int64
+		for i, count := range h.counts {
+			if count == 0 {
+				continue
+			}
+			cumulative += count
+			value := float64(h.highestEquivalent(h.valueAt(int64(i)))) / hdrValueUnitRatio
+			for 100*float64(cumulative)/float64(h.totalCount) >= level {
+				fmt.Fprintf(w, "%12.3f %2.12f %10d %14.2f\n", value, level/100, cumulative, 1/(1-level/100))
+
+				reportingTicks := ticksPerHalfDistance * int64(math.Pow(2, math.Floor(math.Log2(100/(100-level)))+1))
+				level += 100 / float64(reportingTicks)
+
+				// Only one line for the final value; the 100% line follows
+				if cumulative == h.totalCount {
+					break
+				}
+			}
+		}
+		fmt.Fprintf(w, "%12.3f %2.12f %10d\n", float64(h.highestEquivalent(h.max))/hdrValueUnitRatio, 1.0, h.totalCount)
+	}
+
+	fmt.Fprintf(w, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", h.mean()/hdrValueUnitRatio, h.stdDev()/hdrValueUnitRatio)
+	fmt.Fprintf(w, "#[Max     = %12.3f, Total count    = %12d]\n", float64(h.max)/hdrValueUnitRatio, h.totalCount)
+	fmt.Fprintf(w, "#[Buckets = %12d, SubBuckets     = %12d]\n", h.bucketCount, h.subBucketCount)
+}
+
+// writePercentileFile writes the percentile distribution to path
+func writePercentileFile(h *histogram, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	h.writePercentileDistribution(file)
+	return file.Close()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// TestZigZag checks the varints against the LEB128 zigzag encoding of the
+// HdrHistogram V2 format
+func TestZigZag(t *testing.T) {
+	tests := []struct {
+		value   int64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{-1, []byte{0x01}},
+		{1, []byte{0x02}},
+		{-2, []byte{0x03}},
+		{63, []byte{0x7e}},
+		{-64, []byte{0x7f}},
+		{64, []byte{0x80, 0x01}},
+		{300, []byte{0xd8, 0x04}},
+		{1 << 55, []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}},
+		{-1 << 63, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{1<<63 - 1, []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		encoded := appendZigZag(nil, tt.value)
+		if !bytes.Equal(encoded, tt.encoded) {
+			t.Errorf("appendZigZag(%d) = % x, want % x", tt.value, encoded, tt.encoded)
+		}
+		value, n := readZigZag(tt.encoded)
+		if value != tt.value || n != len(tt.encoded) {
+			t.Errorf("readZigZag(% x) = %d, %d, want %d, %d", tt.encoded, value, n, tt.value, len(tt.encoded))
+		}
+	}
+	if _, n := readZigZag([]byte{0x80, 0x80}); n != 0 {
+		t.Errorf("readZigZag of a truncated varint read %d bytes, want 0", n)
+	}
+}
+
+// TestHdrHeader checks the cookies and header fields of the compressed V2
+// encoding
+func TestHdrHeader(t *testing.T) {
+	h := newLatencyHistogram()
+	h.record(1500)
+	data, err := h.encodeCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookie := binary.BigEndian.Uint32(data); cookie != 0x1c849314 {
+		t.Errorf("compressed cookie %#x, want 0x1c849314", cookie)
+	}
+	if length := int(binary.BigEndian.Uint32(data[4:])); length != len(data)-8 {
+		t.Errorf("compressed length %d, want %d", length, len(data)-8)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[8:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	be := binary.BigEndian
+	if cookie := be.Uint32(encoded); cookie != 0x1c849313 {
+		t.Errorf("encoding cookie %#x, want 0x1c849313", cookie)
+	}
+	if length := int(be.Uint32(encoded[4:])); length != len(encoded)-40 {
+		t.Errorf("payload length %d, want %d", length, len(encoded)-40)
+	}
+	if figures := int64(be.Uint32(encoded[12:])); figures != h.significantFigures {
+		t.Errorf("significant figures %d, want %d", figures, h.significantFigures)
+	}
+	if lowest, highest := int64(be.Uint64(encoded[16:])), int64(be.Uint64(encoded[24:])); lowest != 1 || highest != h.highestTrackable {
+		t.Errorf("trackable range %d-%d, want 1-%d", lowest, highest, h.highestTrackable)
+	}
+}
+
+// TestHdrRoundTrip decodes encoded histograms and compares their counts
+// and percentiles. The encoding keeps counts, not the exact maximum, so
+// percentiles are compared by their bucket.
+func TestHdrRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+	}{
+		{"empty", nil},
+		{"single", []int64{1000}},
+		{"spread", []int64{1, 2, 3, 10, 100, 1000, 1000, 1000, 12345, 999999, 60_000_000}},
+		{"runs", func() []int64 {
+			var values []int64
+			for v := int64(1); v < 5_000_000; v = v*3 + 7 {
+				for i := int64(0); i < v%5+1; i++ {
+					values = append(values, v)
+				}
+			}
+			return values
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newLatencyHistogram()
+			for _, v := range tt.values {
+				h.record(v)
+			}
+			data, err := h.encodeCompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeCompressed(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.totalCount != h.totalCount {
+				t.Fatalf("decoded %d values, want %d", decoded.totalCount, h.totalCount)
+			}
+			for i, count := range h.counts {
+				if decoded.counts[i] != count {
+					t.Fatalf("count %d (value %d) is %d, want %d", i, h.valueAt(int64(i)), decoded.counts[i], count)
+				}
+			}
+			for _, p := range []float64{0, 50, 90, 99, 99.9, 100} {
+				if got, want := decoded.valueAtPercentile(p), h.valueAtPercentile(p); h.lowestEquivalent(got) != h.lowestEquivalent(want) {
+					t.Errorf("p%g = %d, want %d", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestHdrDecodeInvalid checks that other data is rejected
+func TestHdrDecodeInvalid(t *testing.T) {
+	h := newLatencyHistogram()
+	h.record(42)
+	data, err := h.encodeCompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"cookie":    append([]byte{0, 0, 0, 0}, data[4:]...),
+		"truncated": data[:len(data)/2],
+	} {
+		if _, err := decodeCompressed(data); err == nil {
+			t.Errorf("%s: decoded without an error", name)
+		}
+	}
+}
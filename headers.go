@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// version is the autocannon version, set at build time with
+// -ldflags "-X main.version=1.2.3"; go install builds use the module
+// version instead
+var version = "dev"
+
+// defaultUserAgent identifies the tool and its version to the server
+func defaultUserAgent() string {
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = strings.TrimPrefix(info.Main.Version, "v")
+	}
+	return "autocannon/" + v
+}
+
+// valuePool hands out header values from a list, in round-robin order or
+// at random. It is safe for concurrent use.
+type valuePool struct {
+	values []string
+	random bool
+	pos    uint64
+}
+
+// loadValuePool reads one value per line, skipping blank lines and
+// # comments
+func loadValuePool(path string) (*valuePool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pool := &valuePool{}
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pool.values = append(pool.values, line)
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	if len(pool.values) == 0 {
+		return nil, fmt.Errorf("%s contains no values", path)
+	}
+	return pool, nil
+}
+
+func (p *valuePool) next() string {
+	if p.random {
+		return p.values[rand.Intn(len(p.values))]
+	}
+	i := atomic.AddUint64(&p.pos, 1) - 1
+	return p.values[i%uint64(len(p.values))]
+}
+
+// headerFileFlag collects repeatable -header-file Name=path flags
+type headerFileFlag map[string]string
+
+func (h headerFileFlag) String() string {
+	return formatTags(h)
+}
+
+func (h headerFileFlag) Set(value string) error {
+	name, path, ok := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || path == "" {
+		return fmt.Errorf("header file %q must be in Name=path form", value)
+	}
+	h[http.CanonicalHeaderKey(name)] = path
+	return nil
+}
+
+// contentCodings are the codings -accept-encoding may ask for
+var contentCodings = map[string]bool{
+	"gzip": true, "br": true, "deflate": true, "zstd": true,
+	"compress": true, "identity": true, "*": true,
+}
+
+// validateAcceptEncoding checks an -accept-encoding value: a comma
+// separated list of content codings, each optionally weighted with ;q=
+func validateAcceptEncoding(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		coding, weight, weighted := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if !contentCodings[coding] {
+			return fmt.Errorf("unknown content coding %q, expected gzip, br, deflate, zstd, compress, identity or *", coding)
+		}
+		if weighted {
+			q, ok := strings.CutPrefix(strings.TrimSpace(weight), "q=")
+			if v, err := strconv.ParseFloat(q, 64); !ok || err != nil || v < 0 || v > 1 {
+				return fmt.Errorf("invalid weight %q for %s, expected q= between 0 and 1", weight, coding)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+)
+
+// histogram is a minimal HdrHistogram: values are recorded into buckets
+// with a fixed number of significant digits, using the same bucket layout
+// as the reference implementation so it can be exported in HdrHistogram
+// formats. Latencies are recorded in microseconds. It is not safe for
+// concurrent use.
+type histogram struct {
+	lowestTrackable             int64
+	highestTrackable            int64
+	significantFigures          int64
+	unitMagnitude               int64
+	subBucketHalfCountMagnitude int64
+	subBucketHalfCount          int64
+	subBucketMask               int64
+	subBucketCount              int64
+	bucketCount                 int64
+	counts                      []int64
+	totalCount                  int64
+	min                         int64
+	max                         int64
+}
+
+// newHistogram creates a histogram tracking 1..highest with the given
+// number of significant figures (1-5)
+func newHistogram(highest int64, significantFigures int64) *histogram {
+	lowest := int64(1)
+	largestSingleUnit := 2 * int64(math.Pow10(int(significantFigures)))
+	subBucketCountMagnitude := int64(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	subBucketHalfCountMagnitude := subBucketCountMagnitude - 1
+	unitMagnitude := int64(math.Floor(math.Log2(float64(lowest))))
+	subBucketCount := int64(1) << uint(subBucketCountMagnitude)
+
+	// Number of buckets needed to cover the range
+	smallestUntrackable := subBucketCount << uint(unitMagnitude)
+	bucketCount := int64(1)
+	for smallestUntrackable < highest {
+		if smallestUntrackable > math.MaxInt64/2 {
+			bucketCount++
+			break
+		}
+		smallestUntrackable <<= 1
+		bucketCount++
+	}
+
+	return &histogram{
+		lowestTrackable:             lowest,
+		highestTrackable:            highest,
+		significantFigures:          significantFigures,
+		unitMagnitude:               unitMagnitude,
+		subBucketHalfCountMagnitude: subBucketHalfCountMagnitude,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               (subBucketCount - 1) << uint(unitMagnitude),
+		subBucketCount:              subBucketCount,
+		bucketCount:                 bucketCount,
+		counts:                      make([]int64, (bucketCount+1)*(subBucketCount/2)),
+	}
+}
+
+// newLatencyHistogram tracks latencies from 1µs to one hour at three
+// significant figures
+func newLatencyHistogram() *histogram {
+	return newHistogram(3600*1000*1000, 3)
+}
+
+// record adds a value, clamping it to the trackable range
+func (h *histogram) record(value int64) {
+	if value < 0 {
+		value = 0
+	}
+	if value > h.highestTrackable {
+		value = h.highestTrackable
+	}
+	h.counts[h.countsIndexFor(value)]++
+	if h.totalCount == 0 || value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+	h.totalCount++
+}
+
+// merge adds all of other's counts to h. Both must share a layout.
+func (h *histogram) merge(other *histogram) {
+	if other.totalCount == 0 {
+		return
+	}
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	if h.totalCount == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.totalCount += other.totalCount
+}
+
+// reset clears all recorded values
+func (h *histogram) reset() {
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.totalCount, h.min, h.max = 0, 0, 0
+}
+
+func (h *histogram) bucketIndex(value int64) int64 {
+	pow2Ceiling := int64(64 - bits.LeadingZeros64(uint64(value|h.subBucketMask)))
+	return pow2Ceiling - h.unitMagnitude - (h.subBucketHalfCountMagnitude + 1)
+}
+
+func (h *histogram) subBucketIndex(value, bucketIndex int64) int64 {
+	return value >> uint(bucketIndex+h.unitMagnitude)
+}
+
+func (h *histogram) countsIndex(bucketIndex, subBucketIndex int64) int64 {
+	bucketBaseIndex := (bucketIndex + 1) << uint(h.subBucketHalfCountMagnitude)
+	return bucketBaseIndex + subBucketIndex - h.subBucketHalfCount
+}
+
+func (h *histogram) countsIndexFor(value int64) int64 {
+	bucketIndex := h.bucketIndex(value)
+	return h.countsIndex(bucketIndex, h.subBucketIndex(value, bucketIndex))
+}
+
+// valueAt returns the lowest value that maps to the counts index
+func (h *histogram) valueAt(index int64) int64 {
+	bucketIndex := (index >> uint(h.subBucketHalfCountMagnitude)) - 1
+	subBucketIndex := (index & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIndex < 0 {
+		subBucketIndex -= h.subBucketHalfCount
+		bucketIndex = 0
+	}
+	return subBucketIndex << uint(bucketIndex+h.unitMagnitude)
+}
+
+// equivalentRange is the width of the bucket value falls into
+func (h *histogram) equivalentRange(value int64) int64 {
+	bucketIndex := h.bucketIndex(value)
+	subBucketIndex := h.subBucketIndex(value, bucketIndex)
+	adjustedBucket := bucketIndex
+	if subBucketIndex >= h.subBucketCount {
+		adjustedBucket++
+	}
+	return int64(1) << uint(h.unitMagnitude+adjustedBucket)
+}
+
+func (h *histogram) lowestEquivalent(value int64) int64 {
+	bucketIndex := h.bucketIndex(value)
+	return h.subBucketIndex(value, bucketIndex) << uint(bucketIndex+h.unitMagnitude)
+}
+
+func (h *histogram) highestEquivalent(value int64) int64 {
+	return h.lowestEquivalent(value) + h.equivalentRange(value) - 1
+}
+
+func (h *histogram) medianEquivalent(value int64) int64 {
+	return h.lowestEquivalent(value) + h.equivalentRange(value)>>1
+}
+
+// valueAtPercentile returns the value at or below which the given
+// percentage (0-100) of recorded values fall
+func (h *histogram) valueAtPercentile(percentile float64) int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	countAtPercentile := int64(percentile/100*float64(h.totalCount) + 0.5)
+	if countAtPercentile < 1 {
+		countAtPercentile = 1
+	}
+
+	var cumulative int64
+	for i, count := range h.counts {
+		cumulative += count
+		if cumulative >= countAtPercentile {
+			value := h.highestEquivalent(h.valueAt(int64(i)))
+			if value > h.max {
+				return h.max
+			}
+			return value
+		}
+	}
+	return h.max
+}
+
+// mean returns the mean of the recorded values
+func (h *histogram) mean() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	var total float64
+	for i, count := range h.counts {
+		if count > 0 {
+			total += float64(count) * float64(h.medianEquivalent(h.valueAt(int64(i))))
+		}
+	}
+	return total / float64(h.totalCount)
+}
+
+// stdDev returns the standard deviation of the recorded values
+func (h *histogram) stdDev() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	mean := h.mean()
+	var geometricDevTotal float64
+	for i, count := range h.counts {
+		if count > 0 {
+			dev := float64(h.medianEquivalent(h.valueAt(int64(i)))) - mean
+			geometricDevTotal += dev * dev * float64(count)
+		}
+	}
+	return math.Sqrt(geometricDevTotal / float64(h.totalCount))
+}
+
+// newCountHistogram tracks per-second counts such as requests or bytes
+func newCountHistogram() *histogram {
+	return newHistogram(1<<40, 3)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// runHooks are the requests of a -hooks file, sent once before the run
+// (e.g. to seed a dataset) and once after it (to clean up), outside of
+// the measurement. Variables extracted by setup requests can be used by
+// later setup requests and by the teardown requests.
+type runHooks struct {
+	setup    []scenarioRequest
+	teardown []scenarioRequest
+	vars     map[string]string
+}
+
+// loadRunHooks reads a hooks file: {"setup": [steps], "teardown":
+// [steps]}, with steps as in scenario files and paths relative to baseURI
+func loadRunHooks(path, baseURI string) (*runHooks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Setup    []scenarioStep `json:"setup"`
+		Teardown []scenarioStep `json:"teardown"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if len(file.Setup) == 0 && len(file.Teardown) == 0 {
+		return nil, fmt.Errorf("%s defines no setup or teardown requests", path)
+	}
+
+	h := &runHooks{vars: make(map[string]string)}
+	for _, list := range []struct {
+		steps  []scenarioStep
+		prefix string
+		into   *[]scenarioRequest
+	}{
+		{file.Setup, "setup", &h.setup},
+		{file.Teardown, "teardown", &h.teardown},
+	} {
+		for i, step := range list.steps {
+			if step.Method == "" {
+				step.Method = "GET"
+			}
+			if step.Name == "" {
+				step.Name = fmt.Sprintf("%s %d %s %s", list.prefix, i+1, step.Method, step.Path)
+			}
+			if len(step.Checks) > 0 || step.ThinkTime != "" {
+				return nil, fmt.Errorf("step %q: checks and thinkTime only apply to scenario steps", step.Name)
+			}
+			request, err := parseScenarioStep(step, baseURI, thinkTime{})
+			if err != nil {
+				return nil, fmt.Errorf("step %q: %v", step.Name, err)
+			}
+			*list.into = append(*list.into, request)
+		}
+	}
+	return h, nil
+}
+
+// run sends the requests in order, stopping at the first that fails
+func (h *runHooks) run(client *http.Client, steps []scenarioRequest, headers map[string]string) error {
+	for i, step := range steps {
+		name := step.tmpl.spec.Operation
+		spec, err := step.tmpl.render(templateData{Seq: int64(i + 1), Worker: -1, Vars: h.vars})
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		req, err := spec.newRequest(context.Background())
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		header, body, err := doHookRequest(client, req, headers)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, e := range step.extract {
+			if value, ok := e.extract(header, body); ok {
+				h.vars[e.name] = value
+			}
+		}
+	}
+	return nil
+}
+
+// doHookRequest sends a setup or teardown request with the headers the
+// run adds to every request, and reads its response. A status of 400 or
+// above is an error.
+func doHookRequest(client *http.Client, req *http.Request, headers map[string]string) (http.Header, []byte, error) {
+	for key, value := range headers {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.Header, body, nil
+}
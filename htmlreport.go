@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// heatmapColumns is the most time buckets drawn; longer runs put
+	// several seconds in each column
+	heatmapColumns = 300
+	// heatmapMinLatency is the upper bound of the lowest latency row;
+	// each row above doubles it
+	heatmapMinLatency = 0.1
+	heatmapRows       = 20
+)
+
+// latencyHeatmap counts requests per second of the run and latency row.
+// Latency rows grow exponentially so both sub-millisecond responses and
+// multi-second stalls are visible. It is safe for concurrent use.
+type latencyHeatmap struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[int][]int64 // second -> per-row counts
+}
+
+func newLatencyHeatmap() *latencyHeatmap {
+	return &latencyHeatmap{counts: make(map[int][]int64)}
+}
+
+func (h *latencyHeatmap) record(s Sample) {
+	if s.Start.IsZero() {
+		return
+	}
+	row := 0
+	if s.Latency > heatmapMinLatency {
+		row = int(math.Ceil(math.Log2(s.Latency / heatmapMinLatency)))
+		if row >= heatmapRows {
+			row = heatmapRows - 1
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.start.IsZero() || s.Start.Before(h.start) {
+		// Samples arrive roughly in order; re-base the rare early one
+		if !h.start.IsZero() {
+			shift := int(h.start.Sub(s.Start.Truncate(time.Second)) / time.Second)
+			shifted := make(map[int][]int64, len(h.counts))
+			for second, rows := range h.counts {
+				shifted[second+shift] = rows
+			}
+			h.counts = shifted
+		}
+		h.start = s.Start.Truncate(time.Second)
+	}
+	second := int(s.Start.Sub(h.start) / time.Second)
+	if h.counts[second] == nil {
+		h.counts[second] = make([]int64, heatmapRows)
+	}
+	h.counts[second][row]++
+}
+
+// heatmapCell is one rectangle of the rendered heatmap
+type heatmapCell struct {
+	X, Y, Width, Height float64
+	Fill                string
+	Title               string
+}
+
+// heatmapLabel is an axis label of the rendered heatmap
+type heatmapLabel struct {
+	X, Y float64
+	Text string
+}
+
+// heatmapView is the heatmap laid out for the SVG in htmlReportTemplate
+type heatmapView struct {
+	Width, Height float64
+	PlotX, PlotH  float64
+	Cells         []heatmapCell
+	XLabels       []heatmapLabel
+	YLabels       []heatmapLabel
+	SecondsPerCol int
+	MaxCount      int64
+}
+
+func (h *latencyHeatmap) view() *heatmapView {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.counts) == 0 {
+		return nil
+	}
+
+	seconds := 0
+	for second := range h.counts {
+		if second+1 > seconds {
+			seconds = second + 1
+		}
+	}
+	perColumn := (seconds + heatmapColumns - 1) / heatmapColumns
+	columns := (seconds + perColumn - 1) / perColumn
+
+	grid := make([][]int64, columns)
+	for i := range grid {
+		grid[i] = make([]int64, heatmapRows)
+	}
+	var maxCount int64
+	for second, rows := range h.counts {
+		for row, count := range rows {
+			grid[second/perColumn][row] += count
+			if grid[second/perColumn][row] > maxCount {
+				maxCount = grid[second/perColumn][row]
+			}
+		}
+	}
+
+	// Only draw the latency rows that were used, plus one row of margin
+	lowest, highest := heatmapRows, 0
+	for _, rows := range grid {
+		for row, count := range rows {
+			if count > 0 {
+				lowest = min(lowest, row)
+				highest = max(highest, row)
+			}
+		}
+	}
+	lowest = max(lowest-1, 0)
+	highest = min(highest+1, heatmapRows-1)
+
+	const plotX, plotW, cellH = 80.0, 900.0, 18.0
+	v := &heatmapView{
+		PlotX:         plotX,
+		PlotH:         float64(highest-lowest+1) * cellH,
+		SecondsPerCol: perColumn,
+		MaxCount:      maxCount,
+	}
+	v.Width = plotX + plotW + 20
+	v.Height = v.PlotH + 40
+	cellW := plotW / float64(columns)
+
+	for col, rows := range grid {
+		for row := lowest; row <= highest; row++ {
+			count := rows[row]
+			if count == 0 {
+				continue
+			}
+			v.Cells = append(v.Cells, heatmapCell{
+				X:      plotX + float64(col)*cellW,
+				Y:      float64(highest-row) * cellH,
+				Width:  cellW,
+				Height: cellH,
+				Fill:   heatmapColor(count, maxCount),
+				Title: fmt.Sprintf("%ds-%ds, %s: %d requests", col*perColumn, (col+1)*perColumn,
+					heatmapRowLabel(row), count),
+			})
+		}
+	}
+	for row := lowest; row <= highest; row++ {
+		v.YLabels = append(v.YLabels, heatmapLabel{X: plotX - 6, Y: float64(highest-row)*cellH + cellH*0.7, Text: heatmapRowLabel(row)})
+	}
+	step := max(columns/10, 1)
+	for col := 0; col <= columns; col += step {
+		v.XLabels = append(v.XLabels, heatmapLabel{X: plotX + float64(col)*cellW, Y: v.PlotH + 16, Text: fmt.Sprintf("%ds", col*perColumn)})
+	}
+	return v
+}
+
+// heatmapRowLabel names the upper latency bound of a row
+func heatmapRowLabel(row int) string {
+	bound := heatmapMinLatency * math.Pow(2, float64(row))
+	if row == heatmapRows-1 {
+		return fmt.Sprintf("> %s", formatBound(bound/2))
+	}
+	return "≤ " + formatBound(bound)
+}
+
+func formatBound(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.3g s", ms/1000)
+	}
+	return fmt.Sprintf("%.3g ms", ms)
+}
+
+// heatmapColor shades counts from pale yellow to dark red on a log scale
+func heatmapColor(count, maxCount int64) string {
+	t := 1.0
+	if maxCount > 1 {
+		t = math.Log(float64(count)) / math.Log(float64(maxCount))
+	}
+	r := 255 - 100*t
+	g := 240 - 220*t
+	b := 160 - 140*t
+	return fmt.Sprintf("rgb(%.0f,%.0f,%.0f)", r, g, b)
+}
+
+// htmlSink writes a standalone HTML report with the summary and a
+// latency-over-time heatmap built from the samples
+type htmlSink struct {
+	path    string
+	heatmap *latencyHeatmap
+}
+
+func newHTMLSink(path string) *htmlSink {
+	return &htmlSink{path: path, heatmap: newLatencyHeatmap()}
+}
+
+func (s *htmlSink) Record(sample Sample) {
+	s.heatmap.record(sample)
+}
+
+func (s *htmlSink) Flush(result BenchmarkResult) error {
+	if err := writeHTMLReport(s.path, result, s.heatmap); err != nil {
+		return fmt.Errorf("writing HTML report: %v", err)
+	}
+	return nil
+}
+
+// writeHTMLReport renders the report to path
+func writeHTMLReport(path string, result BenchmarkResult, heatmap *latencyHeatmap) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = renderHTMLReport(file, result, heatmap)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// renderHTMLReport writes the report's HTML to w
+func renderHTMLReport(w io.Writer, result BenchmarkResult, heatmap *latencyHeatmap) error {
+	summary := [][2]string{
+		{"Method", result.Method},
+		{"Connections", fmt.Sprintf("%d", result.Connections)},
+		{"Duration", fmt.Sprintf("%d seconds", result.Duration)},
+		{"Total Requests", fmt.Sprintf("%d", result.Requests.Total)},
+		{"Failed Requests", fmt.Sprintf("%d", result.Requests.Failed)},
+		{"Requests/sec", fmt.Sprintf("%.2f", result.Throughput.RequestsPerSecond)},
+		{"Average Latency", formatLatency(result.Latency.Average)},
+		{"Min Latency", formatLatency(result.Latency.Min)},
+		{"Max Latency", formatLatency(result.Latency.Max)},
+		{"Data Received", fmt.Sprintf("%s (%s)", formatSize(result.Throughput.BytesRead), formatRate(result.Throughput.BytesRead, result.seconds()))},
+		{"Data Sent", fmt.Sprintf("%s (%s)", formatSize(result.Throughput.BytesWritten), formatRate(result.Throughput.BytesWritten, result.seconds()))},
+		{"Error Rate", fmt.Sprintf("%.2f%%", result.Requests.ErrorRate)},
+	}
+	for _, p := range result.Latency.Percentiles {
+		summary = append(summary, [2]string{fmt.Sprintf("p%g Latency", p.Percentile), formatLatency(p.Value)})
+	}
+
+	codes := make([]int, 0, len(result.StatusCodeCounts))
+	for code := range result.StatusCodeCounts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	statuses := make([][2]string, 0, len(codes))
+	for _, code := range codes {
+		statuses = append(statuses, [2]string{fmt.Sprintf("%d", code), fmt.Sprintf("%d", result.StatusCodeCounts[code])})
+	}
+
+	return htmlReportTemplate.Execute(w, struct {
+		URI       string
+		Timestamp string
+		Tags      string
+		Summary   [][2]string
+		Statuses  [][2]string
+		Heatmap   *heatmapView
+	}{
+		URI:       result.URI,
+		Timestamp: result.Timestamp.Local().Format("2006-01-02 15:04:05"),
+		Tags:      formatTags(result.Tags),
+		Summary:   summary,
+		Statuses:  statuses,
+		Heatmap:   heatmap.view(),
+	})
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>autocannon: {{.URI}}</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 4px 12px; }
+td:last-child { text-align: right; }
+svg text { font-size: 11px; fill: #444; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.URI}}</h1>
+<p class="muted">{{.Timestamp}}{{if .Tags}} &middot; {{.Tags}}{{end}}</p>
+
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+
+<h2>Status Codes</h2>
+<table>
+<tr><th>Status Code</th><th>Count</th></tr>
+{{range .Statuses}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+
+<h2>Latency Over Time</h2>
+{{with .Heatmap}}
+<p class="muted">Requests per {{if eq .SecondsPerCol 1}}second{{else}}{{.SecondsPerCol}} seconds{{end}} and latency range; darker cells hold more requests (up to {{.MaxCount}}). Pauses show up as columns that reach into the higher latency rows.</p>
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+<rect x="{{.PlotX}}" y="0" width="900" height="{{.PlotH}}" fill="#fafafa" stroke="#ccc"/>
+{{range .Cells}}<rect x="{{printf "%.2f" .X}}" y="{{.Y}}" width="{{printf "%.2f" .Width}}" height="{{.Height}}" fill="{{.Fill}}"><title>{{.Title}}</title></rect>
+{{end}}{{range .YLabels}}<text x="{{.X}}" y="{{printf "%.1f" .Y}}" text-anchor="end">{{.Text}}</text>
+{{end}}{{range .XLabels}}<text x="{{printf "%.1f" .X}}" y="{{.Y}}" text-anchor="middle">{{.Text}}</text>
+{{end}}</svg>
+{{else}}
+<p class="muted">No samples were recorded.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,43 @@
+package main
+
+import "strings"
+
+// identityHeaders are the response headers -server-identity reports,
+// which name the server, proxy or CDN location that answered
+var identityHeaders = []string{"Server", "Via", "X-Served-By", "X-Amz-Cf-Pop", "Cf-Ray", "Fly-Region"}
+
+// identityValue reduces a CF-Ray, a request id followed by the data
+// center (e.g. 8a1b2c3d4e5f6a7b-FRA), to the data center, so requests
+// are counted by location rather than one by one
+func identityValue(name, value string) string {
+	if name == "Cf-Ray" {
+		if i := strings.LastIndex(value, "-"); i >= 0 {
+			return value[i+1:]
+		}
+	}
+	return value
+}
+
+// newIdentityCapture collects the distribution of the identity headers
+func newIdentityCapture() *headerCapture {
+	c := newHeaderCapture(identityHeaders)
+	c.normalize = identityValue
+	return c
+}
+
+// identitySummary returns the distributions of the identity headers the
+// target sent, leaving out those no response had
+func identitySummary(c *headerCapture) map[string]HeaderStats {
+	stats := c.summary()
+	for name, s := range stats {
+		if len(s.Values) == 0 {
+			delete(stats, name)
+		}
+	}
+	return stats
+}
+
+// displayIdentityStats prints the -server-identity table
+func displayIdentityStats(result BenchmarkResult) {
+	displayHeaderTable("Server Identity", result.ServerIdentity)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// InformationalStats counts 1xx interim responses (including 103 Early
+// Hints) and how long after sending the request the first one arrived
+type InformationalStats struct {
+	Responses   int64          `json:"responses"`
+	StatusCodes map[int]int64  `json:"statusCodes"`
+	FirstAfter  LatencySummary `json:"firstResponseLatency"`
+}
+
+// TrailerStats counts responses carrying HTTP trailers and which trailer
+// fields were seen
+type TrailerStats struct {
+	Responses int64            `json:"responses"`
+	Fields    map[string]int64 `json:"fields"`
+}
+
+// informationalTracker collects InformationalStats and TrailerStats from
+// concurrent workers
+type informationalTracker struct {
+	mu            sync.Mutex
+	responses     int64
+	statusCodes   map[int]int64
+	firstAfter    latencyTracker
+	trailerResps  int64
+	trailerFields map[string]int64
+}
+
+func newInformationalTracker() *informationalTracker {
+	return &informationalTracker{
+		statusCodes:   make(map[int]int64),
+		trailerFields: make(map[string]int64),
+	}
+}
+
+// recordInformational records a 1xx response; first is true for the first
+// 1xx of its request
+func (t *informationalTracker) recordInformational(code int, sinceStart time.Duration, first bool) {
+	t.mu.Lock()
+	t.responses++
+	t.statusCodes[code]++
+	t.mu.Unlock()
+
+	if first {
+		t.firstAfter.record(float64(sinceStart.Microseconds()) / 1000)
+	}
+}
+
+// recordTrailers records the trailer fields of a fully read response
+func (t *informationalTracker) recordTrailers(fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	sort.Strings(fields)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trailerResps++
+	for _, field := range fields {
+		t.trailerFields[field]++
+	}
+}
+
+func (t *informationalTracker) summary() (InformationalStats, TrailerStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info := InformationalStats{
+		Responses:   t.responses,
+		StatusCodes: make(map[int]int64, len(t.statusCodes)),
+		FirstAfter:  t.firstAfter.summary(),
+	}
+	for code, count := range t.statusCodes {
+		info.StatusCodes[code] = count
+	}
+
+	trailers := TrailerStats{
+		Responses: t.trailerResps,
+		Fields:    make(map[string]int64, len(t.trailerFields)),
+	}
+	for field, count := range t.trailerFields {
+		trailers.Fields[field] = count
+	}
+	return info, trailers
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ttacon/chalk"
+)
+
+// IntervalStats holds the statistics for a single -print-interval window
+type IntervalStats struct {
+	Timestamp        time.Time        `json:"timestamp"`
+	ElapsedSeconds   float64          `json:"elapsedSeconds"`
+	Requests         int64            `json:"requests"`
+	SuccessfulReqs   int64            `json:"successfulRequests"`
+	FailedReqs       int64            `json:"failedRequests"`
+	Timeouts         int64            `json:"timeouts"`
+	RequestsPerSec   float64          `json:"requestsPerSecond"`
+	AverageLatency   float64          `json:"averageLatencyMs"`
+	MinLatency       float64          `json:"minLatencyMs"`
+	MaxLatency       float64          `json:"maxLatencyMs"`
+	BytesRead        int64            `json:"bytesRead"`
+	BytesReadPerSec  float64          `json:"bytesReadPerSecond"`
+	StatusCodeCounts map[int]int64    `json:"statusCodes"`
+	RateLimit        *RateLimitWindow `json:"rateLimit,omitempty"`
+	Rate             float64          `json:"rate,omitempty"`
+	Tuning           []TuningChange   `json:"tuning,omitempty"`
+}
+
+// counterSnapshot is a point-in-time copy of the running totals
+type counterSnapshot struct {
+	Requests    int64
+	Successful  int64
+	Failed      int64
+	Timeouts    int64
+	BytesRead   int64
+	StatusCodes map[int]int64
+}
+
+// intervalTracker turns running totals into per-interval statistics
+type intervalTracker struct {
+	start        time.Time
+	last         time.Time
+	prev         counterSnapshot
+	latencyCount int64
+	latencyTotal float64
+	latencyMin   float64
+	latencyMax   float64
+}
+
+func newIntervalTracker(start time.Time) *intervalTracker {
+	return &intervalTracker{
+		start: start,
+		last:  start,
+		prev:  counterSnapshot{StatusCodes: map[int]int64{}},
+	}
+}
+
+func (t *intervalTracker) recordLatency(latency float64) {
+	if t.latencyCount == 0 || latency < t.latencyMin {
+		t.latencyMin = latency
+	}
+	if latency > t.latencyMax {
+		t.latencyMax = latency
+	}
+	t.latencyCount++
+	t.latencyTotal += latency
+}
+
+// next closes the current interval at now and starts a new one
+func (t *intervalTracker) next(now time.Time, current counterSnapshot) IntervalStats {
+	stats := IntervalStats{
+		Timestamp:        now,
+		ElapsedSeconds:   now.Sub(t.start).Seconds(),
+		Requests:         current.Requests - t.prev.Requests,
+		SuccessfulReqs:   current.Successful - t.prev.Successful,
+		FailedReqs:       current.Failed - t.prev.Failed,
+		Timeouts:         current.Timeouts - t.prev.Timeouts,
+		BytesRead:        current.BytesRead - t.prev.BytesRead,
+		MinLatency:       t.latencyMin,
+		MaxLatency:       t.latencyMax,
+		StatusCodeCounts: make(map[int]int64),
+	}
+	if window := now.Sub(t.last).Seconds(); window > 0 {
+		stats.RequestsPerSec = float64(stats.Requests) / window
+		stats.BytesReadPerSec = float64(stats.BytesRead) / window
+	}
+	if t.latencyCount > 0 {
+		stats.AverageLatency = t.latencyTotal / float64(t.latencyCount)
+	}
+	for code, count := range current.StatusCodes {
+		if delta := count - t.prev.StatusCodes[code]; delta > 0 {
+			stats.StatusCodeCounts[code] = delta
+		}
+	}
+
+	t.last = now
+	t.prev = current
+	t.latencyCount, t.latencyTotal, t.latencyMin, t.latencyMax = 0, 0, 0, 0
+	return stats
+}
+
+// printIntervalStats prints one interval as a JSON line (-json), a
+// key=value line (-quiet) or a timestamped block
+func printIntervalStats(stats IntervalStats, config BenchmarkConfig) {
+	switch {
+	case config.JSONOutput:
+		jsonData, err := json.Marshal(stats)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling interval to JSON: %v\n", err)
+			return
+		}
+		fmt.Println(string(jsonData))
+	case config.Quiet:
+		fmt.Printf("time=%s elapsed=%.3f requests=%d failed=%d timeouts=%d rps=%.2f avg_ms=%.2f min_ms=%.2f max_ms=%.2f",
+			stats.Timestamp.Format("2006-01-02T15:04:05.000Z07:00"), stats.ElapsedSeconds, stats.Requests, stats.FailedReqs,
+			stats.Timeouts, stats.RequestsPerSec, stats.AverageLatency, stats.MinLatency, stats.MaxLatency)
+		if stats.RateLimit != nil {
+			fmt.Printf(" ratelimit_remaining=%d", stats.RateLimit.Remaining)
+		}
+		if stats.Rate > 0 {
+			fmt.Printf(" adaptive_rate=%.1f", stats.Rate)
+		}
+		for _, change := range stats.Tuning {
+			fmt.Printf(" %s=%g", change.Setting, change.To)
+		}
+		fmt.Println()
+	default:
+		fmt.Println(colorize(chalk.Cyan, fmt.Sprintf("[%s] +%.1fs", stats.Timestamp.Format("15:04:05.000"), stats.ElapsedSeconds)))
+		fmt.Printf("  Requests: %d (%.2f/sec), failed: %d, timeouts: %d\n",
+			stats.Requests, stats.RequestsPerSec, stats.FailedReqs, stats.Timeouts)
+		fmt.Printf("  Latency: avg %s, min %s, max %s\n",
+			formatLatency(stats.AverageLatency), formatLatency(stats.MinLatency), formatLatency(stats.MaxLatency))
+		fmt.Printf("  Data received: %s (%s)\n", formatSize(stats.BytesRead), formatSize(int64(stats.BytesReadPerSec))+"/s")
+		if stats.RateLimit != nil {
+			fmt.Printf("  Rate limit: %s\n", stats.RateLimit)
+		}
+		if stats.Rate > 0 {
+			fmt.Printf("  Adaptive rate: %.1f/sec\n", stats.Rate)
+		}
+		for _, change := range stats.Tuning {
+			fmt.Println(colorize(chalk.Yellow, fmt.Sprintf("  Tuned %s at +%.1fs", change, change.ElapsedSeconds)))
+		}
+		if len(stats.StatusCodeCounts) > 0 {
+			codes := make([]int, 0, len(stats.StatusCodeCounts))
+			for code := range stats.StatusCodeCounts {
+				codes = append(codes, code)
+			}
+			sort.Ints(codes)
+			fmt.Print("  Status codes:")
+			for _, code := range codes {
+				fmt.Printf(" %d=%d", code, stats.StatusCodeCounts[code])
+			}
+			fmt.Println()
+		}
+	}
+}
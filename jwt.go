@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// jwtMinter signs a JWT for every request, or for every virtual user, and
+// sends it in a header, so APIs that require short-lived tokens can be
+// benchmarked without a token service. It is safe for concurrent use.
+type jwtMinter struct {
+	algorithm string // "HS256" or "RS256"
+	secret    []byte
+	key       *rsa.PrivateKey
+	keyID     string
+	claims    map[string]any // string claims are templates
+	templates map[string]*template.Template
+	ttl       time.Duration
+	perUser   bool
+	header    string
+	prefix    string
+	seq       int64
+
+	mu     sync.Mutex
+	tokens map[int]jwtToken // per worker, with perUser
+}
+
+type jwtToken struct {
+	value string
+	renew time.Time
+}
+
+// loadJWT reads a -jwt file: {"algorithm": "HS256" or "RS256", "keyFile",
+// "keyID", "claims", "ttl", "per": "request" or "user", "header",
+// "prefix"}. keyFile is relative to the file; for HS256 it holds the
+// secret, trailing newlines aside, and for RS256 a PEM RSA private key.
+func loadJWT(path string) (*jwtMinter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Algorithm string         `json:"algorithm"`
+		KeyFile   string         `json:"keyFile"`
+		KeyID     string         `json:"keyID"`
+		Claims    map[string]any `json:"claims"`
+		TTL       string         `json:"ttl"`
+		Per       string         `json:"per"`
+		Header    string         `json:"header"`
+		Prefix    *string        `json:"prefix"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	m := &jwtMinter{
+		algorithm: strings.ToUpper(file.Algorithm),
+		keyID:     file.KeyID,
+		claims:    file.Claims,
+		templates: make(map[string]*template.Template),
+		ttl:       5 * time.Minute,
+		header:    file.Header,
+		tokens:    make(map[int]jwtToken),
+	}
+	if file.KeyFile == "" {
+		return nil, errors.New("keyFile is required")
+	}
+	keyFile := file.KeyFile
+	if !filepath.IsAbs(keyFile) {
+		keyFile = filepath.Join(filepath.Dir(path), keyFile)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	switch m.algorithm {
+	case "HS256":
+		m.secret = []byte(strings.TrimRight(string(key), "\r\n"))
+		if len(m.secret) == 0 {
+			return nil, fmt.Errorf("%s is empty", file.KeyFile)
+		}
+	case "RS256":
+		if m.key, err = parseRSAKey(key); err != nil {
+			return nil, fmt.Errorf("%s: %v", file.KeyFile, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q, expected HS256 or RS256", file.Algorithm)
+	}
+	if file.TTL != "" {
+		if m.ttl, err = time.ParseDuration(file.TTL); err != nil || m.ttl <= 0 {
+			return nil, fmt.Errorf("invalid ttl %q", file.TTL)
+		}
+	}
+	switch file.Per {
+	case "", "request":
+	case "user":
+		m.perUser = true
+	default:
+		return nil, fmt.Errorf("unknown per %q, expected request or user", file.Per)
+	}
+	if m.header == "" {
+		m.header = "Authorization"
+	}
+	switch {
+	case file.Prefix != nil:
+		m.prefix = *file.Prefix
+	case strings.EqualFold(m.header, "Authorization"):
+		m.prefix = "Bearer "
+	}
+	for name, value := range m.claims {
+		if text, ok := value.(string); ok && isTemplate(text) {
+			if m.templates[name], err = parseTemplate(name, text); err != nil {
+				return nil, fmt.Errorf("claim %q: %v", name, err)
+			}
+		}
+	}
+	// Catch claims that don't render, and keys that don't sign, now
+	if _, err := m.mint(templateData{Worker: -1}, time.Now()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseRSAKey parses a PEM PKCS #1 or PKCS #8 RSA private key
+func parseRSAKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return key, nil
+}
+
+// sign sets the minter's header of a request built for worker, unless
+// the request already has it. With per user, a worker reuses its token
+// until 90% of its ttl has passed; requests not tied to a worker get a
+// token each.
+func (m *jwtMinter) sign(req *http.Request, worker int, requestID string) error {
+	if req.Header.Get(m.header) != "" {
+		return nil
+	}
+	now := time.Now()
+	data := templateData{Seq: atomic.AddInt64(&m.seq, 1), Worker: worker, RequestID: requestID, Row: feedRowFrom(req.Context())}
+	if !m.perUser || worker < 0 {
+		token, err := m.mint(data, now)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(m.header, m.prefix+token)
+		return nil
+	}
+
+	m.mu.Lock()
+	cached, ok := m.tokens[worker]
+	m.mu.Unlock()
+	if !ok || now.After(cached.renew) {
+		token, err := m.mint(data, now)
+		if err != nil {
+			return err
+		}
+		cached = jwtToken{value: token, renew: now.Add(m.ttl * 9 / 10)}
+		m.mu.Lock()
+		m.tokens[worker] = cached
+		m.mu.Unlock()
+	}
+	req.Header.Set(m.header, m.prefix+cached.value)
+	return nil
+}
+
+// mint signs a token with the claims rendered for data, issued now. iat
+// and exp are added unless the claims set them.
+func (m *jwtMinter) mint(data templateData, now time.Time) (string, error) {
+	claims := make(map[string]any, len(m.claims)+2)
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(m.ttl).Unix()
+	var b strings.Builder
+	for name, value := range m.claims {
+		if tmpl, ok := m.templates[name]; ok {
+			b.Reset()
+			if err := tmpl.Execute(&b, data); err != nil {
+				return "", fmt.Errorf("claim %q: %v", name, err)
+			}
+			value = b.String()
+		}
+		claims[name] = value
+	}
+
+	header := map[string]string{"alg": m.algorithm, "typ": "JWT"}
+	if m.keyID != "" {
+		header["kid"] = m.keyID
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	signed := encode(headerJSON) + "." + encode(claimsJSON)
+
+	var signature []byte
+	if m.key != nil {
+		digest := sha256.Sum256([]byte(signed))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	} else {
+		mac := hmac.New(sha256.New, m.secret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + encode(signature), nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// maxSSELine bounds the server-sent event lines kept while parsing a
+// stream; longer lines are skipped
+const maxSSELine = 1 << 20
+
+// LLMStats describes the streams of -llm, OpenAI-style chat completions
+// streamed as server-sent events. Completed streams ended with
+// "data: [DONE]", and Empty ones brought no token. Tokens are the
+// completion tokens the server reported in its usage, or else the events
+// with content. TimeToFirstToken and Completion run from the request
+// being sent; StreamRate is the tokens per second of every stream after
+// its first token.
+type LLMStats struct {
+	Model            string       `json:"model,omitempty"`
+	Streams          int64        `json:"streams"`
+	Completed        int64        `json:"completed"`
+	Empty            int64        `json:"empty"`
+	Tokens           int64        `json:"tokens"`
+	TokensPerSecond  float64      `json:"tokensPerSecond"`
+	TimeToFirstToken PhaseLatency `json:"timeToFirstToken"`
+	Completion       PhaseLatency `json:"completion"`
+	StreamRate       TokenRates   `json:"streamRate"`
+}
+
+// TokenRates is the distribution of the tokens per second of the
+// streams. P10 is the rate the slowest 10% of the streams stayed under.
+type TokenRates struct {
+	Average float64 `json:"average"`
+	P50     float64 `json:"p50"`
+	P10     float64 `json:"p10"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+// llmRequestBody returns the body of a streamed chat completion of the
+// prompt. Template actions in the prompt are kept, so the body is
+// rendered for every request like -body.
+func llmRequestBody(prompt, model string, maxTokens int) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	body := struct {
+		Model         string    `json:"model,omitempty"`
+		Messages      []message `json:"messages"`
+		MaxTokens     int       `json:"max_tokens,omitempty"`
+		Stream        bool      `json:"stream"`
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}{
+		Model:     model,
+		Messages:  []message{{Role: "user", Content: prompt}},
+		MaxTokens: maxTokens,
+		Stream:    true,
+	}
+	body.StreamOptions.IncludeUsage = true
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(body); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(b.Bytes())), nil
+}
+
+// llmStream parses the events of a streamed completion as its body is
+// read, timing its tokens
+type llmStream struct {
+	r     io.Reader
+	start time.Time
+	line  []byte
+	skip  bool // the rest of an overlong line
+
+	first  time.Time
+	last   time.Time
+	events int64
+	usage  int64
+	done   bool
+}
+
+// llmEvent is the part of a completion chunk that is looked at, for the
+// chat and the legacy completions APIs
+type llmEvent struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+		Text string `json:"text"`
+	} `json:"choices"`
+	Usage *struct {
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (s *llmStream) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		now := time.Now()
+		data := p[:n]
+		for len(data) > 0 {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				s.append(data)
+				break
+			}
+			s.append(data[:i])
+			if !s.skip {
+				s.event(bytes.TrimSuffix(s.line, []byte("\r")), now)
+			}
+			s.line, s.skip = s.line[:0], false
+			data = data[i+1:]
+		}
+	}
+	return n, err
+}
+
+func (s *llmStream) append(b []byte) {
+	if s.skip {
+		return
+	}
+	if len(s.line)+len(b) > maxSSELine {
+		s.skip = true
+		return
+	}
+	s.line = append(s.line, b...)
+}
+
+// event handles a line of the stream that arrived at now
+func (s *llmStream) event(line []byte, now time.Time) {
+	payload, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	payload = bytes.TrimSpace(payload)
+	if string(payload) == "[DONE]" {
+		s.done = true
+		return
+	}
+	var event llmEvent
+	if json.Unmarshal(payload, &event) != nil {
+		return
+	}
+	if event.Usage != nil && event.Usage.CompletionTokens > 0 {
+		s.usage = event.Usage.CompletionTokens
+	}
+	for _, choice := range event.Choices {
+		if choice.Delta.Content != "" || choice.Delta.ReasoningContent != "" || choice.Text != "" {
+			if s.events == 0 {
+				s.first = now
+			}
+			s.events++
+			s.last = now
+			return
+		}
+	}
+}
+
+// tokens returns the completion tokens of the stream
+func (s *llmStream) tokens() int64 {
+	if s.usage > 0 {
+		return s.usage
+	}
+	return s.events
+}
+
+// llmTracker collects LLMStats from concurrent workers
+type llmTracker struct {
+	model string
+
+	mu         sync.Mutex
+	streams    int64
+	completed  int64
+	empty      int64
+	tokens     int64
+	firstToken *histogram
+	completion *histogram
+	rates      *histogram // thousandths of a token per second
+}
+
+func newLLMTracker(model string) *llmTracker {
+	return &llmTracker{model: model, firstToken: newLatencyHistogram(), completion: newLatencyHistogram(), rates: newCountHistogram()}
+}
+
+// reader wraps the body of a response to a request sent at start
+func (t *llmTracker) reader(body io.Reader, start time.Time) *llmStream {
+	return &llmStream{r: body, start: start}
+}
+
+// record adds a stream whose body was read in full
+func (t *llmTracker) record(s *llmStream) {
+	end := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streams++
+	if s.done {
+		t.completed++
+	}
+	if s.events == 0 {
+		t.empty++
+		return
+	}
+	tokens := s.tokens()
+	t.tokens += tokens
+	t.firstToken.record(s.first.Sub(s.start).Microseconds())
+	t.completion.record(end.Sub(s.start).Microseconds())
+	if elapsed := s.last.Sub(s.first); tokens > 1 && elapsed > 0 {
+		t.rates.record(int64(float64(tokens-1) / elapsed.Seconds() * 1000))
+	}
+}
+
+// summary returns the stats of a run of the given seconds
+func (t *llmTracker) summary(seconds float64) *LLMStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &LLMStats{
+		Model:            t.model,
+		Streams:          t.streams,
+		Completed:        t.completed,
+		Empty:            t.empty,
+		Tokens:           t.tokens,
+		TimeToFirstToken: phaseLatency(t.firstToken),
+		Completion:       phaseLatency(t.completion),
+		StreamRate: TokenRates{
+			Average: t.rates.mean() / 1000,
+			P50:     float64(t.rates.valueAtPercentile(50)) / 1000,
+			P10:     float64(t.rates.valueAtPercentile(10)) / 1000,
+			Min:     float64(t.rates.min) / 1000,
+			Max:     float64(t.rates.max) / 1000,
+		},
+	}
+	if seconds > 0 {
+		stats.TokensPerSecond = float64(t.tokens) / seconds
+	}
+	return stats
+}
+
+// displayLLMStats prints the -llm table
+func displayLLMStats(result BenchmarkResult) {
+	l := result.LLM
+	if l == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nLLM Streams:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	latency := func(p PhaseLatency) string {
+		return fmt.Sprintf("%s avg, %s median, %s p99, %s max", formatLatency(p.Average), formatLatency(p.P50), formatLatency(p.P99), formatLatency(p.Max))
+	}
+	table.Header("Metric", "Value")
+	if l.Model != "" {
+		table.Append([]string{"Model", l.Model})
+	}
+	table.Append([]string{"Streams", fmt.Sprintf("%d (%d completed, %d without tokens)", l.Streams, l.Completed, l.Empty)})
+	table.Append([]string{"Output Tokens", fmt.Sprintf("%d (%.1f tokens/sec overall)", l.Tokens, l.TokensPerSecond)})
+	if l.Streams > l.Empty {
+		table.Append([]string{"Time to First Token", latency(l.TimeToFirstToken)})
+		table.Append([]string{"Completion Latency", latency(l.Completion)})
+		table.Append([]string{"Tokens/sec per Stream", fmt.Sprintf("%.1f avg, %.1f median, %.1f slowest 10%%, %.1f min, %.1f max", l.StreamRate.Average, l.StreamRate.P50, l.StreamRate.P10, l.StreamRate.Min, l.StreamRate.Max)})
+	}
+	table.Render()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// levelTrace is below slog's debug level, for per-request events
+const levelTrace = slog.LevelDebug - 4
+
+// logger writes diagnostics to stderr, keeping stdout for the results.
+// Nothing is logged by default; -v logs the resolved configuration (info)
+// and a summary every second (debug), and -vv adds every request (trace).
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// configureLogging sets the logger up for the given number of -v flags
+func configureLogging(verbosity int) {
+	if verbosity <= 0 {
+		return
+	}
+	level := slog.LevelDebug
+	if verbosity > 1 {
+		level = levelTrace
+	}
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any().(slog.Level) == levelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	}))
+}
+
+// tracing reports whether per-request events are logged, so the hot path
+// can skip building them
+func tracing() bool {
+	return logger.Enabled(context.Background(), levelTrace)
+}
+
+// logTrace logs a per-request event
+func logTrace(msg string, args ...any) {
+	logger.Log(context.Background(), levelTrace, msg, args...)
+}
+
+// logConfig logs the configuration a run resolved to from its flags
+func logConfig(config BenchmarkConfig) {
+	generator := "static"
+	if config.Generator != nil {
+		generator = fmt.Sprintf("%T", config.Generator)
+	}
+	logger.Info("config",
+		"uri", config.URI,
+		"method", config.Method,
+		"connections", config.Connections,
+		"duration", time.Duration(config.Duration)*time.Second,
+		"timeout", time.Duration(config.Timeout)*time.Second,
+		"header_timeout", config.HeaderTimeout,
+		"model", config.Model,
+		"rate", config.Rate,
+		"arrival", config.Arrival,
+		"generator", generator,
+		"validators", len(config.Validators),
+		"sinks", len(config.Sinks),
+		"percentiles", config.Percentiles,
+	)
+	// Header values may hold credentials, so only their names are logged
+	if len(config.Headers) > 0 {
+		names := make([]string, 0, len(config.Headers))
+		for name := range config.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		logger.Info("config", "headers", strings.Join(names, ","))
+	}
+	if len(config.Tags) > 0 {
+		logger.Info("config", "tags", formatTags(config.Tags))
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// LongPollStats describes the requests of a -long-poll run, which the
+// server holds open until it has something to send or the hold expires.
+// TimeToResponse is the wait for the response headers and HoldTime the
+// time until the response was complete. Responses completed in less than
+// 90% of the hold brought an event (Early); the others expired. Held has
+// the requests held open at every second of the run.
+type LongPollStats struct {
+	HoldSeconds    float64        `json:"holdSeconds"`
+	Responses      int64          `json:"responses"`
+	Early          int64          `json:"early"`
+	Expired        int64          `json:"expired"`
+	TimeToResponse LatencySummary `json:"timeToResponse"`
+	HoldTime       LatencySummary `json:"holdTime"`
+	PeakHeld       int64          `json:"peakHeld"`
+	AverageHeld    float64        `json:"averageHeld"`
+	Held           []int64        `json:"held"`
+}
+
+// longPollTracker counts the requests held open and times their
+// responses. It is safe for concurrent use.
+type longPollTracker struct {
+	hold    time.Duration
+	held    int64
+	peak    int64
+	early   int64
+	expired int64
+
+	timeToResponse latencyTracker
+	holdTime       latencyTracker
+
+	mu      sync.Mutex
+	samples []int64
+}
+
+func newLongPollTracker(hold time.Duration) *longPollTracker {
+	return &longPollTracker{hold: hold}
+}
+
+// begin counts a request being sent, until end is called
+func (t *longPollTracker) begin() {
+	n := atomic.AddInt64(&t.held, 1)
+	for {
+		peak := atomic.LoadInt64(&t.peak)
+		if n <= peak || atomic.CompareAndSwapInt64(&t.peak, peak, n) {
+			return
+		}
+	}
+}
+
+func (t *longPollTracker) end() {
+	atomic.AddInt64(&t.held, -1)
+}
+
+// record adds a complete response, with the milliseconds until its
+// headers and until its end
+func (t *longPollTracker) record(headersMs, totalMs float64) {
+	t.timeToResponse.record(headersMs)
+	t.holdTime.record(totalMs)
+	if totalMs < float64(t.hold.Milliseconds())*0.9 {
+		atomic.AddInt64(&t.early, 1)
+	} else {
+		atomic.AddInt64(&t.expired, 1)
+	}
+}
+
+// sample records the requests held open now, once a second
+func (t *longPollTracker) sample() {
+	t.mu.Lock()
+	t.samples = append(t.samples, atomic.LoadInt64(&t.held))
+	t.mu.Unlock()
+}
+
+func (t *longPollTracker) summary() *LongPollStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &LongPollStats{
+		HoldSeconds:    t.hold.Seconds(),
+		Early:          atomic.LoadInt64(&t.early),
+		Expired:        atomic.LoadInt64(&t.expired),
+		TimeToResponse: t.timeToResponse.summary(),
+		HoldTime:       t.holdTime.summary(),
+		PeakHeld:       atomic.LoadInt64(&t.peak),
+		Held:           append([]int64{}, t.samples...),
+	}
+	stats.Responses = stats.Early + stats.Expired
+	if len(t.samples) > 0 {
+		var total int64
+		for _, n := range t.samples {
+			total += n
+		}
+		stats.AverageHeld = float64(total) / float64(len(t.samples))
+	}
+	return stats
+}
+
+// displayLongPollStats prints the -long-poll table
+func displayLongPollStats(result BenchmarkResult) {
+	p := result.LongPoll
+	if p == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nLong Polling:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	share := func(n int64) string {
+		if p.Responses == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%d (%.2f%%)", n, float64(n)/float64(p.Responses)*100)
+	}
+	table.Header("Metric", "Value")
+	table.Append([]string{"Hold", (time.Duration(p.HoldSeconds * float64(time.Second))).String()})
+	table.Append([]string{"Responses", fmt.Sprintf("%d", p.Responses)})
+	table.Append([]string{"  Early (Events)", share(p.Early)})
+	table.Append([]string{"  Expired Holds", share(p.Expired)})
+	table.Append([]string{"Time to Response", fmt.Sprintf("%s avg, %s min, %s max", formatLatency(p.TimeToResponse.Average), formatLatency(p.TimeToResponse.Min), formatLatency(p.TimeToResponse.Max))})
+	table.Append([]string{"Hold Time", fmt.Sprintf("%s avg, %s min, %s max", formatLatency(p.HoldTime.Average), formatLatency(p.HoldTime.Min), formatLatency(p.HoldTime.Max))})
+	table.Append([]string{"Held Connections", fmt.Sprintf("%.1f avg, %d peak", p.AverageHeld, p.PeakHeld)})
+
+	table.Render()
+}
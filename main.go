@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/textproto"
 	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
@@ -21,245 +34,2642 @@ import (
 type BenchmarkConfig struct {
 	URI              string
 	Connections      int
+	VUs              int
 	Duration         int
 	Timeout          int
+	HeaderTimeout    time.Duration
+	LongPoll         time.Duration
 	Method           string
+	Model            string
+	Rate             float64
+	RatePerConn      bool
+	Burst            int
+	MaxPending       int
+	ReconnectEvery   int
+	ConnectRate      float64
+	RetireOnNoPorts  bool
+	IdleTimeout      time.Duration
+	NoHappyEyeballs  bool
+	NoTLSResumption  bool
+	ClientIdentities []*clientIdentity
+	NTLM             *ntlmCredentials
+	Digest           *digestAuth
+	Proxy            *proxyTunnel
+	Arrival          string
+	ReplayFile       string
+	ReplayFormat     string
+	ReplaySpeed      float64
 	Headers          map[string]string
+	AcceptEncoding   string
+	UserAgent        string
+	UserAgents       *valuePool
+	HeaderPools      map[string]*valuePool
+	JWT              *jwtMinter
+	Signer           *requestSigner
+	RequestIDHeader  bool
+	IdempotencyKey   bool
+	CaptureHeaders   []string
+	Conditional      bool
+	CDN              bool
+	ServerIdentity   bool
+	LatencyPhases    bool
+	Chunks           bool
+	LLM              bool
+	LLMModel         string
+	JSONFields       []jsonField
+	Throttle         bool
+	RetryAfter       bool
+	Adaptive         bool
+	AdaptiveErrors   float64
+	AdaptiveP99      time.Duration
+	ABTest           bool
+	Percentiles      []float64
+	TrimStart        time.Duration
+	TrimEnd          time.Duration
+	TrimOutliers     float64
+	Ranges           *rangeSelector
 	Body             string
+	ExpectContinue   bool
+	ContinueTimeout  time.Duration
 	ExpectStatusCode int
-	Debug            bool
-	OutputFile       string
+	ShowConnections  bool
+	Tags             map[string]string
+	PrintInterval    time.Duration
+	Quiet            bool
+	JSONOutput       bool
+	CheckpointFile   string
+	CheckpointEvery  time.Duration
+	CheckpointKeep   int
+	HdrLogFile       string
+	HdrInterval      time.Duration
+	HdrPercentiles   string
+	TraceParent      bool
+	TraceExemplars   int
+	OpenAPIFile      string
+	OpenAPIMode      string
+	Operations       []requestSpec
+	Generator        RequestGenerator
+	Feed             *feed
+
+	// Handlers receive progress events during the run
+	Handlers []EventHandler
+
+	// Sinks receive every request and the final result, and produce all
+	// of the output
+	Sinks []Sink
+
+	// Validators check every response; a failure fails the request
+	Validators []Validator
+
+	// Checks are the scenario's weighted checks, nil without any
+	Checks *checkTracker
+
+	// Control pauses and resumes the run; without one it can't be paused
+	Control *runControl
+}
+
+// ContinueStats describes Expect: 100-continue handling. The latency is
+// measured from writing the request headers to receiving the 100 response.
+type ContinueStats struct {
+	Sent     int64          `json:"sent"`
+	Received int64          `json:"received"`
+	Latency  LatencySummary `json:"latency"`
 }
 
-// BenchmarkResult holds the results of the benchmark
+// PendingStats describes the -max-pending cap on outstanding requests in
+// the open model. CapHits counts the times the dispatcher had to wait for
+// a response before it could send the next request.
+type PendingStats struct {
+	Max     int   `json:"max"`
+	Peak    int64 `json:"peak"`
+	CapHits int64 `json:"capHits"`
+}
+
+// BenchmarkResult holds the results of the benchmark. Its JSON structure
+// is versioned by SchemaVersion; see result.go.
 type BenchmarkResult struct {
-	Connections      int           `json:"connections"`
-	Duration         int           `json:"durationSeconds"`
-	TotalRequests    int64         `json:"totalRequests"`
-	SuccessfulReqs   int64         `json:"successfulRequests"`
-	FailedReqs       int64         `json:"failedRequests"`
-	Timeouts         int64         `json:"timeouts"`
-	RequestsPerSec   float64       `json:"requestsPerSecond"`
-	AverageLatency   float64       `json:"averageLatencyMs"`
-	MinLatency       float64       `json:"minLatencyMs"`
-	MaxLatency       float64       `json:"maxLatencyMs"`
-	BytesRead        int64         `json:"bytesRead"`
-	BytesWritten     int64         `json:"bytesWritten"`
-	ErrorRate        float64       `json:"errorRate"`
-	StatusCodeCounts map[int]int64 `json:"statusCodes"`
-	Timestamp        time.Time     `json:"timestamp"`
+	SchemaVersion    int                    `json:"schemaVersion"`
+	URI              string                 `json:"uri"`
+	Method           string                 `json:"method"`
+	Model            string                 `json:"model"`
+	Rate             float64                `json:"rate,omitempty"`
+	RatePerConn      bool                   `json:"ratePerConnection,omitempty"`
+	Burst            int                    `json:"burst,omitempty"`
+	Arrival          string                 `json:"arrival,omitempty"`
+	Connections      int                    `json:"connections"`
+	VUs              int                    `json:"vus,omitempty"`
+	Duration         int                    `json:"durationSeconds"`
+	Requests         RequestCounts          `json:"requests"`
+	Latency          LatencyStats           `json:"latency"`
+	Throughput       ThroughputStats        `json:"throughput"`
+	StatusCodeCounts map[int]int64          `json:"statusCodes"`
+	Timestamp        time.Time              `json:"timestamp"`
+	AcceptEncoding   string                 `json:"acceptEncoding,omitempty"`
+	Tags             map[string]string      `json:"tags,omitempty"`
+	Metadata         RunMetadata            `json:"metadata"`
+	Intervals        []IntervalStats        `json:"intervals,omitempty"`
+	Interrupted      bool                   `json:"interrupted,omitempty"`
+	Informational    InformationalStats     `json:"informational"`
+	Trailers         TrailerStats           `json:"trailers"`
+	PerConnection    []ConnectionStats      `json:"perConnection"`
+	Continue         *ContinueStats         `json:"continue,omitempty"`
+	Pending          *PendingStats          `json:"pending,omitempty"`
+	Connect          *ConnectStats          `json:"connect,omitempty"`
+	Traces           *TraceStats            `json:"traces,omitempty"`
+	Operations       []OperationStats       `json:"operations,omitempty"`
+	Validation       *ValidationStats       `json:"validation,omitempty"`
+	Checks           *CheckStats            `json:"checks,omitempty"`
+	Scenarios        []ScenarioStats        `json:"scenarios,omitempty"`
+	Identities       []IdentityStats        `json:"identities,omitempty"`
+	Auth             *AuthStats             `json:"auth,omitempty"`
+	LongPoll         *LongPollStats         `json:"longPoll,omitempty"`
+	Proxy            *ProxyStats            `json:"proxy,omitempty"`
+	Headers          map[string]HeaderStats `json:"headers,omitempty"`
+	Cache            *CacheStats            `json:"cache,omitempty"`
+	CDN              *CDNStats              `json:"cdn,omitempty"`
+	ServerIdentity   map[string]HeaderStats `json:"serverIdentity,omitempty"`
+	Range            *RangeStats            `json:"range,omitempty"`
+	ResponseSize     *ResponseSizeStats     `json:"responseSize,omitempty"`
+	Phases           *PhaseStats            `json:"phases,omitempty"`
+	Chunks           *ChunkStats            `json:"chunks,omitempty"`
+	LLM              *LLMStats              `json:"llm,omitempty"`
+	Fields           []FieldStats           `json:"fields,omitempty"`
+	ServerTiming     *ServerTimingStats     `json:"serverTiming,omitempty"`
+	AB               *ABStats               `json:"ab,omitempty"`
+	Variability      *VariabilityStats      `json:"variability,omitempty"`
+	Trimmed          *TrimmedStats          `json:"trimmed,omitempty"`
+	Replay           *ReplayStats           `json:"replay,omitempty"`
+	VUSetup          *VUSetupStats          `json:"vuSetup,omitempty"`
+	Throttle         *ThrottleStats         `json:"throttle,omitempty"`
+	RateLimit        *RateLimitStats        `json:"rateLimit,omitempty"`
+	Feed             *FeedStats             `json:"feed,omitempty"`
+	Paused           *PauseStats            `json:"paused,omitempty"`
+	Tuning           []TuningChange         `json:"tuning,omitempty"`
+	Adaptive         *AdaptiveStats         `json:"adaptive,omitempty"`
+	PortExhaustion   *PortExhaustionStats   `json:"portExhaustion,omitempty"`
+	Sources          []string               `json:"sources,omitempty"`
+
+	// Distributions used by alternative output formats
+	elapsed           time.Duration
+	latencyHist       *histogram
+	requestSamples    *histogram
+	throughputSamples *histogram
 }
 
-func main() {
-	// Parse command-line arguments
-	uri := flag.String("uri", "", "The uri to benchmark against. (Required)")
-	clients := flag.Int("clients", 10, "The number of connections to open to the server.")
-	runtime := flag.Int("duration", 10, "The number of seconds to run the autocannnon.")
-	timeout := flag.Int("timeout", 10, "The number of seconds before timing out on a request.")
-	method := flag.String("method", "GET", "HTTP method to use")
-	body := flag.String("body", "", "Request body to send")
-	expectStatus := flag.Int("expect", 200, "Expected status code")
-	output := flag.String("output", "", "Output file to write results as JSON")
-	debug := flag.Bool("debug", false, "A utility debug flag.")
-	flag.Parse()
-
-	if *uri == "" {
-		fmt.Println("You must provide a uri to benchmark against.")
-		flag.Usage()
-		os.Exit(1)
+// runBenchmarkCommand implements the "run" subcommand, which is also the
+// default when no subcommand is given
+func runBenchmarkCommand(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon [run] -uri URI [flags]")
+		fs.PrintDefaults()
+	}
+	uri := fs.String("uri", "", "The uri to benchmark against. (Required)")
+	clients := fs.Int("clients", 10, "The number of connections to open to the server.")
+	vus := fs.Int("vus", 0, "Run this many virtual users, each with its own cookie jar, scenario variables and think time, sharing -clients connections (0 runs one user per connection)")
+	runtime := fs.Int("duration", 10, "The number of seconds to run the autocannnon. 0 runs until interrupted.")
+	timeout := fs.Int("timeout", 10, "The number of seconds before timing out on a request.")
+	headerTimeout := fs.Duration("header-timeout", 0, "Time to wait for response headers before timing out, e.g. 2s (0 leaves only -timeout)")
+	longPoll := fs.Duration("long-poll", 0, "Benchmark a long-polling endpoint that holds requests open for up to this long, e.g. 30s: the timeouts start after the hold, and held requests are reported")
+	method := fs.String("method", "GET", "HTTP method to use")
+	model := fs.String("model", "closed", "Workload model: closed (each connection waits for its response) or open (requests are sent on a schedule at -rate)")
+	rate := fs.Float64("rate", 0, "Target requests per second across all connections (0 is unlimited; required by -model open)")
+	adaptive := fs.Bool("adaptive", false, "Start at -rate, lower it when a second's error rate or p99 crosses -adaptive-errors or -adaptive-p99 and raise it slowly otherwise (AIMD), and report the rate the target sustained")
+	adaptiveErrors := fs.Float64("adaptive-errors", 1, "Error rate in percent above which -adaptive lowers the rate")
+	adaptiveP99 := fs.Duration("adaptive-p99", 0, "p99 latency above which -adaptive lowers the rate, e.g. 500ms (0 looks at errors only)")
+	noTLSResumption := fs.Bool("no-tls-resumption", false, "Disable TLS session resumption, so every new connection does a full handshake")
+	clientCerts := fs.String("client-certs", "", "Directory of client certificates (NAME.crt with NAME.key, or NAME.pem with both), presented by the connections or virtual users in turn, one mTLS identity each")
+	ntlmAccount := fs.String("ntlm", "", `Authenticate every connection with an NTLM handshake as DOMAIN\user:password, for intranet services behind Windows-integrated auth`)
+	digestAccount := fs.String("digest-auth", "", "Answer HTTP Digest challenges (RFC 7616) as user:password, counting nonce uses per connection")
+	proxyURL := fs.String("proxy", "", "Benchmark this forward proxy, http://[user:password@]host:port, https://, or SOCKS5 server, socks5:// or socks5h://: every connection is a tunnel through it to the target")
+	ntlmScheme := fs.String("ntlm-scheme", "ntlm", "Authorization scheme of the -ntlm handshake: ntlm, or negotiate for SPNEGO endpoints that accept NTLM tokens")
+	reconnectEvery := fs.Int("reconnect-every", 0, "Maximum requests per connection: close each connection and open a new one after this many requests (0 keeps connections open)")
+	connectRate := fs.Float64("connect-rate", 0, "Open at most this many connections per second at startup, staggering their first requests (0 opens them all at once)")
+	reduceOnPortExhaustion := fs.Bool("reduce-on-port-exhaustion", false, "Retire a connection whenever it fails to get a free local port, lowering the concurrency until ports stop running out")
+	noHappyEyeballs := fs.Bool("no-happy-eyeballs", false, "Disable Happy Eyeballs, dialing a dual-stack target's addresses one at a time in resolver order")
+	idleTimeout := fs.Duration("idle-timeout", 90*time.Second, "Close connections that have been idle for this long, e.g. between paced requests (0 never closes them)")
+	maxPending := fs.Int("max-pending", 0, "Maximum outstanding requests in the open model; the dispatcher waits when it is reached (0 is unlimited)")
+	burst := fs.Int("burst", 1, "Number of requests that may be sent at once above -rate after an idle period (token bucket size)")
+	ratePerConn := fs.Bool("rate-per-connection", false, "Apply -rate to each connection instead of dividing it across all of them (closed model)")
+	feedShard := fs.String("feed-shard", "", "Use only shard i of n of the -feed rows, every nth row, e.g. 2/4 on the second of four generators, so generators never share a row")
+	feedPartition := fs.Bool("feed-partition", false, "Split the -feed rows between the connections (or virtual users), so no row is used by two of them")
+	feedEnd := fs.String("feed-end", "loop", "What happens at the end of the -feed rows: loop back to the first row, or stop (each row is used once; a connection stops when its rows run out)")
+	feedFile := fs.String("feed", "", "CSV file (with a header row) whose rows fill {{.Row.column}} in the uri, body and scenario templates; @method, @path, @body, @header:Name and @name columns override the request")
+	scenarioFile := fs.String("scenario", "", "Send the steps of this JSON scenario file in order on every connection, or a weighted mix of its scenarios")
+	jwtFile := fs.String("jwt", "", "JSON file describing a JWT to sign for every request or virtual user and send in the Authorization header")
+	signFile := fs.String("sign", "", "JSON file describing an HMAC signature of every request to send in headers")
+	hooksFile := fs.String("hooks", "", "JSON file of setup requests to send once before the run and teardown requests to send once after it")
+	targetsFile := fs.String("targets", "", "Send requests from this vegeta target file in round-robin order")
+	targetsFormat := fs.String("targets-format", "http", "Format of the -targets file: http or json")
+	vegetaResults := fs.String("vegeta-results", "", "Write every request to this file in vegeta's JSON results encoding")
+	openAPI := fs.String("openapi", "", "Generate requests for every operation in this OpenAPI 3 spec (YAML or JSON), relative to -uri")
+	openAPIMode := fs.String("openapi-mode", "round-robin", "How -openapi operations are scheduled: round-robin, or per-operation to run each in turn for an equal share of the duration")
+	replay := fs.String("replay", "", "Replay the requests in this access log against -uri, preserving their relative timing")
+	replayFormat := fs.String("format", "combined", "Access log format for -replay: common, combined or csv (timestamp,method,path)")
+	replaySpeed := fs.Float64("speed", 1, "Replay speed multiplier, e.g. 2 replays twice as fast")
+	arrivalSpec := fs.String("arrival", "constant", "Inter-arrival distribution when pacing: constant, poisson, uniform or burst:RATE:ON:EVERY")
+	body := fs.String("body", "", "Request body to send")
+	userAgent := fs.String("user-agent", defaultUserAgent(), "User-Agent header to send")
+	acceptEncoding := fs.String("accept-encoding", "", "Send this Accept-Encoding header, e.g. gzip, br or identity, without decompressing responses, and report their Content-Encoding")
+	requestID := fs.Bool("request-id", false, "Send a unique X-Request-ID header on every request, also available as {{requestID}} in templates")
+	idempotencyKey := fs.Bool("idempotency-key", false, "Send a unique Idempotency-Key header on every POST and PATCH request, the same when the request is sent again after an auth challenge or redirect")
+	headerRotation := fs.String("header-rotation", "round-robin", "How -header-file and -user-agent-file values are picked: round-robin or random")
+	userAgentFile := fs.String("user-agent-file", "", "Rotate the User-Agent through the lines of this file, one per request")
+	expectContinue := fs.Bool("expect-continue", false, "Send Expect: 100-continue on requests with a body")
+	continueTimeout := fs.Duration("continue-timeout", time.Second, "How long to wait for a 100 Continue before sending the body anyway")
+	expectStatus := fs.Int("expect", 200, "Expected status code; other responses count as failed")
+	expectBody := fs.String("expect-body", "", "Count responses whose body does not contain this text as failed")
+	verifySHA256 := fs.String("verify-sha256", "", "Count responses whose body does not have this SHA-256 digest (hex) as failed")
+	verifySHA256Column := fs.String("verify-sha256-column", "", "Like -verify-sha256, with the expected digest taken from this -feed column")
+	output := fs.String("output", "", "Output file to write results as JSON")
+	outputFormat := fs.String("output-format", "json", "Format of -output and -json results: json, or autocannon for the Node.js autocannon result format")
+	store := fs.String("store", "", "SQLite database to append results to")
+	statsdAddr := fs.String("statsd", "", "Send per-request metrics to this StatsD server (host:port, UDP)")
+	statsdPrefix := fs.String("statsd-prefix", "autocannon", "Prefix of the -statsd metric names")
+	promFile := fs.String("prometheus-file", "", "Write the final metrics in Prometheus text format to this file, e.g. for the node_exporter textfile collector")
+	debug := fs.Bool("debug", false, "Log every request to stderr (same as -vv)")
+	verbose := fs.Bool("v", false, "Log the resolved configuration and a summary every second to stderr")
+	veryVerbose := fs.Bool("vv", false, "Like -v, and also log every request")
+	pprofAddr := fs.String("pprof", "", "Serve net/http/pprof on this address during the run, e.g. :6060")
+	cpuProfile := fs.String("cpuprofile", "", "Write a CPU profile of autocannon itself to this file")
+	memProfile := fs.String("memprofile", "", "Write a heap profile of autocannon itself to this file at the end of the run")
+	force := fs.Bool("force", false, "Run even if the pre-flight request to the target fails or the open file limit is too low")
+	quiet := fs.Bool("quiet", false, "Suppress banners and tables, printing only the final numbers")
+	jsonOut := fs.Bool("json", false, "Print the result JSON to stdout instead of tables")
+	latencyUnitFlag := fs.String("latency-unit", "ms", "Show latencies in us, ms or s")
+	precision := fs.Int("precision", 2, "Show latencies with this many decimals")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	checkpoint := fs.String("checkpoint", "", "Periodically write intermediate results as JSON to this file, rotating older checkpoints")
+	checkpointEvery := fs.Duration("checkpoint-interval", time.Minute, "How often to write a checkpoint")
+	checkpointKeep := fs.Int("checkpoint-keep", 5, "The number of rotated checkpoints to keep")
+	perConnection := fs.Bool("per-connection", false, "Show a per-connection statistics table (always included in the JSON output)")
+	hdrLog := fs.String("hdr-log", "", "Write latency histograms to this HdrHistogram interval log (.hlog)")
+	hdrInterval := fs.Duration("hdr-interval", time.Second, "Interval covered by each -hdr-log histogram")
+	hdrPercentiles := fs.String("hdr-percentiles", "", "Write the full latency distribution in HdrHistogram percentile format (.hgrm)")
+	htmlReport := fs.String("html", "", "Write an HTML report with a latency-over-time heatmap to this file")
+	webAddr := fs.String("web", "", "Serve live charts of the run and its final report to browsers on this address, e.g. 127.0.0.1:8080")
+	controlAddr := fs.String("control", "", "Serve an API to pause, resume, tune and extend the run on this address, e.g. localhost:9000")
+	interactive := fs.Bool("interactive", false, "Read commands from the terminal during the run: Enter pauses and resumes, + and - change the connections, r+ and r- the rate, e extends it")
+	recordFile := fs.String("record", "", "Write every request as a CSV row to this file (gzipped if it ends in .gz, Apache Parquet if it ends in .parquet)")
+	traceParent := fs.Bool("traceparent", false, "Send a W3C traceparent header with a new trace id on every request")
+	traceExemplars := fs.Int("trace-exemplars", 10, "Number of slowest traced requests to report")
+	printInterval := fs.Duration("print-interval", 0, "Print interim statistics at this interval, e.g. 500ms or 30s (0 disables)")
+	abTargets := fs.String("ab", "", "Compare two targets in one run: urlA,urlB. Connections are split evenly between them")
+	percentileList := fs.String("percentiles", "50,90,99,99.9", "Latency percentiles to report in the tables, JSON and exporters, e.g. 50,90,95,99,99.99")
+	trimStart := fs.Duration("trim-start", 0, "Also report statistics without the requests completed in this warm-up period, e.g. 10s")
+	trimEnd := fs.Duration("trim-end", 0, "Also report statistics without the requests completed in this cool-down period at the end of the run")
+	trimOutliers := fs.Float64("trim-outliers", 0, "Also report the latency mean without (trimmed) and with clamped (winsorized) slowest percent of requests, e.g. 1")
+	latencyPhases := fs.Bool("latency-phases", false, "Report the time to the response headers and the time to read the body as separate distributions")
+	llmPrompt := fs.String("llm", "", "Benchmark an OpenAI-style streaming completion API: POST this prompt (a template) as a streamed chat completion and report time to first token and tokens/sec")
+	llmModel := fs.String("llm-model", "", "Model of the -llm requests")
+	llmMaxTokens := fs.Int("llm-max-tokens", 256, "Maximum tokens of every -llm completion (0 leaves it to the server)")
+	throttleMode := fs.Bool("throttle", false, "Count 429 Too Many Requests responses as throttled rather than successful or failed, and report the throttle rate and the throughput the rate limiter allowed")
+	retryAfter := fs.Bool("retry-after", false, "With -throttle, pause a connection that got a 429 for its Retry-After before its next request (closed model)")
+	chunks := fs.Bool("chunks", false, "Time the chunks of streamed response bodies and report the chunks per response and inter-chunk latency percentiles")
+	cdn := fs.Bool("cdn", false, "Classify responses by their cache headers (CF-Cache-Status, X-Cache, Age) and report the hit ratio and latency per cache state")
+	serverIdentity := fs.Bool("server-identity", false, "Report the value distribution of the headers naming the server or CDN location that answered (Server, Via, X-Served-By, X-Amz-Cf-Pop, CF-Ray, Fly-Region)")
+	conditional := fs.Bool("conditional", false, "Revalidate: send If-None-Match/If-Modified-Since with the validators of earlier responses to the same URL, and report 304 rates")
+	rangeSize := fs.Int64("range", 0, "Request byte ranges of this many bytes and check for 206 responses with a matching Content-Range")
+	rangeMode := fs.String("range-mode", "random", "How -range picks ranges: random or sequential")
+	rangeObjectSize := fs.Int64("range-object-size", 0, "The size of the object in bytes (default: the Content-Length of a HEAD request)")
+	var captureHeaders headerListFlag
+	fs.Var(&captureHeaders, "capture-header", "Report the value distribution of these response headers, e.g. X-Cache,Server (repeatable)")
+	var fieldPaths headerListFlag
+	fs.Var(&fieldPaths, "json-field", "Report the distribution of a numeric field of the JSON responses and its correlation with latency, e.g. $.processingTimeMs or $.items.length (repeatable)")
+	headerFiles := headerFileFlag{}
+	fs.Var(headerFiles, "header-file", "Rotate a header through the lines of a file, e.g. X-Api-Key=keys.txt (repeatable)")
+	tags := tagFlag{}
+	fs.Var(tags, "tag", "Tag the run with key=value metadata (repeatable)")
+	fs.Parse(args)
+
+	configureColor(*noColor)
+	switch {
+	case *veryVerbose || *debug:
+		configureLogging(2)
+	case *verbose:
+		configureLogging(1)
+	}
+
+	if err := configureLatencyFormat(*latencyUnitFlag, *precision); err != nil {
+		fmt.Printf("Invalid latency format: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if *uri == "" && *targetsFile == "" && *abTargets == "" {
+		fmt.Println("You must provide a uri or a targets file to benchmark against.")
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+
+	// checkURI fails fast on a malformed uri, defaulting a missing scheme
+	checkURI := func(name, raw string) string {
+		normalized, defaulted, err := normalizeURI(raw)
+		if err != nil {
+			fmt.Printf("Invalid %s %q: %v.\n", name, raw, err)
+			os.Exit(exitConfigError)
+		}
+		if defaulted {
+			fmt.Fprintf(os.Stderr, "No scheme in %s, using %s\n", name, normalized)
+		}
+		return normalized
+	}
+	if *uri != "" {
+		*uri = checkURI("-uri", *uri)
+	}
+
+	if *model != "closed" && *model != "open" {
+		fmt.Printf("Unknown workload model %q, expected closed or open.\n", *model)
+		os.Exit(exitConfigError)
+	}
+
+	if *rate < 0 {
+		fmt.Println("The rate cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+
+	// Pacing is enabled by a rate or a burst pattern
+	paced := *rate > 0 || strings.HasPrefix(*arrivalSpec, "burst:")
+	if *model == "open" && !paced {
+		fmt.Println("The open model requires a positive -rate or a burst -arrival.")
+		os.Exit(exitConfigError)
 	}
+	if paced {
+		if _, err := parseArrival(*arrivalSpec, *rate); err != nil {
+			fmt.Printf("Invalid -arrival: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	} else if *arrivalSpec != "constant" {
+		fmt.Println("The -arrival distribution requires a positive -rate.")
+		os.Exit(exitConfigError)
+	}
+	if *longPoll < 0 {
+		fmt.Println("The long poll hold cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *reconnectEvery < 0 {
+		fmt.Println("The reconnect interval cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *vus < 0 {
+		fmt.Println("The number of virtual users cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *vus > 0 && (*model != "closed" || *replay != "") {
+		fmt.Println("-vus only applies to the closed model.")
+		os.Exit(exitConfigError)
+	}
+	if *connectRate < 0 {
+		fmt.Println("The connect rate cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *connectRate > 0 && (*model != "closed" || *replay != "") {
+		fmt.Println("-connect-rate only applies to the fixed connections of the closed model.")
+		os.Exit(exitConfigError)
+	}
+	if *reduceOnPortExhaustion && (*model != "closed" || *replay != "") {
+		fmt.Println("-reduce-on-port-exhaustion only applies to the fixed connections of the closed model.")
+		os.Exit(exitConfigError)
+	}
+	if *idleTimeout < 0 {
+		fmt.Println("The idle timeout cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *maxPending < 0 {
+		fmt.Println("The maximum pending requests cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *maxPending > 0 && *model != "open" {
+		fmt.Println("-max-pending requires the open model.")
+		os.Exit(exitConfigError)
+	}
+	if *burst < 1 {
+		fmt.Println("The burst size must be at least 1.")
+		os.Exit(exitConfigError)
+	}
+	if *burst > 1 && *rate <= 0 {
+		fmt.Println("-burst requires a positive -rate.")
+		os.Exit(exitConfigError)
+	}
+	if *ratePerConn && (!paced || *model == "open") {
+		fmt.Println("-rate-per-connection requires a -rate and the closed model.")
+		os.Exit(exitConfigError)
+	}
+
+	if *replay != "" {
+		reader, err := openReplay(*replay, *replayFormat)
+		if err != nil {
+			fmt.Printf("Invalid -replay: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		reader.Close()
+		if *replaySpeed <= 0 {
+			fmt.Println("The replay speed must be positive.")
+			os.Exit(exitConfigError)
+		}
+
+		// A replay ends with the log unless a duration is given explicitly
+		durationSet := false
+		fs.Visit(func(f *flag.Flag) {
+			durationSet = durationSet || f.Name == "duration"
+		})
+		if !durationSet {
+			*runtime = 0
+		}
+
+		// Replayed requests are sent at their logged times regardless of
+		// outstanding responses
+		*model = "open"
+	}
+
+	if *runtime < 0 {
+		fmt.Println("The duration cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+
+	// generator stays nil for the plain -uri request
+	var generator RequestGenerator
+	var operations []requestSpec
+	if *openAPI != "" {
+		if *replay != "" {
+			fmt.Println("-openapi cannot be combined with -replay.")
+			os.Exit(exitConfigError)
+		}
+		if *openAPIMode != "round-robin" && *openAPIMode != "per-operation" {
+			fmt.Println("The OpenAPI mode must be 'round-robin' or 'per-operation'.")
+			os.Exit(exitConfigError)
+		}
+		if *openAPIMode == "per-operation" && *runtime == 0 {
+			fmt.Println("-openapi-mode per-operation needs a duration to divide between operations.")
+			os.Exit(exitConfigError)
+		}
+		var err error
+		operations, err = loadOpenAPI(*openAPI, *uri)
+		if err != nil {
+			fmt.Printf("Invalid -openapi: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		generator = newOperationPicker(operations, *openAPIMode, time.Duration(*runtime)*time.Second)
+	}
+
+	var targets []requestSpec
+	if *targetsFile != "" {
+		if *replay != "" || *openAPI != "" {
+			fmt.Println("-targets cannot be combined with -replay or -openapi.")
+			os.Exit(exitConfigError)
+		}
+		var err error
+		targets, err = loadTargets(*targetsFile, *targetsFormat)
+		if err != nil {
+			fmt.Printf("Invalid -targets: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if *uri == "" {
+			*uri = targets[0].URL
+		}
+		generator = newOperationPicker(targets, "round-robin", 0)
+	}
+
+	var requestFeed *feed
+	if *feedFile != "" {
+		if generator != nil || *replay != "" {
+			fmt.Println("-feed cannot be combined with -replay, -openapi or -targets.")
+			os.Exit(exitConfigError)
+		}
+		var err error
+		requestFeed, err = loadFeed(*feedFile)
+		if err != nil {
+			fmt.Printf("Invalid -feed: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if *feedShard != "" {
+			i, n, err := parseFeedShard(*feedShard)
+			if err == nil {
+				err = requestFeed.shard(i, n)
+			}
+			if err != nil {
+				fmt.Printf("Invalid -feed-shard: %v\n", err)
+				os.Exit(exitConfigError)
+			}
+		}
+		switch *feedEnd {
+		case "loop":
+		case "stop":
+			requestFeed.once = true
+			// The run ends with the rows unless a duration is given
+			// explicitly
+			durationSet := false
+			fs.Visit(func(f *flag.Flag) {
+				durationSet = durationSet || f.Name == "duration"
+			})
+			if !durationSet {
+				*runtime = 0
+			}
+		default:
+			fmt.Printf("Invalid -feed-end %q, expected loop or stop.\n", *feedEnd)
+			os.Exit(exitConfigError)
+		}
+		if *feedPartition {
+			if *model == "open" {
+				fmt.Println("-feed-partition splits the rows between connections, and needs the closed model.")
+				os.Exit(exitConfigError)
+			}
+			workers := *clients
+			if *vus > 0 {
+				workers = *vus
+			}
+			if err := requestFeed.partition(workers); err != nil {
+				fmt.Printf("Invalid -feed-partition: %v\n", err)
+				os.Exit(exitConfigError)
+			}
+		}
+	} else if *feedShard != "" || *feedPartition || *feedEnd != "loop" {
+		fmt.Println("-feed-shard, -feed-partition and -feed-end need a -feed.")
+		os.Exit(exitConfigError)
+	}
+
+	var jwt *jwtMinter
+	if *jwtFile != "" {
+		var err error
+		jwt, err = loadJWT(*jwtFile)
+		if err != nil {
+			fmt.Printf("Invalid -jwt: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	var signer *requestSigner
+	if *signFile != "" {
+		var err error
+		signer, err = loadSigner(*signFile)
+		if err != nil {
+			fmt.Printf("Invalid -sign: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	var identities []*clientIdentity
+	if *clientCerts != "" {
+		var err error
+		identities, err = loadClientIdentities(*clientCerts)
+		if err != nil {
+			fmt.Printf("Invalid -client-certs: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	var ntlm *ntlmCredentials
+	if *ntlmAccount != "" {
+		var err error
+		ntlm, err = parseNTLMCredentials(*ntlmAccount, *ntlmScheme)
+		if err != nil {
+			fmt.Printf("Invalid -ntlm: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		// NTLM authenticates a connection, not a request, so every
+		// request needs a connection of its own worker
+		if *model != "closed" || *replay != "" || *vus > 0 {
+			fmt.Println("-ntlm only applies to the closed model without -vus, where every connection has a worker.")
+			os.Exit(exitConfigError)
+		}
+	}
+
+	var digest *digestAuth
+	if *digestAccount != "" {
+		if ntlm != nil {
+			fmt.Println("-digest-auth cannot be combined with -ntlm.")
+			os.Exit(exitConfigError)
+		}
+		var err error
+		digest, err = parseDigestAuth(*digestAccount)
+		if err != nil {
+			fmt.Printf("Invalid -digest-auth: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	var proxy *proxyTunnel
+	if *proxyURL != "" {
+		var err error
+		if proxy, err = parseProxy(*proxyURL); err != nil {
+			fmt.Printf("Invalid -proxy: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	var scenario *scenarioGenerator
+	if *scenarioFile != "" {
+		if generator != nil || *replay != "" {
+			fmt.Println("-scenario cannot be combined with -replay, -openapi or -targets.")
+			os.Exit(exitConfigError)
+		}
+		if requestFeed != nil && requestFeed.overrides {
+			fmt.Println("-feed override columns (@method, @path, ...) cannot be combined with -scenario, whose steps define the requests.")
+			os.Exit(exitConfigError)
+		}
+		var err error
+		scenario, err = loadScenario(*scenarioFile, *uri, requestFeed)
+		if err != nil {
+			fmt.Printf("Invalid -scenario: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if *model != "closed" && (len(scenario.setup) > 0 || len(scenario.teardown) > 0) {
+			fmt.Println("Scenario setup and teardown steps only apply to the closed model.")
+			os.Exit(exitConfigError)
+		}
+		generator = scenario
+		operations = scenario.operations()
+	}
+
+	var hooks *runHooks
+	if *hooksFile != "" {
+		var err error
+		hooks, err = loadRunHooks(*hooksFile, *uri)
+		if err != nil {
+			fmt.Printf("Invalid -hooks: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	if *abTargets != "" {
+		if generator != nil || requestFeed != nil || *replay != "" {
+			fmt.Println("-ab cannot be combined with -replay, -openapi, -targets, -feed or -scenario.")
+			os.Exit(exitConfigError)
+		}
+		a, b, ok := strings.Cut(*abTargets, ",")
+		if !ok || a == "" || b == "" || strings.Contains(b, ",") {
+			fmt.Println("-ab takes two urls separated by a comma: urlA,urlB.")
+			os.Exit(exitConfigError)
+		}
+		if *clients < 2 && *model == "closed" {
+			fmt.Println("-ab needs at least 2 connections to split between the targets.")
+			os.Exit(exitConfigError)
+		}
+		a, b = checkURI("-ab", a), checkURI("-ab", b)
+		ab := newABGenerator(*method, *body, a, b)
+		generator = ab
+		operations = ab.operations()
+		if *uri == "" {
+			*uri = a
+		}
+	}
+
+	if *retryAfter && !*throttleMode {
+		fmt.Println("-retry-after needs -throttle.")
+		os.Exit(exitConfigError)
+	}
+	if *retryAfter && *model == "open" {
+		fmt.Println("-retry-after pauses connections, and needs the closed model.")
+		os.Exit(exitConfigError)
+	}
+	if *adaptive && (*rate <= 0 || *replay != "") {
+		fmt.Println("-adaptive changes the rate, and needs a -rate to start at without -replay.")
+		os.Exit(exitConfigError)
+	}
+	if *adaptiveErrors < 0 || *adaptiveP99 < 0 {
+		fmt.Println("The -adaptive thresholds cannot be negative.")
+		os.Exit(exitConfigError)
+	}
+
+	var jsonFields []jsonField
+	for _, path := range fieldPaths {
+		field, err := parseJSONField(path)
+		if err != nil {
+			fmt.Printf("Invalid -json-field: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		jsonFields = append(jsonFields, field)
+	}
+
+	if *llmPrompt != "" {
+		if generator != nil || *replay != "" || *body != "" {
+			fmt.Println("-llm cannot be combined with -body, -replay, -openapi, -targets, -scenario or -ab.")
+			os.Exit(exitConfigError)
+		}
+		if *llmMaxTokens < 0 {
+			fmt.Println("-llm-max-tokens must not be negative.")
+			os.Exit(exitConfigError)
+		}
+		var err error
+		if *body, err = llmRequestBody(*llmPrompt, *llmModel, *llmMaxTokens); err != nil {
+			fmt.Printf("Invalid -llm: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if *method == "GET" {
+			*method = "POST"
+		}
+	}
+
+	// Template actions in the uri or body, or a feed, render every request
+	if generator == nil && (requestFeed != nil || isTemplate(*uri) || isTemplate(*body)) {
+		var err error
+		generator, err = newTemplateGenerator(requestSpec{Method: *method, URL: *uri, Body: *body}, requestFeed)
+		if err != nil {
+			fmt.Printf("Invalid request template: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	// Built-in response assertions. -expect only applies when given, so
+	// plain runs keep counting every response as successful.
+	percentiles, err := parsePercentiles(*percentileList)
+	if err != nil {
+		fmt.Printf("Invalid -percentiles: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if *trimStart < 0 || *trimEnd < 0 {
+		fmt.Println("-trim-start and -trim-end must not be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *trimOutliers < 0 || *trimOutliers >= 50 {
+		fmt.Println("-trim-outliers must be at least 0 and below 50 percent.")
+		os.Exit(exitConfigError)
+	}
+	if *runtime > 0 && (*trimStart+*trimEnd).Seconds() >= float64(*runtime) {
+		fmt.Println("-trim-start and -trim-end leave nothing of the duration.")
+		os.Exit(exitConfigError)
+	}
+
+	var validators []Validator
+	expectSet := false
+	fs.Visit(func(f *flag.Flag) {
+		expectSet = expectSet || f.Name == "expect"
+	})
+	if expectSet {
+		validators = append(validators, statusValidator{expected: *expectStatus})
+	}
+	if *expectBody != "" {
+		validators = append(validators, bodyContainsValidator{substring: []byte(*expectBody)})
+	}
+	if *verifySHA256 != "" && *verifySHA256Column != "" {
+		fmt.Println("-verify-sha256 and -verify-sha256-column cannot be combined.")
+		os.Exit(exitConfigError)
+	}
+	if *verifySHA256 != "" {
+		if digest, err := hex.DecodeString(*verifySHA256); err != nil || len(digest) != sha256.Size {
+			fmt.Printf("Invalid -verify-sha256 %q: expected 64 hex digits\n", *verifySHA256)
+			os.Exit(exitConfigError)
+		}
+		validators = append(validators, checksumValidator{expected: *verifySHA256})
+	}
+	if *verifySHA256Column != "" {
+		if requestFeed == nil {
+			fmt.Println("-verify-sha256-column needs a -feed.")
+			os.Exit(exitConfigError)
+		}
+		if _, ok := requestFeed.rows[0][*verifySHA256Column]; !ok {
+			fmt.Printf("The -feed has no %q column for -verify-sha256-column.\n", *verifySHA256Column)
+			os.Exit(exitConfigError)
+		}
+		validators = append(validators, checksumValidator{column: *verifySHA256Column})
+	}
+
+	var ranges *rangeSelector
+	if *rangeSize < 0 {
+		fmt.Println("-range must not be negative.")
+		os.Exit(exitConfigError)
+	}
+	if *rangeSize > 0 {
+		size := *rangeObjectSize
+		if size == 0 {
+			var err error
+			if size, err = objectSize(*uri); err != nil {
+				fmt.Printf("Error finding the object size for -range: %v\n", err)
+				fmt.Println("Set it with -range-object-size.")
+				os.Exit(exitConfigError)
+			}
+		}
+		var err error
+		if ranges, err = newRangeSelector(*rangeSize, size, *rangeMode); err != nil {
+			fmt.Printf("Invalid -range: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		validators = append(validators, rangeValidator{objectSize: size})
+	}
+
+	if *outputFormat != "json" && *outputFormat != "autocannon" {
+		fmt.Printf("Unknown output format %q, expected json or autocannon.\n", *outputFormat)
+		os.Exit(exitConfigError)
+	}
+
+	if *hdrLog != "" && *hdrInterval <= 0 {
+		fmt.Println("The HdrHistogram log interval must be positive.")
+		os.Exit(exitConfigError)
+	}
+
+	if *checkpoint != "" && *checkpointEvery <= 0 {
+		fmt.Println("The checkpoint interval must be positive.")
+		os.Exit(exitConfigError)
+	}
+
+	// Sinks produce all output: the summary, then the result files and
+	// exporters
+	var sinks []Sink
+	switch {
+	case *jsonOut:
+		sinks = append(sinks, jsonSink{format: *outputFormat})
+	case *quiet:
+		sinks = append(sinks, quietSink{})
+	default:
+		sinks = append(sinks, consoleSink{showConnections: *perConnection})
+	}
+	if *output != "" {
+		sinks = append(sinks, fileSink{path: *output, format: *outputFormat})
+	}
+	if *store != "" {
+		sinks = append(sinks, storeSink{path: *store})
+	}
+	if *promFile != "" {
+		sinks = append(sinks, prometheusSink{path: *promFile})
+	}
+	if *htmlReport != "" {
+		sinks = append(sinks, newHTMLSink(*htmlReport))
+	}
+	if *recordFile != "" {
+		var samples Sink
+		var err error
+		if strings.HasSuffix(*recordFile, ".parquet") {
+			samples, err = newParquetRecorder(*recordFile)
+		} else {
+			samples, err = newSampleRecorder(*recordFile)
+		}
+		if err != nil {
+			fmt.Printf("Error creating sample file: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		sinks = append(sinks, samples)
+	}
+	if *vegetaResults != "" {
+		vegeta, err := newVegetaEncoder(*vegetaResults)
+		if err != nil {
+			fmt.Printf("Error creating vegeta results file: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		sinks = append(sinks, vegeta)
+	}
+	if *statsdAddr != "" {
+		statsd, err := newStatsDSink(*statsdAddr, *statsdPrefix)
+		if err != nil {
+			fmt.Printf("Invalid -statsd: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		sinks = append(sinks, statsd)
+	}
+
+	// Header values rotated per request
+	if *headerRotation != "round-robin" && *headerRotation != "random" {
+		fmt.Printf("Unknown header rotation %q, expected round-robin or random.\n", *headerRotation)
+		os.Exit(exitConfigError)
+	}
+	var userAgents *valuePool
+	if *userAgentFile != "" {
+		var err error
+		userAgents, err = loadValuePool(*userAgentFile)
+		if err != nil {
+			fmt.Printf("Invalid -user-agent-file: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		userAgents.random = *headerRotation == "random"
+	}
+	// With a forced Accept-Encoding, responses are read as served, and
+	// their Content-Encoding distribution is reported
+	if *acceptEncoding != "" {
+		if err := validateAcceptEncoding(*acceptEncoding); err != nil {
+			fmt.Printf("Invalid -accept-encoding: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if !slices.ContainsFunc(captureHeaders, func(name string) bool { return strings.EqualFold(name, "Content-Encoding") }) {
+			captureHeaders = append(captureHeaders, "Content-Encoding")
+		}
+	}
+	headerPools := make(map[string]*valuePool)
+	for name, path := range headerFiles {
+		pool, err := loadValuePool(path)
+		if err != nil {
+			fmt.Printf("Invalid -header-file %s: %v\n", name, err)
+			os.Exit(exitConfigError)
+		}
+		pool.random = *headerRotation == "random"
+		headerPools[name] = pool
+	}
+
+	// Print parameters
+	if !*quiet && !*jsonOut {
+		fmt.Print(colorize(chalk.Green, "Starting autocannon with the following parameters:"), "\n")
+		fmt.Printf("URI: %s\n", *uri)
+		fmt.Printf("Connections: %d\n", *clients)
+		if *vus > 0 {
+			fmt.Printf("Virtual users: %d\n", *vus)
+		}
+		if *runtime > 0 {
+			fmt.Printf("Duration: %d seconds\n", *runtime)
+		} else if *replay != "" {
+			fmt.Println("Duration: until the replay log ends")
+		} else if *feedEnd == "stop" {
+			fmt.Println("Duration: until the feed rows are used")
+		} else {
+			fmt.Println("Duration: until interrupted")
+		}
+		fmt.Printf("Timeout: %d seconds\n", *timeout)
+		if *headerTimeout > 0 {
+			fmt.Printf("Header timeout: %s\n", *headerTimeout)
+		}
+		if *longPoll > 0 {
+			fmt.Printf("Long poll: held up to %s\n", *longPoll)
+		}
+		fmt.Printf("Method: %s\n", *method)
+		for name, pool := range headerPools {
+			fmt.Printf("%s: rotating %d values (%s)\n", name, len(pool.values), *headerRotation)
+		}
+		if userAgents != nil {
+			fmt.Printf("User-Agent: rotating %d values from %s\n", len(userAgents.values), *userAgentFile)
+		} else {
+			fmt.Printf("User-Agent: %s\n", *userAgent)
+		}
+		if *acceptEncoding != "" {
+			fmt.Printf("Accept-Encoding: %s\n", *acceptEncoding)
+		}
+		if *rate > 0 && *ratePerConn {
+			fmt.Printf("Model: %s (%.2f req/sec per connection, %.2f total)\n", *model, *rate, *rate*float64(*clients))
+		} else if *rate > 0 {
+			fmt.Printf("Model: %s (%.2f req/sec)\n", *model, *rate)
+		} else {
+			fmt.Printf("Model: %s\n", *model)
+		}
+		if paced {
+			fmt.Printf("Arrival: %s\n", *arrivalSpec)
+		}
+		if *burst > 1 {
+			fmt.Printf("Burst: %d\n", *burst)
+		}
+		if ranges != nil {
+			fmt.Printf("Byte ranges: %d bytes, %s, of a %d byte object\n", ranges.size, *rangeMode, ranges.objectSize)
+		}
+		if *maxPending > 0 {
+			fmt.Printf("Max pending: %d\n", *maxPending)
+		}
+		if *reconnectEvery > 0 {
+			fmt.Printf("Reconnect every: %d requests\n", *reconnectEvery)
+		}
+		if *idleTimeout != 90*time.Second {
+			fmt.Printf("Idle timeout: %s\n", *idleTimeout)
+		}
+		if *noHappyEyeballs {
+			fmt.Println("Happy Eyeballs: disabled")
+		}
+		if *noTLSResumption {
+			fmt.Println("TLS session resumption: disabled")
+		}
+		if jwt != nil {
+			fmt.Printf("JWT: %s (%s in %s)\n", *jwtFile, jwt.algorithm, jwt.header)
+		}
+		if signer != nil {
+			fmt.Printf("Signature: %s\n", *signFile)
+		}
+		if ntlm != nil {
+			fmt.Printf("NTLM: %s as %s\n", ntlm.scheme, ntlm.account())
+		}
+		if digest != nil {
+			fmt.Printf("Digest auth: %s\n", digest.user)
+		}
+		if proxy != nil {
+			fmt.Printf("Proxy: %s\n", proxy.proxy.Redacted())
+		}
+		if len(identities) > 0 {
+			fmt.Printf("Client certificates: %s (%d identities)\n", *clientCerts, len(identities))
+		}
+		if *targetsFile != "" {
+			fmt.Printf("Targets: %s (%d targets)\n", *targetsFile, len(targets))
+		}
+		if *scenarioFile != "" {
+			fmt.Printf("Scenario: %s (%d steps)\n", *scenarioFile, len(operations))
+		}
+		if hooks != nil {
+			fmt.Printf("Hooks: %s (%d setup, %d teardown requests)\n", *hooksFile, len(hooks.setup), len(hooks.teardown))
+		}
+		if *feedFile != "" {
+			var details []string
+			if *feedShard != "" {
+				details = append(details, "shard "+*feedShard)
+			}
+			if *feedPartition {
+				details = append(details, "partitioned")
+			}
+			if *feedEnd == "stop" {
+				details = append(details, "each row once")
+			}
+			fmt.Printf("Feed: %s (%d rows", *feedFile, len(requestFeed.rows))
+			for _, detail := range details {
+				fmt.Printf(", %s", detail)
+			}
+			fmt.Println(")")
+		}
+		if *openAPI != "" {
+			fmt.Printf("OpenAPI: %s (%d operations, %s)\n", *openAPI, len(operations), *openAPIMode)
+		}
+		if *replay != "" {
+			fmt.Printf("Replay: %s (%s format, %.2fx speed)\n", *replay, *replayFormat, *replaySpeed)
+		}
+		if *traceParent {
+			fmt.Printf("Trace context: traceparent (keeping %d slowest)\n", *traceExemplars)
+		}
+		if *expectContinue {
+			fmt.Printf("Expect: 100-continue (timeout %s)\n", *continueTimeout)
+		}
+		if expectSet {
+			fmt.Printf("Expected status: %d\n", *expectStatus)
+		}
+		if *expectBody != "" {
+			fmt.Printf("Expected body: contains %q\n", *expectBody)
+		}
+		if *output != "" {
+			fmt.Printf("Output file: %s\n", *output)
+		}
+		if *store != "" {
+			fmt.Printf("Results store: %s\n", *store)
+		}
+		if *statsdAddr != "" {
+			fmt.Printf("StatsD: %s (prefix %s)\n", *statsdAddr, *statsdPrefix)
+		}
+		if *promFile != "" {
+			fmt.Printf("Prometheus file: %s\n", *promFile)
+		}
+		if len(tags) > 0 {
+			fmt.Printf("Tags: %s\n", formatTags(tags))
+		}
+		if *printInterval > 0 {
+			fmt.Printf("Print interval: %s\n", *printInterval)
+		}
+		if *checkpoint != "" {
+			fmt.Printf("Checkpoint: %s every %s (keeping %d)\n", *checkpoint, *checkpointEvery, *checkpointKeep)
+		}
+		fmt.Println(colorize(chalk.Green, "Starting autocannon..."))
+	}
+
+	// Configure the benchmark
+	config := BenchmarkConfig{
+		URI:              *uri,
+		Connections:      *clients,
+		VUs:              *vus,
+		Duration:         *runtime,
+		Timeout:          *timeout,
+		HeaderTimeout:    *headerTimeout,
+		LongPoll:         *longPoll,
+		Method:           *method,
+		Model:            *model,
+		Rate:             *rate,
+		RatePerConn:      *ratePerConn,
+		Burst:            *burst,
+		MaxPending:       *maxPending,
+		ReconnectEvery:   *reconnectEvery,
+		ConnectRate:      *connectRate,
+		RetireOnNoPorts:  *reduceOnPortExhaustion,
+		IdleTimeout:      *idleTimeout,
+		NoHappyEyeballs:  *noHappyEyeballs,
+		NoTLSResumption:  *noTLSResumption,
+		ClientIdentities: identities,
+		NTLM:             ntlm,
+		Digest:           digest,
+		Proxy:            proxy,
+		Arrival:          *arrivalSpec,
+		ReplayFile:       *replay,
+		ReplayFormat:     *replayFormat,
+		ReplaySpeed:      *replaySpeed,
+		Headers:          map[string]string{},
+		AcceptEncoding:   *acceptEncoding,
+		UserAgent:        *userAgent,
+		UserAgents:       userAgents,
+		HeaderPools:      headerPools,
+		JWT:              jwt,
+		Signer:           signer,
+		RequestIDHeader:  *requestID,
+		IdempotencyKey:   *idempotencyKey,
+		CaptureHeaders:   captureHeaders,
+		Conditional:      *conditional,
+		CDN:              *cdn,
+		ServerIdentity:   *serverIdentity,
+		LatencyPhases:    *latencyPhases,
+		Chunks:           *chunks,
+		LLM:              *llmPrompt != "",
+		LLMModel:         *llmModel,
+		JSONFields:       jsonFields,
+		Throttle:         *throttleMode,
+		RetryAfter:       *retryAfter,
+		Adaptive:         *adaptive,
+		AdaptiveErrors:   *adaptiveErrors,
+		AdaptiveP99:      *adaptiveP99,
+		ABTest:           *abTargets != "",
+		Percentiles:      percentiles,
+		TrimStart:        *trimStart,
+		TrimEnd:          *trimEnd,
+		TrimOutliers:     *trimOutliers,
+		Ranges:           ranges,
+		Body:             *body,
+		ExpectContinue:   *expectContinue,
+		ContinueTimeout:  *continueTimeout,
+		ExpectStatusCode: *expectStatus,
+		Tags:             tags,
+		ShowConnections:  *perConnection,
+		PrintInterval:    *printInterval,
+		Quiet:            *quiet,
+		JSONOutput:       *jsonOut,
+		CheckpointFile:   *checkpoint,
+		CheckpointEvery:  *checkpointEvery,
+		CheckpointKeep:   *checkpointKeep,
+		HdrLogFile:       *hdrLog,
+		HdrInterval:      *hdrInterval,
+		HdrPercentiles:   *hdrPercentiles,
+		TraceParent:      *traceParent,
+		TraceExemplars:   *traceExemplars,
+		OpenAPIFile:      *openAPI,
+		OpenAPIMode:      *openAPIMode,
+		Operations:       operations,
+		Generator:        generator,
+		Feed:             requestFeed,
+		Validators:       validators,
+		Sinks:            sinks,
+	}
+	if scenario != nil {
+		config.Checks = scenario.checks
+	}
+	if config.LLM {
+		config.Headers["Content-Type"] = "application/json"
+		config.Headers["Accept"] = "text/event-stream"
+	}
+
+	logConfig(config)
+
+	// Each connection needs a file descriptor. The open model's
+	// concurrency is estimated from the rate and the timeout.
+	switch {
+	case config.ReplayFile != "":
+	case config.Model == "open":
+		concurrent := uint64(config.Rate*float64(config.Timeout)) + 1
+		if config.MaxPending > 0 && uint64(config.MaxPending) < concurrent {
+			concurrent = uint64(config.MaxPending)
+		}
+		checkFileLimit(concurrent, true, *force)
+	default:
+		if !checkFileLimit(uint64(config.Connections), false, *force) {
+			os.Exit(exitConfigError)
+		}
+	}
+
+	// Probe the target once before starting all workers
+	probes := []string{config.URI}
+	if ab, ok := config.Generator.(*abGenerator); ok {
+		probes = []string{ab.specs[0].URL, ab.specs[1].URL}
+	}
+	outside := outsideClient(config.ClientIdentities, proxyURLOf(config.Proxy), time.Duration(config.Timeout)*time.Second)
+	for _, probe := range probes {
+		if err := preflight(outside, probe); err != nil {
+			if !confirmAfterPreflight(probe, err, *force) {
+				os.Exit(exitUnreachable)
+			}
+		}
+	}
+
+	// The run's setup requests are sent before anything is measured; the
+	// teardown requests also clean up after a failed setup
+	teardown := func() {
+		if hooks == nil {
+			return
+		}
+		if err := hooks.run(outside, hooks.teardown, config.Headers); err != nil {
+			fmt.Fprintf(os.Stderr, "Teardown failed: %v\n", err)
+		}
+	}
+	if hooks != nil {
+		if err := hooks.run(outside, hooks.setup, config.Headers); err != nil {
+			fmt.Fprintf(os.Stderr, "Setup failed, not running the benchmark: %v\n", err)
+			teardown()
+			os.Exit(exitSetupFailed)
+		}
+	}
+
+	// The run can be paused and tuned from the terminal with -interactive
+	// or the control API, and paused from the web UI
+	control := newRunControl()
+	config.Control = control
+	if *controlAddr != "" {
+		if err := control.serveControl(*controlAddr); err != nil {
+			fmt.Printf("Error starting the control API: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+	if *interactive {
+		if !*quiet {
+			fmt.Fprintln(os.Stderr, "Press Enter to pause or resume the run; type + or - and Enter to change the connections, r+ or r- to change the rate, e to run 30s longer")
+		}
+		go control.readKeyboard(os.Stdin)
+	}
+
+	var web *webUI
+	if *webAddr != "" {
+		var err error
+		web, err = startWebUI(*webAddr, config.URI, control)
+		if err != nil {
+			fmt.Printf("Error starting the web UI: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		config.Handlers = append(config.Handlers, web)
+	}
+
+	prof, err := startProfiling(*pprofAddr, *cpuProfile, *memProfile)
+	if err != nil {
+		fmt.Printf("Error starting profiling: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	// Run the benchmark
+	result := runBenchmark(config)
+	teardown()
+	if err := prof.stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing profile: %v\n", err)
+	}
+	// The web UI keeps the report up for sharing, unless the run itself
+	// was interrupted
+	if web != nil && !result.Interrupted {
+		web.wait()
+	}
+	os.Exit(exitCode(result))
+}
+
+// newPacer builds a limiter for the rate and the configured arrival
+// distribution, which main has already validated
+func newPacer(config BenchmarkConfig, rate float64) *limiter {
+	var every time.Duration
+	if rate > 0 {
+		every = time.Duration(float64(time.Second) / rate)
+	}
+	a, err := parseArrival(config.Arrival, rate)
+	if err != nil {
+		a = constantArrival{every: every}
+	}
+	return newLimiter(a, config.Burst, every)
+}
+
+// requestSpec is what varies between requests: by default every request
+// uses the configured method and uri
+type requestSpec struct {
+	Method      string
+	URL         string
+	UserAgent   string
+	Body        string
+	ContentType string
+	Header      http.Header
+
+	// Operation and Path identify OpenAPI operations for per-operation
+	// statistics
+	Operation string
+	Path      string
+}
+
+func runBenchmark(config BenchmarkConfig) BenchmarkResult {
+	result := BenchmarkResult{
+		SchemaVersion:    ResultSchemaVersion,
+		URI:              config.URI,
+		Method:           config.Method,
+		Model:            config.Model,
+		Rate:             config.Rate,
+		RatePerConn:      config.RatePerConn,
+		Arrival:          config.Arrival,
+		AcceptEncoding:   config.AcceptEncoding,
+		Connections:      config.Connections,
+		VUs:              config.VUs,
+		StatusCodeCounts: make(map[int]int64),
+		Timestamp:        wallTime(time.Now()),
+		Tags:             config.Tags,
+		Metadata:         collectMetadata(),
+	}
+
+	var wg sync.WaitGroup
+	var totalRequests int64
+	var successfulReqs int64
+	var failedReqs int64
+	var timeouts int64
+	var connectTimeouts int64
+	var headerTimeouts int64
+	var bodyTimeouts int64
+	var bytesRead int64
+	var bytesWritten int64
+	var statusCodeMutex sync.Mutex
+	// For latency tracking
+	var totalLatency float64
+	var minLatency float64 = float64(^uint64(0) >> 1) // Max float64 value
+	var maxLatency float64
+
+	// Channel to collect latency measurements
+	latencyChan := make(chan float64, 1000)
+
+	// Full latency distribution, in microseconds
+	latencyHist := newLatencyHistogram()
+
+	// Per-second samples of completed requests and bytes read
+	requestSamples := newCountHistogram()
+	throughputSamples := newCountHistogram()
+
+	// Create a client with specified timeouts. The client timeout bounds
+	// the whole request including the body; the transport's header
+	// timeout only bounds the wait for response headers.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = config.HeaderTimeout
+	// A long-polling server may hold a request for the whole hold before
+	// answering, so the timeouts only start after it
+	if config.HeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout += config.LongPoll
+	}
+	transport.ExpectContinueTimeout = config.ContinueTimeout
+	// Keep an idle connection per worker, so connections are only
+	// replaced when asked to
+	transport.MaxIdleConns = 0
+	transport.MaxIdleConnsPerHost = config.Connections
+	transport.IdleConnTimeout = config.IdleTimeout
+	// Without a forced Accept-Encoding, Go asks for gzip and decompresses
+	// transparently; with one, bodies are read as they were sent
+	transport.DisableCompression = config.AcceptEncoding != ""
+
+	// Workers are the connections, or the virtual users sharing them
+	workers := config.Connections
+	if config.VUs > 0 {
+		workers = config.VUs
+		transport.MaxConnsPerHost = config.Connections
+	}
+
+	// Dual-stack targets are dialed with Happy Eyeballs (racing IPv6 and
+	// IPv4) unless disabled; the timeouts match http.DefaultTransport's
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if config.NoHappyEyeballs {
+		dialer.FallbackDelay = -1
+	}
+	transport.DialContext = dialer.DialContext
+
+	// With -proxy every connection is a tunnel through the proxy, which
+	// the environment's proxy settings would bypass
+	tunnel := config.Proxy
+	if tunnel != nil {
+		tunnel.dial = dialer.DialContext
+		transport.DialContext = tunnel.dialContext
+		transport.Proxy = nil
+	}
+
+	// New connections resume TLS sessions from earlier ones, as browsers
+	// do, unless disabled
+	transport.TLSClientConfig = &tls.Config{}
+	if config.NoTLSResumption {
+		transport.TLSClientConfig.SessionTicketsDisabled = true
+	} else {
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(config.Connections)
+	}
+
+	// Connection setup, and -reconnect-every for requests that are not
+	// sent by a fixed connection
+	var connects connectTracker
+	sizes := newSizeTracker()
+	var phases *phaseTracker
+	if config.LatencyPhases {
+		phases = newPhaseTracker()
+	}
+	var chunks *chunkTracker
+	if config.Chunks {
+		chunks = newChunkTracker()
+	}
+	var llm *llmTracker
+	if config.LLM {
+		llm = newLLMTracker(config.LLMModel)
+	}
+	serverTiming := newServerTimingTracker()
+	rateLimits := newRateLimitTracker()
+	var adaptive *adaptiveController
+	if config.Adaptive {
+		adaptive = newAdaptiveController(config.Rate, config.AdaptiveErrors, config.AdaptiveP99)
+	}
+	var throttle *throttleTracker
+	var throttledReqs int64
+	if config.Throttle {
+		throttle = newThrottleTracker(config.RetryAfter)
+	}
+	var fields *fieldTracker
+	if len(config.JSONFields) > 0 {
+		fields = newFieldTracker(config.JSONFields)
+	}
+	var cdnCache *cdnTracker
+	if config.CDN {
+		cdnCache = newCDNTracker()
+	}
+	var identities *headerCapture
+	if config.ServerIdentity {
+		identities = newIdentityCapture()
+	}
+	var longPoll *longPollTracker
+	if config.LongPoll > 0 {
+		longPoll = newLongPollTracker(config.LongPoll)
+	}
+	var revalidation *conditionalTracker
+	if config.Conditional {
+		revalidation = newConditionalTracker()
+	}
+	var captured *headerCapture
+	if len(config.CaptureHeaders) > 0 {
+		captured = newHeaderCapture(config.CaptureHeaders)
+	}
+	var tlsOnce sync.Once
+	var unpinnedRequests int64
+
+	// Expect: 100-continue applies only to requests with a body
+	expectContinue := config.ExpectContinue
+	var continueSent int64
+	var continueReceived int64
+	var continueLatency latencyTracker
+
+	// Outstanding open model requests, for -max-pending
+	var pending, pendingPeak, pendingCapHits int64
+
+	// Connections that have had their first response, and when the last
+	// one did, for the closed model's ramp-up
+	var rampedUp, rampUp int64
+
+	// Dials that found no free local port, and the connections retired
+	// for it with -reduce-on-port-exhaustion
+	var portExhaustion, retiredConnections int64
+	activeConnections := int64(workers)
+
+	// Interim 1xx responses and trailers
+	informational := newInformationalTracker()
+
+	// Per-connection (or virtual user) totals, indexed by worker id
+	connTrackers := make([]*connectionTracker, workers)
+	for i := range connTrackers {
+		connTrackers[i] = &connectionTracker{identity: identityFor(config.ClientIdentities, i)}
+	}
+
+	// W3C trace context, keeping the slowest requests as exemplars
+	var traces *exemplarTracker
+	if config.TraceParent {
+		traces = newExemplarTracker(config.TraceExemplars)
+	}
+
+	// Event handlers, followed by the sinks
+	handlers := append([]EventHandler(nil), config.Handlers...)
+	for _, sink := range config.Sinks {
+		handlers = append(handlers, sinkHandler{sink: sink})
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(config.Timeout)*time.Second + config.LongPoll,
+	}
+	// Each client certificate has connections of its own, so a connection
+	// always presents the same identity
+	for _, id := range config.ClientIdentities {
+		id.client = &http.Client{Transport: id.identityTransport(transport, config.Connections), Timeout: client.Timeout}
+	}
+	var unpinnedIdentity int64
+
+	// Create a stop channel that will signal workers to stop
+	stopChan := make(chan struct{})
+
+	// While the run is paused no requests are sent, and its clock stops:
+	// runClock is the wall clock with the pauses cut out
+	control := config.Control
+	if control == nil {
+		control = newRunControl()
+	}
+	runClock := func(now time.Time) time.Time {
+		return now.Add(-control.pausedFor(now))
+	}
+
+	// The rate and, in the closed model, the number of connections can be
+	// tuned during the run. addWorker starts another connection.
+	pacers := newPacerSet(config)
+	var tunableConnections int
+	var addWorker func()
+
+	// fillTotals copies the running totals into r. It must only be called
+	// from the latency collector goroutine or once it has finished.
+	fillTotals := func(r *BenchmarkResult, elapsed time.Duration) {
+		r.Duration = int(elapsed.Round(time.Second).Seconds())
+		r.Requests.Total = atomic.LoadInt64(&totalRequests)
+		r.Requests.Successful = atomic.LoadInt64(&successfulReqs)
+		r.Requests.Failed = atomic.LoadInt64(&failedReqs)
+		r.Requests.Timeouts = atomic.LoadInt64(&timeouts)
+		r.Requests.ConnectTimeouts = atomic.LoadInt64(&connectTimeouts)
+		r.Requests.HeaderTimeouts = atomic.LoadInt64(&headerTimeouts)
+		r.Requests.BodyTimeouts = atomic.LoadInt64(&bodyTimeouts)
+		r.Requests.Throttled = atomic.LoadInt64(&throttledReqs)
+		r.Throughput.BytesRead = atomic.LoadInt64(&bytesRead)
+		r.Throughput.BytesWritten = atomic.LoadInt64(&bytesWritten)
+
+		if r.Requests.Total > 0 && elapsed > 0 {
+			r.Throughput.RequestsPerSecond = float64(r.Requests.Total) / elapsed.Seconds()
+			r.Requests.ErrorRate = float64(r.Requests.Failed) / float64(r.Requests.Total) * 100
+		}
+
+		// Every request's latency is recorded, failed ones too
+		if r.Requests.Total > 0 {
+			r.Latency.Average = totalLatency / float64(r.Requests.Total)
+			r.Latency.Min = minLatency
+			r.Latency.Max = maxLatency
+		}
+	}
+
+	// Workers send the configured request unless a generator is given
+	generator := config.Generator
+	if generator == nil {
+		generator = staticGenerator{spec: requestSpec{Method: config.Method, URL: config.URI, Body: config.Body}}
+	}
+	scenario, _ := generator.(*scenarioGenerator)
+
+	// Response validation, when any validators are configured
+	validation := newValidationTracker()
+
+	// Requests tagged with an operation are also counted per operation
+	operations := newOperationTracker(config.Operations)
+	var abSamples *abTracker
+	if config.ABTest {
+		abSamples = newABTracker()
+	}
+
+	// finish ends the run early, e.g. when a replayed log or a generator
+	// is exhausted
+	finished := make(chan struct{})
+	var finishOnce sync.Once
+	finish := func() {
+		finishOnce.Do(func() { close(finished) })
+	}
+
+	// sendRequest issues a single request from gen and records its
+	// outcome. conn is nil when requests are not tied to a worker (the
+	// open model). Latency is measured from startTime, which in the open
+	// model is the scheduled send time, so time spent queued behind a slow
+	// server is not hidden. It returns false once gen is exhausted.
+	sendRequest := func(conn *connectionTracker, startTime time.Time, gen RequestGenerator) bool {
+		// Create request
+		connID := -1
+		if conn != nil {
+			connID = conn.id
+		}
+		requestID := newUUID()
+		req, err := gen.Next(withRequestID(withWorker(context.Background(), connID), requestID))
+		if err == io.EOF {
+			finish()
+			return false
+		}
+		if err == errWorkerDone {
+			if atomic.AddInt64(&activeConnections, -1) == 0 {
+				finish()
+			}
+			return false
+		}
+		// A request that can't be built or signed fails without being sent
+		requestFailed := func(err error) bool {
+			atomic.AddInt64(&failedReqs, 1)
+			logTrace("request error", "error", err)
+			failure := Sample{Start: startTime, Connection: connID, ErrorClass: "request", Error: err.Error(), RequestID: requestID}
+			for _, h := range handlers {
+				h.OnRequestDone(failure)
+				h.OnError(failure)
+			}
+			return true
+		}
+		if err == nil && config.JWT != nil {
+			err = config.JWT.sign(req, connID, requestID)
+		}
+		if err != nil {
+			return requestFailed(err)
+		}
+
+		// Add headers the generator did not set
+		for key, value := range config.Headers {
+			if req.Header.Get(key) == "" {
+				req.Header.Add(key, value)
+			}
+		}
+		if config.AcceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", config.AcceptEncoding)
+		}
+		if config.RequestIDHeader && req.Header.Get("X-Request-ID") == "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		// The key is set once per request: resending it, to answer an auth
+		// challenge or follow a redirect, keeps it
+		if config.IdempotencyKey && (req.Method == http.MethodPost || req.Method == http.MethodPatch) && req.Header.Get("Idempotency-Key") == "" {
+			req.Header.Set("Idempotency-Key", requestID)
+		}
+		for name, pool := range config.HeaderPools {
+			if req.Header.Get(name) == "" {
+				req.Header.Set(name, pool.next())
+			}
+		}
+		if config.Ranges != nil && req.Header.Get("Range") == "" {
+			start, end := config.Ranges.pick()
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		}
+		if revalidation != nil {
+			revalidation.prepare(req)
+		}
+		if req.Header.Get("User-Agent") == "" {
+			if config.UserAgents != nil {
+				req.Header.Set("User-Agent", config.UserAgents.next())
+			} else if config.UserAgent != "" {
+				req.Header.Set("User-Agent", config.UserAgent)
+			}
+		}
+		var traceID string
+		if traces != nil {
+			var traceparent string
+			traceID, traceparent = newTraceparent()
+			req.Header.Set("traceparent", traceparent)
+		}
+		// The signature covers the headers set above
+		if config.Signer != nil {
+			if err := config.Signer.sign(req); err != nil {
+				return requestFailed(err)
+			}
+		}
+
+		// Trace interim 1xx responses, and the 100 Continue
+		// round trip when enabled
+		seen1xx := false
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				informational.recordInformational(code, time.Since(startTime), !seen1xx)
+				seen1xx = true
+				return nil
+			},
+		}
+		if expectContinue && req.Body != nil && req.Body != http.NoBody {
+			req.Header.Set("Expect", "100-continue")
+			atomic.AddInt64(&continueSent, 1)
+
+			var wroteHeaders time.Time
+			trace.WroteHeaders = func() {
+				wroteHeaders = time.Now()
+			}
+			trace.Got100Continue = func() {
+				atomic.AddInt64(&continueReceived, 1)
+				continueLatency.record(float64(time.Since(wroteHeaders).Microseconds()) / 1000)
+			}
+		}
+		connects.hooks(trace)
+		if tunnel != nil {
+			tunnel.hooks(trace)
+		}
+		// A request that times out before it has a connection timed out
+		// dialing or in the TLS handshake, not waiting for the headers
+		var connected atomic.Bool
+		gotConn := trace.GotConn
+		trace.GotConn = func(info httptrace.GotConnInfo) {
+			connected.Store(true)
+			gotConn(info)
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		// Close the connection after every Nth request of the worker
+		if config.ReconnectEvery > 0 {
+			var n int64
+			if conn != nil {
+				n = conn.requests + 1
+			} else {
+				n = atomic.AddInt64(&unpinnedRequests, 1)
+			}
+			req.Close = n%int64(config.ReconnectEvery) == 0
+		}
+
+		// Send request and measure time, with the virtual user's cookies
+		// and the worker's client certificate; requests that are not sent
+		// by a fixed worker take the certificates in turn
+		httpClient := client
+		var identity *clientIdentity
+		if conn != nil {
+			identity = conn.identity
+		} else if ids := config.ClientIdentities; len(ids) > 0 {
+			identity = ids[(atomic.AddInt64(&unpinnedIdentity, 1)-1)%int64(len(ids))]
+		}
+		if identity != nil {
+			httpClient = identity.client
+		}
+		if conn != nil && conn.client != nil {
+			httpClient = conn.client
+		}
+		if longPoll != nil {
+			longPoll.begin()
+			defer longPoll.end()
+		}
+		var resp *http.Response
+		if config.Digest != nil {
+			resp, err = config.Digest.do(httpClient, req)
+		} else {
+			resp, err = httpClient.Do(req)
+		}
+		latency := float64(time.Since(startTime).Microseconds()) / 1000
+
+		// Send latency to channel for stats
+		latencyChan <- latency
+
+		// Increment request counter
+		atomic.AddInt64(&totalRequests, 1)
+		if conn != nil {
+			conn.requests++
+			conn.totalLatency += latency
+		}
+
+		// Handle response or error
+		outcome := Sample{Start: wallTime(startTime), Latency: latency, Connection: connID, Method: req.Method, URL: req.URL.String(), RequestID: requestID}
+		if err != nil {
+			atomic.AddInt64(&failedReqs, 1)
+			outcome.ErrorClass = classifyError(err, false)
+			outcome.Error = err.Error()
+			if conn != nil {
+				conn.errors++
+			}
+			if outcome.ErrorClass == "port_exhaustion" {
+				atomic.AddInt64(&portExhaustion, 1)
+				if conn != nil {
+					conn.portExhausted = true
+				}
+			}
+			logTrace("request error", "method", req.Method, "url", req.URL, "error", err)
+			// Check if it's a timeout; no response on a connection
+			// means the headers were too slow
+			if os.IsTimeout(err) {
+				atomic.AddInt64(&timeouts, 1)
+				if connected.Load() {
+					atomic.AddInt64(&headerTimeouts, 1)
+				} else {
+					atomic.AddInt64(&connectTimeouts, 1)
+					outcome.ErrorClass = "connect_timeout"
+				}
+			}
+			if config.Checks != nil {
+				if op, ok := operationFrom(req.Context()); ok {
+					config.Checks.recordFailed(op)
+				}
+			}
+		} else {
+			if resp.TLS != nil {
+				tlsOnce.Do(func() {
+					tlsInfo := describeTLS(resp.TLS)
+					statusCodeMutex.Lock()
+					result.Metadata.TLS = tlsInfo
+					statusCodeMutex.Unlock()
+				})
+			}
+
+			if captured != nil {
+				captured.record(resp.Header)
+			}
+			if identities != nil {
+				identities.record(resp.Header)
+			}
+			if cdnCache != nil {
+				cdnCache.record(resp.Header, latency)
+			}
+			if revalidation != nil {
+				revalidation.record(req, resp, latency)
+			}
+
+			// Use mutex to protect map update
+			statusCodeMutex.Lock()
+			result.StatusCodeCounts[resp.StatusCode]++
+			statusCodeMutex.Unlock()
+
+			// Read and discard body (important to close connections properly)
+			bodyStart := time.Now()
+			var streamed *chunkReader
+			bodyReader := io.Reader(resp.Body)
+			if chunks != nil {
+				streamed = chunks.reader(resp.Body)
+				bodyReader = streamed
+			}
+			var stream *llmStream
+			if llm != nil {
+				stream = llm.reader(bodyReader, startTime)
+				bodyReader = stream
+			}
+			body, readErr := io.ReadAll(bodyReader)
+			if streamed != nil && readErr == nil {
+				chunks.record(streamed)
+			}
+			if stream != nil && readErr == nil {
+				llm.record(stream)
+			}
+			if phases != nil && readErr == nil {
+				phases.record(latency, float64(time.Since(bodyStart).Microseconds())/1000, int64(len(body)))
+			}
+			if longPoll != nil && readErr == nil {
+				longPoll.record(latency, float64(time.Since(startTime).Microseconds())/1000)
+			}
+			atomic.AddInt64(&bytesRead, int64(len(body)))
+			outcome.Status = resp.StatusCode
+			outcome.Bytes = int64(len(body))
+			outcome.BytesOut = req.ContentLength
+			atomic.AddInt64(&bytesWritten, int64(req.ContentLength))
 
-	// Print parameters
-	fmt.Print(chalk.Green, "Starting autocannon with the following parameters:\n", chalk.Reset)
-	fmt.Printf("URI: %s\n", *uri)
-	fmt.Printf("Connections: %d\n", *clients)
-	fmt.Printf("Duration: %d seconds\n", *runtime)
-	fmt.Printf("Timeout: %d seconds\n", *timeout)
-	fmt.Printf("Method: %s\n", *method)
-	fmt.Printf("Expected status: %d\n", *expectStatus)
-	if *output != "" {
-		fmt.Printf("Output file: %s\n", *output)
-	}
-	fmt.Printf("Debug: %t\n", *debug)
-	fmt.Println(chalk.Green, "Starting autocannon...", chalk.Reset)
+			resp.Body.Close()
 
-	// Configure the benchmark
-	config := BenchmarkConfig{
-		URI:              *uri,
-		Connections:      *clients,
-		Duration:         *runtime,
-		Timeout:          *timeout,
-		Method:           *method,
-		Headers:          map[string]string{},
-		Body:             *body,
-		ExpectStatusCode: *expectStatus,
-		Debug:            *debug,
-		OutputFile:       *output,
-	}
+			// Trailers are only populated once the body is read
+			if len(resp.Trailer) > 0 {
+				fields := make([]string, 0, len(resp.Trailer))
+				for field := range resp.Trailer {
+					fields = append(fields, field)
+				}
+				informational.recordTrailers(fields)
+			}
+			serverTiming.record(resp.Header, resp.Trailer, latency)
+			rateLimits.record(resp.Header)
+			var retryAfter time.Duration
+			if throttle != nil {
+				retryAfter = throttle.record(resp)
+			}
 
-	// Run the benchmark
-	result := runBenchmark(config)
+			if readErr == nil {
+				sizes.record(int64(len(body)))
+				if fields != nil {
+					fields.record(body, latency)
+				}
+				if scenario != nil {
+					scenario.observe(req, resp.Header, body)
+				}
+			}
 
-	// Display results
-	displayResults(result)
+			if readErr != nil {
+				atomic.AddInt64(&failedReqs, 1)
+				outcome.ErrorClass = classifyError(readErr, true)
+				outcome.Error = readErr.Error()
+				if conn != nil {
+					conn.errors++
+				}
+				logTrace("response body error", "method", req.Method, "url", req.URL, "error", readErr)
+				// Headers arrived but the body was too slow
+				if os.IsTimeout(readErr) {
+					atomic.AddInt64(&timeouts, 1)
+					atomic.AddInt64(&bodyTimeouts, 1)
+				}
+			} else if throttle != nil && resp.StatusCode == http.StatusTooManyRequests {
+				// Throttled responses are neither validated nor failed
+				atomic.AddInt64(&throttledReqs, 1)
+				if conn != nil {
+					conn.retryAfter = retryAfter
+				}
+			} else if verdict := validate(config.Validators, req, resp, body); !verdict.Pass {
+				atomic.AddInt64(&failedReqs, 1)
+				validation.record(verdict)
+				outcome.ErrorClass = "validation"
+				outcome.Error = verdict.Category
+				if conn != nil {
+					conn.errors++
+				}
+				logTrace("validation failed", "method", req.Method, "url", req.URL, "category", verdict.Category)
+			} else {
+				validation.record(verdict)
+				atomic.AddInt64(&successfulReqs, 1)
+			}
+			if config.Checks != nil {
+				if op, ok := operationFrom(req.Context()); ok {
+					if readErr != nil {
+						config.Checks.recordFailed(op)
+					} else {
+						config.Checks.record(op, resp.StatusCode, body, latency)
+					}
+				}
+			}
+		}
 
-	// Write results to file if specified
-	if config.OutputFile != "" {
-		writeResultsToFile(result, config.OutputFile)
+		if tracing() {
+			logTrace("request", "method", outcome.Method, "url", outcome.URL, "status", outcome.Status,
+				"latency_ms", outcome.Latency, "bytes", outcome.Bytes, "connection", connID, "error", outcome.ErrorClass)
+		}
+		for _, h := range handlers {
+			h.OnRequestDone(outcome)
+			if outcome.ErrorClass != "" {
+				h.OnError(outcome)
+			}
+		}
+		if identity != nil {
+			identity.record(latency, outcome.ErrorClass != "", outcome.Status)
+		}
+		if op, ok := operationFrom(req.Context()); ok {
+			operations.record(op, req.Method, latency, outcome.ErrorClass != "")
+			if scenario != nil {
+				scenario.record(req.Context(), latency, outcome.ErrorClass != "")
+			}
+			if abSamples != nil && outcome.ErrorClass == "" {
+				abSamples.record(op.Name, latency)
+			}
+		}
+		if adaptive != nil {
+			adaptive.record(latency, outcome.ErrorClass != "" || outcome.Status == http.StatusTooManyRequests)
+		}
+		if traces != nil {
+			traces.record(TraceExemplar{TraceID: traceID, Latency: latency, Status: outcome.Status, Timestamp: wallTime(startTime)})
+		}
+		return true
 	}
-}
 
-func runBenchmark(config BenchmarkConfig) BenchmarkResult {
-	result := BenchmarkResult{
-		Connections:      config.Connections,
-		Duration:         config.Duration,
-		StatusCodeCounts: make(map[int]int64),
-		Timestamp:        time.Now(),
-	}
+	var replayStats *ReplayStats
 
-	var wg sync.WaitGroup
-	var totalRequests int64
-	var successfulReqs int64
-	var failedReqs int64
-	var timeouts int64
-	var bytesRead int64
-	var bytesWritten int64
-	var statusCodeMutex sync.Mutex
-	// For latency tracking
-	var totalLatency float64
-	var minLatency float64 = float64(^uint64(0) >> 1) // Max float64 value
-	var maxLatency float64
+	// The scenario's per-worker setup and teardown steps
+	var vuSetup *VUSetupStats
+	var teardownFailures hookFailures
 
-	// Channel to collect latency measurements
-	latencyChan := make(chan float64, 1000)
+	switch {
+	case config.ReplayFile != "":
+		// Replay: requests are sent at their logged times relative to the
+		// first entry, scaled by the speed multiplier
+		replayStats = &ReplayStats{File: config.ReplayFile, Format: config.ReplayFormat, Speed: config.ReplaySpeed}
+		reader, err := openReplay(config.ReplayFile, config.ReplayFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening replay log: %v\n", err)
+			finish()
+			break
+		}
 
-	// Create a client with specified timeout
-	client := &http.Client{
-		Timeout: time.Duration(config.Timeout) * time.Second,
-	}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer finish()
+			defer reader.Close()
 
-	// Create a stop channel that will signal workers to stop
-	stopChan := make(chan struct{})
+			var first time.Time
+			start := time.Now()
+			for {
+				entry, err := reader.next()
+				if err != nil {
+					if err != io.EOF {
+						fmt.Fprintf(os.Stderr, "Error reading replay log: %v\n", err)
+					}
+					break
+				}
+				if first.IsZero() {
+					first = entry.Time
+				}
+
+				// The log's times are shifted by the pauses of the run
+				offset := time.Duration(float64(entry.Time.Sub(first)) / config.ReplaySpeed)
+				var scheduled time.Time
+				for {
+					if !control.wait(stopChan) {
+						replayStats.Skipped = reader.skipped
+						return
+					}
+					scheduled = start.Add(offset + control.pausedFor(time.Now()))
+					d := time.Until(scheduled)
+					if d <= 0 {
+						break
+					}
+					timer := time.NewTimer(d)
+					select {
+					case <-stopChan:
+						timer.Stop()
+						replayStats.Skipped = reader.skipped
+						return
+					case <-timer.C:
+					}
+					if !control.isPaused() {
+						break
+					}
+				}
 
-	// Launch worker goroutines
-	for i := 0; i < config.Connections; i++ {
+				replayStats.Entries++
+				spec := requestSpec{
+					Method:    entry.Method,
+					URL:       replayURL(config.URI, entry.Path),
+					UserAgent: entry.UserAgent,
+					Body:      config.Body,
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					sendRequest(nil, scheduled, staticGenerator{spec: spec})
+				}()
+			}
+			replayStats.Skipped = reader.skipped
+		}()
+	case config.Model == "open":
+		// Open model: a dispatcher issues requests on schedule, each in
+		// its own goroutine, regardless of how many are outstanding
+		// unless -max-pending caps them
+		var pendingSlots chan struct{}
+		if config.MaxPending > 0 {
+			pendingSlots = make(chan struct{}, config.MaxPending)
+		}
 		wg.Add(1)
-		go func(workerID int) {
+		go func() {
 			defer wg.Done()
-
+			lim := pacers.add()
+			var resumed time.Time
 			for {
-				select {
-				case <-stopChan:
+				scheduled, ok := lim.wait(stopChan)
+				if !ok {
 					return
-				default:
-					startTime := time.Now()
-
-					// Create request
-					req, err := http.NewRequest(config.Method, config.URI, nil)
-					if err != nil {
-						atomic.AddInt64(&failedReqs, 1)
-						if config.Debug {
-							fmt.Printf("Error creating request: %v\n", err)
+				}
+				// A slot that falls in a pause, or was missed during one,
+				// is due when it ends
+				if control.isPaused() {
+					if !control.wait(stopChan) {
+						return
+					}
+					resumed = time.Now()
+				}
+				if scheduled.Before(resumed) {
+					scheduled = resumed
+				}
+				if pendingSlots != nil {
+					select {
+					case pendingSlots <- struct{}{}:
+					default:
+						atomic.AddInt64(&pendingCapHits, 1)
+						select {
+						case pendingSlots <- struct{}{}:
+						case <-stopChan:
+							return
 						}
-						continue
 					}
-
-					// Add headers
-					for key, value := range config.Headers {
-						req.Header.Add(key, value)
+				}
+				n := atomic.AddInt64(&pending, 1)
+				for {
+					peak := atomic.LoadInt64(&pendingPeak)
+					if n <= peak || atomic.CompareAndSwapInt64(&pendingPeak, peak, n) {
+						break
+					}
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					sendRequest(nil, scheduled, generator)
+					atomic.AddInt64(&pending, -1)
+					if pendingSlots != nil {
+						<-pendingSlots
 					}
+				}()
+			}
+		}()
+	default:
+		// Closed model: each connection waits for its response before
+		// sending the next request, optionally paced by a limiter that is
+		// shared, or one per connection with -rate-per-connection
+		paced := config.Rate > 0 || strings.HasPrefix(config.Arrival, "burst:")
+		var shared *limiter
+		if paced && !config.RatePerConn {
+			shared = pacers.add()
+		}
+
+		// With -connect-rate, connection i starts after i/rate seconds.
+		// The ramp-up ends when every connection has a response.
+		startDelay := func(workerID int) time.Duration {
+			if config.ConnectRate <= 0 {
+				return 0
+			}
+			return time.Duration(float64(workerID) / config.ConnectRate * float64(time.Second))
+		}
 
-					// Send request and measure time
-					resp, err := client.Do(req)
-					latency := float64(time.Since(startTime).Milliseconds())
+		// Virtual users have clients of their own, for their cookies
+		if config.VUs > 0 {
+			for _, conn := range connTrackers {
+				jar, _ := cookiejar.New(nil)
+				conn.client = &http.Client{Transport: transport, Timeout: client.Timeout, Jar: jar}
+				if conn.identity != nil {
+					conn.client.Transport = conn.identity.client.Transport
+				}
+			}
+		}
+		// With -ntlm every worker has a connection of its own, which it
+		// authenticates once
+		ntlmClient := func(conn *connectionTracker) {
+			if config.NTLM == nil {
+				return
+			}
+			base := transport
+			if conn.identity != nil {
+				base = conn.identity.client.Transport.(*http.Transport)
+			}
+			conn.client = &http.Client{Transport: newNTLMTransport(base, config.NTLM), Timeout: client.Timeout}
+		}
+		for _, conn := range connTrackers {
+			ntlmClient(conn)
+		}
+		workerClient := func(conn *connectionTracker) *http.Client {
+			if conn.client != nil {
+				return conn.client
+			}
+			if conn.identity != nil {
+				return conn.identity.client
+			}
+			return client
+		}
 
-					// Send latency to channel for stats
-					latencyChan <- latency
+		// Every worker runs the scenario's setup steps before the
+		// measurement starts, and its teardown steps when it stops
+		ready := make([]bool, workers)
+		for i := range ready {
+			ready[i] = true
+		}
+		if scenario != nil && (len(scenario.setup) > 0 || len(scenario.teardown) > 0) {
+			vuSetup = &VUSetupStats{Workers: workers}
+		}
+		if scenario != nil && len(scenario.setup) > 0 {
+			var setupFailures hookFailures
+			var setup sync.WaitGroup
+			for i := range ready {
+				setup.Add(1)
+				go func(workerID int) {
+					defer setup.Done()
+					if err := scenario.runHook(workerClient(connTrackers[workerID]), workerID, scenario.setup, config.Headers); err != nil {
+						setupFailures.record(err)
+						ready[workerID] = false
+					}
+				}(i)
+			}
+			setup.Wait()
+			vuSetup.SetupFailed = setupFailures.report("setup", workers)
+			vuSetup.SetupSeconds = time.Since(result.Timestamp).Seconds()
+			result.Timestamp = wallTime(time.Now())
+		}
 
-					// Increment request counter
-					atomic.AddInt64(&totalRequests, 1)
+		// Workers get their tracker passed in, as addWorker grows
+		// connTrackers while they run
+		startWorker := func(workerID int, conn *connectionTracker) {
+			wg.Add(1)
+			go func(workerID int) {
+				defer wg.Done()
+				conn.id = workerID
+				if scenario != nil && len(scenario.teardown) > 0 {
+					defer func() {
+						if err := scenario.runHook(workerClient(conn), workerID, scenario.teardown, config.Headers); err != nil {
+							teardownFailures.record(err)
+						}
+					}()
+				}
+				lim := shared
+				if paced && config.RatePerConn {
+					lim = pacers.add()
+				}
+				if delay := time.Until(result.Timestamp.Add(startDelay(workerID))); delay > 0 {
+					timer := time.NewTimer(delay)
+					select {
+					case <-stopChan:
+						timer.Stop()
+						return
+					case <-timer.C:
+					}
+				}
 
-					// Handle response or error
-					if err != nil {
-						atomic.AddInt64(&failedReqs, 1)
-						if config.Debug {
-							fmt.Printf("Request error: %v\n", err)
+				first := true
+				for {
+					select {
+					case <-stopChan:
+						return
+					default:
+						// Connections beyond the tuned number idle, like
+						// all of them while the run is paused
+						if !control.waitTurn(workerID, stopChan) {
+							return
 						}
-						// Check if it's a timeout
-						if os.IsTimeout(err) {
-							atomic.AddInt64(&timeouts, 1)
+						if lim != nil {
+							if _, ok := lim.wait(stopChan); !ok {
+								return
+							}
+							if !control.waitTurn(workerID, stopChan) {
+								return
+							}
+						}
+						ok := sendRequest(conn, time.Now(), generator)
+						if first {
+							first = false
+							if atomic.AddInt64(&rampedUp, 1) == int64(workers) {
+								atomic.StoreInt64(&rampUp, int64(time.Since(result.Timestamp)))
+							}
+						}
+						if !ok {
+							return
+						}
+						if pause := conn.retryAfter; pause > 0 {
+							conn.retryAfter = 0
+							waitStart := time.Now()
+							timer := time.NewTimer(pause)
+							select {
+							case <-stopChan:
+								timer.Stop()
+								throttle.recordWait(time.Since(waitStart))
+								return
+							case <-timer.C:
+							}
+							throttle.recordWait(pause)
+						}
+						if scenario != nil {
+							if pause := scenario.thinkTime(workerID); pause > 0 {
+								timer := time.NewTimer(pause)
+								select {
+								case <-stopChan:
+									timer.Stop()
+									return
+								case <-timer.C:
+								}
+							}
+						}
+						if conn.portExhausted && config.RetireOnNoPorts {
+							conn.portExhausted = false
+							// Keep at least one connection running
+							if atomic.AddInt64(&activeConnections, -1) > 0 {
+								atomic.AddInt64(&retiredConnections, 1)
+								return
+							}
+							atomic.AddInt64(&activeConnections, 1)
 						}
-					} else {
-						atomic.AddInt64(&successfulReqs, 1)
-
-						// Use mutex to protect map update
-						statusCodeMutex.Lock()
-						result.StatusCodeCounts[resp.StatusCode]++
-						statusCodeMutex.Unlock()
-
-						// Read and discard body (important to close connections properly)
-						body, _ := io.ReadAll(resp.Body)
-						atomic.AddInt64(&bytesRead, int64(len(body)))
-						atomic.AddInt64(&bytesWritten, int64(req.ContentLength))
-
-						resp.Body.Close()
 					}
 				}
+			}(workerID)
+		}
+		for i := 0; i < workers; i++ {
+			if ready[i] {
+				startWorker(i, connTrackers[i])
+			}
+		}
+
+		// Virtual users and workers with setup steps are set up for the
+		// whole run, so only plain connections can be added, and a
+		// partitioned feed's rows are already split between the workers
+		partitioned := config.Feed != nil && config.Feed.partitions > 0
+		if config.VUs == 0 && (scenario == nil || len(scenario.setup) == 0) && !partitioned {
+			tunableConnections = workers
+			addWorker = func() {
+				conn := &connectionTracker{identity: identityFor(config.ClientIdentities, len(connTrackers))}
+				ntlmClient(conn)
+				connTrackers = append(connTrackers, conn)
+				atomic.AddInt64(&activeConnections, 1)
+				startWorker(len(connTrackers)-1, conn)
 			}
-		}(i)
+		}
+	}
+
+	// Start latency collector goroutine, which also emits interim
+	// statistics when a print interval is configured
+	var trim *trimTracker
+	if config.TrimStart > 0 || config.TrimEnd > 0 || config.TrimOutliers > 0 {
+		trim = newTrimTracker(result.Timestamp, config.TrimStart, config.TrimEnd)
 	}
 
-	// Start latency collector goroutine
 	latencyDone := make(chan struct{})
 	go func() {
-		count := 0
-		for latency := range latencyChan {
-			count++
-			totalLatency += latency
+		defer close(latencyDone)
+
+		var tick <-chan time.Time
+		if config.PrintInterval > 0 {
+			ticker := time.NewTicker(config.PrintInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		interval := newIntervalTracker(result.Timestamp)
+		var intervalTuning, tickTuning int // changes annotated so far
 
-			if latency < minLatency {
-				minLatency = latency
+		var hdrTick <-chan time.Time
+		var hdrLog *hdrLogWriter
+		var hdrIntervalHist *histogram
+		hdrIntervalStart := result.Timestamp
+		if config.HdrLogFile != "" {
+			var err error
+			hdrLog, err = newHdrLogWriter(config.HdrLogFile, result.Timestamp)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating HdrHistogram log: %v\n", err)
+			} else {
+				defer hdrLog.Close()
+				hdrIntervalHist = newLatencyHistogram()
+				ticker := time.NewTicker(config.HdrInterval)
+				defer ticker.Stop()
+				hdrTick = ticker.C
+			}
+		}
+		writeHdrInterval := func(now time.Time) {
+			if err := hdrLog.writeInterval(hdrIntervalHist, hdrIntervalStart, now); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing HdrHistogram log: %v\n", err)
 			}
-			if latency > maxLatency {
-				maxLatency = latency
+			hdrIntervalHist.reset()
+			hdrIntervalStart = now
+		}
+
+		sampleTicker := time.NewTicker(time.Second)
+		defer sampleTicker.Stop()
+		var sampledRequests, sampledBytes int64
+
+		var checkpointTick <-chan time.Time
+		if config.CheckpointFile != "" {
+			ticker := time.NewTicker(config.CheckpointEvery)
+			defer ticker.Stop()
+			checkpointTick = ticker.C
+		}
+
+		for {
+			select {
+			case latency, ok := <-latencyChan:
+				if !ok {
+					if hdrLog != nil {
+						writeHdrInterval(time.Now())
+					}
+					return
+				}
+				latencyMicros := int64(latency * 1000)
+				latencyHist.record(latencyMicros)
+				if trim != nil {
+					trim.record(latency, runClock(time.Now()))
+				}
+				if hdrIntervalHist != nil {
+					hdrIntervalHist.record(latencyMicros)
+				}
+				totalLatency += latency
+
+				if latency < minLatency {
+					minLatency = latency
+				}
+				if latency > maxLatency {
+					maxLatency = latency
+				}
+				interval.recordLatency(latency)
+			case now := <-tick:
+				if control.isPaused() {
+					continue
+				}
+				snapshot := counterSnapshot{
+					Requests:    atomic.LoadInt64(&totalRequests),
+					Successful:  atomic.LoadInt64(&successfulReqs),
+					Failed:      atomic.LoadInt64(&failedReqs),
+					Timeouts:    atomic.LoadInt64(&timeouts),
+					BytesRead:   atomic.LoadInt64(&bytesRead),
+					StatusCodes: make(map[int]int64),
+				}
+				statusCodeMutex.Lock()
+				for code, count := range result.StatusCodeCounts {
+					snapshot.StatusCodes[code] = count
+				}
+				statusCodeMutex.Unlock()
+
+				stats := interval.next(runClock(now), snapshot)
+				stats.Timestamp = wallTime(now)
+				stats.RateLimit = rateLimits.next()
+				if adaptive != nil {
+					_, stats.Rate = control.settings()
+				}
+				stats.Tuning = control.tuningSince(intervalTuning)
+				intervalTuning += len(stats.Tuning)
+				result.Intervals = append(result.Intervals, stats)
+				printIntervalStats(stats, config)
+			case now := <-hdrTick:
+				writeHdrInterval(now)
+			case now := <-sampleTicker.C:
+				// Paused seconds aren't samples of the run; what completes
+				// in them counts toward the next tick
+				if control.isPaused() {
+					continue
+				}
+				requests := atomic.LoadInt64(&totalRequests)
+				bytes := atomic.LoadInt64(&bytesRead)
+				if longPoll != nil {
+					longPoll.sample()
+				}
+				requestSamples.record(requests - sampledRequests)
+				throughputSamples.record(bytes - sampledBytes)
+
+				tick := Tick{
+					Elapsed:       runClock(now).Sub(result.Timestamp),
+					Requests:      requests - sampledRequests,
+					BytesRead:     bytes - sampledBytes,
+					TotalRequests: requests,
+					Failed:        atomic.LoadInt64(&failedReqs),
+					Tuning:        control.tuningSince(tickTuning),
+				}
+				tickTuning += len(tick.Tuning)
+				logger.Debug("tick", "elapsed", tick.Elapsed.Round(time.Second), "requests", tick.Requests,
+					"bytes", tick.BytesRead, "total", tick.TotalRequests, "failed", tick.Failed)
+				for _, h := range handlers {
+					h.OnTick(tick)
+				}
+				sampledRequests, sampledBytes = requests, bytes
+			case now := <-checkpointTick:
+				statusCodeMutex.Lock()
+				checkpoint := result
+				checkpoint.StatusCodeCounts = make(map[int]int64)
+				for code, count := range result.StatusCodeCounts {
+					checkpoint.StatusCodeCounts[code] = count
+				}
+				statusCodeMutex.Unlock()
+				fillTotals(&checkpoint, runClock(now).Sub(result.Timestamp))
+
+				if err := writeCheckpoint(checkpoint, config.CheckpointFile, config.CheckpointKeep); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing checkpoint: %v\n", err)
+				}
 			}
 		}
-		close(latencyDone)
 	}()
 
-	// Run for specified duration
-	time.Sleep(time.Duration(config.Duration) * time.Second)
+	// Run for the specified duration, or until interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	var deadline <-chan time.Time
+	var timer *time.Timer
+	if config.Duration > 0 {
+		timer = time.NewTimer(time.Duration(config.Duration) * time.Second)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var tunableRate float64
+	if config.ReplayFile == "" {
+		tunableRate = config.Rate
+	}
+	control.begin(result.Timestamp, time.Duration(config.Duration)*time.Second, tunableConnections, tunableRate)
+	if adaptive != nil {
+		go adaptive.run(control, stopChan)
+	}
+	for waiting := true; waiting; {
+		select {
+		case <-deadline:
+			waiting = false
+		case <-finished:
+			waiting = false
+		case <-interrupt:
+			result.Interrupted = true
+			fmt.Fprintln(os.Stderr, "Interrupted, finishing in-flight requests...")
+			waiting = false
+		case <-control.changes:
+			connections, rate := control.settings()
+			for addWorker != nil && len(connTrackers) < connections {
+				addWorker()
+			}
+			pacers.retune(rate)
+
+			// The duration doesn't run while the run is paused, and
+			// may have been extended
+			if timer == nil {
+				continue
+			}
+			if control.isPaused() {
+				timer.Stop()
+			} else {
+				timer.Reset(control.plannedDuration() - runClock(time.Now()).Sub(result.Timestamp))
+			}
+		}
+	}
+	elapsed := time.Since(result.Timestamp) - control.end()
+	result.Metadata.Started = result.Timestamp.UTC()
+	result.Metadata.Ended = wallTime(time.Now()).UTC()
 
 	// Signal workers to stop
 	close(stopChan)
 
 	// Wait for all workers to finish
 	wg.Wait()
+	if vuSetup != nil {
+		vuSetup.TeardownFailed = teardownFailures.report("teardown", vuSetup.Workers)
+	}
+	result.VUSetup = vuSetup
+	if throttle != nil {
+		result.Throttle = throttle.summary(result.Timestamp, elapsed)
+	}
+	result.RateLimit = rateLimits.summary(result.Timestamp)
+	if config.Feed != nil {
+		result.Feed = config.Feed.summary()
+	}
+	result.Paused = control.summary()
+	result.Tuning = control.tuningSince(0)
+	if adaptive != nil {
+		_, rate := control.settings()
+		result.Adaptive = adaptive.summary(rate)
+	}
 
 	close(latencyChan)
 	<-latencyDone
-	result.TotalRequests = totalRequests
-	result.SuccessfulReqs = successfulReqs
-	result.FailedReqs = failedReqs
-	result.Timeouts = timeouts
-	result.BytesRead = bytesRead
-	result.BytesWritten = bytesWritten
+	fillTotals(&result, elapsed)
+	result.elapsed = elapsed
+	result.latencyHist = latencyHist
+	result.requestSamples = requestSamples
+	result.throughputSamples = throughputSamples
+	if result.Requests.Total > 0 {
+		for _, p := range config.Percentiles {
+			result.Latency.Percentiles = append(result.Latency.Percentiles, PercentileValue{
+				Percentile: p,
+				Value:      float64(latencyHist.valueAtPercentile(p)) / 1000,
+			})
+		}
+		if encoded, err := latencyHist.encodeCompressed(); err == nil {
+			result.Latency.Histogram = base64.StdEncoding.EncodeToString(encoded)
+		}
+	}
+	if trim != nil {
+		result.Trimmed = trim.summary(elapsed, config.Percentiles, config.TrimOutliers)
+	}
+	result.Variability = &VariabilityStats{
+		LatencyStdDev:           latencyHist.stdDev() / 1000,
+		RequestsPerSecondStdDev: requestSamples.stdDev(),
+		Seconds:                 requestSamples.totalCount,
+	}
+
+	result.Informational, result.Trailers = informational.summary()
+
+	if traces != nil {
+		result.Traces = traces.summary()
+	}
+	result.Operations = operations.summary()
+	if abSamples != nil {
+		result.AB = abSamples.summary(result.Operations, elapsed.Seconds())
+	}
+	if len(config.Validators) > 0 {
+		result.Validation = validation.summary()
+	}
+	if scenario != nil {
+		result.Scenarios = scenario.mixSummary()
+	}
+	if len(config.ClientIdentities) > 0 {
+		result.Identities = clientIdentitySummaries(config.ClientIdentities)
+	}
+	if config.NTLM != nil {
+		result.Auth = config.NTLM.summary()
+	}
+	if config.Digest != nil {
+		result.Auth = config.Digest.summary()
+	}
+	if tunnel != nil {
+		result.Proxy = tunnel.summary()
+	}
+	if longPoll != nil {
+		result.LongPoll = longPoll.summary()
+	}
+	if config.Checks != nil {
+		result.Checks = config.Checks.summary()
+	}
+
+	if config.HdrPercentiles != "" {
+		if err := writePercentileFile(latencyHist, config.HdrPercentiles); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing HdrHistogram percentiles: %v\n", err)
+		}
+	}
+	result.Replay = replayStats
+	if config.Burst > 1 {
+		result.Burst = config.Burst
+	}
+	if config.Model != "open" {
+		result.PerConnection = connectionSummaries(connTrackers)
+	}
+
+	if captured != nil {
+		result.Headers = captured.summary()
+	}
+	result.ResponseSize = sizes.summary()
+	if phases != nil {
+		result.Phases = phases.summary()
+	}
+	if chunks != nil {
+		result.Chunks = chunks.summary()
+	}
+	if llm != nil {
+		result.LLM = llm.summary(result.seconds())
+	}
+	if fields != nil {
+		result.Fields = fields.summary()
+	}
+	result.ServerTiming = serverTiming.summary()
+	if revalidation != nil {
+		result.Cache = revalidation.summary()
+	}
+	if cdnCache != nil {
+		result.CDN = cdnCache.summary()
+	}
+	if identities != nil {
+		result.ServerIdentity = identitySummary(identities)
+	}
+	if config.Ranges != nil {
+		result.Range = config.Ranges.stats()
+	}
+	result.Connect = connects.summary(config.ReconnectEvery, config.NoTLSResumption, !config.NoHappyEyeballs)
+	result.Connect.ConnectRate = config.ConnectRate
+	result.Connect.RampUpSeconds = time.Duration(atomic.LoadInt64(&rampUp)).Seconds()
+	if config.MaxPending > 0 {
+		result.Pending = &PendingStats{Max: config.MaxPending, Peak: pendingPeak, CapHits: pendingCapHits}
+	}
+
+	if expectContinue && continueSent > 0 {
+		result.Continue = &ContinueStats{
+			Sent:     continueSent,
+			Received: continueReceived,
+			Latency:  continueLatency.summary(),
+		}
+	}
 
-	if totalRequests > 0 {
-		result.RequestsPerSec = float64(totalRequests) / float64(config.Duration)
-		result.ErrorRate = float64(failedReqs) / float64(totalRequests) * 100
+	if portExhaustion > 0 {
+		result.PortExhaustion = newPortExhaustionStats(portExhaustion, retiredConnections)
 	}
 
-	if successfulReqs > 0 {
-		result.AverageLatency = totalLatency / float64(successfulReqs)
-		result.MinLatency = minLatency
-		result.MaxLatency = maxLatency
+	for _, h := range handlers {
+		h.OnFinish(result)
+	}
+	if result.PortExhaustion != nil {
+		warnPortExhaustion(result.PortExhaustion)
 	}
 
 	return result
 }
 func displayResults(result BenchmarkResult) {
-	fmt.Println(chalk.Green, "\nBenchmark Results:", chalk.Reset)
+	fmt.Println(colorize(chalk.Green, "\nBenchmark Results:"))
 
 	// Main results table
 	mainTable := tablewriter.NewTable(os.Stdout,
@@ -280,21 +2690,115 @@ func displayResults(result BenchmarkResult) {
 
 	mainTable.Header("Metric", "Value")
 
-	mainTable.Append([]string{"Total Requests", fmt.Sprintf("%d", result.TotalRequests)})
-	mainTable.Append([]string{"Successful Requests", fmt.Sprintf("%d", result.SuccessfulReqs)})
-	mainTable.Append([]string{"Failed Requests", fmt.Sprintf("%d", result.FailedReqs)})
-	mainTable.Append([]string{"Timeouts", fmt.Sprintf("%d", result.Timeouts)})
-	mainTable.Append([]string{"Requests/sec", fmt.Sprintf("%.2f", result.RequestsPerSec)})
-	mainTable.Append([]string{"Average Latency", fmt.Sprintf("%.2f ms", result.AverageLatency)})
-	mainTable.Append([]string{"Min Latency", fmt.Sprintf("%.2f ms", result.MinLatency)})
-	mainTable.Append([]string{"Max Latency", fmt.Sprintf("%.2f ms", result.MaxLatency)})
-	mainTable.Append([]string{"Total Data Received", fmt.Sprintf("%d bytes", result.BytesRead)})
-	mainTable.Append([]string{"Error Rate", fmt.Sprintf("%.2f%%", result.ErrorRate)})
+	mainTable.Append([]string{"Total Requests", fmt.Sprintf("%d", result.Requests.Total)})
+	mainTable.Append([]string{"Successful Requests", fmt.Sprintf("%d", result.Requests.Successful)})
+	mainTable.Append([]string{"Failed Requests", fmt.Sprintf("%d", result.Requests.Failed)})
+	if result.Throttle != nil {
+		mainTable.Append([]string{"Throttled Requests", fmt.Sprintf("%d", result.Requests.Throttled)})
+	}
+	mainTable.Append([]string{"Timeouts", fmt.Sprintf("%d", result.Requests.Timeouts)})
+	mainTable.Append([]string{"  Slow Connect", fmt.Sprintf("%d", result.Requests.ConnectTimeouts)})
+	mainTable.Append([]string{"  Slow Headers", fmt.Sprintf("%d", result.Requests.HeaderTimeouts)})
+	mainTable.Append([]string{"  Slow Body", fmt.Sprintf("%d", result.Requests.BodyTimeouts)})
+	mainTable.Append([]string{"Requests/sec", fmt.Sprintf("%.2f", result.Throughput.RequestsPerSecond)})
+	mainTable.Append([]string{"Average Latency", formatLatency(result.Latency.Average)})
+	mainTable.Append([]string{"Min Latency", formatLatency(result.Latency.Min)})
+	mainTable.Append([]string{"Max Latency", formatLatency(result.Latency.Max)})
+	for _, p := range result.Latency.Percentiles {
+		mainTable.Append([]string{fmt.Sprintf("p%g Latency", p.Percentile), formatLatency(p.Value)})
+	}
+	mainTable.Append([]string{"Total Data Received", formatSize(result.Throughput.BytesRead)})
+	mainTable.Append([]string{"Total Data Sent", formatSize(result.Throughput.BytesWritten)})
+	mainTable.Append([]string{"Read Throughput", formatRate(result.Throughput.BytesRead, result.seconds())})
+	mainTable.Append([]string{"Write Throughput", formatRate(result.Throughput.BytesWritten, result.seconds())})
+	if result.ResponseSize != nil {
+		mainTable.Append([]string{"Response Size", fmt.Sprintf("%s avg, %s p99, %s max", formatSize(int64(result.ResponseSize.Mean)), formatSize(result.ResponseSize.P99), formatSize(result.ResponseSize.Max))})
+	}
+	mainTable.Append([]string{"Error Rate", fmt.Sprintf("%.2f%%", result.Requests.ErrorRate)})
+
+	if result.VUSetup != nil {
+		setup := fmt.Sprintf("%d users in %.2f s", result.VUSetup.Workers, result.VUSetup.SetupSeconds)
+		if result.VUSetup.SetupFailed > 0 {
+			setup += fmt.Sprintf(", %d failed", result.VUSetup.SetupFailed)
+		}
+		mainTable.Append([]string{"Scenario Setup", setup})
+		if result.VUSetup.TeardownFailed > 0 {
+			mainTable.Append([]string{"Scenario Teardown Failures", fmt.Sprintf("%d", result.VUSetup.TeardownFailed)})
+		}
+	}
+	if result.Feed != nil {
+		rows := fmt.Sprintf("%d of %d", result.Feed.Used, result.Feed.Rows)
+		if result.Feed.Consumed > int64(result.Feed.Used) {
+			rows += fmt.Sprintf(", %d times in all", result.Feed.Consumed)
+		}
+		if result.Feed.Exhausted {
+			rows += " (exhausted)"
+		}
+		mainTable.Append([]string{"Feed Rows Used", rows})
+	}
+	if result.Paused != nil {
+		mainTable.Append([]string{"Paused", fmt.Sprintf("%d times, %.2f s", result.Paused.Pauses, result.Paused.Seconds)})
+	}
+	for _, change := range result.Tuning {
+		mainTable.Append([]string{fmt.Sprintf("Tuned at %.1f s", change.ElapsedSeconds), change.String()})
+	}
+	if result.Informational.Responses > 0 {
+		mainTable.Append([]string{"1xx Responses", fmt.Sprintf("%d", result.Informational.Responses)})
+		mainTable.Append([]string{"First 1xx Latency", fmt.Sprintf("%s avg, %s max", formatLatency(result.Informational.FirstAfter.Average), formatLatency(result.Informational.FirstAfter.Max))})
+	}
+	if result.Trailers.Responses > 0 {
+		mainTable.Append([]string{"Responses With Trailers", fmt.Sprintf("%d", result.Trailers.Responses)})
+	}
+	if result.Connect != nil {
+		mainTable.Append([]string{"Connections Opened", fmt.Sprintf("%d", result.Connect.Opened)})
+		if result.Connect.ConnectRate > 0 {
+			rampUp := "incomplete"
+			if result.Connect.RampUpSeconds > 0 {
+				rampUp = fmt.Sprintf("%.2f s", result.Connect.RampUpSeconds)
+			}
+			mainTable.Append([]string{"Connection Ramp-Up", fmt.Sprintf("%s at %g/s", rampUp, result.Connect.ConnectRate)})
+		}
+		mainTable.Append([]string{"Connect Time", fmt.Sprintf("%s avg, %s max", formatLatency(result.Connect.Dial.Average), formatLatency(result.Connect.Dial.Max))})
+		if len(result.Connect.Families) > 1 || result.Connect.Fallbacks > 0 {
+			mainTable.Append([]string{"  IPv4 / IPv6", fmt.Sprintf("%d / %d", result.Connect.Families["ipv4"], result.Connect.Families["ipv6"])})
+			mainTable.Append([]string{"  Family Fallbacks", fmt.Sprintf("%d", result.Connect.Fallbacks)})
+		}
+		if result.Connect.TLSHandshake != nil {
+			mainTable.Append([]string{"TLS Handshake", fmt.Sprintf("%s avg, %s max", formatLatency(result.Connect.TLSHandshake.Average), formatLatency(result.Connect.TLSHandshake.Max))})
+		}
+		if r := result.Connect.TLSResumption; r != nil {
+			mainTable.Append([]string{"  Full", fmt.Sprintf("%d, %s avg", r.Full.Count, formatLatency(r.Full.Average))})
+			mainTable.Append([]string{"  Resumed", fmt.Sprintf("%d, %s avg", r.Resumed.Count, formatLatency(r.Resumed.Average))})
+		}
+	}
+	if result.Auth != nil {
+		mainTable.Append([]string{result.Auth.Scheme + " Handshakes", fmt.Sprintf("%d (%d failed)", result.Auth.Handshakes, result.Auth.Failed)})
+		if result.Auth.Stale > 0 {
+			mainTable.Append([]string{"  Stale Nonces", fmt.Sprintf("%d", result.Auth.Stale)})
+		}
+		if result.Auth.Latency.Count > 0 {
+			mainTable.Append([]string{"  Handshake Latency", fmt.Sprintf("%s avg, %s max", formatLatency(result.Auth.Latency.Average), formatLatency(result.Auth.Latency.Max))})
+		}
+	}
+	if result.Cache != nil {
+		mainTable.Append([]string{"Conditional Requests", fmt.Sprintf("%d", result.Cache.Conditional)})
+		mainTable.Append([]string{"  304 Not Modified", fmt.Sprintf("%d (%.2f%%)", result.Cache.NotModified, result.Cache.NotModifiedRate)})
+		mainTable.Append([]string{"  304 Latency", fmt.Sprintf("%s avg, %s max", formatLatency(result.Cache.NotModifiedLatency.Average), formatLatency(result.Cache.NotModifiedLatency.Max))})
+		mainTable.Append([]string{"  Full Response Latency", fmt.Sprintf("%s avg, %s max", formatLatency(result.Cache.FullLatency.Average), formatLatency(result.Cache.FullLatency.Max))})
+	}
+	if result.Pending != nil {
+		mainTable.Append([]string{"Peak Pending Requests", fmt.Sprintf("%d / %d", result.Pending.Peak, result.Pending.Max)})
+		mainTable.Append([]string{"Pending Cap Hits", fmt.Sprintf("%d", result.Pending.CapHits)})
+	}
+	if result.Continue != nil {
+		mainTable.Append([]string{"100 Continue Received", fmt.Sprintf("%d / %d", result.Continue.Received, result.Continue.Sent)})
+		mainTable.Append([]string{"100 Continue Latency", fmt.Sprintf("%s avg, %s max", formatLatency(result.Continue.Latency.Average), formatLatency(result.Continue.Latency.Max))})
+	}
 
 	mainTable.Render()
 
 	// Status code distribution table
-	fmt.Println(chalk.Green, "\nStatus Code Distribution:", chalk.Reset)
+	fmt.Println(colorize(chalk.Green, "\nStatus Code Distribution:"))
 
 	statusTable := tablewriter.NewTable(os.Stdout,
 		tablewriter.WithConfig(tablewriter.Config{
@@ -314,30 +2818,78 @@ func displayResults(result BenchmarkResult) {
 
 	statusTable.Header("Status Code", "Count", "Percentage")
 
+	// Sort codes numerically, totalling each class as we go
+	codes := make([]int, 0, len(result.StatusCodeCounts))
+	classTotals := make(map[int]int64)
 	for code, count := range result.StatusCodeCounts {
-		percentage := float64(count) / float64(result.TotalRequests) * 100
-		statusTable.Append([]string{
-			fmt.Sprintf("%d", code),
-			fmt.Sprintf("%d", count),
-			fmt.Sprintf("%.2f%%", percentage),
-		})
+		codes = append(codes, code)
+		classTotals[code/100] += count
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		statusTable.Append(statusRow(fmt.Sprintf("%d", code), code/100, result.StatusCodeCounts[code], result.Requests.Total))
+	}
+
+	// Rollup rows per class
+	classes := make([]int, 0, len(classTotals))
+	for class := range classTotals {
+		classes = append(classes, class)
+	}
+	sort.Ints(classes)
+
+	for _, class := range classes {
+		statusTable.Append(statusRow(fmt.Sprintf("%dxx total", class), class, classTotals[class], result.Requests.Total))
 	}
 
 	statusTable.Render()
 }
 
-func writeResultsToFile(result BenchmarkResult, filename string) {
-	jsonData, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		fmt.Printf("Error marshaling results to JSON: %v\n", err)
-		return
+// statusRow builds a status table row colored by its status class
+func statusRow(label string, class int, count int64, total int64) []string {
+	color := statusClassColor(class)
+	percentage := float64(count) / float64(total) * 100
+	return []string{
+		colorize(color, label),
+		colorize(color, fmt.Sprintf("%d", count)),
+		colorize(color, fmt.Sprintf("%.2f%%", percentage)),
 	}
+}
 
-	err = ioutil.WriteFile(filename, jsonData, 0644)
-	if err != nil {
-		fmt.Printf("Error writing results to file: %v\n", err)
-		return
+// statusClassColor picks the display color for a status class (2 for 2xx, ...)
+func statusClassColor(class int) chalk.Color {
+	switch class {
+	case 2:
+		return chalk.Green
+	case 3:
+		return chalk.Cyan
+	case 4:
+		return chalk.Yellow
+	case 5:
+		return chalk.Red
+	default:
+		return chalk.White
+	}
+}
+
+// printQuietSummary prints the headline numbers as a single key=value
+// line, for use in shell pipelines
+func printQuietSummary(result BenchmarkResult) {
+	fmt.Printf("requests=%d successful=%d failed=%d timeouts=%d connect_timeouts=%d header_timeouts=%d body_timeouts=%d rps=%.2f avg_ms=%.2f min_ms=%.2f max_ms=%.2f bytes_read=%d error_rate=%.2f",
+		result.Requests.Total, result.Requests.Successful, result.Requests.Failed, result.Requests.Timeouts,
+		result.Requests.ConnectTimeouts, result.Requests.HeaderTimeouts, result.Requests.BodyTimeouts,
+		result.Throughput.RequestsPerSecond, result.Latency.Average, result.Latency.Min, result.Latency.Max,
+		result.Throughput.BytesRead, result.Requests.ErrorRate)
+	for _, p := range result.Latency.Percentiles {
+		fmt.Printf(" p%g_ms=%.2f", p.Percentile, p.Value)
 	}
+	fmt.Println()
+}
 
-	fmt.Printf("Results written to %s\n", filename)
+// marshalResult encodes the result as JSON in the given output format
+func marshalResult(result BenchmarkResult, format string) ([]byte, error) {
+	if format == "autocannon" {
+		return json.MarshalIndent(toAutocannon(result), "", "  ")
+	}
+	return json.MarshalIndent(result, "", "  ")
 }
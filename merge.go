@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runMerge implements the "merge" subcommand, combining the result files
+// of generators that ran at the same time, e.g. one per host or shard,
+// into one result
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("output", "", "Write the merged result JSON to this file")
+	jsonOutput := fs.Bool("json", false, "Print the merged result as JSON instead of tables")
+	percentileList := fs.String("percentiles", "", "Latency percentiles of the merged result, e.g. 50,90,99 (default: those of the inputs)")
+	latencyUnitFlag := fs.String("latency-unit", "ms", "Show latencies in us, ms or s")
+	precision := fs.Int("precision", 2, "Show latencies with this many decimals")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon merge [flags] a.json b.json...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureColor(*noColor)
+
+	if err := configureLatencyFormat(*latencyUnitFlag, *precision); err != nil {
+		fmt.Printf("Invalid latency format: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	var percentiles []float64
+	if *percentileList != "" {
+		var err error
+		if percentiles, err = parsePercentiles(*percentileList); err != nil {
+			fmt.Printf("Invalid -percentiles: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+	var results []BenchmarkResult
+	for _, path := range fs.Args() {
+		result, err := readResultFile(path)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", path, err)
+			os.Exit(exitConfigError)
+		}
+		results = append(results, result)
+	}
+
+	merged, err := mergeResults(results, percentiles)
+	if err != nil {
+		fmt.Printf("Error merging: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	merged.Sources = fs.Args()
+
+	if *output != "" {
+		data, err := json.MarshalIndent(merged, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*output, data, 0644)
+		}
+		if err != nil {
+			fmt.Printf("Error writing %s: %v\n", *output, err)
+			os.Exit(exitConfigError)
+		}
+	}
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(merged, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	consoleSink{}.Flush(merged)
+	if merged.latencyHist == nil {
+		fmt.Println("\nLatency percentiles are left out: not every result has a latency histogram (written before autocannon kept one).")
+	}
+}
+
+// mergeResults combines the results of generators that ran at the same
+// time. Counters, rates and connections are summed; the latency average
+// is weighted by the requests of each result, and the percentiles come from the
+// merged latency histograms, when every result has one. Intervals are
+// aligned on their wall-clock timestamps. Sections of optional flags are
+// not merged.
+func mergeResults(results []BenchmarkResult, percentiles []float64) (BenchmarkResult, error) {
+	first := results[0]
+	merged := BenchmarkResult{
+		SchemaVersion:    ResultSchemaVersion,
+		URI:              joinDistinct(results, func(r BenchmarkResult) string { return r.URI }),
+		Method:           joinDistinct(results, func(r BenchmarkResult) string { return r.Method }),
+		Model:            joinDistinct(results, func(r BenchmarkResult) string { return r.Model }),
+		AcceptEncoding:   first.AcceptEncoding,
+		StatusCodeCounts: make(map[int]int64),
+		Timestamp:        first.Timestamp,
+		Tags:             commonTags(results),
+		Metadata: RunMetadata{
+			Hostname:  joinDistinct(results, func(r BenchmarkResult) string { return r.Metadata.Hostname }),
+			GoVersion: first.Metadata.GoVersion,
+			GitSHA:    joinDistinct(results, func(r BenchmarkResult) string { return r.Metadata.GitSHA }),
+			OS:        first.Metadata.OS,
+			Arch:      first.Metadata.Arch,
+			TimeZone:  joinDistinct(results, func(r BenchmarkResult) string { return r.Metadata.TimeZone }),
+		},
+	}
+	if percentiles == nil {
+		for _, p := range first.Latency.Percentiles {
+			percentiles = append(percentiles, p.Percentile)
+		}
+	}
+
+	var latencyTotal float64
+	var hist *histogram
+	histograms := true
+	for i, r := range results {
+		merged.Rate += r.Rate
+		merged.Connections += r.Connections
+		merged.VUs += r.VUs
+		merged.Duration = max(merged.Duration, r.Duration)
+		merged.Interrupted = merged.Interrupted || r.Interrupted
+		if r.Timestamp.Before(merged.Timestamp) {
+			merged.Timestamp = r.Timestamp
+		}
+		if !r.Metadata.Started.IsZero() && (merged.Metadata.Started.IsZero() || r.Metadata.Started.Before(merged.Metadata.Started)) {
+			merged.Metadata.Started = r.Metadata.Started
+		}
+		if r.Metadata.Ended.After(merged.Metadata.Ended) {
+			merged.Metadata.Ended = r.Metadata.Ended
+		}
+
+		merged.Requests.Total += r.Requests.Total
+		merged.Requests.Successful += r.Requests.Successful
+		merged.Requests.Failed += r.Requests.Failed
+		merged.Requests.Throttled += r.Requests.Throttled
+		merged.Requests.Timeouts += r.Requests.Timeouts
+		merged.Requests.ConnectTimeouts += r.Requests.ConnectTimeouts
+		merged.Requests.HeaderTimeouts += r.Requests.HeaderTimeouts
+		merged.Requests.BodyTimeouts += r.Requests.BodyTimeouts
+		merged.Throughput.RequestsPerSecond += r.Throughput.RequestsPerSecond
+		merged.Throughput.BytesRead += r.Throughput.BytesRead
+		merged.Throughput.BytesWritten += r.Throughput.BytesWritten
+		for code, count := range r.StatusCodeCounts {
+			merged.StatusCodeCounts[code] += count
+		}
+
+		if r.Requests.Total > 0 {
+			latencyTotal += r.Latency.Average * float64(r.Requests.Total)
+			if merged.Latency.Min == 0 || r.Latency.Min < merged.Latency.Min {
+				merged.Latency.Min = r.Latency.Min
+			}
+			merged.Latency.Max = max(merged.Latency.Max, r.Latency.Max)
+		}
+		if r.Latency.Histogram == "" {
+			histograms = histograms && r.Requests.Total == 0
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(r.Latency.Histogram)
+		if err != nil {
+			return merged, fmt.Errorf("latency histogram of result %d: %v", i+1, err)
+		}
+		h, err := decodeCompressed(data)
+		if err != nil {
+			return merged, fmt.Errorf("latency histogram of result %d: %v", i+1, err)
+		}
+		if hist == nil {
+			hist = h
+		} else if len(h.counts) != len(hist.counts) || h.significantFigures != hist.significantFigures {
+			return merged, fmt.Errorf("latency histogram of result %d has a different range", i+1)
+		} else {
+			hist.merge(h)
+		}
+	}
+	if merged.Requests.Total > 0 {
+		merged.Requests.ErrorRate = float64(merged.Requests.Failed) / float64(merged.Requests.Total) * 100
+	}
+	if merged.Requests.Total > 0 {
+		merged.Latency.Average = latencyTotal / float64(merged.Requests.Total)
+	}
+	if histograms && hist != nil {
+		merged.latencyHist = hist
+		for _, p := range percentiles {
+			merged.Latency.Percentiles = append(merged.Latency.Percentiles, PercentileValue{
+				Percentile: p,
+				Value:      float64(hist.valueAtPercentile(p)) / 1000,
+			})
+		}
+		if encoded, err := hist.encodeCompressed(); err == nil {
+			merged.Latency.Histogram = base64.StdEncoding.EncodeToString(encoded)
+		}
+		merged.Variability = &VariabilityStats{LatencyStdDev: hist.stdDev() / 1000}
+	}
+	merged.Intervals = mergeIntervals(results, merged.Timestamp)
+	return merged, nil
+}
+
+// mergeIntervals sums the intervals of the results that have the same
+// wall-clock second
+func mergeIntervals(results []BenchmarkResult, start time.Time) []IntervalStats {
+	byTime := make(map[time.Time]*IntervalStats)
+	latencyTotals := make(map[time.Time]float64)
+	for _, r := range results {
+		for _, in := range r.Intervals {
+			at := in.Timestamp.UTC().Round(time.Second)
+			m, ok := byTime[at]
+			if !ok {
+				m = &IntervalStats{Timestamp: at, StatusCodeCounts: make(map[int]int64), MinLatency: in.MinLatency}
+				byTime[at] = m
+			}
+			m.Requests += in.Requests
+			m.SuccessfulReqs += in.SuccessfulReqs
+			m.FailedReqs += in.FailedReqs
+			m.Timeouts += in.Timeouts
+			m.RequestsPerSec += in.RequestsPerSec
+			m.BytesRead += in.BytesRead
+			m.BytesReadPerSec += in.BytesReadPerSec
+			if in.Requests > 0 && (in.MinLatency < m.MinLatency || m.MinLatency == 0) {
+				m.MinLatency = in.MinLatency
+			}
+			m.MaxLatency = max(m.MaxLatency, in.MaxLatency)
+			latencyTotals[at] += in.AverageLatency * float64(in.Requests)
+			for code, count := range in.StatusCodeCounts {
+				m.StatusCodeCounts[code] += count
+			}
+		}
+	}
+	intervals := make([]IntervalStats, 0, len(byTime))
+	for at, m := range byTime {
+		if m.Requests > 0 {
+			m.AverageLatency = latencyTotals[at] / float64(m.Requests)
+		}
+		m.ElapsedSeconds = at.Sub(start.Round(time.Second)).Seconds()
+		intervals = append(intervals, *m)
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Timestamp.Before(intervals[j].Timestamp) })
+	return intervals
+}
+
+// joinDistinct joins the distinct non-empty values of the results, in
+// order of first appearance
+func joinDistinct(results []BenchmarkResult, value func(BenchmarkResult) string) string {
+	var values []string
+	for _, r := range results {
+		if v := value(r); v != "" && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// commonTags returns the tags every result has with the same value
+func commonTags(results []BenchmarkResult) map[string]string {
+	var tags map[string]string
+	for key, value := range results[0].Tags {
+		common := true
+		for _, r := range results[1:] {
+			if v, ok := r.Tags[key]; !ok || v != value {
+				common = false
+				break
+			}
+		}
+		if common {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[key] = value
+		}
+	}
+	return tags
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunMetadata describes the environment a benchmark was run from
+type RunMetadata struct {
+	Hostname  string `json:"hostname,omitempty"`
+	GoVersion string `json:"goVersion"`
+	GitSHA    string `json:"gitSha,omitempty"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+
+	// Started and Ended are when the measured run began and ended, in
+	// UTC, so results of several hosts can be aligned; TimeZone is the
+	// local zone of this host, e.g. "CEST (UTC+02:00)"
+	Started  time.Time `json:"started,omitzero"`
+	Ended    time.Time `json:"ended,omitzero"`
+	TimeZone string    `json:"timeZone,omitempty"`
+
+	// TLS is what the first TLS connection negotiated
+	TLS *TLSInfo `json:"tls,omitempty"`
+}
+
+// collectMetadata captures the hostname, Go version and, when run from
+// inside a git checkout, the current commit
+func collectMetadata() RunMetadata {
+	meta := RunMetadata{
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	now := time.Now()
+	zone, _ := now.Zone()
+	meta.TimeZone = fmt.Sprintf("%s (UTC%s)", zone, now.Format("-07:00"))
+	if hostname, err := os.Hostname(); err == nil {
+		meta.Hostname = hostname
+	}
+	if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		meta.GitSHA = strings.TrimSpace(string(out))
+	}
+	return meta
+}
+
+// tagFlag collects repeatable -tag key=value flags
+type tagFlag map[string]string
+
+func (t tagFlag) String() string {
+	return formatTags(t)
+}
+
+func (t tagFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("tag %q must be in key=value form", value)
+	}
+	t[key] = strings.TrimSpace(val)
+	return nil
+}
+
+// formatTags renders tags as a stable, comma separated key=value list
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+tags[key])
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// scenarioEntry is one scenario of a weighted mix in a scenario file
+type scenarioEntry struct {
+	Name      string         `json:"name"`
+	Weight    float64        `json:"weight"`
+	Steps     []scenarioStep `json:"steps"`
+	ThinkTime string         `json:"thinkTime"`
+}
+
+// scenarioShare is a scenario of the mix: its steps are count steps of
+// the generator from first on. Workers are assigned the scenario whose
+// picks are furthest below its weight, so the mix follows the weights
+// exactly rather than on average.
+type scenarioShare struct {
+	name   string
+	weight float64
+	first  int
+	count  int
+	picks  int64
+
+	mu         sync.Mutex
+	users      int
+	iterations int64
+	errors     int64
+	latency    latencyTracker
+}
+
+// addScenario parses the steps of a scenario and adds them to the
+// generator
+func (g *scenarioGenerator) addScenario(entry scenarioEntry, baseURI string, defaultThink thinkTime) (*scenarioShare, error) {
+	if g.mixed {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("scenario %d has no name", len(g.mix)+1)
+		}
+		for _, share := range g.mix {
+			if share.name == entry.Name {
+				return nil, fmt.Errorf("scenario %q is defined twice", entry.Name)
+			}
+		}
+		if len(entry.Steps) == 0 {
+			return nil, fmt.Errorf("scenario %q defines no steps", entry.Name)
+		}
+	}
+	if entry.Weight < 0 {
+		return nil, fmt.Errorf("scenario %q has a negative weight", entry.Name)
+	}
+	if entry.Weight == 0 {
+		entry.Weight = 1
+	}
+	think := defaultThink
+	if entry.ThinkTime != "" {
+		var err error
+		if think, err = parseThinkTime(entry.ThinkTime); err != nil {
+			return nil, fmt.Errorf("scenario %q: thinkTime: %v", entry.Name, err)
+		}
+	}
+	steps, err := parseScenarioSteps(entry.Steps, "", entry.Name, baseURI, think)
+	if err != nil {
+		return nil, err
+	}
+	share := &scenarioShare{name: entry.Name, weight: entry.Weight, first: len(g.steps), count: len(steps)}
+	g.steps = append(g.steps, steps...)
+	return share, nil
+}
+
+// scenarioOf returns the scenario of a worker about to send the step at
+// position, assigning one to a worker that has none. In the open model,
+// where requests aren't tied to a worker, every pass through the steps
+// is assigned anew. g.mu must be held.
+func (g *scenarioGenerator) scenarioOf(worker, position int) *scenarioShare {
+	share, ok := g.assigned[worker]
+	if !ok || worker < 0 && position == 0 {
+		share = g.mix[0]
+		for _, s := range g.mix[1:] {
+			if float64(s.picks)/s.weight < float64(share.picks)/share.weight {
+				share = s
+			}
+		}
+		share.picks++
+		g.assigned[worker] = share
+		if worker >= 0 {
+			share.users++
+		}
+	}
+	if position == 0 {
+		share.iterations++
+	}
+	return share
+}
+
+// ScenarioStats describes a scenario of a weighted mix: the users it was
+// assigned, the passes through its steps they started, and their
+// requests
+type ScenarioStats struct {
+	Scenario   string         `json:"scenario"`
+	Weight     float64        `json:"weight"`
+	Users      int            `json:"users"`
+	Iterations int64          `json:"iterations"`
+	Requests   int64          `json:"requests"`
+	Errors     int64          `json:"errors"`
+	Latency    LatencySummary `json:"latency"`
+}
+
+// record adds a measured request to the statistics of its scenario
+func (g *scenarioGenerator) record(ctx context.Context, latency float64, failed bool) {
+	step, ok := scenarioStepFrom(ctx)
+	if !ok || !g.mixed || step < 0 {
+		return
+	}
+	for _, share := range g.mix {
+		if step >= share.first && step < share.first+share.count {
+			if failed {
+				share.mu.Lock()
+				share.errors++
+				share.mu.Unlock()
+			}
+			share.latency.record(latency)
+			return
+		}
+	}
+}
+
+// mixSummary returns the statistics of each scenario, nil for a file
+// without a mix
+func (g *scenarioGenerator) mixSummary() []ScenarioStats {
+	if !g.mixed {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make([]ScenarioStats, 0, len(g.mix))
+	for _, share := range g.mix {
+		latency := share.latency.summary()
+		share.mu.Lock()
+		stats = append(stats, ScenarioStats{
+			Scenario:   share.name,
+			Weight:     share.weight,
+			Users:      share.users,
+			Iterations: share.iterations,
+			Requests:   latency.Count,
+			Errors:     share.errors,
+			Latency:    latency,
+		})
+		share.mu.Unlock()
+	}
+	return stats
+}
+
+// displayScenarioStats prints the per-scenario table of a weighted mix
+func displayScenarioStats(result BenchmarkResult) {
+	if len(result.Scenarios) == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nScenario Mix:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Scenario", "Weight", "Users", "Iterations", "Requests", "Errors", "Avg Latency", "Max Latency")
+
+	var weights float64
+	for _, s := range result.Scenarios {
+		weights += s.Weight
+	}
+	for _, s := range result.Scenarios {
+		table.Append([]string{
+			s.Scenario,
+			fmt.Sprintf("%.1f%%", s.Weight/weights*100),
+			fmt.Sprintf("%d", s.Users),
+			fmt.Sprintf("%d", s.Iterations),
+			fmt.Sprintf("%d", s.Requests),
+			fmt.Sprintf("%d", s.Errors),
+			formatLatency(s.Latency.Average),
+			formatLatency(s.Latency.Max),
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// clientIdentity is a client certificate of -client-certs, presented by
+// the connections of the workers it is assigned to
+type clientIdentity struct {
+	name string
+	cert tls.Certificate
+
+	// client sends requests over connections of the identity's own, set
+	// up by runBenchmark
+	client *http.Client
+
+	workers  int64
+	requests int64
+	errors   int64
+	limited  int64
+	latency  latencyTracker
+}
+
+// loadClientIdentities loads the client certificates in dir, one
+// identity per NAME.crt, NAME.cer or NAME.pem file, with its private key
+// in NAME.key or in the same file, in the order of their names
+func loadClientIdentities(dir string) ([]*clientIdentity, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".crt", ".cer", ".pem":
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var identities []*clientIdentity
+	for _, name := range names {
+		certFile := filepath.Join(dir, name)
+		keyFile := strings.TrimSuffix(certFile, filepath.Ext(certFile)) + ".key"
+		if _, err := os.Stat(keyFile); err != nil {
+			keyFile = certFile
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		identities = append(identities, &clientIdentity{name: strings.TrimSuffix(name, filepath.Ext(name)), cert: cert})
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%s has no .crt, .cer or .pem certificates", dir)
+	}
+	return identities, nil
+}
+
+// identityTransport returns a copy of transport whose connections present
+// the identity's certificate. Sessions are cached per identity, as a
+// resumed session keeps the identity it was established with.
+func (id *clientIdentity) identityTransport(transport *http.Transport, sessions int) *http.Transport {
+	t := transport.Clone()
+	t.TLSClientConfig.Certificates = []tls.Certificate{id.cert}
+	if t.TLSClientConfig.ClientSessionCache != nil {
+		t.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(sessions)
+	}
+	return t
+}
+
+// outsideClient returns the client of the requests sent outside of the
+// run, the preflight request and -hooks, which present the first client
+// certificate with -client-certs and go through the -proxy
+func outsideClient(identities []*clientIdentity, proxy *url.URL, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if len(identities) > 0 || proxy != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if len(identities) > 0 {
+			transport.TLSClientConfig = &tls.Config{Certificates: []tls.Certificate{identities[0].cert}}
+		}
+		if proxy != nil {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		client.Transport = transport
+	}
+	return client
+}
+
+// identityFor returns the identity of a worker: the identities are
+// assigned to the workers in turn
+func identityFor(identities []*clientIdentity, worker int) *clientIdentity {
+	if len(identities) == 0 {
+		return nil
+	}
+	id := identities[worker%len(identities)]
+	atomic.AddInt64(&id.workers, 1)
+	return id
+}
+
+func (id *clientIdentity) record(latency float64, failed bool, status int) {
+	atomic.AddInt64(&id.requests, 1)
+	if failed {
+		atomic.AddInt64(&id.errors, 1)
+	}
+	if status == http.StatusTooManyRequests {
+		atomic.AddInt64(&id.limited, 1)
+	}
+	id.latency.record(latency)
+}
+
+// IdentityStats counts the requests sent with a client certificate of
+// -client-certs. RateLimited counts 429 responses.
+type IdentityStats struct {
+	Identity    string         `json:"identity"`
+	Subject     string         `json:"subject,omitempty"`
+	Workers     int64          `json:"workers"`
+	Requests    int64          `json:"requests"`
+	Errors      int64          `json:"errors"`
+	RateLimited int64          `json:"rateLimited"`
+	Latency     LatencySummary `json:"latency"`
+}
+
+func clientIdentitySummaries(identities []*clientIdentity) []IdentityStats {
+	stats := make([]IdentityStats, len(identities))
+	for i, id := range identities {
+		stats[i] = IdentityStats{
+			Identity:    id.name,
+			Workers:     atomic.LoadInt64(&id.workers),
+			Requests:    atomic.LoadInt64(&id.requests),
+			Errors:      atomic.LoadInt64(&id.errors),
+			RateLimited: atomic.LoadInt64(&id.limited),
+			Latency:     id.latency.summary(),
+		}
+		if leaf := id.cert.Leaf; leaf != nil {
+			stats[i].Subject = leaf.Subject.CommonName
+		}
+	}
+	return stats
+}
+
+// displayClientIdentities prints the per-identity table of -client-certs
+func displayClientIdentities(result BenchmarkResult) {
+	if len(result.Identities) == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nClient Identities:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Identity", "Subject", "Workers", "Requests", "Errors", "Rate Limited", "Avg Latency")
+
+	for _, id := range result.Identities {
+		table.Append([]string{
+			id.Identity,
+			id.Subject,
+			fmt.Sprintf("%d", id.Workers),
+			fmt.Sprintf("%d", id.Requests),
+			fmt.Sprintf("%d", id.Errors),
+			fmt.Sprintf("%d", id.RateLimited),
+			formatLatency(id.Latency.Average),
+		})
+	}
+
+	table.Render()
+}
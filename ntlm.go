@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+)
+
+// ntlmCredentials are the account of -ntlm, DOMAIN\user:password
+type ntlmCredentials struct {
+	domain   string
+	user     string
+	password string
+	scheme   string // "NTLM" or "Negotiate"
+
+	handshakes int64
+	failed     int64
+	latency    latencyTracker
+}
+
+// parseNTLMCredentials parses DOMAIN\user:password, or user:password
+func parseNTLMCredentials(s, scheme string) (*ntlmCredentials, error) {
+	account, password, ok := strings.Cut(s, ":")
+	if !ok || account == "" {
+		return nil, errors.New(`expected DOMAIN\user:password`)
+	}
+	c := &ntlmCredentials{user: account, password: password}
+	if domain, user, ok := strings.Cut(account, `\`); ok {
+		c.domain, c.user = domain, user
+	}
+	switch strings.ToLower(scheme) {
+	case "ntlm":
+		c.scheme = "NTLM"
+	case "negotiate":
+		c.scheme = "Negotiate"
+	default:
+		return nil, fmt.Errorf("unknown scheme %q, expected ntlm or negotiate", scheme)
+	}
+	return c, nil
+}
+
+// account returns DOMAIN\user, without the password
+func (c *ntlmCredentials) account() string {
+	if c.domain == "" {
+		return c.user
+	}
+	return c.domain + `\` + c.user
+}
+
+// AuthStats describes the handshakes of -ntlm, where every new connection
+// authenticates once, or the challenges answered by -digest-auth. Latency
+// is that of the requests sent with a handshake, both legs included.
+type AuthStats struct {
+	Scheme     string         `json:"scheme"`
+	Handshakes int64          `json:"handshakes"`
+	Failed     int64          `json:"failed"`
+	Stale      int64          `json:"stale,omitempty"` // Digest nonces
+	Latency    LatencySummary `json:"latency"`
+}
+
+func (c *ntlmCredentials) summary() *AuthStats {
+	return &AuthStats{
+		Scheme:     c.scheme,
+		Handshakes: atomic.LoadInt64(&c.handshakes),
+		Failed:     atomic.LoadInt64(&c.failed),
+		Latency:    c.latency.summary(),
+	}
+}
+
+// ntlmTransport authenticates the single connection of its transport
+// with an NTLM handshake: a negotiate message, the server's challenge in
+// a 401, and the authenticate message on the same connection. A request
+// rejected with a 401 on an authenticated connection, which the server
+// closed or -reconnect-every replaced, authenticates again.
+type ntlmTransport struct {
+	base  *http.Transport // with at most one connection
+	creds *ntlmCredentials
+
+	mu            sync.Mutex
+	authenticated bool
+}
+
+func newNTLMTransport(base *http.Transport, creds *ntlmCredentials) *ntlmTransport {
+	base = base.Clone()
+	base.MaxConnsPerHost = 1
+	base.MaxIdleConnsPerHost = 1
+	return &ntlmTransport{base: base, creds: creds}
+}
+
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests that authenticate themselves are left alone
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	t.mu.Lock()
+	authenticated := t.authenticated
+	t.mu.Unlock()
+	if authenticated {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || !t.challenged(resp) {
+			t.closing(req, resp)
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, nil
+		}
+		discard(resp)
+	}
+	return t.handshake(req)
+}
+
+// handshake authenticates the connection while sending req
+func (t *ntlmTransport) handshake(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	atomic.AddInt64(&t.creds.handshakes, 1)
+	fail := func(resp *http.Response, err error) (*http.Response, error) {
+		atomic.AddInt64(&t.creds.failed, 1)
+		t.mu.Lock()
+		t.authenticated = false
+		t.mu.Unlock()
+		return resp, err
+	}
+
+	negotiate, err := rewind(req)
+	if err != nil {
+		return fail(nil, err)
+	}
+	negotiate.Header.Set("Authorization", t.creds.scheme+" "+base64.StdEncoding.EncodeToString(ntlmNegotiate()))
+	resp, err := t.base.RoundTrip(negotiate)
+	if err != nil {
+		return fail(nil, err)
+	}
+	var challenge []byte
+	if resp.StatusCode != http.StatusUnauthorized || !t.challenged(resp) {
+		return fail(resp, nil)
+	}
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		if token, ok := strings.CutPrefix(value, t.creds.scheme+" "); ok {
+			challenge, _ = base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+		}
+	}
+	discard(resp)
+	authenticate, err := ntlmAuthenticate(challenge, t.creds)
+	if err != nil {
+		return fail(nil, fmt.Errorf("NTLM challenge: %v", err))
+	}
+
+	final, err := rewind(req)
+	if err != nil {
+		return fail(nil, err)
+	}
+	final.Header.Set("Authorization", t.creds.scheme+" "+base64.StdEncoding.EncodeToString(authenticate))
+	resp, err = t.base.RoundTrip(final)
+	if err != nil {
+		return fail(nil, err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fail(resp, nil)
+	}
+	t.mu.Lock()
+	t.authenticated = true
+	t.mu.Unlock()
+	t.closing(final, resp)
+	t.creds.latency.record(float64(time.Since(start).Microseconds()) / 1000)
+	return resp, nil
+}
+
+// closing forgets the authentication of a connection that closes after
+// resp, by -reconnect-every or by the server, so the next request
+// authenticates its new connection without being rejected first
+func (t *ntlmTransport) closing(req *http.Request, resp *http.Response) {
+	if req.Close || resp == nil || resp.Close {
+		t.mu.Lock()
+		t.authenticated = false
+		t.mu.Unlock()
+	}
+}
+
+// challenged reports whether a 401 asks for the transport's scheme
+func (t *ntlmTransport) challenged(resp *http.Response) bool {
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		if fields := strings.Fields(value); len(fields) > 0 && strings.EqualFold(fields[0], t.creds.scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewind returns a copy of req with a fresh body, to send it again
+func rewind(req *http.Request) (*http.Request, error) {
+	out := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, errors.New("NTLM authentication needs a request body that can be sent twice")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		out.Body = body
+	}
+	return out, nil
+}
+
+// discard reads the rest of a response, so its connection can be reused
+// for the next leg of the handshake
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+}
+
+// NTLM message flags, see [MS-NLMP] 2.2.2.5
+const (
+	ntlmUnicode          = 0x00000001
+	ntlmRequestTarget    = 0x00000004
+	ntlmNTLM             = 0x00000200
+	ntlmAlwaysSign       = 0x00008000
+	ntlmExtendedSecurity = 0x00080000
+	ntlmTargetInfo       = 0x00800000
+	ntlm128              = 0x20000000
+	ntlm56               = 0x80000000
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiate returns the NEGOTIATE_MESSAGE that starts a handshake
+func ntlmNegotiate() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmUnicode|ntlmRequestTarget|ntlmNTLM|ntlmAlwaysSign|ntlmExtendedSecurity|ntlmTargetInfo|ntlm128|ntlm56)
+	return msg
+}
+
+// ntlmAuthenticate answers a CHALLENGE_MESSAGE with the
+// AUTHENTICATE_MESSAGE of an NTLMv2 response
+func ntlmAuthenticate(challenge []byte, creds *ntlmCredentials) ([]byte, error) {
+	if len(challenge) < 32 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("not an NTLM challenge")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+	var targetInfo []byte
+	if len(challenge) >= 48 {
+		length := int(binary.LittleEndian.Uint16(challenge[40:]))
+		offset := int(binary.LittleEndian.Uint32(challenge[44:]))
+		if offset+length > len(challenge) {
+			return nil, errors.New("truncated target info")
+		}
+		targetInfo = challenge[offset : offset+length]
+	}
+
+	// The server's timestamp, if it sent one, or the current time, in
+	// 100ns intervals since 1601
+	timestamp := ntlmTimestamp(targetInfo)
+	if timestamp == nil {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+	}
+	clientChallenge := make([]byte, 8)
+	rand.Read(clientChallenge)
+	ntResponse, lmResponse := ntlmV2Response(creds, serverChallenge, clientChallenge, timestamp, targetInfo)
+
+	domain := utf16le(creds.domain)
+	user := utf16le(creds.user)
+	workstation := utf16le("")
+	payloads := [][]byte{lmResponse, ntResponse, domain, user, workstation, nil}
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	offset := len(msg)
+	for i, payload := range payloads {
+		field := 12 + 8*i
+		binary.LittleEndian.PutUint16(msg[field:], uint16(len(payload)))
+		binary.LittleEndian.PutUint16(msg[field+2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(msg[field+4:], uint32(offset))
+		offset += len(payload)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags&^ntlmRequestTarget|ntlmUnicode)
+	for _, payload := range payloads {
+		msg = append(msg, payload...)
+	}
+	return msg, nil
+}
+
+// ntlmV2Response computes the NTLMv2 and LMv2 responses, see [MS-NLMP]
+// 3.3.2
+func ntlmV2Response(creds *ntlmCredentials, serverChallenge, clientChallenge, timestamp, targetInfo []byte) (nt, lm []byte) {
+	ntHash := md4Sum(utf16le(creds.password))
+	v2Hash := hmacMD5(ntHash, utf16le(strings.ToUpper(creds.user)+creds.domain))
+
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	proof := hmacMD5(v2Hash, append(append([]byte(nil), serverChallenge...), temp...))
+	nt = append(proof, temp...)
+	lm = append(hmacMD5(v2Hash, append(append([]byte(nil), serverChallenge...), clientChallenge...)), clientChallenge...)
+	return nt, lm
+}
+
+// ntlmTimestamp returns the MsvAvTimestamp of the target info, or nil
+func ntlmTimestamp(targetInfo []byte) []byte {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo)
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if id == 0 || 4+length > len(targetInfo) {
+			return nil
+		}
+		if id == 7 && length == 8 {
+			return targetInfo[4:12]
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+	return nil
+}
+
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// md4Sum is MD4 (RFC 1320), which NTLM hashes passwords with and the
+// standard library doesn't have
+func md4Sum(data []byte) []byte {
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	length := uint64(len(data)) * 8
+	msg := append(append([]byte(nil), data...), 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, length)
+
+	var x [16]uint32
+	for block := 0; block < len(msg); block += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[block+4*i:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+		f := func(x, y, z uint32) uint32 { return x&y | ^x&z }
+		g := func(x, y, z uint32) uint32 { return x&y | x&z | y&z }
+		h := func(x, y, z uint32) uint32 { return x ^ y ^ z }
+		for _, i := range []int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+f(b, c, d)+x[i], 3)
+			d = bits.RotateLeft32(d+f(a, b, c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+f(d, a, b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+f(c, d, a)+x[i+3], 19)
+		}
+		for _, i := range []int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+g(b, c, d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+g(a, b, c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+g(d, a, b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+g(c, d, a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range []int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+h(b, c, d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+h(a, b, c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+h(d, a, b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+h(c, d, a)+x[i+12]+0x6ed9eba1, 15)
+		}
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+	}
+	sum := make([]byte, 16)
+	binary.LittleEndian.PutUint32(sum, a)
+	binary.LittleEndian.PutUint32(sum[4:], b)
+	binary.LittleEndian.PutUint32(sum[8:], c)
+	binary.LittleEndian.PutUint32(sum[12:], d)
+	return sum
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// TestMD4 checks md4Sum against the test suite of RFC 1320
+func TestMD4(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890", "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(md4Sum([]byte(tt.in))); got != tt.want {
+			t.Errorf("md4Sum(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// ntlmTestTargetInfo is the target info of the [MS-NLMP] 4.2.4 example:
+// the NetBIOS domain "Domain" and computer "Server", and the end marker
+var ntlmTestTargetInfo = mustHex("02000c0044006f006d00610069006e00" + "01000c00530065007200760065007200" + "00000000")
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestNTLMv2Response checks the responses against the NTLMv2 example of
+// [MS-NLMP] 4.2.4
+func TestNTLMv2Response(t *testing.T) {
+	creds, err := parseNTLMCredentials(`Domain\User:Password`, "ntlm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverChallenge := mustHex("0123456789abcdef")
+	clientChallenge := mustHex("aaaaaaaaaaaaaaaa")
+	timestamp := make([]byte, 8)
+
+	nt, lm := ntlmV2Response(creds, serverChallenge, clientChallenge, timestamp, ntlmTestTargetInfo)
+	if got, want := hex.EncodeToString(lm), "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"; got != want {
+		t.Errorf("LMv2 response %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(nt[:16]), "68cd0ab851e51c96aabc927bebef6a1c"; got != want {
+		t.Errorf("NTProofStr %s, want %s", got, want)
+	}
+	temp := append(mustHex("0101000000000000"+"0000000000000000"+"aaaaaaaaaaaaaaaa"+"00000000"), ntlmTestTargetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+	if !bytes.Equal(nt[16:], temp) {
+		t.Errorf("NTLMv2 client challenge % x, want % x", nt[16:], temp)
+	}
+}
+
+// TestNTLMAuthenticate checks the fields of the AUTHENTICATE_MESSAGE
+// answering a challenge with a timestamp
+func TestNTLMAuthenticate(t *testing.T) {
+	creds, err := parseNTLMCredentials(`Domain\User:Password`, "negotiate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The target info with an MsvAvTimestamp before the end marker
+	targetInfo := append(append([]byte(nil), ntlmTestTargetInfo[:len(ntlmTestTargetInfo)-4]...), mustHex("070008000102030405060708"+"00000000")...)
+	challenge := make([]byte, 48)
+	copy(challenge, ntlmSignature)
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], ntlmUnicode|ntlmRequestTarget|ntlmNTLM|ntlmTargetInfo)
+	copy(challenge[24:], mustHex("0123456789abcdef"))
+	binary.LittleEndian.PutUint16(challenge[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(challenge[44:], 48)
+	challenge = append(challenge, targetInfo...)
+
+	msg, err := ntlmAuthenticate(challenge, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 3 {
+		t.Fatalf("not an AUTHENTICATE_MESSAGE: % x", msg[:12])
+	}
+	field := func(i int) []byte {
+		length := int(binary.LittleEndian.Uint16(msg[12+8*i:]))
+		offset := int(binary.LittleEndian.Uint32(msg[16+8*i:]))
+		return msg[offset : offset+length]
+	}
+	if got := field(2); !bytes.Equal(got, utf16le("Domain")) {
+		t.Errorf("domain % x, want Domain", got)
+	}
+	if got := field(3); !bytes.Equal(got, utf16le("User")) {
+		t.Errorf("user % x, want User", got)
+	}
+	nt := field(1)
+	if len(nt) != 16+28+len(targetInfo)+4 {
+		t.Fatalf("NT response of %d bytes, want %d", len(nt), 16+28+len(targetInfo)+4)
+	}
+	if got := nt[24:32]; !bytes.Equal(got, mustHex("0102030405060708")) {
+		t.Errorf("timestamp % x, want the server's", got)
+	}
+	// The proof is that of the client challenge the message carries
+	clientChallenge := nt[32:40]
+	want, _ := ntlmV2Response(creds, challenge[24:32], clientChallenge, nt[24:32], targetInfo)
+	if !bytes.Equal(nt, want) {
+		t.Errorf("NT response % x, want % x", nt, want)
+	}
+	if flags := binary.LittleEndian.Uint32(msg[60:]); flags&ntlmRequestTarget != 0 || flags&ntlmUnicode == 0 {
+		t.Errorf("flags %#x", flags)
+	}
+
+	for name, bad := range map[string][]byte{
+		"short":     challenge[:20],
+		"signature": append([]byte("NTLMSSX\x00"), challenge[8:]...),
+		"truncated": challenge[:60],
+	} {
+		if _, err := ntlmAuthenticate(bad, creds); err == nil {
+			t.Errorf("%s challenge: no error", name)
+		}
+	}
+}
+
+// TestParseNTLMCredentials checks the account forms and schemes
+func TestParseNTLMCredentials(t *testing.T) {
+	creds, err := parseNTLMCredentials(`CORP\alice:pa:ss`, "Negotiate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.domain != "CORP" || creds.user != "alice" || creds.password != "pa:ss" || creds.scheme != "Negotiate" || creds.account() != `CORP\alice` {
+		t.Errorf("got %+v", creds)
+	}
+	if creds, err := parseNTLMCredentials("bob:secret", "ntlm"); err != nil || creds.domain != "" || creds.account() != "bob" {
+		t.Errorf("got %+v, %v", creds, err)
+	}
+	for _, bad := range [][2]string{{"nopassword", "ntlm"}, {":secret", "ntlm"}, {"bob:secret", "kerberos"}} {
+		if _, err := parseNTLMCredentials(bad[0], bad[1]); err == nil {
+			t.Errorf("parseNTLMCredentials(%q, %q): no error", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+	"gopkg.in/yaml.v3"
+)
+
+// OperationStats holds the totals for one OpenAPI operation
+type OperationStats struct {
+	Operation string         `json:"operation"`
+	Method    string         `json:"method"`
+	Path      string         `json:"path"`
+	Requests  int64          `json:"requests"`
+	Errors    int64          `json:"errors"`
+	Latency   LatencySummary `json:"latency"`
+}
+
+// openAPIMethods are the operation keys of a path item, in report order
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPISpec wraps a decoded OpenAPI 3 document for $ref resolution
+type openAPISpec struct {
+	root map[string]any
+}
+
+// loadOpenAPI reads an OpenAPI 3 spec in YAML or JSON and returns one
+// request per operation, with path, query and header parameters and JSON
+// bodies filled from examples, defaults or synthetic values
+func loadOpenAPI(path, baseURI string) ([]requestSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	spec := &openAPISpec{root: root}
+
+	paths, _ := root["paths"].(map[string]any)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s defines no paths", path)
+	}
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+
+	var specs []requestSpec
+	for _, p := range keys {
+		item, _ := spec.resolve(paths[p]).(map[string]any)
+		shared, _ := item["parameters"].([]any)
+
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			// Operation parameters override path item parameters
+			params, _ := op["parameters"].([]any)
+			params = append(append([]any{}, params...), shared...)
+			specs = append(specs, spec.request(baseURI, p, strings.ToUpper(method), op, params))
+		}
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("%s defines no operations", path)
+	}
+	return specs, nil
+}
+
+// request builds the request for one operation
+func (s *openAPISpec) request(baseURI, path, method string, op map[string]any, params []any) requestSpec {
+	name, _ := op["operationId"].(string)
+	if name == "" {
+		name = method + " " + path
+	}
+
+	target := path
+	query := url.Values{}
+	header := http.Header{}
+	seen := make(map[string]bool)
+	for _, raw := range params {
+		param, _ := s.resolve(raw).(map[string]any)
+		paramName, _ := param["name"].(string)
+		key := fmt.Sprint(param["in"]) + ":" + paramName
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		required, _ := param["required"].(bool)
+		_, hasExample := param["example"]
+		value := fmt.Sprint(s.exampleValue(param, 0))
+
+		switch param["in"] {
+		case "path":
+			target = strings.ReplaceAll(target, "{"+paramName+"}", url.PathEscape(value))
+		case "query":
+			if required || hasExample {
+				query.Set(paramName, value)
+			}
+		case "header":
+			if required || hasExample {
+				header.Set(paramName, value)
+			}
+		}
+	}
+
+	rs := requestSpec{
+		Method:    method,
+		URL:       replayURL(baseURI, target),
+		Operation: name,
+		Path:      path,
+		Header:    header,
+	}
+	if len(query) > 0 {
+		rs.URL += "?" + query.Encode()
+	}
+
+	// Only JSON bodies can be synthesized; other media types need an example
+	if body, ok := s.resolve(op["requestBody"]).(map[string]any); ok {
+		content, _ := body["content"].(map[string]any)
+		if media, ok := content["application/json"].(map[string]any); ok {
+			encoded, _ := json.Marshal(s.exampleValue(media, 0))
+			rs.Body = string(encoded)
+			rs.ContentType = "application/json"
+		} else {
+			for contentType, raw := range content {
+				media, _ := raw.(map[string]any)
+				if example, ok := media["example"].(string); ok {
+					rs.Body = example
+					rs.ContentType = contentType
+					break
+				}
+			}
+		}
+	}
+	return rs
+}
+
+// exampleValue returns the example of a parameter, media type or schema
+// object, falling back to a value synthesized from its schema
+func (s *openAPISpec) exampleValue(node map[string]any, depth int) any {
+	if example, ok := node["example"]; ok {
+		return example
+	}
+	if examples, ok := node["examples"].(map[string]any); ok {
+		for _, raw := range examples {
+			if example, ok := s.resolve(raw).(map[string]any); ok {
+				if value, ok := example["value"]; ok {
+					return value
+				}
+			}
+		}
+	}
+	if schema, ok := s.resolve(node["schema"]).(map[string]any); ok {
+		return s.synthesize(schema, depth)
+	}
+	return s.synthesize(node, depth)
+}
+
+// synthesize generates a plausible value for a schema
+func (s *openAPISpec) synthesize(schema map[string]any, depth int) any {
+	if example, ok := schema["example"]; ok {
+		return example
+	}
+	if value, ok := schema["default"]; ok {
+		return value
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+		if variants, ok := schema[key].([]any); ok && len(variants) > 0 {
+			if key != "allOf" || len(variants) == 1 {
+				variant, _ := s.resolve(variants[0]).(map[string]any)
+				return s.synthesize(variant, depth)
+			}
+			merged := map[string]any{}
+			for _, raw := range variants {
+				variant, _ := s.resolve(raw).(map[string]any)
+				if value, ok := s.synthesize(variant, depth).(map[string]any); ok {
+					for k, v := range value {
+						merged[k] = v
+					}
+				}
+			}
+			return merged
+		}
+	}
+
+	// Guard against recursive schemas
+	if depth > 8 {
+		return nil
+	}
+
+	switch schema["type"] {
+	case "integer":
+		if minimum, ok := schema["minimum"]; ok {
+			return minimum
+		}
+		return 1
+	case "number":
+		if minimum, ok := schema["minimum"]; ok {
+			return minimum
+		}
+		return 1.5
+	case "boolean":
+		return true
+	case "array":
+		items, _ := s.resolve(schema["items"]).(map[string]any)
+		return []any{s.synthesize(items, depth+1)}
+	case "string":
+		switch schema["format"] {
+		case "date-time":
+			return time.Now().UTC().Format(time.RFC3339)
+		case "date":
+			return time.Now().UTC().Format("2006-01-02")
+		case "uuid":
+			return "00000000-0000-4000-8000-000000000000"
+		case "email":
+			return "user@example.com"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		return "string"
+	}
+
+	// Objects, with or without an explicit type
+	object := map[string]any{}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, raw := range properties {
+		property, _ := s.resolve(raw).(map[string]any)
+		object[name] = s.synthesize(property, depth+1)
+	}
+	return object
+}
+
+// resolve follows local "#/..." $ref pointers
+func (s *openAPISpec) resolve(node any) any {
+	for i := 0; i < 32; i++ {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return node
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+
+		var target any = s.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			parent, _ := target.(map[string]any)
+			target = parent[part]
+		}
+		node = target
+	}
+	return node
+}
+
+// operationPicker chooses the operation for each request: round-robin,
+// or one operation at a time for an equal share of the duration, counted
+// from the first request
+type operationPicker struct {
+	specs     []requestSpec
+	mode      string
+	slice     time.Duration
+	next      uint64
+	startOnce sync.Once
+	start     time.Time
+}
+
+func newOperationPicker(specs []requestSpec, mode string, duration time.Duration) *operationPicker {
+	return &operationPicker{
+		specs: specs,
+		mode:  mode,
+		slice: duration / time.Duration(len(specs)),
+	}
+}
+
+func (p *operationPicker) Next(ctx context.Context) (*http.Request, error) {
+	return p.pick().newRequest(ctx)
+}
+
+func (p *operationPicker) pick() requestSpec {
+	if p.mode == "per-operation" {
+		p.startOnce.Do(func() { p.start = time.Now() })
+		i := int(time.Since(p.start) / p.slice)
+		if i >= len(p.specs) {
+			i = len(p.specs) - 1
+		}
+		return p.specs[i]
+	}
+	n := atomic.AddUint64(&p.next, 1) - 1
+	return p.specs[n%uint64(len(p.specs))]
+}
+
+// operationTracker accumulates OperationStats. It is safe for concurrent
+// use.
+type operationTracker struct {
+	mu    sync.Mutex
+	order []string
+	stats map[string]*operationTotals
+}
+
+type operationTotals struct {
+	method  string
+	path    string
+	errors  int64
+	latency latencyTracker
+}
+
+func newOperationTracker(specs []requestSpec) *operationTracker {
+	t := &operationTracker{stats: make(map[string]*operationTotals)}
+	for _, spec := range specs {
+		if _, ok := t.stats[spec.Operation]; !ok {
+			t.order = append(t.order, spec.Operation)
+			t.stats[spec.Operation] = &operationTotals{method: spec.Method, path: spec.Path}
+		}
+	}
+	return t
+}
+
+func (t *operationTracker) record(op operationInfo, method string, latency float64, failed bool) {
+	t.mu.Lock()
+	totals, ok := t.stats[op.Name]
+	if !ok {
+		t.order = append(t.order, op.Name)
+		totals = &operationTotals{method: method, path: op.Path}
+		t.stats[op.Name] = totals
+	}
+	if failed {
+		totals.errors++
+	}
+	t.mu.Unlock()
+	totals.latency.record(latency)
+}
+
+func (t *operationTracker) summary() []OperationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make([]OperationStats, 0, len(t.order))
+	for _, name := range t.order {
+		totals := t.stats[name]
+		latency := totals.latency.summary()
+		stats = append(stats, OperationStats{
+			Operation: name,
+			Method:    totals.method,
+			Path:      totals.path,
+			Requests:  latency.Count,
+			Errors:    totals.errors,
+			Latency:   latency,
+		})
+	}
+	return stats
+}
+
+// displayOperationStats prints the per-operation table
+func displayOperationStats(result BenchmarkResult) {
+	if len(result.Operations) == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nPer-Operation Statistics:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Operation", "Method", "Path", "Requests", "Errors", "Avg Latency", "Max Latency")
+
+	for _, op := range result.Operations {
+		table.Append([]string{
+			op.Operation,
+			op.Method,
+			op.Path,
+			fmt.Sprintf("%d", op.Requests),
+			fmt.Sprintf("%d", op.Errors),
+			formatLatency(op.Latency.Average),
+			formatLatency(op.Latency.Max),
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+// parquetRowGroupSize is the number of samples buffered per row group
+const parquetRowGroupSize = 1 << 20
+
+// Parquet physical types, repetitions, converted types, encodings and
+// codecs used by parquetRecorder, from the Parquet format's parquet.thrift
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetGzip = 2
+)
+
+// parquetColumn is one column of the sample file, buffering the values of
+// the current row group. Optional columns are null for empty strings and
+// negative connection ids, as the CSV file leaves them empty.
+type parquetColumn struct {
+	name      string
+	kind      int32
+	converted int32 // -1 when none
+	optional  bool
+
+	values  []byte // PLAIN encoded non-null values
+	defined []bool // per row, for optional columns
+}
+
+type parquetChunk struct {
+	offset           int64
+	compressedSize   int64
+	uncompressedSize int64
+}
+
+// parquetRecorder streams one row per request to an Apache Parquet file,
+// with the columns of the CSV sample file. Rows are buffered and written
+// as gzip compressed row groups of parquetRowGroupSize rows, so the
+// memory use stays bounded however long the run is. It is safe for
+// concurrent use.
+type parquetRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	offset  int64
+	columns []*parquetColumn
+	rows    int
+	total   int64
+	groups  []parquetRowGroup
+	err     error
+}
+
+type parquetRowGroup struct {
+	rows   int
+	chunks []parquetChunk
+}
+
+func newParquetRecorder(path string) (*parquetRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &parquetRecorder{
+		file: file,
+		columns: []*parquetColumn{
+			{name: "timestamp", kind: parquetInt64, converted: parquetTimestampMicros},
+			{name: "latency_ms", kind: parquetDouble, converted: -1},
+			{name: "status", kind: parquetInt32, converted: -1},
+			{name: "bytes", kind: parquetInt64, converted: -1},
+			{name: "connection", kind: parquetInt32, converted: -1, optional: true},
+			{name: "error", kind: parquetByteArray, converted: parquetUTF8, optional: true},
+			{name: "request_id", kind: parquetByteArray, converted: parquetUTF8, optional: true},
+		},
+	}
+	r.write([]byte("PAR1"))
+	return r, r.err
+}
+
+func (r *parquetRecorder) Record(s Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	le := binary.LittleEndian
+	c := r.columns
+	c[0].values = le.AppendUint64(c[0].values, uint64(s.Start.UnixMicro()))
+	c[1].values = le.AppendUint64(c[1].values, math.Float64bits(s.Latency))
+	c[2].values = le.AppendUint32(c[2].values, uint32(s.Status))
+	c[3].values = le.AppendUint64(c[3].values, uint64(s.Bytes))
+	c[4].defined = append(c[4].defined, s.Connection >= 0)
+	if s.Connection >= 0 {
+		c[4].values = le.AppendUint32(c[4].values, uint32(s.Connection))
+	}
+	for i, value := range []string{s.ErrorClass, s.RequestID} {
+		column := c[5+i]
+		column.defined = append(column.defined, value != "")
+		if value != "" {
+			column.values = le.AppendUint32(column.values, uint32(len(value)))
+			column.values = append(column.values, value...)
+		}
+	}
+
+	r.rows++
+	if r.rows == parquetRowGroupSize {
+		r.writeRowGroup()
+	}
+}
+
+func (r *parquetRecorder) Flush(BenchmarkResult) error {
+	if err := r.Close(); err != nil {
+		return fmt.Errorf("writing sample file: %v", err)
+	}
+	return nil
+}
+
+// Close writes the buffered rows and the footer, and returns the first
+// write error
+func (r *parquetRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rows > 0 {
+		r.writeRowGroup()
+	}
+	footer := r.footer()
+	r.write(footer)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	r.write(length[:])
+	r.write([]byte("PAR1"))
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+func (r *parquetRecorder) write(b []byte) {
+	if r.err != nil {
+		return
+	}
+	n, err := r.file.Write(b)
+	r.offset += int64(n)
+	r.err = err
+}
+
+// writeRowGroup writes every column's buffered values as a single data
+// page, and starts the next row group
+func (r *parquetRecorder) writeRowGroup() {
+	group := parquetRowGroup{rows: r.rows}
+	for _, column := range r.columns {
+		var page bytes.Buffer
+		if column.optional {
+			levels := encodeDefinitionLevels(column.defined)
+			page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))))
+			page.Write(levels)
+		}
+		page.Write(column.values)
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(page.Bytes())
+		gz.Close()
+
+		header := parquetPageHeader(r.rows, page.Len(), compressed.Len())
+		chunk := parquetChunk{
+			offset:           r.offset,
+			compressedSize:   int64(len(header) + compressed.Len()),
+			uncompressedSize: int64(len(header) + page.Len()),
+		}
+		r.write(header)
+		r.write(compressed.Bytes())
+		group.chunks = append(group.chunks, chunk)
+
+		column.values = column.values[:0]
+		column.defined = column.defined[:0]
+	}
+	r.groups = append(r.groups, group)
+	r.total += int64(r.rows)
+	r.rows = 0
+}
+
+// encodeDefinitionLevels encodes the definition levels (1 for a value, 0
+// for null) of an optional column with the RLE/bit-packing hybrid
+// encoding, as runs of equal levels
+func encodeDefinitionLevels(defined []bool) []byte {
+	var b []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		b = binary.AppendUvarint(b, uint64(j-i)<<1)
+		if defined[i] {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		i = j
+	}
+	return b
+}
+
+// parquetPageHeader encodes the PageHeader of a data page
+func parquetPageHeader(values, uncompressed, compressed int) []byte {
+	var w compactWriter
+	w.i32(1, 0) // DATA_PAGE
+	w.i32(2, int32(uncompressed))
+	w.i32(3, int32(compressed))
+	w.structField(5, func() {
+		w.i32(1, int32(values))
+		w.i32(2, parquetPlain)
+		w.i32(3, parquetRLE)
+		w.i32(4, parquetRLE)
+	})
+	w.stop()
+	return w.b
+}
+
+// footer encodes the FileMetaData
+func (r *parquetRecorder) footer() []byte {
+	var w compactWriter
+	w.i32(1, 1)
+	w.list(2, compactStruct, len(r.columns)+1)
+	w.structElement(func() {
+		w.binary(4, "schema")
+		w.i32(5, int32(len(r.columns)))
+	})
+	for _, column := range r.columns {
+		w.structElement(func() {
+			w.i32(1, column.kind)
+			repetition := int32(parquetRequired)
+			if column.optional {
+				repetition = parquetOptional
+			}
+			w.i32(3, repetition)
+			w.binary(4, column.name)
+			if column.converted >= 0 {
+				w.i32(6, column.converted)
+			}
+		})
+	}
+	w.i64(3, r.total)
+	w.list(4, compactStruct, len(r.groups))
+	for _, group := range r.groups {
+		w.structElement(func() {
+			var size int64
+			w.list(1, compactStruct, len(group.chunks))
+			for i, chunk := range group.chunks {
+				column := r.columns[i]
+				size += chunk.uncompressedSize
+				w.structElement(func() {
+					w.i64(2, chunk.offset)
+					w.structField(3, func() {
+						w.i32(1, column.kind)
+						w.list(2, compactI32, 2)
+						w.listI32(parquetPlain)
+						w.listI32(parquetRLE)
+						w.list(3, compactBinary, 1)
+						w.listBinary(column.name)
+						w.i32(4, parquetGzip)
+						w.i64(5, int64(group.rows))
+						w.i64(6, chunk.uncompressedSize)
+						w.i64(7, chunk.compressedSize)
+						w.i64(9, chunk.offset)
+					})
+				})
+			}
+			w.i64(2, size)
+			w.i64(3, int64(group.rows))
+		})
+	}
+	w.binary(6, "autocannon")
+	w.stop()
+	return w.b
+}
+
+// Thrift compact protocol field types
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes Thrift structs in the compact protocol, which is
+// how Parquet stores its page headers and footer. Only the types Parquet
+// metadata needs are supported.
+type compactWriter struct {
+	b      []byte
+	last   int16
+	parent []int16
+}
+
+func (w *compactWriter) field(id int16, kind byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.b = append(w.b, byte(delta)<<4|kind)
+	} else {
+		w.b = append(w.b, kind)
+		w.varint(int64(id))
+	}
+	w.last = id
+}
+
+// varint appends a zigzag encoded varint
+func (w *compactWriter) varint(v int64) {
+	w.b = binary.AppendUvarint(w.b, uint64(v<<1^v>>63))
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.varint(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.varint(v)
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, compactBinary)
+	w.listBinary(s)
+}
+
+func (w *compactWriter) list(id int16, kind byte, n int) {
+	w.field(id, compactList)
+	if n < 15 {
+		w.b = append(w.b, byte(n)<<4|kind)
+	} else {
+		w.b = append(w.b, 0xf0|kind)
+		w.b = binary.AppendUvarint(w.b, uint64(n))
+	}
+}
+
+func (w *compactWriter) listI32(v int32) {
+	w.varint(int64(v))
+}
+
+func (w *compactWriter) listBinary(s string) {
+	w.b = binary.AppendUvarint(w.b, uint64(len(s)))
+	w.b = append(w.b, s...)
+}
+
+// structField writes a struct valued field
+func (w *compactWriter) structField(id int16, fields func()) {
+	w.field(id, compactStruct)
+	w.structElement(fields)
+}
+
+// structElement writes a struct, as a list element or the value of a
+// struct field
+func (w *compactWriter) structElement(fields func()) {
+	w.parent = append(w.parent, w.last)
+	w.last = 0
+	fields()
+	w.stop()
+	w.last = w.parent[len(w.parent)-1]
+	w.parent = w.parent[:len(w.parent)-1]
+}
+
+// stop ends a struct
+func (w *compactWriter) stop() {
+	w.b = append(w.b, 0)
+}
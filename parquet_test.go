@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps of field
+// id to value: int64 for integers, string for binary, []any for lists and
+// map[int16]any for structs
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.b) {
+		panic("thrift: unexpected end of data")
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic("thrift: invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) value(kind byte) any {
+	switch kind {
+	case 1, 2:
+		return kind == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos-8:]))
+	case compactBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.b[r.pos-n : r.pos])
+	case compactList:
+		header := r.byte()
+		n, elem := int(header>>4), header&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case compactStruct:
+		fields := make(map[int16]any)
+		var last int16
+		for {
+			header := r.byte()
+			if header == 0 {
+				return fields
+			}
+			id := last + int16(header>>4)
+			if header>>4 == 0 {
+				id = int16(r.zigzag())
+			}
+			fields[id] = r.value(header & 0x0f)
+			last = id
+		}
+	}
+	panic(fmt.Sprintf("thrift: unsupported type %d", kind))
+}
+
+// readParquet reads the columns of a file written by parquetRecorder by
+// name, nil for null values, checking its metadata on the way
+func readParquet(t *testing.T, path string) (rows int64, columns map[string][]any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{b: data[len(data)-8-footerLength : len(data)-8]}
+	meta := footer.value(compactStruct).(map[int16]any)
+	if footer.pos != footerLength {
+		t.Fatalf("footer is %d bytes, read %d", footerLength, footer.pos)
+	}
+
+	schema := meta[2].([]any)
+	if root := schema[0].(map[int16]any); root[5] != int64(len(schema)-1) {
+		t.Fatalf("schema root has %v children, want %d", root[5], len(schema)-1)
+	}
+	rows = meta[3].(int64)
+	columns = make(map[string][]any)
+	groups, _ := meta[4].([]any)
+	for _, g := range groups {
+		group := g.(map[int16]any)
+		for i, c := range group[1].([]any) {
+			element := schema[i+1].(map[int16]any)
+			name, kind, optional := element[4].(string), element[1].(int64), element[3] == int64(parquetOptional)
+			chunk := c.(map[int16]any)[3].(map[int16]any)
+			if chunk[4] != int64(parquetGzip) || chunk[5] != group[3] {
+				t.Fatalf("column %s: codec %v with %v values, want gzip with %v", name, chunk[4], chunk[5], group[3])
+			}
+
+			offset := int(chunk[9].(int64))
+			page := &thriftReader{b: data[offset:]}
+			header := page.value(compactStruct).(map[int16]any)
+			if page.pos+int(header[3].(int64)) != int(chunk[7].(int64)) {
+				t.Fatalf("column %s: page of %d bytes, chunk of %v", name, page.pos+int(header[3].(int64)), chunk[7])
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(data[offset+page.pos : offset+int(chunk[7].(int64))]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(body) != int(header[2].(int64)) {
+				t.Fatalf("column %s: page of %d bytes uncompressed, header says %v", name, len(body), header[2])
+			}
+
+			n := int(header[5].(map[int16]any)[1].(int64))
+			defined := make([]bool, n)
+			for i := range defined {
+				defined[i] = true
+			}
+			if optional {
+				length := int(binary.LittleEndian.Uint32(body))
+				levels := &thriftReader{b: body[4 : 4+length]}
+				for i := 0; i < n; {
+					run := levels.uvarint()
+					if run&1 != 0 {
+						t.Fatalf("column %s: unexpected bit-packed run", name)
+					}
+					level := levels.byte()
+					for j := 0; j < int(run>>1); j++ {
+						defined[i] = level == 1
+						i++
+					}
+				}
+				body = body[4+length:]
+			}
+			values := &thriftReader{b: body}
+			for _, ok := range defined {
+				if !ok {
+					columns[name] = append(columns[name], nil)
+					continue
+				}
+				var v any
+				switch kind {
+				case parquetInt32:
+					v = int64(int32(binary.LittleEndian.Uint32(body[values.pos:])))
+					values.pos += 4
+				case parquetInt64:
+					v = int64(binary.LittleEndian.Uint64(body[values.pos:]))
+					values.pos += 8
+				case parquetDouble:
+					v = math.Float64frombits(binary.LittleEndian.Uint64(body[values.pos:]))
+					values.pos += 8
+				case parquetByteArray:
+					length := int(binary.LittleEndian.Uint32(body[values.pos:]))
+					v = string(body[values.pos+4 : values.pos+4+length])
+					values.pos += 4 + length
+				}
+				columns[name] = append(columns[name], v)
+			}
+			if values.pos != len(body) {
+				t.Fatalf("column %s: %d bytes left after the values", name, len(body)-values.pos)
+			}
+		}
+	}
+	return rows, columns
+}
+
+// TestParquetRoundTrip writes samples and reads them back
+func TestParquetRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.parquet")
+	recorder, err := newParquetRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	var samples []Sample
+	for i := 0; i < 100; i++ {
+		s := Sample{
+			Start:      start.Add(time.Duration(i) * time.Millisecond),
+			Latency:    float64(i) * 1.25,
+			Status:     200,
+			Bytes:      int64(i) << 20,
+			Connection: i % 7,
+			RequestID:  fmt.Sprintf("req-%d", i),
+		}
+		// Runs of nulls and values in the optional columns
+		if i%10 >= 6 {
+			s.Status, s.Connection, s.ErrorClass = 0, -1, "timeout"
+		}
+		if i%3 == 0 {
+			s.RequestID = ""
+		}
+		samples = append(samples, s)
+		recorder.Record(s)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, columns := readParquet(t, path)
+	if rows != int64(len(samples)) {
+		t.Fatalf("file has %d rows, want %d", rows, len(samples))
+	}
+	nullable := func(v any, null bool) any {
+		if null {
+			return nil
+		}
+		return v
+	}
+	for i, s := range samples {
+		want := map[string]any{
+			"timestamp":  s.Start.UnixMicro(),
+			"latency_ms": s.Latency,
+			"status":     int64(s.Status),
+			"bytes":      s.Bytes,
+			"connection": nullable(int64(s.Connection), s.Connection < 0),
+			"error":      nullable(s.ErrorClass, s.ErrorClass == ""),
+			"request_id": nullable(s.RequestID, s.RequestID == ""),
+		}
+		for name, value := range want {
+			if len(columns[name]) != len(samples) {
+				t.Fatalf("column %s has %d values, want %d", name, len(columns[name]), len(samples))
+			}
+			if columns[name][i] != value {
+				t.Errorf("row %d %s = %v, want %v", i, name, columns[name][i], value)
+			}
+		}
+	}
+}
+
+// TestParquetEmpty checks that a file without samples is still valid
+func TestParquetEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.parquet")
+	recorder, err := newParquetRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rows, columns := readParquet(t, path); rows != 0 || len(columns) != 0 {
+		t.Errorf("got %d rows in %d columns, want none", rows, len(columns))
+	}
+}
+
+// TestEncodeDefinitionLevels checks the RLE runs of the levels
+func TestEncodeDefinitionLevels(t *testing.T) {
+	tests := []struct {
+		defined []bool
+		want    []byte
+	}{
+		{nil, nil},
+		{[]bool{true}, []byte{0x02, 0x01}},
+		{[]bool{false, false, true}, []byte{0x04, 0x00, 0x02, 0x01}},
+		{make([]bool, 64), []byte{0x80, 0x01, 0x00}},
+	}
+	for _, tt := range tests {
+		if got := encodeDefinitionLevels(tt.defined); !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeDefinitionLevels(%v) = % x, want % x", tt.defined, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// PhaseStats splits the latency of complete responses into the time to
+// the response headers (time to first byte), the time spent reading the
+// body, and their sum (time to last byte). BodyBytesPerSecond is the
+// transfer rate during the body phase only, so it is not diluted by
+// server processing time.
+type PhaseStats struct {
+	Headers            PhaseLatency `json:"headers"`
+	Body               PhaseLatency `json:"body"`
+	Total              PhaseLatency `json:"total"`
+	BodyBytesPerSecond float64      `json:"bodyBytesPerSecond"`
+}
+
+// PhaseLatency is the latency distribution of one phase in milliseconds
+type PhaseLatency struct {
+	Average float64 `json:"averageMs"`
+	P50     float64 `json:"p50Ms"`
+	P90     float64 `json:"p90Ms"`
+	P99     float64 `json:"p99Ms"`
+	Max     float64 `json:"maxMs"`
+}
+
+// phaseTracker collects PhaseStats from concurrent workers
+type phaseTracker struct {
+	mu        sync.Mutex
+	headers   *histogram
+	body      *histogram
+	total     *histogram
+	bodyBytes int64
+	bodyTime  int64 // microseconds
+}
+
+func newPhaseTracker() *phaseTracker {
+	return &phaseTracker{headers: newLatencyHistogram(), body: newLatencyHistogram(), total: newLatencyHistogram()}
+}
+
+// record adds a response whose headers arrived after headers and whose
+// body of size bytes took body to read
+func (t *phaseTracker) record(headersMs, bodyMs float64, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.headers.record(int64(headersMs * 1000))
+	t.body.record(int64(bodyMs * 1000))
+	t.total.record(int64((headersMs + bodyMs) * 1000))
+	t.bodyBytes += bytes
+	t.bodyTime += int64(bodyMs * 1000)
+}
+
+func (t *phaseTracker) summary() *PhaseStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &PhaseStats{
+		Headers: phaseLatency(t.headers),
+		Body:    phaseLatency(t.body),
+		Total:   phaseLatency(t.total),
+	}
+	if t.bodyTime > 0 {
+		stats.BodyBytesPerSecond = float64(t.bodyBytes) / (float64(t.bodyTime) / 1e6)
+	}
+	return stats
+}
+
+func phaseLatency(h *histogram) PhaseLatency {
+	return PhaseLatency{
+		Average: h.mean() / 1000,
+		P50:     float64(h.valueAtPercentile(50)) / 1000,
+		P90:     float64(h.valueAtPercentile(90)) / 1000,
+		P99:     float64(h.valueAtPercentile(99)) / 1000,
+		Max:     float64(h.max) / 1000,
+	}
+}
+
+// displayPhaseStats prints the -latency-phases table
+func displayPhaseStats(result BenchmarkResult) {
+	if result.Phases == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nLatency Phases:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("", "Headers", "Body", "Total")
+	phases := []PhaseLatency{result.Phases.Headers, result.Phases.Body, result.Phases.Total}
+	for _, stat := range []struct {
+		name  string
+		value func(PhaseLatency) float64
+	}{
+		{"Average", func(l PhaseLatency) float64 { return l.Average }},
+		{"Median", func(l PhaseLatency) float64 { return l.P50 }},
+		{"90th Percentile", func(l PhaseLatency) float64 { return l.P90 }},
+		{"99th Percentile", func(l PhaseLatency) float64 { return l.P99 }},
+		{"Max", func(l PhaseLatency) float64 { return l.Max }},
+	} {
+		row := []string{stat.name}
+		for _, phase := range phases {
+			row = append(row, formatLatency(stat.value(phase)))
+		}
+		table.Append(row)
+	}
+	table.Render()
+	fmt.Printf("Body transfer rate: %s/sec\n", formatSize(int64(result.Phases.BodyBytesPerSecond)))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/ttacon/chalk"
+)
+
+// PortExhaustionStats counts the requests that failed because the client
+// ran out of local (ephemeral) ports, along with the limits in effect.
+// It is set only when that happened.
+type PortExhaustionStats struct {
+	Errors             int64  `json:"errors"`
+	RetiredConnections int64  `json:"retiredConnections,omitempty"`
+	PortRange          string `json:"portRange,omitempty"`
+	FileLimit          uint64 `json:"fileLimit,omitempty"`
+}
+
+// isPortExhaustion reports whether a dial failed because no local port
+// was free, which is a client-side limit rather than a server failure
+func isPortExhaustion(err error) bool {
+	if errors.Is(err, syscall.EADDRNOTAVAIL) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "cannot assign requested address") ||
+		strings.Contains(msg, "requested address is not valid in its context")
+}
+
+// newPortExhaustionStats fills in the local port range and file limit
+func newPortExhaustionStats(errors, retired int64) *PortExhaustionStats {
+	stats := &PortExhaustionStats{Errors: errors, RetiredConnections: retired}
+	if runtime.GOOS == "linux" {
+		if data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range"); err == nil {
+			stats.PortRange = strings.Join(strings.Fields(string(data)), "-")
+		}
+	}
+	if soft, _, err := fileLimit(); err == nil {
+		stats.FileLimit = soft
+	}
+	return stats
+}
+
+// warnPortExhaustion explains port exhaustion errors on stderr, since
+// they are easily mistaken for the server refusing connections
+func warnPortExhaustion(stats *PortExhaustionStats) {
+	fmt.Fprintln(os.Stderr, colorizeStderr(chalk.Yellow, fmt.Sprintf(
+		"Warning: %d requests failed because this machine ran out of local ports (EADDRNOTAVAIL). These are client-side errors, not server failures.", stats.Errors)))
+	if stats.PortRange != "" {
+		fmt.Fprintf(os.Stderr, "  Local port range: %s (net.ipv4.ip_local_port_range)\n", stats.PortRange)
+	}
+	if stats.FileLimit > 0 {
+		fmt.Fprintf(os.Stderr, "  Open file limit: %d (ulimit -n)\n", stats.FileLimit)
+	}
+	if stats.RetiredConnections > 0 {
+		fmt.Fprintf(os.Stderr, "  Retired connections: %d (-reduce-on-port-exhaustion)\n", stats.RetiredConnections)
+	}
+	fmt.Fprintln(os.Stderr, "  Closed connections hold their port in TIME_WAIT for a while. Reuse connections (avoid low -reconnect-every values),")
+	fmt.Fprintln(os.Stderr, "  use fewer -clients or a lower -rate, widen the port range, enable net.ipv4.tcp_tw_reuse, or run from several machines.")
+}
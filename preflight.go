@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// preflight sends a single HEAD request to uri, so a wrong host, a closed
+// port or a TLS problem is reported once before the run instead of as
+// thousands of failed requests. Any HTTP response counts as reachable.
+// Templated uris are probed at their origin, or not at all when the host
+// itself is templated.
+func preflight(client *http.Client, uri string) error {
+	target, ok := probeURL(uri)
+	if !ok {
+		return nil
+	}
+	resp, err := client.Head(target)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// probeURL returns the url preflight checks for uri
+func probeURL(uri string) (string, bool) {
+	i := strings.Index(uri, "{{")
+	if i < 0 {
+		return uri, true
+	}
+	prefix := uri[:i]
+	scheme, rest, ok := strings.Cut(prefix, "://")
+	if !ok || !strings.Contains(rest, "/") {
+		return "", false
+	}
+	u, err := url.Parse(prefix)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	return scheme + "://" + u.Host + "/", true
+}
+
+// confirmAfterPreflight reports a failed pre-flight request and decides
+// whether to run anyway: always with -force, after asking when stdin is a
+// terminal, and never otherwise
+func confirmAfterPreflight(uri string, err error, force bool) bool {
+	fmt.Fprintf(os.Stderr, "Pre-flight request to %s failed: %v\n", uri, err)
+	if force {
+		fmt.Fprintln(os.Stderr, "Running anyway (-force).")
+		return true
+	}
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "Use -force to run anyway.")
+		return false
+	}
+	fmt.Fprint(os.Stderr, "Run anyway? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
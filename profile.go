@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// profiler profiles autocannon itself, to find client-side bottlenecks:
+// it serves net/http/pprof on an address, and writes a CPU profile for
+// the whole run and a heap profile at its end
+type profiler struct {
+	cpu        *os.File
+	memProfile string
+}
+
+// startProfiling starts the profiles that are configured
+func startProfiling(pprofAddr, cpuProfile, memProfile string) (*profiler, error) {
+	p := &profiler{memProfile: memProfile}
+	if pprofAddr != "" {
+		// A mux of its own keeps the handlers off http.DefaultServeMux
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		listener, err := net.Listen("tcp", pprofAddr)
+		if err != nil {
+			return nil, fmt.Errorf("pprof: %v", err)
+		}
+		go http.Serve(listener, mux)
+	}
+	if cpuProfile != "" {
+		file, err := os.Create(cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("cpu profile: %v", err)
+		}
+		if err := rpprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cpu profile: %v", err)
+		}
+		p.cpu = file
+	}
+	return p, nil
+}
+
+// stop finishes the CPU profile and writes the heap profile
+func (p *profiler) stop() error {
+	if p.cpu != nil {
+		rpprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			return fmt.Errorf("cpu profile: %v", err)
+		}
+	}
+	if p.memProfile != "" {
+		file, err := os.Create(p.memProfile)
+		if err != nil {
+			return fmt.Errorf("memory profile: %v", err)
+		}
+		defer file.Close()
+		runtime.GC()
+		if err := rpprof.WriteHeapProfile(file); err != nil {
+			return fmt.Errorf("memory profile: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// prometheusSink writes the final result in the Prometheus text
+// exposition format, for node_exporter's textfile collector or a
+// Pushgateway. The file is replaced atomically so a scrape never sees a
+// partial write.
+type prometheusSink struct {
+	path string
+}
+
+func (prometheusSink) Record(Sample) {}
+
+// promLabelEscaper escapes label values per the exposition format
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (s prometheusSink) Flush(result BenchmarkResult) error {
+	labels := fmt.Sprintf(`uri="%s",method="%s"`, promLabelEscaper.Replace(result.URI), promLabelEscaper.Replace(result.Method))
+	var b strings.Builder
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	sample := func(name, extra string, value float64) {
+		fmt.Fprintf(&b, "%s{%s%s} %g\n", name, labels, extra, value)
+	}
+
+	metric("autocannon_requests", "Requests sent during the run, by outcome.")
+	sample("autocannon_requests", `,outcome="successful"`, float64(result.Requests.Successful))
+	sample("autocannon_requests", `,outcome="failed"`, float64(result.Requests.Failed))
+	sample("autocannon_requests", `,outcome="timeout"`, float64(result.Requests.Timeouts))
+
+	metric("autocannon_responses", "Responses received during the run, by status code.")
+	codes := make([]int, 0, len(result.StatusCodeCounts))
+	for code := range result.StatusCodeCounts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		sample("autocannon_responses", fmt.Sprintf(`,code="%d"`, code), float64(result.StatusCodeCounts[code]))
+	}
+
+	metric("autocannon_requests_per_second", "Average request rate of the run.")
+	sample("autocannon_requests_per_second", "", result.Throughput.RequestsPerSecond)
+
+	metric("autocannon_error_rate", "Percentage of failed requests.")
+	sample("autocannon_error_rate", "", result.Requests.ErrorRate)
+
+	metric("autocannon_latency_milliseconds", "Latency of successful requests.")
+	sample("autocannon_latency_milliseconds", `,stat="average"`, result.Latency.Average)
+	sample("autocannon_latency_milliseconds", `,stat="min"`, result.Latency.Min)
+	sample("autocannon_latency_milliseconds", `,stat="max"`, result.Latency.Max)
+	for _, p := range result.Latency.Percentiles {
+		sample("autocannon_latency_milliseconds", fmt.Sprintf(`,stat="p%g"`, p.Percentile), p.Value)
+	}
+
+	metric("autocannon_bytes_read", "Response bytes read during the run.")
+	sample("autocannon_bytes_read", "", float64(result.Throughput.BytesRead))
+	metric("autocannon_bytes_written", "Request bytes written during the run.")
+	sample("autocannon_bytes_written", "", float64(result.Throughput.BytesWritten))
+
+	metric("autocannon_duration_seconds", "Duration of the run.")
+	sample("autocannon_duration_seconds", "", result.elapsed.Seconds())
+	metric("autocannon_run_timestamp_seconds", "Unix time the run started.")
+	sample("autocannon_run_timestamp_seconds", "", float64(result.Timestamp.Unix()))
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".autocannon-*.prom")
+	if err != nil {
+		return fmt.Errorf("writing Prometheus file: %v", err)
+	}
+	_, err = tmp.WriteString(b.String())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writing Prometheus file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Prometheus metrics written to %s\n", s.path)
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RangeStats describes a -range run: the size of each requested range,
+// how ranges were picked and the size of the object they were taken from
+type RangeStats struct {
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"`
+	ObjectSize int64  `json:"objectSize"`
+}
+
+// rangeSelector picks the byte range of each request. Sequential ranges
+// walk the object from the start and wrap around at the end. It is safe
+// for concurrent use.
+type rangeSelector struct {
+	size       int64
+	objectSize int64
+	random     bool
+
+	mu   sync.Mutex
+	next int64
+}
+
+func newRangeSelector(size, objectSize int64, mode string) (*rangeSelector, error) {
+	if mode != "random" && mode != "sequential" {
+		return nil, fmt.Errorf("unknown range mode %q, expected random or sequential", mode)
+	}
+	if objectSize <= 0 {
+		return nil, fmt.Errorf("object size must be positive")
+	}
+	if size > objectSize {
+		size = objectSize
+	}
+	return &rangeSelector{size: size, objectSize: objectSize, random: mode == "random"}, nil
+}
+
+// pick returns the first and last byte of the next range
+func (s *rangeSelector) pick() (int64, int64) {
+	var start int64
+	if s.random {
+		start = rand.Int63n(s.objectSize - s.size + 1)
+	} else {
+		s.mu.Lock()
+		start = s.next
+		s.next += s.size
+		if s.next >= s.objectSize {
+			s.next = 0
+		}
+		s.mu.Unlock()
+	}
+	end := start + s.size - 1
+	if end >= s.objectSize {
+		end = s.objectSize - 1
+	}
+	return start, end
+}
+
+func (s *rangeSelector) stats() *RangeStats {
+	mode := "sequential"
+	if s.random {
+		mode = "random"
+	}
+	return &RangeStats{Size: s.size, Mode: mode, ObjectSize: s.objectSize}
+}
+
+// objectSize asks the server for the size of the object at uri with a
+// HEAD request
+func objectSize(uri string) (int64, error) {
+	resp, err := http.Head(uri)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HEAD %s returned %d", uri, resp.StatusCode)
+	}
+	if resp.ContentLength <= 0 {
+		return 0, fmt.Errorf("HEAD %s did not report a Content-Length", uri)
+	}
+	return resp.ContentLength, nil
+}
+
+// rangeValidator checks that a ranged request was answered with 206
+// Partial Content, a Content-Range matching the requested range and a
+// body of the requested length
+type rangeValidator struct {
+	objectSize int64
+}
+
+func (v rangeValidator) Validate(resp ValidationResponse) ValidationResult {
+	start, end, ok := parseByteRange(resp.Request.Header.Get("Range"))
+	if !ok {
+		return ValidationResult{Pass: true}
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return ValidationResult{Category: fmt.Sprintf("status %d", resp.StatusCode)}
+	}
+	expected := fmt.Sprintf("bytes %d-%d/%d", start, end, v.objectSize)
+	if resp.Header.Get("Content-Range") != expected {
+		return ValidationResult{Category: "content-range"}
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil || n != end-start+1 {
+		return ValidationResult{Category: "range length"}
+	}
+	return ValidationResult{Pass: true}
+}
+
+// parseByteRange parses a single "bytes=start-end" Range header
+func parseByteRange(header string) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
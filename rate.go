@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// arrival generates the gap between consecutive send slots
+type arrival interface {
+	interval(at time.Time) time.Duration
+}
+
+// constantArrival sends at perfectly regular intervals
+type constantArrival struct {
+	every time.Duration
+}
+
+func (a constantArrival) interval(time.Time) time.Duration {
+	return a.every
+}
+
+// poissonArrival draws exponentially distributed gaps, modeling
+// independent arrivals at an average rate
+type poissonArrival struct {
+	mean time.Duration
+}
+
+func (a poissonArrival) interval(time.Time) time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(a.mean))
+}
+
+// uniformArrival draws gaps uniformly between 0 and twice the mean
+type uniformArrival struct {
+	mean time.Duration
+}
+
+func (a uniformArrival) interval(time.Time) time.Duration {
+	return time.Duration(rand.Float64() * 2 * float64(a.mean))
+}
+
+// burstArrival sends at a burst rate for the first `on` of every `every`
+// period and at the base rate (or not at all) for the rest of it
+type burstArrival struct {
+	start time.Time
+	base  time.Duration
+	burst time.Duration
+	on    time.Duration
+	every time.Duration
+}
+
+func (a *burstArrival) interval(at time.Time) time.Duration {
+	if a.start.IsZero() {
+		a.start = at
+	}
+	phase := at.Sub(a.start) % a.every
+	if phase < a.on {
+		return a.burst
+	}
+	untilBurst := a.every - phase
+	if a.base > 0 && a.base < untilBurst {
+		return a.base
+	}
+	return untilBurst
+}
+
+// parseArrival builds the arrival process for -arrival at the given base
+// rate. Supported specs are constant, poisson, uniform and
+// burst:RATE:ON:EVERY (e.g. burst:100:1s:10s).
+func parseArrival(spec string, rate float64) (arrival, error) {
+	var mean time.Duration
+	if rate > 0 {
+		mean = time.Duration(float64(time.Second) / rate)
+	}
+
+	name, params, _ := strings.Cut(spec, ":")
+	if name != "burst" && mean == 0 {
+		return nil, fmt.Errorf("the %s arrival distribution requires a positive -rate", name)
+	}
+
+	switch name {
+	case "constant":
+		return constantArrival{every: mean}, nil
+	case "poisson":
+		return poissonArrival{mean: mean}, nil
+	case "uniform":
+		return uniformArrival{mean: mean}, nil
+	case "burst":
+		parts := strings.Split(params, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("burst arrival must be burst:RATE:ON:EVERY, got %q", spec)
+		}
+		burstRate, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil || burstRate <= 0 {
+			return nil, fmt.Errorf("invalid burst rate %q", parts[0])
+		}
+		on, err := time.ParseDuration(parts[1])
+		if err != nil || on <= 0 {
+			return nil, fmt.Errorf("invalid burst length %q", parts[1])
+		}
+		every, err := time.ParseDuration(parts[2])
+		if err != nil || every <= on {
+			return nil, fmt.Errorf("invalid burst period %q, it must be longer than the burst", parts[2])
+		}
+		return &burstArrival{
+			base:  mean,
+			burst: time.Duration(float64(time.Second) / burstRate),
+			on:    on,
+			every: every,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival distribution %q", spec)
+	}
+}
+
+// limiter hands out send slots spaced by an arrival process. Unused
+// slots accumulate up to the burst size, as in a token bucket, and are
+// handed out at once when sending resumes. It is safe for concurrent use,
+// so a single limiter can pace many workers.
+type limiter struct {
+	mu      sync.Mutex
+	arrival arrival
+	slack   time.Duration // how far behind schedule slots may accumulate
+	next    time.Time
+	started time.Time // when the first slot was reserved
+}
+
+// newLimiter builds a limiter that lets burst slots through at once,
+// each worth interval; a burst of 1 strictly follows the arrival process
+func newLimiter(a arrival, burst int, interval time.Duration) *limiter {
+	l := &limiter{arrival: a}
+	if burst > 1 {
+		l.slack = time.Duration(burst-1) * interval
+	}
+	return l
+}
+
+// retune takes over the arrival process and burst of from, for the
+// slots after the one already reserved
+func (l *limiter) retune(from *limiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.arrival = from.arrival
+	l.slack = from.slack
+}
+
+// wait reserves the next slot and sleeps until it. It returns the slot's
+// scheduled time, or false if stop was closed first.
+//
+// A slot the caller fell behind on keeps its scheduled time, so the delay
+// shows in open model latency rather than being hidden by coordinated
+// omission. Slots further behind than the burst are skipped, and the next
+// one handed out stands for the earliest of them. The burst available at
+// the start is due at once.
+func (l *limiter) wait(stop <-chan struct{}) (time.Time, bool) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.started.IsZero() {
+		l.started = now
+	}
+	slot := l.next
+	if earliest := now.Add(-l.slack); l.next.Before(earliest) {
+		l.next = earliest
+	}
+	l.next = l.next.Add(l.arrival.interval(l.next))
+	if slot.Before(l.started) {
+		slot = l.started
+	}
+	l.mu.Unlock()
+
+	if d := time.Until(slot); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-stop:
+			return slot, false
+		case <-timer.C:
+		}
+	}
+	return slot, true
+}
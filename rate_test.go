@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLimiterKeepsMissedSlots checks that a slot the caller fell behind on
+// keeps its scheduled time instead of moving to when it was taken, so the
+// delay shows in open model latency
+func TestLimiterKeepsMissedSlots(t *testing.T) {
+	const every = 10 * time.Millisecond
+	lim := newLimiter(constantArrival{every: every}, 1, every)
+
+	first, ok := lim.wait(nil)
+	if !ok {
+		t.Fatal("wait returned false")
+	}
+	time.Sleep(5 * every)
+	missed, _ := lim.wait(nil)
+	if want := first.Add(every); !missed.Equal(want) {
+		t.Errorf("missed slot is %v after the first, want %v", missed.Sub(first), every)
+	}
+
+	// The slots skipped while behind are not handed out
+	next, _ := lim.wait(nil)
+	if lag := time.Since(next); lag > 2*every {
+		t.Errorf("the slot after the missed one is %v behind, want it back on schedule", lag)
+	}
+}
+
+// TestLimiterBurstAtStart checks that the burst available at the start
+// is due at once rather than before the limiter was first used
+func TestLimiterBurstAtStart(t *testing.T) {
+	const every = 10 * time.Millisecond
+	lim := newLimiter(constantArrival{every: every}, 5, every)
+
+	before := time.Now()
+	for i := 0; i < 5; i++ {
+		slot, _ := lim.wait(nil)
+		if slot.Before(before) {
+			t.Errorf("burst slot %d is %v before the start", i, before.Sub(slot))
+		}
+	}
+	if elapsed := time.Since(before); elapsed > 3*every {
+		t.Errorf("the burst took %v, want it handed out at once", elapsed)
+	}
+}
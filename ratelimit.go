@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// RateLimitWindow is the quota the rate limit headers of an interval's
+// responses reported: the limit, the lowest remaining requests, and the
+// seconds until the quota reset as of the last response
+type RateLimitWindow struct {
+	Responses int64   `json:"responses"`
+	Limit     int64   `json:"limit,omitempty"`
+	Remaining int64   `json:"remaining"`
+	Reset     float64 `json:"resetSeconds,omitempty"`
+}
+
+// RateLimitStats describes the rate limit headers of a run. Exhausted
+// counts the responses reporting no remaining requests, the first of them
+// ExhaustedAfter seconds into the run.
+type RateLimitStats struct {
+	Responses      int64   `json:"responses"`
+	Limit          int64   `json:"limit,omitempty"`
+	Remaining      int64   `json:"lowestRemaining"`
+	Exhausted      int64   `json:"exhausted"`
+	ExhaustedAfter float64 `json:"exhaustedAfterSeconds,omitempty"`
+}
+
+// rateLimitHeaders are the names of the limit, remaining and reset
+// headers, in the common X- form and that of the IETF draft
+var rateLimitHeaders = [][3]string{
+	{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+	{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+}
+
+// rateLimit is the quota a response reported
+type rateLimit struct {
+	limit     int64
+	remaining int64
+	reset     float64 // seconds, -1 without one
+}
+
+// parseRateLimit reads the rate limit headers of a response received at
+// now. A reset is seconds from now, or a Unix time when it is too large
+// to be one.
+func parseRateLimit(header http.Header, now time.Time) (rateLimit, bool) {
+	for _, names := range rateLimitHeaders {
+		remaining, err := strconv.ParseInt(strings.TrimSpace(header.Get(names[1])), 10, 64)
+		if err != nil {
+			continue
+		}
+		limit := rateLimit{remaining: remaining, reset: -1}
+		// A limit may carry a policy, e.g. "100, 100;w=60"
+		value, _, _ := strings.Cut(header.Get(names[0]), ",")
+		limit.limit, _ = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if reset, err := strconv.ParseFloat(strings.TrimSpace(header.Get(names[2])), 64); err == nil && reset >= 0 {
+			if reset > 1e9 {
+				reset = max(reset-float64(now.UnixNano())/1e9, 0)
+			}
+			limit.reset = reset
+		}
+		return limit, true
+	}
+	return rateLimit{}, false
+}
+
+// rateLimitTracker collects the rate limit headers of the responses from
+// concurrent workers, for the run and for the current interval
+type rateLimitTracker struct {
+	mu        sync.Mutex
+	run       RateLimitStats
+	window    RateLimitWindow
+	exhausted time.Time
+}
+
+func newRateLimitTracker() *rateLimitTracker {
+	return &rateLimitTracker{}
+}
+
+// record adds the rate limit headers of a response, if it has any
+func (t *rateLimitTracker) record(header http.Header) {
+	now := time.Now()
+	limit, ok := parseRateLimit(header, now)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.run.Responses == 0 || limit.remaining < t.run.Remaining {
+		t.run.Remaining = limit.remaining
+	}
+	if t.window.Responses == 0 || limit.remaining < t.window.Remaining {
+		t.window.Remaining = limit.remaining
+	}
+	t.run.Responses++
+	t.window.Responses++
+	if limit.limit > 0 {
+		t.run.Limit = limit.limit
+		t.window.Limit = limit.limit
+	}
+	if limit.reset >= 0 {
+		t.window.Reset = limit.reset
+	}
+	if limit.remaining <= 0 {
+		t.run.Exhausted++
+		if t.exhausted.IsZero() {
+			t.exhausted = now
+		}
+	}
+}
+
+// next returns the window of the interval that ends, or nil when none of
+// its responses had rate limit headers
+func (t *rateLimitTracker) next() *RateLimitWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.window.Responses == 0 {
+		return nil
+	}
+	window := t.window
+	t.window = RateLimitWindow{}
+	return &window
+}
+
+// summary returns the stats of a run that started at start, or nil when
+// no response had rate limit headers
+func (t *rateLimitTracker) summary(start time.Time) *RateLimitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.run.Responses == 0 {
+		return nil
+	}
+	stats := t.run
+	if !t.exhausted.IsZero() {
+		stats.ExhaustedAfter = max(t.exhausted.Sub(start).Seconds(), 0)
+	}
+	return &stats
+}
+
+// String describes the quota of an interval
+func (w RateLimitWindow) String() string {
+	s := fmt.Sprintf("%d remaining", w.Remaining)
+	if w.Limit > 0 {
+		s = fmt.Sprintf("%d of %d remaining", w.Remaining, w.Limit)
+	}
+	if w.Reset > 0 {
+		s += fmt.Sprintf(", resets in %.0fs", w.Reset)
+	}
+	return s
+}
+
+// displayRateLimitStats prints the rate limit headers table
+func displayRateLimitStats(result BenchmarkResult) {
+	r := result.RateLimit
+	if r == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nRate Limit Headers:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Metric", "Value")
+	table.Append([]string{"Responses With Headers", fmt.Sprintf("%d", r.Responses)})
+	if r.Limit > 0 {
+		table.Append([]string{"Limit", fmt.Sprintf("%d", r.Limit)})
+	}
+	table.Append([]string{"Lowest Remaining", fmt.Sprintf("%d", r.Remaining)})
+	exhausted := "never"
+	if r.Exhausted > 0 {
+		exhausted = fmt.Sprintf("%d responses, first after %.2f s", r.Exhausted, r.ExhaustedAfter)
+	}
+	table.Append([]string{"Quota Exhausted", exhausted})
+	table.Render()
+}
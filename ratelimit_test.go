@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestParseRateLimit checks the X-RateLimit-* headers and those of the
+// IETF draft, with resets in seconds and as Unix times
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   rateLimit
+		ok     bool
+	}{
+		{
+			"x-ratelimit",
+			map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "42", "X-RateLimit-Reset": "30"},
+			rateLimit{limit: 100, remaining: 42, reset: 30}, true,
+		},
+		{
+			"unix reset",
+			map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000045"},
+			rateLimit{limit: 100, remaining: 0, reset: 45}, true,
+		},
+		{
+			"past unix reset",
+			map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset": "1699999990"},
+			rateLimit{remaining: 3, reset: 0}, true,
+		},
+		{
+			"draft with policy",
+			map[string]string{"RateLimit-Limit": "10, 10;w=1, 1000;w=3600", "RateLimit-Remaining": " 9 ", "RateLimit-Reset": "1"},
+			rateLimit{limit: 10, remaining: 9, reset: 1}, true,
+		},
+		{
+			"without limit and reset",
+			map[string]string{"X-RateLimit-Remaining": "7"},
+			rateLimit{remaining: 7, reset: -1}, true,
+		},
+		{
+			"invalid reset",
+			map[string]string{"X-RateLimit-Remaining": "7", "X-RateLimit-Reset": "-1"},
+			rateLimit{remaining: 7, reset: -1}, true,
+		},
+		{
+			"x- form first",
+			map[string]string{"X-RateLimit-Remaining": "5", "RateLimit-Remaining": "6"},
+			rateLimit{remaining: 5, reset: -1}, true,
+		},
+		{"no remaining", map[string]string{"X-RateLimit-Limit": "100"}, rateLimit{}, false},
+		{"invalid remaining", map[string]string{"X-RateLimit-Remaining": "many"}, rateLimit{}, false},
+		{"none", nil, rateLimit{}, false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for name, value := range tt.header {
+			header.Set(name, value)
+		}
+		got, ok := parseRateLimit(header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestRateLimitTracker checks the lowest remaining requests of the run
+// and of each interval
+func TestRateLimitTracker(t *testing.T) {
+	tracker := newRateLimitTracker()
+	start := time.Now()
+	header := func(remaining string) http.Header {
+		return http.Header{"X-Ratelimit-Limit": {"10"}, "X-Ratelimit-Remaining": {remaining}, "X-Ratelimit-Reset": {"20"}}
+	}
+	tracker.record(header("5"))
+	tracker.record(header("3"))
+	tracker.record(http.Header{})
+	if w := tracker.next(); w == nil || *w != (RateLimitWindow{Responses: 2, Limit: 10, Remaining: 3, Reset: 20}) {
+		t.Errorf("first window %+v", w)
+	}
+	if w := tracker.next(); w != nil {
+		t.Errorf("empty window %+v, want nil", w)
+	}
+	tracker.record(header("0"))
+	tracker.record(header("0"))
+	tracker.record(header("9"))
+	if w := tracker.next(); w == nil || w.Remaining != 0 || w.Responses != 3 {
+		t.Errorf("second window %+v", w)
+	}
+	s := tracker.summary(start)
+	if s == nil || s.Responses != 5 || s.Limit != 10 || s.Remaining != 0 || s.Exhausted != 2 || s.ExhaustedAfter < 0 {
+		t.Errorf("summary %+v", s)
+	}
+	if newRateLimitTracker().summary(start) != nil {
+		t.Error("summary without headers is not nil")
+	}
+}
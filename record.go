@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxRecordedBody is the most of a request body the scenario and HAR
+// formats keep; longer bodies are truncated
+const maxRecordedBody = 1 << 20
+
+// recordedRequest is a request that went through the record proxy, with
+// the response it got
+type recordedRequest struct {
+	start    time.Time
+	duration time.Duration
+	method   string
+	url      string // absolute
+	path     string // as sent to -target, or absolute without one
+	proto    string
+	header   http.Header
+	body     []byte
+
+	status     int
+	respHeader http.Header
+	respSize   int64
+}
+
+// statusRecorder keeps the status and size of a proxied response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// runRecord implements the "record" subcommand, a proxy that records
+// every request it forwards, so real traffic can be run later as a
+// scenario or replayed with "autocannon replay -format csv". With -target
+// it is a reverse proxy in front of the target; without, clients such as
+// browsers use it as their HTTP proxy. HTTPS through the forward proxy
+// is tunneled, but can't be recorded, as that would take intercepting
+// TLS. The proxy only reaches the hosts of -allow-host, and the -target
+// host by default.
+func runRecord(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8081", "Address to accept traffic on")
+	target := fs.String("target", "", "The uri to forward traffic to; without it, clients use the recorder as their HTTP proxy (HTTPS is tunneled, not recorded)")
+	allowHosts := fs.String("allow-host", "", "Comma-separated hosts the proxy may forward and tunnel to, or * for any (default the -target host, or any host when listening on localhost)")
+	output := fs.String("output", "", "File to record requests to (default requests.json, requests.csv or requests.har, by -format)")
+	format := fs.String("format", "scenario", "Format of the recording: scenario (a -scenario file), csv (for replay) or har")
+	fs.Parse(args)
+
+	if *format != "scenario" && *format != "csv" && *format != "har" {
+		fmt.Printf("Invalid -format %q, expected scenario, csv or har.\n", *format)
+		os.Exit(exitConfigError)
+	}
+	if *output == "" {
+		*output = "requests.json"
+		if *format != "scenario" {
+			*output = "requests." + *format
+		}
+	}
+	var proxy *httputil.ReverseProxy
+	if *target != "" {
+		targetURL, err := url.Parse(*target)
+		if err != nil || targetURL.Host == "" {
+			fmt.Printf("Invalid -target %q.\n", *target)
+			os.Exit(exitConfigError)
+		}
+		proxy = httputil.NewSingleHostReverseProxy(targetURL)
+		if *allowHosts == "" {
+			*allowHosts = targetURL.Host
+		}
+	} else {
+		// Requests to a forward proxy carry the absolute URL to fetch
+		proxy = &httputil.ReverseProxy{Rewrite: func(*httputil.ProxyRequest) {}}
+	}
+
+	if *allowHosts == "" {
+		// Without a target, the proxy forwards anywhere, so only local
+		// clients may use it unless the hosts are given
+		if !isLoopbackAddr(*listen) {
+			fmt.Printf("Invalid -listen %q: a proxy without -target listening beyond localhost needs -allow-host.\n", *listen)
+			os.Exit(exitConfigError)
+		}
+		*allowHosts = "*"
+	}
+	allowed := func(host string) bool { return hostAllowed(*allowHosts, host) }
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Printf("Error creating %s: %v\n", *output, err)
+		os.Exit(exitConfigError)
+	}
+	log := csv.NewWriter(file)
+	if *format == "csv" {
+		log.Write([]string{"timestamp", "method", "path"})
+	}
+
+	var mu sync.Mutex
+	var requests []recordedRequest
+	recorded, tunneled := 0, 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			if !allowed(r.Host) {
+				http.Error(w, fmt.Sprintf("%s is not an allowed host", r.Host), http.StatusForbidden)
+				return
+			}
+			mu.Lock()
+			tunneled++
+			mu.Unlock()
+			tunnel(w, r)
+			return
+		}
+		req := recordedRequest{start: time.Now(), method: r.Method, path: r.URL.RequestURI(), proto: r.Proto, header: r.Header.Clone()}
+		if *target != "" {
+			req.url = strings.TrimSuffix(*target, "/") + r.URL.RequestURI()
+		} else {
+			if !r.URL.IsAbs() {
+				http.Error(w, "autocannon record is a proxy without -target: configure it as the client's HTTP proxy", http.StatusBadRequest)
+				return
+			}
+			if !allowed(r.URL.Host) {
+				http.Error(w, fmt.Sprintf("%s is not an allowed host", r.URL.Host), http.StatusForbidden)
+				return
+			}
+			req.url = r.URL.String()
+			req.path = req.url
+		}
+		if *format != "csv" && r.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxRecordedBody+1))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			req.body = body[:min(len(body), maxRecordedBody)]
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		proxy.ServeHTTP(rec, r)
+		req.duration = time.Since(req.start)
+		req.status = rec.status
+		req.respHeader = w.Header().Clone()
+		req.respSize = rec.size
+
+		mu.Lock()
+		defer mu.Unlock()
+		recorded++
+		if *format == "csv" {
+			log.Write([]string{wallTime(req.start).UTC().Format(time.RFC3339Nano), req.method, req.path})
+			log.Flush()
+			return
+		}
+		requests = append(requests, req)
+	})
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if *target != "" {
+			fmt.Printf("Recording %s -> %s to %s (Ctrl-C to stop)\n", *listen, *target, *output)
+		} else {
+			fmt.Printf("Recording requests through the HTTP proxy on %s to %s (Ctrl-C to stop); HTTPS is tunneled without being recorded\n", *listen, *output)
+		}
+		if err := http.ListenAndServe(*listen, handler); err != nil {
+			fmt.Printf("Error serving: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}()
+	<-interrupt
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Requests are appended as they complete; the think times and HAR
+	// entries follow the order they started in
+	slices.SortFunc(requests, func(a, b recordedRequest) int { return a.start.Compare(b.start) })
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	switch *format {
+	case "scenario":
+		err = encoder.Encode(recordedScenario(requests))
+	case "har":
+		err = encoder.Encode(recordedHAR(requests))
+	default:
+		log.Flush()
+		err = log.Error()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *output, err)
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *output, err)
+	}
+	fmt.Printf("\nRecorded %d requests to %s\n", recorded, *output)
+	if tunneled > 0 {
+		fmt.Printf("%d HTTPS connections were tunneled without being recorded; record HTTPS sites with -target.\n", tunneled)
+	}
+}
+
+// isLoopbackAddr reports whether a listen address only accepts local
+// connections
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hostAllowed reports whether host, with or without a port, is one of the
+// comma-separated hosts of allow. An entry without a port allows every
+// port of its host.
+func hostAllowed(allow, host string) bool {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	for _, entry := range strings.Split(allow, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "*" || strings.EqualFold(entry, host) {
+			return true
+		}
+		if _, _, err := net.SplitHostPort(entry); err != nil && strings.EqualFold(strings.Trim(entry, "[]"), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnel relays a CONNECT request's connection to its host as it is
+func tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	go func() {
+		io.Copy(upstream, buffered)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+}
+
+// unrecordedHeaders are left out of recorded scenario steps: the
+// transport and the cookie jar of -vus set them for every request
+var unrecordedHeaders = []string{
+	"Host", "Content-Length", "Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Accept-Encoding", "Cookie",
+}
+
+// recordedStep is a scenarioStep as written by record, without the
+// fields a recording doesn't fill in
+type recordedStep struct {
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Body      string            `json:"body,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ThinkTime string            `json:"thinkTime,omitempty"`
+}
+
+// recordedScenario turns recorded requests into a scenario file, with
+// the pause between a response and the next request as its think time.
+// Template actions in the recorded values are escaped.
+func recordedScenario(requests []recordedRequest) any {
+	literal := strings.NewReplacer("{{", `{{"{{"}}`)
+	steps := make([]recordedStep, len(requests))
+	for i, req := range requests {
+		step := recordedStep{Method: req.method, Path: literal.Replace(req.path), Body: literal.Replace(string(req.body))}
+		for name, values := range req.header {
+			if !slices.ContainsFunc(unrecordedHeaders, func(h string) bool { return strings.EqualFold(h, name) }) {
+				if step.Headers == nil {
+					step.Headers = make(map[string]string)
+				}
+				step.Headers[name] = literal.Replace(strings.Join(values, ", "))
+			}
+		}
+		if i+1 < len(requests) {
+			if pause := requests[i+1].start.Sub(req.start.Add(req.duration)).Round(time.Millisecond); pause > 0 {
+				step.ThinkTime = pause.String()
+			}
+		}
+		steps[i] = step
+	}
+	return struct {
+		Steps []recordedStep `json:"steps"`
+	}{steps}
+}
+
+// harNameValue is a header or query parameter of a HAR entry
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	list := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			list = append(list, harNameValue{name, value})
+		}
+	}
+	return list
+}
+
+// recordedHAR turns recorded requests into a HAR 1.2 log
+func recordedHAR(requests []recordedRequest) any {
+	type postData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+	type harRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		Cookies     []harNameValue `json:"cookies"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+		PostData    *postData      `json:"postData,omitempty"`
+	}
+	type harContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+	}
+	type harResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Headers     []harNameValue `json:"headers"`
+		Cookies     []harNameValue `json:"cookies"`
+		Content     harContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int64          `json:"bodySize"`
+	}
+	type harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+	type harEntry struct {
+		StartedDateTime string      `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+	}
+
+	entries := make([]harEntry, len(requests))
+	for i, req := range requests {
+		ms := float64(req.duration) / float64(time.Millisecond)
+		request := harRequest{
+			Method:      req.method,
+			URL:         req.url,
+			HTTPVersion: req.proto,
+			Headers:     harHeaders(req.header),
+			QueryString: []harNameValue{},
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(req.body),
+		}
+		if u, err := url.Parse(req.url); err == nil {
+			for name, values := range u.Query() {
+				for _, value := range values {
+					request.QueryString = append(request.QueryString, harNameValue{name, value})
+				}
+			}
+		}
+		if len(req.body) > 0 {
+			request.PostData = &postData{MimeType: req.header.Get("Content-Type"), Text: string(req.body)}
+		}
+		entries[i] = harEntry{
+			StartedDateTime: wallTime(req.start).UTC().Format(time.RFC3339Nano),
+			Time:            ms,
+			Request:         request,
+			Response: harResponse{
+				Status:      req.status,
+				StatusText:  http.StatusText(req.status),
+				HTTPVersion: req.proto,
+				Headers:     harHeaders(req.respHeader),
+				Cookies:     []harNameValue{},
+				Content:     harContent{Size: req.respSize, MimeType: req.respHeader.Get("Content-Type")},
+				RedirectURL: req.respHeader.Get("Location"),
+				HeadersSize: -1,
+				BodySize:    req.respSize,
+			},
+			Timings: harTimings{Wait: ms},
+		}
+	}
+
+	type harLog struct {
+		Version string `json:"version"`
+		Creator struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	}
+	log := harLog{Version: "1.2", Entries: entries}
+	log.Creator.Name, log.Creator.Version, _ = strings.Cut(defaultUserAgent(), "/")
+	return struct {
+		Log harLog `json:"log"`
+	}{log}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayStats describes an access-log replay
+type ReplayStats struct {
+	File    string  `json:"file"`
+	Format  string  `json:"format"`
+	Speed   float64 `json:"speed"`
+	Entries int64   `json:"entries"`
+	Skipped int64   `json:"skipped"`
+}
+
+// replayEntry is a single request read from an access log
+type replayEntry struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	UserAgent string
+}
+
+// commonLogPattern matches the Common and Combined Log Formats, e.g.
+// 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "ref" "agent"
+var commonLogPattern = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" \S+ \S+(?: "[^"]*" "([^"]*)")?`)
+
+const commonLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// replayReader streams entries from an access log in file order
+type replayReader struct {
+	file    *os.File
+	format  string
+	lines   *bufio.Scanner
+	csv     *csv.Reader
+	skipped int64
+}
+
+// openReplay opens an access log in the given format: common, combined
+// or csv (timestamp,method,path with an optional header row)
+func openReplay(path, format string) (*replayReader, error) {
+	if format != "common" && format != "combined" && format != "csv" {
+		return nil, fmt.Errorf("unknown replay format %q, expected common, combined or csv", format)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &replayReader{file: file, format: format}
+	if format == "csv" {
+		r.csv = csv.NewReader(file)
+		r.csv.FieldsPerRecord = -1
+	} else {
+		r.lines = bufio.NewScanner(file)
+		r.lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	}
+	return r, nil
+}
+
+func (r *replayReader) Close() error {
+	return r.file.Close()
+}
+
+// next returns the next valid entry, counting and skipping lines that
+// cannot be parsed. It returns io.EOF at the end of the log.
+func (r *replayReader) next() (replayEntry, error) {
+	for {
+		entry, ok, err := r.read()
+		if err != nil {
+			return replayEntry{}, err
+		}
+		if ok {
+			return entry, nil
+		}
+		r.skipped++
+	}
+}
+
+func (r *replayReader) read() (replayEntry, bool, error) {
+	if r.csv != nil {
+		record, err := r.csv.Read()
+		if err != nil {
+			if _, isParseErr := err.(*csv.ParseError); isParseErr {
+				return replayEntry{}, false, nil
+			}
+			return replayEntry{}, false, err
+		}
+		if len(record) < 3 || strings.EqualFold(record[0], "timestamp") {
+			return replayEntry{}, false, nil
+		}
+		ts, err := parseReplayTimestamp(record[0])
+		if err != nil {
+			return replayEntry{}, false, nil
+		}
+		return replayEntry{Time: ts, Method: strings.ToUpper(record[1]), Path: record[2]}, true, nil
+	}
+
+	if !r.lines.Scan() {
+		if err := r.lines.Err(); err != nil {
+			return replayEntry{}, false, err
+		}
+		return replayEntry{}, false, io.EOF
+	}
+
+	match := commonLogPattern.FindStringSubmatch(r.lines.Text())
+	if match == nil {
+		return replayEntry{}, false, nil
+	}
+	ts, err := time.Parse(commonLogTimeLayout, match[1])
+	if err != nil {
+		return replayEntry{}, false, nil
+	}
+	entry := replayEntry{Time: ts, Method: match[2], Path: match[3]}
+	if r.format == "combined" && match[4] != "-" {
+		entry.UserAgent = match[4]
+	}
+	return entry, true, nil
+}
+
+// parseReplayTimestamp accepts RFC3339 timestamps or Unix seconds
+func parseReplayTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return ts, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// replayURL joins a logged path onto the base uri
+func replayURL(base, path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
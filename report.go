@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runReport implements the "report" subcommand, printing the result
+// tables of a saved result file as they were shown after the run, or
+// writing them as an HTML report with -html. "report grafana" prints a
+// dashboard for the exported metrics instead.
+func runReport(args []string) {
+	if len(args) > 0 && args[0] == "grafana" {
+		runGrafanaReport(args[1:])
+		return
+	}
+
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	perConnection := fs.Bool("per-connection", false, "Show the per-connection statistics table")
+	htmlReport := fs.String("html", "", "Write an HTML report to this file instead of printing the tables")
+	samples := fs.String("samples", "", "The -record sample file of the run, for the HTML report's latency heatmap")
+	latencyUnitFlag := fs.String("latency-unit", "ms", "Show latencies in us, ms or s")
+	precision := fs.Int("precision", 2, "Show latencies with this many decimals")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon report [flags] result.json\n       autocannon report grafana [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	configureColor(*noColor)
+
+	if err := configureLatencyFormat(*latencyUnitFlag, *precision); err != nil {
+		fmt.Printf("Invalid latency format: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+	result, err := readResultFile(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", fs.Arg(0), err)
+		os.Exit(exitConfigError)
+	}
+
+	if *htmlReport != "" {
+		heatmap := newLatencyHeatmap()
+		if *samples != "" {
+			if err := readSampleFile(*samples, heatmap.record); err != nil {
+				fmt.Printf("Error reading %s: %v\n", *samples, err)
+				os.Exit(exitConfigError)
+			}
+		}
+		if err := writeHTMLReport(*htmlReport, result, heatmap); err != nil {
+			fmt.Printf("Error writing HTML report: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		fmt.Printf("HTML report written to %s\n", *htmlReport)
+		return
+	}
+
+	consoleSink{showConnections: *perConnection}.Flush(result)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResultSchemaVersion is the version of the BenchmarkResult JSON
+// structure. It is incremented whenever a field is moved, renamed or
+// changes meaning; adding fields does not change the version.
+//
+//	1: the original flat structure, without a schemaVersion field
+//	2: request counts, latency and throughput grouped into objects
+//	3: latency covers every request, failed ones included
+//	4: headerTimeouts leaves out the timeouts before a connection was
+//	   made, counted in connectTimeouts
+const ResultSchemaVersion = 4
+
+// RequestCounts holds the outcome counts of a run
+type RequestCounts struct {
+	Total           int64   `json:"total"`
+	Successful      int64   `json:"successful"`
+	Failed          int64   `json:"failed"`
+	Timeouts        int64   `json:"timeouts"`
+	ConnectTimeouts int64   `json:"connectTimeouts"`
+	HeaderTimeouts  int64   `json:"headerTimeouts"`
+	BodyTimeouts    int64   `json:"bodyTimeouts"`
+	Throttled       int64   `json:"throttled,omitempty"`
+	ErrorRate       float64 `json:"errorRate"`
+}
+
+// LatencyStats holds the latency of every request, failed ones included,
+// in milliseconds.
+// Histogram is the full distribution in microseconds, a base64 compressed
+// HdrHistogram, so results can be merged.
+type LatencyStats struct {
+	Average     float64           `json:"averageMs"`
+	Min         float64           `json:"minMs"`
+	Max         float64           `json:"maxMs"`
+	Percentiles []PercentileValue `json:"percentiles,omitempty"`
+	Histogram   string            `json:"histogram,omitempty"`
+}
+
+// PercentileValue is the latency at one of the -percentiles
+type PercentileValue struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"valueMs"`
+}
+
+// parsePercentiles parses a comma-separated -percentiles list
+func parsePercentiles(list string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(field), "p"))
+		if field == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(field, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q, expected a number above 0 and at most 100", field)
+		}
+		percentiles = append(percentiles, p)
+	}
+	sort.Float64s(percentiles)
+	return percentiles, nil
+}
+
+// ThroughputStats holds the request rate and transferred bytes of a run
+type ThroughputStats struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	BytesRead         int64   `json:"bytesRead"`
+	BytesWritten      int64   `json:"bytesWritten"`
+}
+
+// resultV1 holds the version 1 fields that moved in version 2
+type resultV1 struct {
+	TotalRequests  int64   `json:"totalRequests"`
+	SuccessfulReqs int64   `json:"successfulRequests"`
+	FailedReqs     int64   `json:"failedRequests"`
+	Timeouts       int64   `json:"timeouts"`
+	HeaderTimeouts int64   `json:"headerTimeouts"`
+	BodyTimeouts   int64   `json:"bodyTimeouts"`
+	RequestsPerSec float64 `json:"requestsPerSecond"`
+	AverageLatency float64 `json:"averageLatencyMs"`
+	MinLatency     float64 `json:"minLatencyMs"`
+	MaxLatency     float64 `json:"maxLatencyMs"`
+	BytesRead      int64   `json:"bytesRead"`
+	BytesWritten   int64   `json:"bytesWritten"`
+	ErrorRate      float64 `json:"errorRate"`
+}
+
+// decodeResult parses result JSON of any schema version, upgrading it to
+// the current one
+func decodeResult(data []byte) (BenchmarkResult, error) {
+	var version struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return BenchmarkResult{}, err
+	}
+	if version.SchemaVersion > ResultSchemaVersion {
+		return BenchmarkResult{}, fmt.Errorf("result schema version %d is newer than the supported version %d", version.SchemaVersion, ResultSchemaVersion)
+	}
+
+	var result BenchmarkResult
+	if version.SchemaVersion < 2 {
+		// Fields that did not move decode directly, the moved ones are
+		// read separately
+		if err := json.Unmarshal(data, &result); err != nil {
+			return BenchmarkResult{}, err
+		}
+		var v1 resultV1
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return BenchmarkResult{}, err
+		}
+
+		result.Requests = RequestCounts{
+			Total:          v1.TotalRequests,
+			Successful:     v1.SuccessfulReqs,
+			Failed:         v1.FailedReqs,
+			Timeouts:       v1.Timeouts,
+			HeaderTimeouts: v1.HeaderTimeouts,
+			BodyTimeouts:   v1.BodyTimeouts,
+			ErrorRate:      v1.ErrorRate,
+		}
+		result.Latency = LatencyStats{Average: v1.AverageLatency, Min: v1.MinLatency, Max: v1.MaxLatency}
+		result.Throughput = ThroughputStats{
+			RequestsPerSecond: v1.RequestsPerSec,
+			BytesRead:         v1.BytesRead,
+			BytesWritten:      v1.BytesWritten,
+		}
+	} else if err := json.Unmarshal(data, &result); err != nil {
+		return BenchmarkResult{}, err
+	}
+	// Latency of versions before 3 covers successful requests only, which
+	// can't be recomputed; it is kept as it is
+
+	result.SchemaVersion = ResultSchemaVersion
+	return result, nil
+}
+
+// runConvert implements the "convert" subcommand, upgrading result files
+// written by older versions to the current schema
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	output := fs.String("output", "", "Write the converted result to this file instead of replacing the input")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon convert [-output file] result.json...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || (*output != "" && fs.NArg() > 1) {
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		result, err := decodeResult(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error converting %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+
+		target := path
+		if *output != "" {
+			target = *output
+		}
+		jsonData, err := json.MarshalIndent(result, "", "  ")
+		if err == nil {
+			err = os.WriteFile(target, jsonData, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", target, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Converted %s to schema version %d\n", target, ResultSchemaVersion)
+	}
+	if failed {
+		os.Exit(exitConfigError)
+	}
+}
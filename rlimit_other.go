@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// fileLimit is not available on this platform
+func fileLimit() (soft, hard uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+// raiseFileLimit is not available on this platform
+func raiseFileLimit(want uint64) (soft, hard uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
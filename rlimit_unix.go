@@ -0,0 +1,31 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// fileLimit returns the soft and hard limits on open files
+func fileLimit() (soft, hard uint64, err error) {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return 0, 0, err
+	}
+	return uint64(lim.Cur), uint64(lim.Max), nil
+}
+
+// raiseFileLimit raises the soft limit on open files to the hard limit
+// when it is below want, and returns the resulting limits
+func raiseFileLimit(want uint64) (soft, hard uint64, err error) {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return 0, 0, err
+	}
+	if uint64(lim.Cur) < want && lim.Cur < lim.Max {
+		raised := lim
+		raised.Cur = raised.Max
+		if syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised) == nil {
+			lim = raised
+		}
+	}
+	return uint64(lim.Cur), uint64(lim.Max), nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sampleRecorder streams one CSV row per request to a file, gzipped when
+// the file name ends in .gz. It is safe for concurrent use.
+type sampleRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	csv  *csv.Writer
+	err  error
+}
+
+// Sample is a single request outcome. Connection is -1 when the request
+// was not sent by a fixed connection (open model and replay).
+type Sample struct {
+	Start      time.Time
+	Method     string
+	URL        string
+	Latency    float64
+	Status     int
+	Bytes      int64
+	BytesOut   int64
+	Connection int
+	ErrorClass string
+	Error      string
+	RequestID  string
+}
+
+func newSampleRecorder(path string) (*sampleRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &sampleRecorder{file: file}
+	var w io.Writer = file
+	if strings.HasSuffix(path, ".gz") {
+		r.gz = gzip.NewWriter(file)
+		w = r.gz
+	}
+	r.csv = csv.NewWriter(w)
+	r.csv.Write([]string{"timestamp", "latency_ms", "status", "bytes", "connection", "error", "request_id"})
+	return r, nil
+}
+
+func (r *sampleRecorder) Record(s Sample) {
+	connection := ""
+	if s.Connection >= 0 {
+		connection = strconv.Itoa(s.Connection)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.csv.Write([]string{
+		s.Start.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		strconv.FormatFloat(s.Latency, 'f', 3, 64),
+		strconv.Itoa(s.Status),
+		strconv.FormatInt(s.Bytes, 10),
+		connection,
+		s.ErrorClass,
+		s.RequestID,
+	})
+}
+
+func (r *sampleRecorder) Flush(BenchmarkResult) error {
+	if err := r.Close(); err != nil {
+		return fmt.Errorf("writing sample file: %v", err)
+	}
+	return nil
+}
+
+// Close flushes all buffered rows and returns the first write error
+func (r *sampleRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.csv.Flush()
+	if r.err == nil {
+		r.err = r.csv.Error()
+	}
+	if r.gz != nil {
+		if err := r.gz.Close(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// classifyError reduces a request error to a short class for the sample
+// export: header_timeout, body_timeout, connect, port_exhaustion, or the
+// failing phase. A timeout before the connection was made is reclassified
+// as connect_timeout by the caller, which traces the connection.
+func classifyError(err error, readingBody bool) string {
+	if os.IsTimeout(err) {
+		if readingBody {
+			return "body_timeout"
+		}
+		return "header_timeout"
+	}
+	if readingBody {
+		return "body"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		if isPortExhaustion(err) {
+			return "port_exhaustion"
+		}
+		return "connect"
+	}
+	return "transport"
+}
+
+// readSampleFile calls fn for every row of a -record sample file
+func readSampleFile(path string, fn func(Sample)) error {
+	if strings.HasSuffix(path, ".parquet") {
+		return errors.New("Parquet sample files cannot be read back; record to .csv or .csv.gz instead")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	rows := csv.NewReader(r)
+	rows.FieldsPerRecord = -1
+	header, err := rows.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	for {
+		record, err := rows.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, err := time.Parse(time.RFC3339Nano, field(record, "timestamp"))
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", field(record, "timestamp"))
+		}
+		s := Sample{Start: start, Connection: -1, ErrorClass: field(record, "error"), RequestID: field(record, "request_id")}
+		s.Latency, _ = strconv.ParseFloat(field(record, "latency_ms"), 64)
+		s.Status, _ = strconv.Atoi(field(record, "status"))
+		s.Bytes, _ = strconv.ParseInt(field(record, "bytes"), 10, 64)
+		if c, err := strconv.Atoi(field(record, "connection")); err == nil {
+			s.Connection = c
+		}
+		fn(s)
+	}
+}
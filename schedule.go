@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runSchedule implements the "schedule" subcommand: it runs a benchmark
+// on an interval, keeps every result in a results store and notifies when
+// a run regressed against the previous run of the same uri. The run flags
+// follow the schedule flags, e.g.
+// schedule -every 6h -store runs.db -- -uri http://api.local -duration 60
+func runSchedule(args []string) {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	every := fs.Duration("every", time.Hour, "Time between the starts of two runs")
+	runs := fs.Int("runs", 0, "Stop after this many runs (0 runs until interrupted); with -runs 1 the exit code reports a regression, for cron")
+	store := fs.String("store", "", "The SQLite results store to add every run to. (Required)")
+	maxRPSDrop := fs.Float64("max-rps-drop", 10, "Percent drop in requests/sec from the previous run that counts as a regression (0 disables)")
+	maxLatencyRise := fs.Float64("max-latency-increase", 20, "Percent increase in average latency from the previous run that counts as a regression (0 disables)")
+	maxErrorRate := fs.Float64("max-error-rate", 1, "Error rate in percent above which a run counts as a regression (0 disables)")
+	webhook := fs.String("notify-webhook", "", "POST a JSON notification to this URL when a run regresses")
+	command := fs.String("notify-command", "", "Run this shell command when a run regresses, with the details in AUTOCANNON_* environment variables (e.g. to send an email)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: autocannon schedule [flags] -- [run flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *store == "" {
+		fmt.Println("You must provide a results store for the scheduled runs.")
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+	if *every <= 0 {
+		fmt.Println("The schedule interval must be positive.")
+		os.Exit(exitConfigError)
+	}
+	runArgs := fs.Args()
+	if len(runArgs) == 0 {
+		fmt.Println("You must provide the run flags to schedule.")
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Error locating the autocannon binary: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	thresholds := regressionThresholds{rpsDrop: *maxRPSDrop, latencyRise: *maxLatencyRise, errorRate: *maxErrorRate}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	regressed := false
+	for n := 1; *runs == 0 || n <= *runs; n++ {
+		started := time.Now()
+		result, err := scheduledRun(exe, *store, runArgs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s run %d failed: %v\n", started.Format(time.RFC3339), n, err)
+			os.Exit(exitConfigError)
+		}
+
+		previous, err := previousRun(*store, result.URI)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading the previous run: %v\n", err)
+		}
+		regressions := thresholds.check(result, previous)
+		regressed = len(regressions) > 0
+
+		status := "ok"
+		if regressed {
+			status = "REGRESSED: " + strings.Join(regressions, "; ")
+		}
+		fmt.Printf("%s run %d: %.2f req/sec, %.2f ms avg, %.2f%% errors - %s\n",
+			started.Format(time.RFC3339), n, result.Throughput.RequestsPerSecond, result.Latency.Average, result.Requests.ErrorRate, status)
+
+		if regressed {
+			notification := regressionNotification{
+				URI:               result.URI,
+				Timestamp:         result.Timestamp,
+				Regressions:       regressions,
+				RequestsPerSecond: result.Throughput.RequestsPerSecond,
+				AverageLatency:    result.Latency.Average,
+				ErrorRate:         result.Requests.ErrorRate,
+			}
+			if previous != nil {
+				notification.PreviousRun = previous.ID
+			}
+			if *webhook != "" {
+				if err := notification.post(*webhook); err != nil {
+					fmt.Fprintf(os.Stderr, "Error notifying %s: %v\n", *webhook, err)
+				}
+			}
+			if *command != "" {
+				if err := notification.run(*command); err != nil {
+					fmt.Fprintf(os.Stderr, "Error running the notify command: %v\n", err)
+				}
+			}
+		}
+
+		if *runs != 0 && n == *runs {
+			break
+		}
+		select {
+		case <-time.After(time.Until(started.Add(*every))):
+		case <-interrupt:
+			return
+		}
+	}
+
+	if regressed {
+		os.Exit(exitAssertionFailed)
+	}
+}
+
+// scheduledRun runs the benchmark in a child process, storing the result
+func scheduledRun(exe, store string, runArgs []string) (BenchmarkResult, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(exe, append([]string{"run", "-json", "-store", store}, runArgs...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if code := cmd.ProcessState.ExitCode(); err != nil && (code == exitConfigError || code < 0) {
+		return BenchmarkResult{}, fmt.Errorf("%v\n%s%s", err, stdout.Bytes(), stderr.Bytes())
+	}
+	return decodeResult(stdout.Bytes())
+}
+
+// previousRun returns the stored run of uri before the latest one, or nil
+func previousRun(store, uri string) (*storedRun, error) {
+	db, err := openStore(store)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	runs, err := queryRuns(db, uri, nil, 2)
+	if err != nil || len(runs) < 2 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// regressionThresholds are the schedule limits; zero disables a check
+type regressionThresholds struct {
+	rpsDrop     float64
+	latencyRise float64
+	errorRate   float64
+}
+
+// check describes every way result regressed against previous, which is
+// nil for the first run of a uri
+func (t regressionThresholds) check(result BenchmarkResult, previous *storedRun) []string {
+	var regressions []string
+	if t.errorRate > 0 && result.Requests.ErrorRate > t.errorRate {
+		regressions = append(regressions, fmt.Sprintf("error rate %.2f%% above %.2f%%", result.Requests.ErrorRate, t.errorRate))
+	}
+	if previous == nil {
+		return regressions
+	}
+	if t.rpsDrop > 0 && previous.RequestsPerSec > 0 {
+		drop := (previous.RequestsPerSec - result.Throughput.RequestsPerSecond) / previous.RequestsPerSec * 100
+		if drop > t.rpsDrop {
+			regressions = append(regressions, fmt.Sprintf("requests/sec down %.1f%% from run #%d", drop, previous.ID))
+		}
+	}
+	if t.latencyRise > 0 && previous.AverageLatency > 0 {
+		rise := (result.Latency.Average - previous.AverageLatency) / previous.AverageLatency * 100
+		if rise > t.latencyRise {
+			regressions = append(regressions, fmt.Sprintf("average latency up %.1f%% from run #%d", rise, previous.ID))
+		}
+	}
+	return regressions
+}
+
+// regressionNotification is sent to -notify-webhook as JSON
+type regressionNotification struct {
+	URI               string    `json:"uri"`
+	Timestamp         time.Time `json:"timestamp"`
+	Regressions       []string  `json:"regressions"`
+	RequestsPerSecond float64   `json:"requestsPerSecond"`
+	AverageLatency    float64   `json:"averageLatencyMs"`
+	ErrorRate         float64   `json:"errorRate"`
+	PreviousRun       int64     `json:"previousRun,omitempty"`
+}
+
+func (n regressionNotification) post(url string) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (n regressionNotification) run(command string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"AUTOCANNON_URI="+n.URI,
+		"AUTOCANNON_REGRESSIONS="+strings.Join(n.Regressions, "; "),
+		fmt.Sprintf("AUTOCANNON_REQUESTS_PER_SECOND=%.2f", n.RequestsPerSecond),
+		fmt.Sprintf("AUTOCANNON_AVERAGE_LATENCY_MS=%.2f", n.AverageLatency),
+		fmt.Sprintf("AUTOCANNON_ERROR_RATE=%.2f", n.ErrorRate),
+	)
+	return cmd.Run()
+}
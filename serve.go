@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// runServe implements the "serve" subcommand, an HTTP agent that runs
+// benchmarks on request. POST /run with {"args": [...]} runs the given
+// run flags with -json and responds with the result JSON, and the run's
+// exit code in the X-Autocannon-Exit-Code header. Runs execute in a child
+// process, one at a time, and may only use the flags of serveFlags.
+// GET /live is a WebSocket that streams the
+// current run's start, per-second statistics and result as JSON messages.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to accept runs on; the agent has no authentication, so only listen on other interfaces of trusted networks")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Error locating the autocannon binary: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	var running sync.Mutex
+	live := newLiveHub()
+	mux := http.NewServeMux()
+	mux.Handle("/live", live)
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Args []string `json:"args"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := checkServeArgs(req.Args); err != nil {
+			http.Error(w, fmt.Sprintf("invalid args: %v", err), http.StatusBadRequest)
+			return
+		}
+		if !running.TryLock() {
+			http.Error(w, "a run is already in progress", http.StatusConflict)
+			return
+		}
+		defer running.Unlock()
+
+		// The child prints a compact JSON line per second, which goes to
+		// the live clients, before the indented result
+		var stdout, stderr bytes.Buffer
+		intervals := &intervalSplitter{rest: &stdout, live: live}
+		cmd := exec.CommandContext(r.Context(), exe, append([]string{"run", "-json", "-print-interval", "1s"}, req.Args...)...)
+		cmd.Stdout = intervals
+		cmd.Stderr = &stderr
+		live.publish(liveMessage{Type: "start", Args: req.Args})
+		err := cmd.Run()
+		code := cmd.ProcessState.ExitCode()
+		if err != nil && (code == exitConfigError || code < 0) {
+			live.publish(liveMessage{Type: "failed", ExitCode: &code, Error: strings.TrimSpace(stdout.String() + stderr.String())})
+			http.Error(w, fmt.Sprintf("run failed: %v\n%s%s", err, stdout.Bytes(), stderr.Bytes()), http.StatusUnprocessableEntity)
+			return
+		}
+		done := liveMessage{Type: "result", ExitCode: &code}
+		if json.Valid(stdout.Bytes()) {
+			done.Result = json.RawMessage(stdout.Bytes())
+		}
+		live.publish(done)
+		// Runs that completed report their outcome as the exit code
+		w.Header().Set("X-Autocannon-Exit-Code", strconv.Itoa(code))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(stdout.Bytes())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	fmt.Printf("Accepting runs on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		fmt.Printf("Error serving: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+}
+
+// serveFlags are the run flags a serve client may use, and whether they
+// take a value. Flags that read or write files on the agent, open
+// listeners, or are set by the agent itself are left out.
+var serveFlags = map[string]bool{
+	"uri": true, "ab": true, "method": true, "body": true,
+	"clients": true, "vus": true, "duration": true, "timeout": true,
+	"header-timeout": true, "long-poll": true, "idle-timeout": true,
+	"model": true, "rate": true, "burst": true, "arrival": true,
+	"rate-per-connection": false, "max-pending": true,
+	"adaptive": false, "adaptive-errors": true, "adaptive-p99": true,
+	"connect-rate": true, "reconnect-every": true,
+	"reduce-on-port-exhaustion": false, "no-happy-eyeballs": false,
+	"no-tls-resumption": false, "proxy": true,
+	"ntlm": true, "ntlm-scheme": true, "digest-auth": true,
+	"user-agent": true, "accept-encoding": true,
+	"request-id": false, "idempotency-key": false, "traceparent": false,
+	"trace-exemplars": true, "expect-continue": false, "continue-timeout": true,
+	"expect": true, "expect-body": true, "verify-sha256": true,
+	"llm": true, "llm-model": true, "llm-max-tokens": true,
+	"throttle": false, "retry-after": false, "chunks": false, "cdn": false,
+	"server-identity": false, "conditional": false,
+	"range": true, "range-mode": true, "range-object-size": true,
+	"capture-header": true, "json-field": true, "tag": true,
+	"percentiles": true, "latency-phases": false, "per-connection": false,
+	"trim-start": true, "trim-end": true, "trim-outliers": true,
+}
+
+// checkServeArgs returns an error for args that use a flag outside
+// serveFlags, or that aren't flags
+func checkServeArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		takesValue, ok := serveFlags[name]
+		if !ok {
+			return fmt.Errorf("flag -%s is not allowed", name)
+		}
+		if takesValue && !hasValue {
+			// The value is the next arg
+			i++
+		}
+	}
+	return nil
+}
+
+// liveMessage is a message of the /live WebSocket. Type is "start" with
+// the run's args, "interval" once per second with the statistics of the
+// last second, "result" with the exit code and the result JSON, or
+// "failed" when the run could not start.
+type liveMessage struct {
+	Type     string          `json:"type"`
+	Args     []string        `json:"args,omitempty"`
+	Interval json.RawMessage `json:"interval,omitempty"`
+	ExitCode *int            `json:"exitCode,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// intervalSplitter publishes the interval lines of a run's -json output
+// as they are written, and passes every other line on to rest. Interval
+// lines are single line objects; the indented result's lines are not.
+type intervalSplitter struct {
+	rest    io.Writer
+	live    *liveHub
+	pending []byte
+}
+
+func (s *intervalSplitter) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := s.pending[:i+1]
+		if bytes.HasPrefix(line, []byte(`{"`)) {
+			s.live.publish(liveMessage{Type: "interval", Interval: json.RawMessage(bytes.TrimSpace(line))})
+		} else if _, err := s.rest.Write(line); err != nil {
+			return len(p), err
+		}
+		s.pending = s.pending[i+1:]
+	}
+}
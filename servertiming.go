@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// maxServerTimingMetrics bounds the metrics kept; others are counted as
+// "(other)"
+const maxServerTimingMetrics = 50
+
+// ServerTimingStats describes the Server-Timing headers (and trailers)
+// of the responses that had one: the distribution of every metric's
+// duration, and Client, the latency of those responses, so the time
+// spent in the server can be told from the time around it.
+type ServerTimingStats struct {
+	Responses int64                `json:"responses"`
+	Metrics   []ServerTimingMetric `json:"metrics"`
+	Client    PhaseLatency         `json:"client"`
+}
+
+// ServerTimingMetric is the duration distribution of one metric. Count is
+// the responses that had it with a duration.
+type ServerTimingMetric struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Count       int64        `json:"count"`
+	Duration    PhaseLatency `json:"duration"`
+}
+
+// serverTimingTracker collects ServerTimingStats from concurrent workers
+type serverTimingTracker struct {
+	mu           sync.Mutex
+	responses    int64
+	metrics      map[string]*histogram
+	descriptions map[string]string
+	client       *histogram
+}
+
+func newServerTimingTracker() *serverTimingTracker {
+	return &serverTimingTracker{
+		metrics:      make(map[string]*histogram),
+		descriptions: make(map[string]string),
+		client:       newLatencyHistogram(),
+	}
+}
+
+// serverTiming is a metric of a Server-Timing header
+type serverTiming struct {
+	name        string
+	description string
+	duration    float64 // milliseconds
+	hasDuration bool
+}
+
+// parseServerTiming parses the metrics of Server-Timing values, e.g.
+// `db;dur=53.2, cache;desc="Cache Read";dur=23.2`
+func parseServerTiming(values []string) []serverTiming {
+	var timings []serverTiming
+	for _, value := range values {
+		for _, entry := range splitQuoted(value, ',') {
+			params := splitQuoted(entry, ';')
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			timing := serverTiming{name: name}
+			for _, param := range params[1:] {
+				key, val, _ := strings.Cut(param, "=")
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "dur":
+					if d, err := strconv.ParseFloat(val, 64); err == nil && d >= 0 && !timing.hasDuration {
+						timing.duration, timing.hasDuration = d, true
+					}
+				case "desc":
+					if timing.description == "" {
+						timing.description = val
+					}
+				}
+			}
+			timings = append(timings, timing)
+		}
+	}
+	return timings
+}
+
+// splitQuoted splits s at sep outside of double quotes
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// record adds the Server-Timing metrics of a response of the given
+// latency, if it has any
+func (t *serverTimingTracker) record(header, trailer http.Header, latency float64) {
+	values := append(header.Values("Server-Timing"), trailer.Values("Server-Timing")...)
+	if len(values) == 0 {
+		return
+	}
+	timings := parseServerTiming(values)
+	if len(timings) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses++
+	t.client.record(int64(latency * 1000))
+	for _, timing := range timings {
+		if !timing.hasDuration {
+			continue
+		}
+		name := timing.name
+		hist, ok := t.metrics[name]
+		if !ok {
+			if len(t.metrics) >= maxServerTimingMetrics {
+				name = "(other)"
+				hist = t.metrics[name]
+			}
+			if hist == nil {
+				hist = newLatencyHistogram()
+				t.metrics[name] = hist
+			}
+		}
+		if timing.description != "" && t.descriptions[name] == "" && name != "(other)" {
+			t.descriptions[name] = timing.description
+		}
+		hist.record(int64(timing.duration * 1000))
+	}
+}
+
+// summary returns the stats, or nil when no response had Server-Timing
+func (t *serverTimingTracker) summary() *ServerTimingStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.responses == 0 {
+		return nil
+	}
+	stats := &ServerTimingStats{Responses: t.responses, Client: phaseLatency(t.client)}
+	for name, hist := range t.metrics {
+		stats.Metrics = append(stats.Metrics, ServerTimingMetric{
+			Name:        name,
+			Description: t.descriptions[name],
+			Count:       hist.totalCount,
+			Duration:    phaseLatency(hist),
+		})
+	}
+	// Slowest metrics first
+	sort.Slice(stats.Metrics, func(i, j int) bool {
+		a, b := stats.Metrics[i], stats.Metrics[j]
+		if a.Duration.Average != b.Duration.Average {
+			return a.Duration.Average > b.Duration.Average
+		}
+		return a.Name < b.Name
+	})
+	return stats
+}
+
+// displayServerTiming prints the Server-Timing metrics next to the
+// client latency
+func displayServerTiming(result BenchmarkResult) {
+	s := result.ServerTiming
+	if s == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nServer Timing:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	row := func(name, count string, l PhaseLatency) []string {
+		return []string{name, count, formatLatency(l.Average), formatLatency(l.P50), formatLatency(l.P99), formatLatency(l.Max)}
+	}
+	table.Header("Metric", "Responses", "Average", "Median", "Tail", "Max")
+	for _, m := range s.Metrics {
+		name := m.Name
+		if m.Description != "" {
+			name = fmt.Sprintf("%s (%s)", m.Name, m.Description)
+		}
+		table.Append(row(name, fmt.Sprintf("%d", m.Count), m.Duration))
+	}
+	table.Append(row("Client Latency", fmt.Sprintf("%d", s.Responses), s.Client))
+	table.Render()
+	fmt.Println("Tail is the 99th percentile; Client Latency is the latency of the responses with Server-Timing.")
+}
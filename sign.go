@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// requestSigner computes an HMAC over a canonical string of every request
+// and sends it in headers, for APIs with signature schemes of their own
+type requestSigner struct {
+	hash     func() hash.Hash
+	key      []byte
+	encoding string // "hex" or "base64"
+	canon    *template.Template
+	headers  map[string]*template.Template
+	names    []string // of headers, in the order they are set
+}
+
+// signData is the data of the string to sign and header templates. Header
+// returns a request header, and Signature is only set for the header
+// templates.
+type signData struct {
+	Method          string
+	URL             string
+	Host            string
+	Path            string
+	Query           string
+	Body            string
+	BodySHA256      string
+	Timestamp       string // Unix seconds
+	TimestampMillis string
+	Date            string // RFC 3339, UTC
+	Nonce           string
+	Signature       string
+	header          http.Header
+}
+
+func (d signData) Header(name string) string {
+	return d.header.Get(name)
+}
+
+// signHashes are the hash functions of the HMAC
+var signHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// loadSigner reads a -sign file: {"algorithm": "sha1", "sha256",
+// "sha384" or "sha512", "keyFile", "stringToSign", "encoding": "hex" or
+// "base64", "headers": {"Name": template}}. keyFile is relative to the
+// file and holds the key, trailing newlines aside.
+func loadSigner(path string) (*requestSigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Algorithm    string            `json:"algorithm"`
+		KeyFile      string            `json:"keyFile"`
+		StringToSign string            `json:"stringToSign"`
+		Encoding     string            `json:"encoding"`
+		Headers      map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	s := &requestSigner{encoding: file.Encoding, headers: make(map[string]*template.Template)}
+	if file.Algorithm == "" {
+		file.Algorithm = "sha256"
+	}
+	var ok bool
+	if s.hash, ok = signHashes[strings.ToLower(file.Algorithm)]; !ok {
+		return nil, fmt.Errorf("unknown algorithm %q, expected sha1, sha256, sha384 or sha512", file.Algorithm)
+	}
+	if file.KeyFile == "" {
+		return nil, errors.New("keyFile is required")
+	}
+	keyFile := file.KeyFile
+	if !filepath.IsAbs(keyFile) {
+		keyFile = filepath.Join(filepath.Dir(path), keyFile)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if s.key = []byte(strings.TrimRight(string(key), "\r\n")); len(s.key) == 0 {
+		return nil, fmt.Errorf("%s is empty", file.KeyFile)
+	}
+	switch s.encoding {
+	case "":
+		s.encoding = "hex"
+	case "hex", "base64":
+	default:
+		return nil, fmt.Errorf("unknown encoding %q, expected hex or base64", file.Encoding)
+	}
+	if file.StringToSign == "" {
+		return nil, errors.New("stringToSign is required")
+	}
+	if s.canon, err = template.New("stringToSign").Funcs(templateFuncs).Parse(file.StringToSign); err != nil {
+		return nil, fmt.Errorf("stringToSign: %v", err)
+	}
+	if len(file.Headers) == 0 {
+		file.Headers = map[string]string{"X-Signature": "{{.Signature}}"}
+	}
+	for name, text := range file.Headers {
+		if s.headers[name], err = template.New(name).Funcs(templateFuncs).Parse(text); err != nil {
+			return nil, fmt.Errorf("header %q: %v", name, err)
+		}
+		s.names = append(s.names, name)
+	}
+	sort.Strings(s.names)
+
+	// Catch templates that don't render now
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	if err := s.sign(req); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sign sets the signature headers of a request, once every other header
+// is set
+func (s *requestSigner) sign(req *http.Request) error {
+	now := time.Now()
+	data := signData{
+		Method:          req.Method,
+		URL:             req.URL.String(),
+		Host:            req.URL.Host,
+		Path:            req.URL.EscapedPath(),
+		Query:           req.URL.RawQuery,
+		Timestamp:       strconv.FormatInt(now.Unix(), 10),
+		TimestampMillis: strconv.FormatInt(now.UnixMilli(), 10),
+		Date:            now.UTC().Format(time.RFC3339),
+		Nonce:           newUUID(),
+		header:          req.Header,
+	}
+	if req.Host != "" {
+		data.Host = req.Host
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		data.Body = string(content)
+	}
+	digest := sha256.Sum256([]byte(data.Body))
+	data.BodySHA256 = hex.EncodeToString(digest[:])
+
+	var b strings.Builder
+	if err := s.canon.Execute(&b, data); err != nil {
+		return fmt.Errorf("stringToSign: %v", err)
+	}
+	mac := hmac.New(s.hash, s.key)
+	mac.Write([]byte(b.String()))
+	if s.encoding == "base64" {
+		data.Signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		data.Signature = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	values := make([]string, len(s.names))
+	for i, name := range s.names {
+		b.Reset()
+		if err := s.headers[name].Execute(&b, data); err != nil {
+			return fmt.Errorf("header %q: %v", name, err)
+		}
+		values[i] = b.String()
+	}
+	for i, name := range s.names {
+		req.Header.Set(name, values[i])
+	}
+	return nil
+}
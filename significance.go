@@ -0,0 +1,137 @@
+package main
+
+import "math"
+
+// VariabilityStats holds the spread of a run's headline numbers, so two
+// runs can be compared with a significance test: the standard deviation
+// of the latency of all requests, and of the request count of each
+// full second of the run
+type VariabilityStats struct {
+	LatencyStdDev           float64 `json:"latencyStdDevMs"`
+	RequestsPerSecondStdDev float64 `json:"requestsPerSecondStdDev"`
+	Seconds                 int64   `json:"seconds"`
+}
+
+// significance is the outcome of a Welch's t-test on the difference of
+// two means: the difference (after minus before), its 95% confidence
+// interval and the two-sided p-value
+type significance struct {
+	diff, low, high float64
+	p               float64
+}
+
+// significant reports whether the difference is unlikely to be noise
+func (s significance) significant() bool {
+	return s.p < 0.05
+}
+
+// welchTest compares two means given their standard deviations and
+// sample sizes. ok is false when either side has fewer than two samples.
+func welchTest(meanBefore, sdBefore float64, nBefore int64, meanAfter, sdAfter float64, nAfter int64) (s significance, ok bool) {
+	if nBefore < 2 || nAfter < 2 {
+		return s, false
+	}
+	n1, n2 := float64(nBefore), float64(nAfter)
+	v1, v2 := sdBefore*sdBefore/n1, sdAfter*sdAfter/n2
+	s.diff = meanAfter - meanBefore
+	se := math.Sqrt(v1 + v2)
+	if se == 0 {
+		s.low, s.high = s.diff, s.diff
+		s.p = 1
+		if s.diff != 0 {
+			s.p = 0
+		}
+		return s, true
+	}
+
+	// Welch-Satterthwaite degrees of freedom
+	df := (v1 + v2) * (v1 + v2) / (v1*v1/(n1-1) + v2*v2/(n2-1))
+	t := s.diff / se
+	s.p = 2 * studentTTail(math.Abs(t), df)
+	margin := studentTQuantile975(df) * se
+	s.low, s.high = s.diff-margin, s.diff+margin
+	return s, true
+}
+
+// studentTTail returns P(T > t) for Student's t distribution with df
+// degrees of freedom and t >= 0
+func studentTTail(t, df float64) float64 {
+	return 0.5 * regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// studentTQuantile975 returns the 97.5th percentile of Student's t
+// distribution, the multiplier of a two-sided 95% confidence interval
+func studentTQuantile975(df float64) float64 {
+	// Bisect the tail probability, which falls as t grows
+	low, high := 0.0, 1000.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if studentTTail(mid, df) > 0.025 {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// regularizedIncompleteBeta evaluates I_x(a, b) with the continued
+// fraction from Numerical Recipes
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		// Even step
+		num := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// Odd step
+		num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 3e-14 {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestStudentTQuantile975 checks the multiplier against the two-sided 95%
+// column of the t table
+func TestStudentTQuantile975(t *testing.T) {
+	tests := []struct {
+		df, want float64
+	}{
+		{1, 12.7062},
+		{2, 4.3027},
+		{5, 2.5706},
+		{10, 2.2281},
+		{18, 2.1009},
+		{30, 2.0423},
+		{120, 1.9799},
+		{1e6, 1.9600},
+	}
+	for _, tt := range tests {
+		if got := studentTQuantile975(tt.df); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("studentTQuantile975(%g) = %.5f, want %.4f", tt.df, got, tt.want)
+		}
+		if p := studentTTail(tt.want, tt.df); math.Abs(p-0.025) > 1e-5 {
+			t.Errorf("studentTTail(%g, %g) = %.6f, want 0.025", tt.want, tt.df, p)
+		}
+	}
+	if p := studentTTail(0, 7); math.Abs(p-0.5) > 1e-12 {
+		t.Errorf("studentTTail(0, 7) = %g, want 0.5", p)
+	}
+}
+
+// TestRegularizedIncompleteBeta checks the closed forms of I_x(a, b)
+func TestRegularizedIncompleteBeta(t *testing.T) {
+	tests := []struct {
+		a, b, x, want float64
+	}{
+		{1, 1, 0.3, 0.3},
+		{3, 1, 0.5, 0.125},
+		{1, 4, 0.2, 1 - math.Pow(0.8, 4)},
+		{2.5, 2.5, 0.5, 0.5},
+		{10, 10, 0.5, 0.5},
+		{2, 3, 0, 0},
+		{2, 3, 1, 1},
+		// I_x(2, 2) = 3x² - 2x³
+		{2, 2, 0.9, 3*0.81 - 2*0.729},
+	}
+	for _, tt := range tests {
+		if got := regularizedIncompleteBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > 1e-10 {
+			t.Errorf("I_%g(%g, %g) = %.12f, want %.12f", tt.x, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestWelchTest checks the p-value and confidence interval of Welch's
+// t-test
+func TestWelchTest(t *testing.T) {
+	// Equal sizes and deviations: 18 degrees of freedom, and a difference
+	// of exactly the table's t times the standard error is just
+	// significant at 5%
+	se := math.Sqrt(0.2)
+	s, ok := welchTest(10, 1, 10, 10+2.1009*se, 1, 10)
+	if !ok {
+		t.Fatal("welchTest was not ok")
+	}
+	if math.Abs(s.p-0.05) > 1e-4 || math.Abs(s.low) > 1e-4 {
+		t.Errorf("got p %.5f and low %.5f, want 0.05 and 0", s.p, s.low)
+	}
+
+	// Unequal deviations: t = 1.46385 on 12.035 degrees of freedom
+	s, ok = welchTest(20, 2, 15, 22, 4, 10)
+	if !ok {
+		t.Fatal("welchTest was not ok")
+	}
+	if s.diff != 2 || math.Abs(s.p-0.16886) > 1e-4 || s.significant() {
+		t.Errorf("got diff %g and p %.5f, want 2 and 0.16886, not significant", s.diff, s.p)
+	}
+	margin := studentTQuantile975(12.0351) * math.Sqrt(4.0/15+16.0/10)
+	if math.Abs(s.low-(2-margin)) > 1e-3 || math.Abs(s.high-(2+margin)) > 1e-3 {
+		t.Errorf("got interval %.4f to %.4f, want %.4f to %.4f", s.low, s.high, 2-margin, 2+margin)
+	}
+
+	if s, _ := welchTest(5, 0, 10, 6, 0, 10); s.p != 0 || !s.significant() {
+		t.Errorf("a difference without variance has p %g, want 0", s.p)
+	}
+	if s, _ := welchTest(5, 0, 10, 5, 0, 10); s.p != 1 {
+		t.Errorf("equal means without variance have p %g, want 1", s.p)
+	}
+	if _, ok := welchTest(5, 1, 1, 6, 1, 10); ok {
+		t.Error("welchTest of a single sample was ok")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Sink is where results go: it receives every request outcome and then
+// the final result. The console tables, JSON output, result files, the
+// store and the per-request exports are all sinks; set
+// BenchmarkConfig.Sinks to add your own.
+//
+// Record is called from the request goroutines and must be safe for
+// concurrent use. Flush is called once after the run, in the order the
+// sinks were configured. Flush errors are printed as "Error <err>", so
+// they should say what failed, e.g. "writing results file: ...".
+type Sink interface {
+	Record(Sample)
+	Flush(BenchmarkResult) error
+}
+
+// sinkHandler delivers the run's events to a Sink
+type sinkHandler struct {
+	NopEventHandler
+	sink Sink
+}
+
+func (h sinkHandler) OnRequestDone(s Sample) {
+	h.sink.Record(s)
+}
+
+func (h sinkHandler) OnFinish(result BenchmarkResult) {
+	if err := h.sink.Flush(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+	}
+}
+
+// consoleSink prints the result tables
+type consoleSink struct {
+	showConnections bool
+}
+
+func (consoleSink) Record(Sample) {}
+
+func (s consoleSink) Flush(result BenchmarkResult) error {
+	displayResults(result)
+	displayTrimmedStats(result)
+	displayResponseSize(result)
+	displayPhaseStats(result)
+	displayChunkStats(result)
+	displayLLMStats(result)
+	displayFieldStats(result)
+	displayServerTiming(result)
+	displayThrottleStats(result)
+	displayRateLimitStats(result)
+	displayAdaptiveStats(result)
+	displayLongPollStats(result)
+	displayTLSInfo(result)
+	displayHeaderStats(result)
+	displayCDNStats(result)
+	displayIdentityStats(result)
+	displayClientIdentities(result)
+	displayProxyStats(result)
+	if s.showConnections {
+		displayConnectionStats(result)
+	}
+	displayValidationStats(result)
+	displayScenarioStats(result)
+	displayCheckStats(result)
+	displayOperationStats(result)
+	displayABStats(result)
+	displayTraceExemplars(result)
+	return nil
+}
+
+// quietSink prints the single-line summary of -quiet
+type quietSink struct{}
+
+func (quietSink) Record(Sample) {}
+
+func (quietSink) Flush(result BenchmarkResult) error {
+	printQuietSummary(result)
+	return nil
+}
+
+// jsonSink prints the result JSON to stdout
+type jsonSink struct {
+	format string
+}
+
+func (jsonSink) Record(Sample) {}
+
+func (s jsonSink) Flush(result BenchmarkResult) error {
+	jsonData, err := marshalResult(result, s.format)
+	if err != nil {
+		return fmt.Errorf("marshaling results to JSON: %v", err)
+	}
+	fmt.Println(string(jsonData))
+	return nil
+}
+
+// fileSink writes the result JSON to a file. Its status message goes to
+// stderr so stdout stays parseable in -json and -quiet modes.
+type fileSink struct {
+	path   string
+	format string
+}
+
+func (fileSink) Record(Sample) {}
+
+func (s fileSink) Flush(result BenchmarkResult) error {
+	jsonData, err := marshalResult(result, s.format)
+	if err != nil {
+		return fmt.Errorf("marshaling results to JSON: %v", err)
+	}
+	if err := os.WriteFile(s.path, jsonData, 0644); err != nil {
+		return fmt.Errorf("writing results to file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Results written to %s\n", s.path)
+	return nil
+}
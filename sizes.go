@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// sizeBucketBounds are the upper bounds of the response size histogram
+var sizeBucketBounds = []int64{0, 1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20, 100 << 20}
+
+// ResponseSizeStats describes the sizes of the response bodies read
+// during a run, in bytes. Histogram counts the bodies per size bucket;
+// buckets without responses are left out.
+type ResponseSizeStats struct {
+	Count     int64        `json:"count"`
+	Min       int64        `json:"min"`
+	Mean      float64      `json:"mean"`
+	Max       int64        `json:"max"`
+	P50       int64        `json:"p50"`
+	P90       int64        `json:"p90"`
+	P99       int64        `json:"p99"`
+	Histogram []SizeBucket `json:"histogram"`
+}
+
+// SizeBucket counts the responses no larger than UpTo bytes and larger
+// than the previous bucket's bound. UpTo is -1 for the last bucket.
+type SizeBucket struct {
+	UpTo  int64 `json:"upTo"`
+	Count int64 `json:"count"`
+}
+
+// sizeTracker collects ResponseSizeStats from concurrent workers
+type sizeTracker struct {
+	mu      sync.Mutex
+	hist    *histogram
+	total   int64
+	buckets []int64
+}
+
+func newSizeTracker() *sizeTracker {
+	return &sizeTracker{hist: newCountHistogram(), buckets: make([]int64, len(sizeBucketBounds)+1)}
+}
+
+func (t *sizeTracker) record(size int64) {
+	bucket := len(sizeBucketBounds)
+	for i, bound := range sizeBucketBounds {
+		if size <= bound {
+			bucket = i
+			break
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hist.record(size)
+	t.total += size
+	t.buckets[bucket]++
+}
+
+func (t *sizeTracker) summary() *ResponseSizeStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hist.totalCount == 0 {
+		return nil
+	}
+	stats := &ResponseSizeStats{
+		Count: t.hist.totalCount,
+		Min:   t.hist.min,
+		Mean:  float64(t.total) / float64(t.hist.totalCount),
+		Max:   t.hist.max,
+		P50:   t.hist.valueAtPercentile(50),
+		P90:   t.hist.valueAtPercentile(90),
+		P99:   t.hist.valueAtPercentile(99),
+	}
+	for i, count := range t.buckets {
+		if count == 0 {
+			continue
+		}
+		upTo := int64(-1)
+		if i < len(sizeBucketBounds) {
+			upTo = sizeBucketBounds[i]
+		}
+		stats.Histogram = append(stats.Histogram, SizeBucket{UpTo: upTo, Count: count})
+	}
+	return stats
+}
+
+// formatSize formats a byte count with a binary unit
+func formatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.2f GiB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// formatRate formats a transfer rate, in the units of formatSize
+func formatRate(bytes int64, seconds float64) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	return formatSize(int64(float64(bytes)/seconds)) + "/s"
+}
+
+// seconds returns how long the run took. Results read from a file only
+// have the rounded duration.
+func (r BenchmarkResult) seconds() float64 {
+	if r.elapsed > 0 {
+		return r.elapsed.Seconds()
+	}
+	return float64(r.Duration)
+}
+
+// displayResponseSize prints the response size distribution when the
+// sizes varied
+func displayResponseSize(result BenchmarkResult) {
+	stats := result.ResponseSize
+	if stats == nil || stats.Min == stats.Max {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nResponse Sizes:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Size", "Count", "Percentage")
+	for _, bucket := range stats.Histogram {
+		label := "≤ " + formatSize(bucket.UpTo)
+		if bucket.UpTo == -1 {
+			label = "> " + formatSize(sizeBucketBounds[len(sizeBucketBounds)-1])
+		} else if bucket.UpTo == 0 {
+			label = "0 B"
+		}
+		table.Append([]string{
+			label,
+			fmt.Sprintf("%d", bucket.Count),
+			fmt.Sprintf("%.2f%%", float64(bucket.Count)/float64(stats.Count)*100),
+		})
+	}
+	table.Render()
+	fmt.Printf("Min %s, mean %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		formatSize(stats.Min), formatSize(int64(stats.Mean)), formatSize(stats.P50),
+		formatSize(stats.P90), formatSize(stats.P99), formatSize(stats.Max))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 (RFC 1928) methods, commands and address types, and the
+// username/password subnegotiation of RFC 1929
+const (
+	socksVersion      = 0x05
+	socksNoAuth       = 0x00
+	socksPassword     = 0x02
+	socksNoAcceptable = 0xff
+	socksConnectCmd   = 0x01
+	socksIPv4         = 0x01
+	socksDomain       = 0x03
+	socksIPv6         = 0x04
+	socksAuthVersion  = 0x01
+)
+
+// socksReplies are the SOCKS5 reply codes that refuse a connection
+var socksReplies = map[byte]string{
+	0x01: "general failure",
+	0x02: "not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks reports whether the proxy is a SOCKS5 server
+func (t *proxyTunnel) socks() bool {
+	return t.proxy.Scheme == "socks5" || t.proxy.Scheme == "socks5h"
+}
+
+// socksConnect negotiates a SOCKS5 connection to addr, authenticating
+// with the proxy URL's user info when it has one. With socks5:// the
+// target's name is resolved locally, with socks5h:// by the server. A
+// refusal returns the server's reply.
+func (t *proxyTunnel) socksConnect(ctx context.Context, conn net.Conn, addr string) (*bufio.Reader, string, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, "", err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, "", err
+	}
+	request := []byte{socksVersion, socksConnectCmd, 0}
+	ip := net.ParseIP(host)
+	if ip == nil && t.proxy.Scheme == "socks5" {
+		ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, "", err
+		}
+		ip = net.IP(ips[0].Unmap().AsSlice())
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return nil, "", fmt.Errorf("host name %q is too long for SOCKS5", host)
+		}
+		request = append(request, socksDomain, byte(len(host)))
+		request = append(request, host...)
+	case ip.To4() != nil:
+		request = append(request, socksIPv4)
+		request = append(request, ip.To4()...)
+	default:
+		request = append(request, socksIPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+
+	methods := []byte{socksVersion, 1, socksNoAuth}
+	user := t.proxy.User
+	if user != nil {
+		methods = []byte{socksVersion, 2, socksNoAuth, socksPassword}
+	}
+	if _, err := conn.Write(methods); err != nil {
+		return nil, "", fmt.Errorf("SOCKS5 greeting: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(reader, reply); err != nil {
+		return nil, "", fmt.Errorf("SOCKS5 greeting: %v", err)
+	}
+	if reply[0] != socksVersion {
+		return nil, "", fmt.Errorf("SOCKS5 greeting: unexpected version %d", reply[0])
+	}
+	switch reply[1] {
+	case socksNoAuth:
+	case socksPassword:
+		if user == nil {
+			return nil, "authentication required", errors.New("SOCKS5 server requires a user and password")
+		}
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return nil, "", errors.New("SOCKS5 user and password are limited to 255 bytes")
+		}
+		auth := []byte{socksAuthVersion, byte(len(user.Username()))}
+		auth = append(auth, user.Username()...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return nil, "", fmt.Errorf("SOCKS5 authentication: %v", err)
+		}
+		if _, err := io.ReadFull(reader, reply); err != nil {
+			return nil, "", fmt.Errorf("SOCKS5 authentication: %v", err)
+		}
+		if reply[1] != 0 {
+			return nil, "authentication failed", errors.New("SOCKS5 authentication failed")
+		}
+	case socksNoAcceptable:
+		return nil, "no acceptable methods", errors.New("SOCKS5 server accepts none of the authentication methods offered")
+	default:
+		return nil, "", fmt.Errorf("SOCKS5 server chose unknown method %d", reply[1])
+	}
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, "", fmt.Errorf("SOCKS5 connect: %v", err)
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, "", fmt.Errorf("SOCKS5 connect: %v", err)
+	}
+	if header[1] != 0 {
+		reason, ok := socksReplies[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("reply %d", header[1])
+		}
+		return nil, reason, fmt.Errorf("SOCKS5 server refused connection to %s: %s", addr, reason)
+	}
+	// The bound address, which is of no use here
+	var skip int
+	switch header[3] {
+	case socksIPv4:
+		skip = net.IPv4len
+	case socksIPv6:
+		skip = net.IPv6len
+	case socksDomain:
+		n, err := reader.ReadByte()
+		if err != nil {
+			return nil, "", fmt.Errorf("SOCKS5 connect: %v", err)
+		}
+		skip = int(n)
+	default:
+		return nil, "", fmt.Errorf("SOCKS5 connect: unknown address type %d", header[3])
+	}
+	if _, err := reader.Discard(skip + 2); err != nil {
+		return nil, "", fmt.Errorf("SOCKS5 connect: %v", err)
+	}
+	return reader, "", nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import "sync"
+
+// LatencySummary is the JSON form of a latencyTracker
+type LatencySummary struct {
+	Count   int64   `json:"count"`
+	Average float64 `json:"averageMs"`
+	Min     float64 `json:"minMs"`
+	Max     float64 `json:"maxMs"`
+}
+
+// latencyTracker keeps a concurrency-safe count/min/max/mean of latencies
+// in milliseconds, for secondary timings measured inside the workers
+type latencyTracker struct {
+	mu    sync.Mutex
+	count int64
+	total float64
+	min   float64
+	max   float64
+}
+
+func (t *latencyTracker) record(ms float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.count == 0 || ms < t.min {
+		t.min = ms
+	}
+	if ms > t.max {
+		t.max = ms
+	}
+	t.count++
+	t.total += ms
+}
+
+func (t *latencyTracker) summary() LatencySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := LatencySummary{Count: t.count, Min: t.min, Max: t.max}
+	if t.count > 0 {
+		s.Average = t.total / float64(t.count)
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statsdPacketSize keeps StatsD packets within a typical Ethernet MTU
+const statsdPacketSize = 1432
+
+// statsdSink sends every request to a StatsD server over UDP, as the
+// <prefix>.latency timer (ms) and the <prefix>.requests, <prefix>.errors
+// and <prefix>.status.<code> counters. Metrics are batched into packets
+// that are sent when full, or at most a second after the last one. It is
+// safe for concurrent use.
+type statsdSink struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	prefix string
+	buf    []byte
+	sent   time.Time
+}
+
+func newStatsDSink(addr, prefix string) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdSink{conn: conn, prefix: prefix, buf: make([]byte, 0, statsdPacketSize), sent: time.Now()}, nil
+}
+
+func (s *statsdSink) Record(sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add("requests:1|c")
+	s.add("latency:" + strconv.FormatFloat(sample.Latency, 'f', 3, 64) + "|ms")
+	if sample.Status > 0 {
+		s.add("status." + strconv.Itoa(sample.Status) + ":1|c")
+	}
+	if sample.ErrorClass != "" {
+		s.add("errors:1|c")
+	}
+	if time.Since(s.sent) >= time.Second {
+		s.send()
+	}
+}
+
+// add appends a metric line to the current packet, sending it first if
+// the line does not fit
+func (s *statsdSink) add(metric string) {
+	size := len(s.prefix) + 1 + len(metric)
+	if len(s.buf) > 0 && len(s.buf)+1+size > statsdPacketSize {
+		s.send()
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, s.prefix...)
+	s.buf = append(s.buf, '.')
+	s.buf = append(s.buf, metric...)
+}
+
+// send writes the current packet. Write errors are ignored: StatsD is
+// lossy by design and a missing metric must not fail the run.
+func (s *statsdSink) send() {
+	if len(s.buf) > 0 {
+		s.conn.Write(s.buf)
+		s.buf = s.buf[:0]
+	}
+	s.sent = time.Now()
+}
+
+func (s *statsdSink) Flush(BenchmarkResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.send()
+	if err := s.conn.Close(); err != nil {
+		return fmt.Errorf("closing StatsD connection: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+	_ "modernc.org/sqlite"
+)
+
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp         TEXT    NOT NULL,
+	uri               TEXT    NOT NULL,
+	method            TEXT    NOT NULL,
+	connections       INTEGER NOT NULL,
+	duration_seconds  INTEGER NOT NULL,
+	total_requests    INTEGER NOT NULL,
+	requests_per_sec  REAL    NOT NULL,
+	avg_latency_ms    REAL    NOT NULL,
+	max_latency_ms    REAL    NOT NULL,
+	error_rate        REAL    NOT NULL,
+	result            TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_uri ON runs (uri, timestamp);
+`
+
+// openStore opens (creating if needed) the SQLite results store at path
+func openStore(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// storeSink appends the result to the SQLite store. The summary columns
+// are kept for filtering and sorting; the full result is stored as JSON
+// so nothing is lost as BenchmarkResult grows.
+type storeSink struct {
+	path string
+}
+
+func (storeSink) Record(Sample) {}
+
+func (s storeSink) Flush(result BenchmarkResult) error {
+	db, err := openStore(s.path)
+	if err != nil {
+		return fmt.Errorf("opening results store: %v", err)
+	}
+	defer db.Close()
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshaling results to JSON: %v", err)
+	}
+
+	res, err := db.Exec(`INSERT INTO runs (timestamp, uri, method, connections, duration_seconds,
+		total_requests, requests_per_sec, avg_latency_ms, max_latency_ms, error_rate, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.Timestamp.UTC().Format(time.RFC3339Nano), result.URI, result.Method,
+		result.Connections, result.Duration, result.Requests.Total, result.Throughput.RequestsPerSecond,
+		result.Latency.Average, result.Latency.Max, result.Requests.ErrorRate, string(jsonData))
+	if err != nil {
+		return fmt.Errorf("writing results to store: %v", err)
+	}
+
+	id, _ := res.LastInsertId()
+	fmt.Fprintf(os.Stderr, "Results stored in %s as run #%d\n", s.path, id)
+	return nil
+}
+
+// storedRun is a single row returned by the query subcommand
+type storedRun struct {
+	ID             int64
+	Timestamp      string
+	URI            string
+	Method         string
+	Connections    int
+	TotalRequests  int64
+	RequestsPerSec float64
+	AverageLatency float64
+	MaxLatency     float64
+	ErrorRate      float64
+	Tags           map[string]string
+	Result         string
+}
+
+// runQuery implements the "query" subcommand, listing stored runs
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	store := fs.String("store", "", "The SQLite results store to read. (Required)")
+	target := fs.String("target", "", "Only show runs against this uri")
+	tags := tagFlag{}
+	fs.Var(tags, "tag", "Only show runs with this key=value tag (repeatable)")
+	limit := fs.Int("limit", 20, "The maximum number of runs to show")
+	trend := fs.Bool("trend", false, "Show the change in throughput and latency relative to the previous run of the same uri")
+	show := fs.Int64("show", 0, "Print the full stored JSON result of the run with this id")
+	latencyUnitFlag := fs.String("latency-unit", "ms", "Show latencies in us, ms or s")
+	precision := fs.Int("precision", 2, "Show latencies with this many decimals")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	fs.Parse(args)
+
+	configureColor(*noColor)
+
+	if err := configureLatencyFormat(*latencyUnitFlag, *precision); err != nil {
+		fmt.Printf("Invalid latency format: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if *store == "" {
+		fmt.Println("You must provide a results store to query.")
+		fs.Usage()
+		os.Exit(exitConfigError)
+	}
+
+	db, err := openStore(*store)
+	if err != nil {
+		fmt.Printf("Error opening results store: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	defer db.Close()
+
+	if *show != 0 {
+		var raw string
+		err := db.QueryRow(`SELECT result FROM runs WHERE id = ?`, *show).Scan(&raw)
+		if err != nil {
+			fmt.Printf("Error reading run #%d: %v\n", *show, err)
+			os.Exit(exitConfigError)
+		}
+		// Runs stored by older versions are shown in the current schema
+		result, err := decodeResult([]byte(raw))
+		if err != nil {
+			fmt.Printf("Error decoding run #%d: %v\n", *show, err)
+			os.Exit(exitConfigError)
+		}
+		jsonData, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	runs, err := queryRuns(db, *target, tags, *limit)
+	if err != nil {
+		fmt.Printf("Error querying results store: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	if len(runs) == 0 {
+		fmt.Println("No stored runs match.")
+		return
+	}
+
+	displayRuns(runs, *trend)
+}
+
+// queryRuns returns up to limit runs matching the target and every tag,
+// oldest first
+func queryRuns(db *sql.DB, target string, tags map[string]string, limit int) ([]storedRun, error) {
+	query := `SELECT id, timestamp, uri, method, connections, total_requests,
+		requests_per_sec, avg_latency_ms, max_latency_ms, error_rate, result FROM runs`
+	var conditions []string
+	var args []interface{}
+	if target != "" {
+		conditions = append(conditions, `uri = ?`)
+		args = append(args, target)
+	}
+	for key, value := range tags {
+		conditions = append(conditions, `json_extract(result, '$.tags.' || json_quote(?)) = ?`)
+		args = append(args, key, value)
+	}
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []storedRun
+	for rows.Next() {
+		var r storedRun
+		if err := rows.Scan(&r.ID, &r.Timestamp, &r.URI, &r.Method, &r.Connections, &r.TotalRequests,
+			&r.RequestsPerSec, &r.AverageLatency, &r.MaxLatency, &r.ErrorRate, &r.Result); err != nil {
+			return nil, err
+		}
+		var stored BenchmarkResult
+		if err := json.Unmarshal([]byte(r.Result), &stored); err == nil {
+			r.Tags = stored.Tags
+		}
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reverse so trends read top to bottom
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs, nil
+}
+
+func displayRuns(runs []storedRun, trend bool) {
+	fmt.Println(colorize(chalk.Green, "\nStored Runs:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	showTags := false
+	for _, r := range runs {
+		if len(r.Tags) > 0 {
+			showTags = true
+			break
+		}
+	}
+
+	header := []string{"ID", "Timestamp", "Method", "URI", "Conns", "Requests", "Requests/sec", "Avg Latency", "Max Latency", "Error Rate"}
+	if showTags {
+		header = append(header, "Tags")
+	}
+	if trend {
+		header = append(header, "Δ Req/sec", "Δ Avg Latency")
+	}
+	table.Header(header)
+
+	// Previous run per uri, for trend deltas
+	previous := make(map[string]storedRun)
+
+	for _, r := range runs {
+		row := []string{
+			fmt.Sprintf("%d", r.ID),
+			formatStoredTimestamp(r.Timestamp),
+			r.Method,
+			r.URI,
+			fmt.Sprintf("%d", r.Connections),
+			fmt.Sprintf("%d", r.TotalRequests),
+			fmt.Sprintf("%.2f", r.RequestsPerSec),
+			formatLatency(r.AverageLatency),
+			formatLatency(r.MaxLatency),
+			fmt.Sprintf("%.2f%%", r.ErrorRate),
+		}
+		if showTags {
+			row = append(row, formatTags(r.Tags))
+		}
+		if trend {
+			if prev, ok := previous[r.URI]; ok {
+				row = append(row, percentChange(prev.RequestsPerSec, r.RequestsPerSec), percentChange(prev.AverageLatency, r.AverageLatency))
+			} else {
+				row = append(row, "-", "-")
+			}
+			previous[r.URI] = r
+		}
+		table.Append(row)
+	}
+
+	table.Render()
+}
+
+// formatStoredTimestamp shortens a stored RFC3339 timestamp for display
+func formatStoredTimestamp(ts string) string {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return ts
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// percentChange formats the relative change from before to after
+func percentChange(before, after float64) string {
+	if before == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f%%", (after-before)/before*100)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// loadTargets reads a vegeta target file. The http format lists each
+// target as "METHOD URL", followed by optional "Header: value" lines and
+// an "@path" body line, with targets separated by blank lines. The json
+// format has one {"method","url","body","header"} object per line, with
+// the body base64 encoded.
+func loadTargets(path, format string) ([]requestSpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []requestSpec
+	switch format {
+	case "http":
+		targets, err = parseHTTPTargets(file.Name(), bufio.NewScanner(file))
+	case "json":
+		targets, err = parseJSONTargets(bufio.NewScanner(file))
+	default:
+		return nil, fmt.Errorf("unknown targets format %q, expected http or json", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s contains no targets", path)
+	}
+	return targets, nil
+}
+
+func parseHTTPTargets(name string, lines *bufio.Scanner) ([]requestSpec, error) {
+	var targets []requestSpec
+	var current *requestSpec
+	lineNo := 0
+	for lines.Scan() {
+		lineNo++
+		line := strings.TrimSpace(lines.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case current == nil || isTargetLine(line):
+			method, url, ok := strings.Cut(line, " ")
+			if !ok || !isTargetLine(line) {
+				return nil, fmt.Errorf("%s:%d: expected \"METHOD URL\", got %q", name, lineNo, line)
+			}
+			targets = append(targets, requestSpec{Method: method, URL: strings.TrimSpace(url), Header: http.Header{}})
+			current = &targets[len(targets)-1]
+		case strings.HasPrefix(line, "@"):
+			body, err := os.ReadFile(strings.TrimPrefix(line, "@"))
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, lineNo, err)
+			}
+			current.Body = string(body)
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: expected a header, got %q", name, lineNo, line)
+			}
+			current.Header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	return targets, lines.Err()
+}
+
+// isTargetLine reports whether line starts a new target, e.g. "GET http://..."
+func isTargetLine(line string) bool {
+	method, url, ok := strings.Cut(line, " ")
+	if !ok || method == "" || strings.ToUpper(method) != method || strings.HasSuffix(method, ":") {
+		return false
+	}
+	url = strings.TrimSpace(url)
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// jsonTarget is vegeta's JSON target format
+type jsonTarget struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Body   []byte      `json:"body"`
+	Header http.Header `json:"header"`
+}
+
+func parseJSONTargets(lines *bufio.Scanner) ([]requestSpec, error) {
+	lines.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var targets []requestSpec
+	lineNo := 0
+	for lines.Scan() {
+		lineNo++
+		if strings.TrimSpace(lines.Text()) == "" {
+			continue
+		}
+		var t jsonTarget
+		if err := json.Unmarshal(lines.Bytes(), &t); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		if t.Method == "" {
+			t.Method = "GET"
+		}
+		targets = append(targets, requestSpec{Method: t.Method, URL: t.URL, Body: string(t.Body), Header: t.Header})
+	}
+	return targets, lines.Err()
+}
+
+// vegetaResult is one line of vegeta's JSON results encoding
+type vegetaResult struct {
+	Attack    string      `json:"attack"`
+	Seq       uint64      `json:"seq"`
+	Code      int         `json:"code"`
+	Timestamp time.Time   `json:"timestamp"`
+	Latency   int64       `json:"latency"`
+	BytesOut  int64       `json:"bytes_out"`
+	BytesIn   int64       `json:"bytes_in"`
+	Error     string      `json:"error"`
+	Body      []byte      `json:"body"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Headers   http.Header `json:"headers"`
+}
+
+// vegetaEncoder writes request outcomes in vegeta's JSON encoding, so they
+// can be fed to `vegeta report` or `vegeta plot`. It is safe for
+// concurrent use.
+type vegetaEncoder struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	seq  uint64
+	err  error
+}
+
+func newVegetaEncoder(path string) (*vegetaEncoder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	return &vegetaEncoder{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (e *vegetaEncoder) Record(s Sample) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return
+	}
+	e.err = e.enc.Encode(vegetaResult{
+		Seq:       e.seq,
+		Code:      s.Status,
+		Timestamp: s.Start,
+		Latency:   int64(s.Latency * float64(time.Millisecond)),
+		BytesOut:  s.BytesOut,
+		BytesIn:   s.Bytes,
+		Error:     s.Error,
+		Method:    s.Method,
+		URL:       s.URL,
+	})
+	e.seq++
+}
+
+func (e *vegetaEncoder) Flush(BenchmarkResult) error {
+	if err := e.Close(); err != nil {
+		return fmt.Errorf("writing vegeta results file: %v", err)
+	}
+	return nil
+}
+
+// Close flushes all buffered results and returns the first write error
+func (e *vegetaEncoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.buf.Flush(); err != nil && e.err == nil {
+		e.err = err
+	}
+	if err := e.file.Close(); err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// ThrottleStats describes the 429 Too Many Requests responses of -throttle
+// runs, which count as throttled rather than successful or failed.
+// Allowed responses are the others: AllowedPerSecond is the throughput the
+// rate limiter let through, and AllowedBefore the responses before the
+// first 429, its burst. RetryAfter counts the 429s with a Retry-After, and
+// with -retry-after connections waited that long Waits times.
+type ThrottleStats struct {
+	Throttled          int64   `json:"throttled"`
+	ThrottleRate       float64 `json:"throttleRate"`
+	AllowedPerSecond   float64 `json:"allowedPerSecond"`
+	ThrottledPerSecond float64 `json:"throttledPerSecond"`
+	FirstThrottled     float64 `json:"firstThrottledSeconds,omitempty"`
+	AllowedBefore      int64   `json:"allowedBeforeFirst"`
+	RetryAfter         int64   `json:"retryAfter"`
+	RetryAfterAverage  float64 `json:"retryAfterAverageSeconds"`
+	RetryAfterMax      float64 `json:"retryAfterMaxSeconds"`
+	Honored            bool    `json:"honored"`
+	Waits              int64   `json:"waits"`
+	WaitSeconds        float64 `json:"waitSeconds"`
+}
+
+// throttleTracker collects ThrottleStats from concurrent workers
+type throttleTracker struct {
+	honor bool
+
+	mu            sync.Mutex
+	responses     int64
+	throttled     int64
+	first         time.Time
+	allowedBefore int64
+	retryAfter    int64
+	retryAfterSum time.Duration
+	retryAfterMax time.Duration
+	waits         int64
+	waited        time.Duration
+}
+
+func newThrottleTracker(honor bool) *throttleTracker {
+	return &throttleTracker{honor: honor}
+}
+
+// parseRetryAfter parses a Retry-After value, delay seconds or an HTTP
+// date, as the time to wait from now
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
+
+// record adds a response, and returns how long the connection should wait
+// before its next request: the Retry-After of a 429 when honored
+func (t *throttleTracker) record(resp *http.Response) time.Duration {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses++
+	if resp.StatusCode != http.StatusTooManyRequests {
+		if t.first.IsZero() {
+			t.allowedBefore++
+		}
+		return 0
+	}
+	t.throttled++
+	if t.first.IsZero() {
+		t.first = now
+	}
+	wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	if !ok {
+		return 0
+	}
+	t.retryAfter++
+	t.retryAfterSum += wait
+	t.retryAfterMax = max(t.retryAfterMax, wait)
+	if !t.honor {
+		return 0
+	}
+	return wait
+}
+
+// recordWait adds a wait of a connection for a Retry-After
+func (t *throttleTracker) recordWait(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.waits++
+	t.waited += d
+}
+
+// summary returns the stats of a run that started at start and lasted
+// elapsed
+func (t *throttleTracker) summary(start time.Time, elapsed time.Duration) *ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &ThrottleStats{
+		Throttled:     t.throttled,
+		AllowedBefore: t.allowedBefore,
+		RetryAfter:    t.retryAfter,
+		RetryAfterMax: t.retryAfterMax.Seconds(),
+		Honored:       t.honor,
+		Waits:         t.waits,
+		WaitSeconds:   t.waited.Seconds(),
+	}
+	if t.responses > 0 {
+		stats.ThrottleRate = float64(t.throttled) / float64(t.responses) * 100
+	}
+	if elapsed > 0 {
+		stats.AllowedPerSecond = float64(t.responses-t.throttled) / elapsed.Seconds()
+		stats.ThrottledPerSecond = float64(t.throttled) / elapsed.Seconds()
+	}
+	if !t.first.IsZero() {
+		stats.FirstThrottled = max(t.first.Sub(start).Seconds(), 0)
+	}
+	if t.retryAfter > 0 {
+		stats.RetryAfterAverage = t.retryAfterSum.Seconds() / float64(t.retryAfter)
+	}
+	return stats
+}
+
+// displayThrottleStats prints the -throttle table
+func displayThrottleStats(result BenchmarkResult) {
+	s := result.Throttle
+	if s == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nRate Limiting:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Metric", "Value")
+	table.Append([]string{"Throttled (429)", fmt.Sprintf("%d (%.2f%%)", s.Throttled, s.ThrottleRate)})
+	table.Append([]string{"Allowed Requests/sec", fmt.Sprintf("%.2f", s.AllowedPerSecond)})
+	table.Append([]string{"Throttled Requests/sec", fmt.Sprintf("%.2f", s.ThrottledPerSecond)})
+	if s.Throttled > 0 {
+		table.Append([]string{"First Throttled", fmt.Sprintf("after %.2f s and %d allowed responses", s.FirstThrottled, s.AllowedBefore)})
+		retryAfter := "none"
+		if s.RetryAfter > 0 {
+			retryAfter = fmt.Sprintf("%d, %.2f s avg, %.2f s max", s.RetryAfter, s.RetryAfterAverage, s.RetryAfterMax)
+		}
+		table.Append([]string{"With Retry-After", retryAfter})
+	}
+	if s.Honored {
+		table.Append([]string{"Retry-After Waits", fmt.Sprintf("%d, %.2f s in all", s.Waits, s.WaitSeconds)})
+	}
+	table.Render()
+}
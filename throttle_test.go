@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestParseRetryAfter checks both forms of RFC 9110 section 10.2.3, with
+// the HTTP dates in each format http.ParseTime accepts
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(1999, 12, 31, 23, 58, 59, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"1.5", 1500 * time.Millisecond, true},
+		{"Fri, 31 Dec 1999 23:59:59 GMT", time.Minute, true},
+		{"Friday, 31-Dec-99 23:59:59 GMT", time.Minute, true},
+		{"Fri Dec 31 23:59:59 1999", time.Minute, true},
+		// A date in the past means now
+		{"Fri, 31 Dec 1999 23:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestThrottleTracker counts 429s and their Retry-After, and returns the
+// wait only when honored
+func TestThrottleTracker(t *testing.T) {
+	response := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+	for _, honor := range []bool{false, true} {
+		tracker := newThrottleTracker(honor)
+		start := time.Now()
+		var waits []time.Duration
+		for _, resp := range []*http.Response{
+			response(200, ""),
+			response(200, "5"),
+			response(429, "2"),
+			response(429, ""),
+			response(200, ""),
+			response(429, "4"),
+		} {
+			waits = append(waits, tracker.record(resp))
+		}
+		tracker.recordWait(2 * time.Second)
+
+		want := []time.Duration{0, 0, 0, 0, 0, 0}
+		if honor {
+			want[2], want[5] = 2*time.Second, 4*time.Second
+		}
+		for i := range want {
+			if waits[i] != want[i] {
+				t.Errorf("honor %v: response %d waits %v, want %v", honor, i, waits[i], want[i])
+			}
+		}
+		s := tracker.summary(start, 2*time.Second)
+		if s.Throttled != 3 || s.AllowedBefore != 2 || s.RetryAfter != 2 || s.RetryAfterAverage != 3 || s.RetryAfterMax != 4 || s.Honored != honor {
+			t.Errorf("honor %v: got %+v", honor, s)
+		}
+		if s.ThrottleRate != 50 || s.AllowedPerSecond != 1.5 || s.ThrottledPerSecond != 1.5 || s.Waits != 1 || s.WaitSeconds != 2 {
+			t.Errorf("honor %v: got rates %+v", honor, s)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// TLSInfo records what was negotiated on the first TLS connection of a
+// run, so results document the protocol and certificates they measured
+type TLSInfo struct {
+	Version      string            `json:"version"`
+	CipherSuite  string            `json:"cipherSuite"`
+	ALPN         string            `json:"alpn,omitempty"`
+	ServerName   string            `json:"serverName,omitempty"`
+	Certificates []CertificateInfo `json:"certificates"`
+}
+
+// CertificateInfo summarizes one certificate of the server's chain, leaf
+// first
+type CertificateInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	DNSNames  []string  `json:"dnsNames,omitempty"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+}
+
+func describeTLS(state *tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		ServerName:  state.ServerName,
+	}
+	for _, cert := range state.PeerCertificates {
+		info.Certificates = append(info.Certificates, CertificateInfo{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			DNSNames:  cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+		})
+	}
+	return info
+}
+
+// displayTLSInfo prints the negotiated TLS parameters and certificate chain
+func displayTLSInfo(result BenchmarkResult) {
+	info := result.Metadata.TLS
+	if info == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nTLS:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignLeft,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Property", "Value")
+	table.Append([]string{"Version", info.Version})
+	table.Append([]string{"Cipher Suite", info.CipherSuite})
+	alpn := info.ALPN
+	if alpn == "" {
+		alpn = "-"
+	}
+	table.Append([]string{"ALPN", alpn})
+	for i, cert := range info.Certificates {
+		label := "Certificate"
+		if i > 0 {
+			label = fmt.Sprintf("Issuer %d", i)
+		}
+		value := fmt.Sprintf("%s (expires %s)", cert.Subject, cert.NotAfter.Format("2006-01-02"))
+		if len(cert.DNSNames) > 0 {
+			value += "\n" + strings.Join(cert.DNSNames, ", ")
+		}
+		table.Append([]string{label, value})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// TraceExemplar links one request to its trace in the server's tracing
+// backend
+type TraceExemplar struct {
+	TraceID   string    `json:"traceId"`
+	Latency   float64   `json:"latencyMs"`
+	Status    int       `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TraceStats summarizes W3C trace context propagation
+type TraceStats struct {
+	Propagated int64           `json:"propagated"`
+	Exemplars  []TraceExemplar `json:"exemplars"`
+}
+
+// newTraceparent returns a random trace id and the W3C traceparent header
+// value for a new, sampled root span
+func newTraceparent() (traceID, header string) {
+	traceID = fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+	spanID := fmt.Sprintf("%016x", rand.Uint64())
+	return traceID, "00-" + traceID + "-" + spanID + "-01"
+}
+
+// exemplarTracker keeps the slowest traced requests. It is safe for
+// concurrent use.
+type exemplarTracker struct {
+	mu         sync.Mutex
+	keep       int
+	propagated int64
+	exemplars  []TraceExemplar // sorted slowest first
+}
+
+func newExemplarTracker(keep int) *exemplarTracker {
+	return &exemplarTracker{keep: keep}
+}
+
+func (t *exemplarTracker) record(e TraceExemplar) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.propagated++
+	if t.keep <= 0 {
+		return
+	}
+	if len(t.exemplars) == t.keep && e.Latency <= t.exemplars[len(t.exemplars)-1].Latency {
+		return
+	}
+
+	i := sort.Search(len(t.exemplars), func(i int) bool {
+		return t.exemplars[i].Latency < e.Latency
+	})
+	t.exemplars = append(t.exemplars, TraceExemplar{})
+	copy(t.exemplars[i+1:], t.exemplars[i:])
+	t.exemplars[i] = e
+	if len(t.exemplars) > t.keep {
+		t.exemplars = t.exemplars[:t.keep]
+	}
+}
+
+func (t *exemplarTracker) summary() *TraceStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &TraceStats{
+		Propagated: t.propagated,
+		Exemplars:  append([]TraceExemplar(nil), t.exemplars...),
+	}
+}
+
+// displayTraceExemplars prints the slowest traced requests
+func displayTraceExemplars(result BenchmarkResult) {
+	if result.Traces == nil || len(result.Traces.Exemplars) == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nSlowest Traces:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignLeft,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Trace ID", "Latency", "Status", "Started")
+
+	for _, e := range result.Traces.Exemplars {
+		status := fmt.Sprintf("%d", e.Status)
+		if e.Status == 0 {
+			status = "error"
+		}
+		table.Append([]string{
+			e.TraceID,
+			formatLatency(e.Latency),
+			status,
+			e.Timestamp.Format("15:04:05.000"),
+		})
+	}
+
+	table.Render()
+}
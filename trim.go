@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// TrimmedStats repeats the headline numbers without the warm-up and
+// cool-down of a run: requests that completed in the first StartSeconds
+// or the last EndSeconds are left out. With OutlierPercent set, the
+// slowest OutlierPercent of the remaining latencies are dropped from
+// TrimmedMean, and clamped to OutlierThreshold in WinsorizedMean.
+type TrimmedStats struct {
+	StartSeconds      float64      `json:"startSeconds"`
+	EndSeconds        float64      `json:"endSeconds"`
+	OutlierPercent    float64      `json:"outlierPercent,omitempty"`
+	Requests          int64        `json:"requests"`
+	RequestsPerSecond float64      `json:"requestsPerSecond"`
+	Latency           LatencyStats `json:"latency"`
+	OutlierThreshold  float64      `json:"outlierThresholdMs,omitempty"`
+	TrimmedMean       float64      `json:"trimmedMeanMs,omitempty"`
+	WinsorizedMean    float64      `json:"winsorizedMeanMs,omitempty"`
+}
+
+// trimTracker keeps the latency histogram of the trimmed window. The
+// last seconds of the run are held back in one histogram per second and
+// only merged into the window once they are older than the end trim, so
+// the end of runs without a fixed duration can be trimmed too. It is
+// used from the single stats goroutine and is not safe for concurrent
+// use.
+type trimTracker struct {
+	start, end time.Duration
+	begin      time.Time
+	window     *histogram
+	recent     []*histogram  // one per second, oldest first
+	recentFrom int           // second of the run of recent[0]
+	covered    time.Duration // end of the last second merged into window
+}
+
+func newTrimTracker(begin time.Time, start, end time.Duration) *trimTracker {
+	return &trimTracker{start: start, end: end, begin: begin, window: newLatencyHistogram()}
+}
+
+// record adds a latency in milliseconds completed at now
+func (t *trimTracker) record(latency float64, now time.Time) {
+	elapsed := now.Sub(t.begin)
+	if elapsed < t.start {
+		return
+	}
+	second := int(elapsed / time.Second)
+	if len(t.recent) == 0 {
+		t.recentFrom = second
+	}
+	for t.recentFrom+len(t.recent) <= second {
+		t.recent = append(t.recent, newLatencyHistogram())
+	}
+	t.recent[second-t.recentFrom].record(int64(latency * 1000))
+
+	// Seconds entirely before now-end can no longer be trimmed
+	keep := int((t.end + time.Second - 1) / time.Second)
+	for len(t.recent) > keep+1 {
+		t.merge(0)
+		t.recent = t.recent[1:]
+		t.recentFrom++
+	}
+}
+
+// merge adds the held back second recent[i] to the window
+func (t *trimTracker) merge(i int) {
+	t.window.merge(t.recent[i])
+	t.covered = time.Duration(t.recentFrom+i+1) * time.Second
+}
+
+// summary computes the trimmed statistics of a run that lasted elapsed
+func (t *trimTracker) summary(elapsed time.Duration, percentiles []float64, outlierPercent float64) *TrimmedStats {
+	cutoff := elapsed - t.end
+	for i := range t.recent {
+		if time.Duration(t.recentFrom+i+1)*time.Second <= cutoff {
+			t.merge(i)
+		}
+	}
+	t.recent = nil
+
+	h := t.window
+	stats := &TrimmedStats{
+		StartSeconds:   t.start.Seconds(),
+		EndSeconds:     t.end.Seconds(),
+		OutlierPercent: outlierPercent,
+		Requests:       h.totalCount,
+	}
+	if window := t.covered - t.start; window > 0 {
+		stats.RequestsPerSecond = float64(h.totalCount) / window.Seconds()
+	}
+	if h.totalCount == 0 {
+		return stats
+	}
+	stats.Latency = LatencyStats{
+		Average: h.mean() / 1000,
+		Min:     float64(h.min) / 1000,
+		Max:     float64(h.max) / 1000,
+	}
+	for _, p := range percentiles {
+		stats.Latency.Percentiles = append(stats.Latency.Percentiles, PercentileValue{Percentile: p, Value: float64(h.valueAtPercentile(p)) / 1000})
+	}
+	if outlierPercent > 0 {
+		threshold := h.valueAtPercentile(100 - outlierPercent)
+		stats.OutlierThreshold = float64(threshold) / 1000
+		var kept, trimmedTotal, winsorizedTotal float64
+		for i, count := range h.counts {
+			if count == 0 {
+				continue
+			}
+			value := h.medianEquivalent(h.valueAt(int64(i)))
+			if h.valueAt(int64(i)) <= threshold {
+				kept += float64(count)
+				trimmedTotal += float64(count) * float64(value)
+				winsorizedTotal += float64(count) * float64(value)
+			} else {
+				winsorizedTotal += float64(count) * float64(threshold)
+			}
+		}
+		if kept > 0 {
+			stats.TrimmedMean = trimmedTotal / kept / 1000
+		}
+		stats.WinsorizedMean = winsorizedTotal / float64(h.totalCount) / 1000
+	}
+	return stats
+}
+
+// displayTrimmedStats prints the -trim-* statistics next to the raw ones
+func displayTrimmedStats(result BenchmarkResult) {
+	trimmed := result.Trimmed
+	if trimmed == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nTrimmed Statistics:"))
+	description := fmt.Sprintf("Excluding requests completed in the first %gs and the last %gs of the run", trimmed.StartSeconds, trimmed.EndSeconds)
+	if trimmed.OutlierPercent > 0 {
+		description += fmt.Sprintf("; the slowest %g%% (above %s) are dropped from the trimmed mean and clamped in the winsorized mean", trimmed.OutlierPercent, formatLatency(trimmed.OutlierThreshold))
+	}
+	fmt.Println(description)
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Metric", "Raw", "Trimmed")
+	table.Append([]string{"Requests", fmt.Sprintf("%d", result.Requests.Total), fmt.Sprintf("%d", trimmed.Requests)})
+	table.Append([]string{"Requests/sec", fmt.Sprintf("%.2f", result.Throughput.RequestsPerSecond), fmt.Sprintf("%.2f", trimmed.RequestsPerSecond)})
+	table.Append([]string{"Average Latency", formatLatency(result.Latency.Average), formatLatency(trimmed.Latency.Average)})
+	table.Append([]string{"Max Latency", formatLatency(result.Latency.Max), formatLatency(trimmed.Latency.Max)})
+	for i, p := range trimmed.Latency.Percentiles {
+		raw := "-"
+		if i < len(result.Latency.Percentiles) {
+			raw = formatLatency(result.Latency.Percentiles[i].Value)
+		}
+		table.Append([]string{fmt.Sprintf("p%g Latency", p.Percentile), raw, formatLatency(p.Value)})
+	}
+	if trimmed.OutlierPercent > 0 {
+		table.Append([]string{"Trimmed Mean", "", formatLatency(trimmed.TrimmedMean)})
+		table.Append([]string{"Winsorized Mean", "", formatLatency(trimmed.WinsorizedMean)})
+	}
+	table.Render()
+}
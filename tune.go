@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TuningChange is a change of the duration, the connections or the rate
+// made while the run was in progress. Changes are annotated in the run's
+// time series.
+type TuningChange struct {
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	Setting        string  `json:"setting"` // "duration" (in seconds), "connections" or "rate"
+	From           float64 `json:"from"`
+	To             float64 `json:"to"`
+}
+
+func (t TuningChange) String() string {
+	return fmt.Sprintf("%s %g -> %g", t.Setting, t.From, t.To)
+}
+
+// tuningStep is the share by which + and - change a setting
+const tuningStep = 0.1
+
+// extendStep is how much e adds to the duration
+const extendStep = 30 * time.Second
+
+// settings returns the tuned connections and rate, 0 for those that
+// can't be tuned
+func (c *runControl) settings() (int, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connections, c.rate
+}
+
+// plannedDuration returns the duration of the run, including extensions
+func (c *runControl) plannedDuration() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.duration
+}
+
+// extend makes the run longer
+func (c *runControl) extend(by time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return errors.New("the run isn't in progress")
+	}
+	if c.duration == 0 {
+		return errors.New("the run has no -duration to extend")
+	}
+	if by <= 0 {
+		return errors.New("the extension must be positive")
+	}
+	c.record("duration", c.duration.Seconds(), (c.duration + by).Seconds())
+	c.duration += by
+	c.notify()
+	return nil
+}
+
+// setConnections changes the number of connections that send requests.
+// Connections beyond it stay open but idle.
+func (c *runControl) setConnections(n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return errors.New("the run isn't in progress")
+	}
+	if c.connections == 0 {
+		return errors.New("the connections can only be changed in the closed model, without -vus, -feed-partition or scenario setup steps")
+	}
+	if n < 1 {
+		return errors.New("the run needs at least one connection")
+	}
+	if n != c.connections {
+		c.record("connections", float64(c.connections), float64(n))
+		c.connections = n
+		c.notify()
+	}
+	return nil
+}
+
+// setRate changes the target rate, which is per connection with
+// -rate-per-connection as at the start
+func (c *runControl) setRate(rate float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return errors.New("the run isn't in progress")
+	}
+	if c.rate == 0 {
+		return errors.New("the rate can only be changed in runs with a -rate")
+	}
+	if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return errors.New("the rate must be positive")
+	}
+	if rate != c.rate {
+		c.record("rate", c.rate, rate)
+		c.rate = rate
+		c.notify()
+	}
+	return nil
+}
+
+// adaptRate changes the rate for -adaptive. Unlike setRate, the change
+// isn't printed or recorded: the rate of every interval is in its stats.
+func (c *runControl) adaptRate(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running || c.rate == 0 || rate <= 0 || rate == c.rate {
+		return
+	}
+	c.rate = rate
+	c.notify()
+}
+
+// stepConnections changes the connections by tuningStep, at least one
+func (c *runControl) stepConnections(up bool) error {
+	connections, _ := c.settings()
+	step := max(1, int(float64(connections)*tuningStep))
+	if !up {
+		step = -step
+	}
+	return c.setConnections(connections + step)
+}
+
+// stepRate changes the rate by tuningStep
+func (c *runControl) stepRate(up bool) error {
+	_, rate := c.settings()
+	if up {
+		return c.setRate(rate * (1 + tuningStep))
+	}
+	return c.setRate(rate * (1 - tuningStep))
+}
+
+// record adds a change at the run's elapsed time. c.mu must be held.
+func (c *runControl) record(setting string, from, to float64) {
+	now := time.Now()
+	paused := c.total
+	if c.paused {
+		paused += now.Sub(c.since)
+	}
+	change := TuningChange{
+		ElapsedSeconds: (now.Sub(c.start) - paused).Seconds(),
+		Setting:        setting,
+		From:           from,
+		To:             to,
+	}
+	c.tuning = append(c.tuning, change)
+	fmt.Fprintf(os.Stderr, "Tuned %s\n", change)
+}
+
+// tuningSince returns the changes after the first n, so each time series
+// can annotate the changes once
+func (c *runControl) tuningSince(n int) []TuningChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n >= len(c.tuning) {
+		return nil
+	}
+	return append([]TuningChange(nil), c.tuning[n:]...)
+}
+
+// command runs a line typed in the terminal: an empty line pauses or
+// resumes the run, + and - change the connections, r+ and r- the rate,
+// "c N" and "r N" set them, and e extends the run by extendStep or "e
+// DURATION" by that
+func (c *runControl) command(line string) error {
+	line = strings.TrimSpace(line)
+	if by, ok := strings.CutPrefix(line, "e "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(by))
+		if err != nil {
+			return err
+		}
+		return c.extend(d)
+	}
+	switch line {
+	case "":
+		if c.isPaused() {
+			return c.resume()
+		}
+		return c.pause()
+	case "e":
+		return c.extend(extendStep)
+	case "+", "c+":
+		return c.stepConnections(true)
+	case "-", "c-":
+		return c.stepConnections(false)
+	case "r+":
+		return c.stepRate(true)
+	case "r-":
+		return c.stepRate(false)
+	}
+	var setting string
+	var value float64
+	if _, err := fmt.Sscanf(line, "%s %g", &setting, &value); err != nil {
+		return fmt.Errorf("unknown command %q, expected Enter, +, -, r+, r-, c N, r N, e or e DURATION", line)
+	}
+	switch setting {
+	case "c":
+		if value != math.Trunc(value) {
+			return errors.New("the connections must be a whole number")
+		}
+		return c.setConnections(int(value))
+	case "r":
+		return c.setRate(value)
+	}
+	return fmt.Errorf("unknown command %q, expected Enter, +, -, r+, r-, c N, r N, e or e DURATION", line)
+}
+
+// pacerSet keeps the limiters of a paced run, so that a tuned rate
+// applies to all of them, including those created after the change
+type pacerSet struct {
+	mu       sync.Mutex
+	config   BenchmarkConfig
+	rate     float64
+	limiters []*limiter
+}
+
+func newPacerSet(config BenchmarkConfig) *pacerSet {
+	return &pacerSet{config: config, rate: config.Rate}
+}
+
+// add creates a limiter at the current rate
+func (p *pacerSet) add() *limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l := newPacer(p.config, p.rate)
+	p.limiters = append(p.limiters, l)
+	return l
+}
+
+// retune changes the rate of every limiter from its next slot on
+func (p *pacerSet) retune(rate float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rate <= 0 || rate == p.rate {
+		return
+	}
+	p.rate = rate
+	for _, l := range p.limiters {
+		l.retune(newPacer(p.config, rate))
+	}
+}
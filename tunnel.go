@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// ProxyStats describes the tunnels of -proxy, every connection of the run
+// being one. Handshake is the CONNECT round trip to the proxy, and the TLS
+// handshake with an https:// proxy, or the SOCKS5 negotiation, after the
+// connection to it is open; Origin is the time from a request being
+// written to its first response byte through the tunnel. Refused counts
+// the proxy's refusals by CONNECT status or SOCKS5 reply.
+type ProxyStats struct {
+	Proxy         string           `json:"proxy"`
+	Tunnels       int64            `json:"tunnels"`
+	Failed        int64            `json:"failed"`
+	Refused       map[string]int64 `json:"refused,omitempty"`
+	Handshake     LatencySummary   `json:"handshake"`
+	Origin        LatencySummary   `json:"origin"`
+	BytesSent     int64            `json:"bytesSent"`
+	BytesReceived int64            `json:"bytesReceived"`
+}
+
+// proxyTunnel dials every connection through a forward proxy with
+// CONNECT, or through a SOCKS5 server, whatever the scheme of the target,
+// so the proxy itself is benchmarked
+type proxyTunnel struct {
+	proxy *url.URL
+	// Proxy-Authorization of CONNECT, from the proxy URL's user info
+	auth string
+	// dial connects to the proxy
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	tunnels   int64
+	failed    int64
+	sent      int64
+	received  int64
+	handshake latencyTracker
+	origin    latencyTracker
+
+	mu      sync.Mutex
+	refused map[string]int64
+}
+
+// parseProxy parses the -proxy URL, http://, https://, socks5:// or
+// socks5h://, with an optional user:password
+func parseProxy(s string) (*proxyTunnel, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, expected http, https, socks5 or socks5h", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", s)
+	}
+	t := &proxyTunnel{proxy: u, refused: make(map[string]int64)}
+	if u.User != nil && !t.socks() {
+		password, _ := u.User.Password()
+		t.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password))
+	}
+	return t, nil
+}
+
+// proxyURLOf returns the URL of a -proxy, or nil without one
+func proxyURLOf(t *proxyTunnel) *url.URL {
+	if t == nil {
+		return nil
+	}
+	return t.proxy
+}
+
+// address returns the host:port of the proxy
+func (t *proxyTunnel) address() string {
+	if t.proxy.Port() != "" {
+		return t.proxy.Host
+	}
+	switch t.proxy.Scheme {
+	case "https":
+		return net.JoinHostPort(t.proxy.Hostname(), "443")
+	case "socks5", "socks5h":
+		return net.JoinHostPort(t.proxy.Hostname(), "1080")
+	}
+	return net.JoinHostPort(t.proxy.Hostname(), "80")
+}
+
+// dialContext opens a connection to the proxy and a tunnel through it to
+// addr. The proxy's refusal fails the request, with its reason.
+func (t *proxyTunnel) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := t.dial(ctx, network, t.address())
+	if err != nil {
+		atomic.AddInt64(&t.failed, 1)
+		return nil, err
+	}
+	start := time.Now()
+	if t.proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: t.proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			atomic.AddInt64(&t.failed, 1)
+			return nil, fmt.Errorf("proxy TLS handshake: %v", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var reader *bufio.Reader
+	var refusal string
+	if t.socks() {
+		reader, refusal, err = t.socksConnect(ctx, conn, addr)
+	} else {
+		reader, refusal, err = t.connect(conn, addr)
+	}
+	if err != nil {
+		conn.Close()
+		atomic.AddInt64(&t.failed, 1)
+		if refusal != "" {
+			t.mu.Lock()
+			t.refused[refusal]++
+			t.mu.Unlock()
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	t.handshake.record(float64(time.Since(start).Microseconds()) / 1000)
+	atomic.AddInt64(&t.tunnels, 1)
+	return &tunnelConn{Conn: conn, reader: reader, tunnel: t}, nil
+}
+
+// connect opens a tunnel with CONNECT. A refusal returns the proxy's
+// status.
+func (t *proxyTunnel) connect(conn net.Conn, addr string) (*bufio.Reader, string, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if t.auth != "" {
+		req.Header.Set("Proxy-Authorization", t.auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, "", fmt.Errorf("proxy CONNECT: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, "", fmt.Errorf("proxy CONNECT: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, strconv.Itoa(resp.StatusCode), fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	return reader, "", nil
+}
+
+// hooks adds the timing of the origin's response through the tunnel to a
+// request's trace
+func (t *proxyTunnel) hooks(trace *httptrace.ClientTrace) {
+	var wrote time.Time
+	trace.WroteRequest = func(httptrace.WroteRequestInfo) {
+		wrote = time.Now()
+	}
+	trace.GotFirstResponseByte = func() {
+		if !wrote.IsZero() {
+			t.origin.record(float64(time.Since(wrote).Microseconds()) / 1000)
+		}
+	}
+}
+
+func (t *proxyTunnel) summary() *ProxyStats {
+	stats := &ProxyStats{
+		Proxy:         t.proxy.Redacted(),
+		Tunnels:       atomic.LoadInt64(&t.tunnels),
+		Failed:        atomic.LoadInt64(&t.failed),
+		Handshake:     t.handshake.summary(),
+		Origin:        t.origin.summary(),
+		BytesSent:     atomic.LoadInt64(&t.sent),
+		BytesReceived: atomic.LoadInt64(&t.received),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.refused) > 0 {
+		stats.Refused = make(map[string]int64, len(t.refused))
+		for reason, n := range t.refused {
+			stats.Refused[reason] = n
+		}
+	}
+	return stats
+}
+
+// tunnelConn is a connection through a tunnel, counting the bytes that
+// cross it. Reads go through the reader of the proxy's answer, which may
+// hold the origin's first bytes.
+type tunnelConn struct {
+	net.Conn
+	reader *bufio.Reader
+	tunnel *proxyTunnel
+}
+
+func (c *tunnelConn) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	atomic.AddInt64(&c.tunnel.received, int64(n))
+	return n, err
+}
+
+func (c *tunnelConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.tunnel.sent, int64(n))
+	return n, err
+}
+
+// displayProxyStats prints the -proxy table
+func displayProxyStats(result BenchmarkResult) {
+	p := result.Proxy
+	if p == nil {
+		return
+	}
+	fmt.Println(colorize(chalk.Green, "\nProxy Tunnels:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignLeft,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Property", "Value")
+	table.Append([]string{"Proxy", p.Proxy})
+	table.Append([]string{"Tunnels", fmt.Sprintf("%d (%d failed)", p.Tunnels, p.Failed)})
+	reasons := make([]string, 0, len(p.Refused))
+	for reason := range p.Refused {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		table.Append([]string{"  Refused: " + reason, fmt.Sprintf("%d", p.Refused[reason])})
+	}
+	if p.Tunnels == 0 {
+		table.Render()
+		return
+	}
+	table.Append([]string{"Handshake Latency", fmt.Sprintf("%s avg, %s min, %s max", formatLatency(p.Handshake.Average), formatLatency(p.Handshake.Min), formatLatency(p.Handshake.Max))})
+	table.Append([]string{"Origin Response", fmt.Sprintf("%s avg, %s min, %s max", formatLatency(p.Origin.Average), formatLatency(p.Origin.Min), formatLatency(p.Origin.Max))})
+	table.Append([]string{"Tunnel Sent", fmt.Sprintf("%s (%s)", formatSize(p.BytesSent), formatRate(p.BytesSent, result.seconds()))})
+	table.Append([]string{"Tunnel Received", fmt.Sprintf("%s (%s)", formatSize(p.BytesReceived), formatRate(p.BytesReceived, result.seconds()))})
+
+	table.Render()
+}
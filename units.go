@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// latencyUnit and latencyPrecision control how latencies are printed in
+// the console tables, the interim output and the HTML report. The JSON,
+// -quiet and metrics outputs always use milliseconds.
+var (
+	latencyUnit      = "ms"
+	latencyPrecision = 2
+)
+
+// latencyUnits maps each -latency-unit to its label and its size in
+// milliseconds
+var latencyUnits = map[string]struct {
+	label string
+	ms    float64
+}{
+	"us": {"µs", 0.001},
+	"µs": {"µs", 0.001},
+	"ms": {"ms", 1},
+	"s":  {"s", 1000},
+}
+
+// configureLatencyFormat sets the unit and precision latencies are
+// printed with
+func configureLatencyFormat(unit string, precision int) error {
+	if _, ok := latencyUnits[unit]; !ok {
+		return fmt.Errorf("unknown unit %q, expected us, ms or s", unit)
+	}
+	if precision < 0 || precision > 9 {
+		return fmt.Errorf("the precision must be between 0 and 9 decimals")
+	}
+	latencyUnit, latencyPrecision = unit, precision
+	return nil
+}
+
+// formatLatency formats a latency in milliseconds in the configured unit
+// and precision, e.g. "1.23 ms"
+func formatLatency(ms float64) string {
+	unit := latencyUnits[latencyUnit]
+	return fmt.Sprintf("%.*f %s", latencyPrecision, ms/unit.ms, unit.label)
+}
+
+// formatLatencyDelta formats a latency difference with an explicit sign
+func formatLatencyDelta(ms float64) string {
+	formatted := formatLatency(ms)
+	if !strings.HasPrefix(formatted, "-") {
+		formatted = "+" + formatted
+	}
+	return formatted
+}
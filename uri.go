@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// normalizeURI checks a target uri before the run starts, so a typo fails
+// once instead of as an error per request. A uri without a scheme gets
+// http://, which is reported through defaulted. Templated uris are only
+// checked up to their first template action, as the rest varies.
+func normalizeURI(raw string) (normalized string, defaulted bool, err error) {
+	normalized = strings.TrimSpace(raw)
+	if !strings.Contains(normalized, "://") {
+		normalized = "http://" + strings.TrimPrefix(normalized, "//")
+		defaulted = true
+	}
+
+	static := normalized
+	if i := strings.Index(static, "{{"); i >= 0 {
+		static = static[:i]
+	}
+	u, err := url.Parse(static)
+	if urlErr, ok := err.(*url.Error); ok {
+		return "", false, urlErr.Err
+	} else if err != nil {
+		return "", false, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, fmt.Errorf("unsupported scheme %q, expected http or https", u.Scheme)
+	}
+	if static != normalized {
+		return normalized, defaulted, nil
+	}
+	if u.Hostname() == "" {
+		return "", false, fmt.Errorf("missing host")
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", false, fmt.Errorf("invalid port %q", port)
+		}
+	}
+	return normalized, defaulted, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+	"github.com/ttacon/chalk"
+)
+
+// Validator checks every response that was read completely. A failing
+// response counts as a failed request. Validate is called concurrently
+// and must be safe for concurrent use.
+type Validator interface {
+	Validate(resp ValidationResponse) ValidationResult
+}
+
+// ValidationResponse is the response passed to a Validator. Body is a
+// fresh reader over the full response body for each validator.
+type ValidationResponse struct {
+	Request    *http.Request
+	StatusCode int
+	Header     http.Header
+	Body       io.Reader
+}
+
+// ValidationResult is a Validator's verdict. Category groups failures
+// in the results, e.g. "status" or "body".
+type ValidationResult struct {
+	Pass     bool
+	Category string
+}
+
+// ValidationStats counts validation outcomes, with failures by category
+type ValidationStats struct {
+	Passed   int64            `json:"passed"`
+	Failed   int64            `json:"failed"`
+	Failures map[string]int64 `json:"failures"`
+}
+
+// statusValidator is the built-in -expect assertion
+type statusValidator struct {
+	expected int
+}
+
+func (v statusValidator) Validate(resp ValidationResponse) ValidationResult {
+	if resp.StatusCode == v.expected {
+		return ValidationResult{Pass: true}
+	}
+	return ValidationResult{Category: fmt.Sprintf("status %d", resp.StatusCode)}
+}
+
+// bodyContainsValidator is the built-in -expect-body assertion
+type bodyContainsValidator struct {
+	substring []byte
+}
+
+func (v bodyContainsValidator) Validate(resp ValidationResponse) ValidationResult {
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && bytes.Contains(body, v.substring) {
+		return ValidationResult{Pass: true}
+	}
+	return ValidationResult{Category: "body"}
+}
+
+// checksumValidator is the built-in -verify-sha256 assertion. The
+// expected digest is either fixed or, with column set, taken from the
+// feed row the request was rendered from; requests without a digest in
+// their row are not checked.
+type checksumValidator struct {
+	expected string
+	column   string
+}
+
+func (v checksumValidator) Validate(resp ValidationResponse) ValidationResult {
+	expected := v.expected
+	if v.column != "" {
+		expected = feedRowFrom(resp.Request.Context())[v.column]
+		if expected == "" {
+			return ValidationResult{Pass: true}
+		}
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err == nil && strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), strings.TrimSpace(expected)) {
+		return ValidationResult{Pass: true}
+	}
+	return ValidationResult{Category: "checksum"}
+}
+
+// validate runs the validators in order, returning the first failure
+func validate(validators []Validator, req *http.Request, resp *http.Response, body []byte) ValidationResult {
+	for _, v := range validators {
+		result := v.Validate(ValidationResponse{
+			Request:    req,
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       bytes.NewReader(body),
+		})
+		if !result.Pass {
+			if result.Category == "" {
+				result.Category = "other"
+			}
+			return result
+		}
+	}
+	return ValidationResult{Pass: true}
+}
+
+// validationTracker accumulates ValidationStats. It is safe for
+// concurrent use.
+type validationTracker struct {
+	mu       sync.Mutex
+	passed   int64
+	failed   int64
+	failures map[string]int64
+}
+
+func newValidationTracker() *validationTracker {
+	return &validationTracker{failures: make(map[string]int64)}
+}
+
+func (t *validationTracker) record(result ValidationResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if result.Pass {
+		t.passed++
+		return
+	}
+	t.failed++
+	t.failures[result.Category]++
+}
+
+func (t *validationTracker) summary() *ValidationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &ValidationStats{Passed: t.passed, Failed: t.failed, Failures: make(map[string]int64)}
+	for category, count := range t.failures {
+		stats.Failures[category] = count
+	}
+	return stats
+}
+
+// displayValidationStats prints the validation failures by category
+func displayValidationStats(result BenchmarkResult) {
+	if result.Validation == nil || result.Validation.Failed == 0 {
+		return
+	}
+	fmt.Println(colorize(chalk.Red, "\nValidation Failures:"))
+
+	table := tablewriter.NewTable(os.Stdout,
+		tablewriter.WithConfig(tablewriter.Config{
+			Row: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignRight,
+				},
+			},
+			Header: tw.CellConfig{
+				Formatting: tw.CellFormatting{
+					Alignment: tw.AlignCenter,
+				},
+			},
+		}),
+	)
+
+	table.Header("Category", "Count", "Percentage")
+
+	categories := make([]string, 0, len(result.Validation.Failures))
+	for category := range result.Validation.Failures {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	checked := result.Validation.Passed + result.Validation.Failed
+	for _, category := range categories {
+		count := result.Validation.Failures[category]
+		table.Append([]string{
+			category,
+			fmt.Sprintf("%d", count),
+			fmt.Sprintf("%.2f%%", float64(count)/float64(checked)*100),
+		})
+	}
+
+	table.Render()
+}
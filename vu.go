@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// thinkTime is the pause after a scenario step, fixed or uniformly
+// random between min and max
+type thinkTime struct {
+	min, max time.Duration
+}
+
+// parseThinkTime parses a duration such as "1s", or a range such as
+// "500ms-2s"
+func parseThinkTime(s string) (thinkTime, error) {
+	if s == "" {
+		return thinkTime{}, nil
+	}
+	low, high, isRange := strings.Cut(s, "-")
+	min, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil {
+		return thinkTime{}, err
+	}
+	max := min
+	if isRange {
+		if max, err = time.ParseDuration(strings.TrimSpace(high)); err != nil {
+			return thinkTime{}, err
+		}
+	}
+	if min < 0 || max < min {
+		return thinkTime{}, fmt.Errorf("invalid think time %q", s)
+	}
+	return thinkTime{min: min, max: max}, nil
+}
+
+func (t thinkTime) next() time.Duration {
+	if t.max <= t.min {
+		return t.min
+	}
+	return t.min + time.Duration(mathrand.Int63n(int64(t.max-t.min)+1))
+}
+
+// extraction takes a variable's value from a response, so later steps
+// of the same virtual user can use it as {{.Vars.name}}
+type extraction struct {
+	name   string
+	source string // "header", "json" or "regex"
+	header string
+	path   []string
+	regex  *regexp.Regexp
+}
+
+// parseExtraction parses an extraction spec: "header:Name",
+// "json:path.to.field" (array elements by index, e.g. "items.0.id") or
+// "regex:pattern" (the first group, or the whole match)
+func parseExtraction(name, spec string) (extraction, error) {
+	e := extraction{name: name}
+	source, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return e, fmt.Errorf("extract %q: expected header:, json: or regex: followed by a value", name)
+	}
+	e.source = source
+	switch source {
+	case "header":
+		e.header = arg
+	case "json":
+		e.path = strings.Split(arg, ".")
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return e, fmt.Errorf("extract %q: %v", name, err)
+		}
+		e.regex = re
+	default:
+		return e, fmt.Errorf("extract %q: unknown source %q, expected header, json or regex", name, source)
+	}
+	return e, nil
+}
+
+// extract returns the value from the response, if it has one
+func (e extraction) extract(header http.Header, body []byte) (string, bool) {
+	switch e.source {
+	case "header":
+		value := header.Get(e.header)
+		return value, value != ""
+	case "json":
+		var v any
+		if json.Unmarshal(body, &v) != nil {
+			return "", false
+		}
+		for _, key := range e.path {
+			switch node := v.(type) {
+			case map[string]any:
+				v = node[key]
+			case []any:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(node) {
+					return "", false
+				}
+				v = node[i]
+			default:
+				return "", false
+			}
+		}
+		switch value := v.(type) {
+		case nil:
+			return "", false
+		case string:
+			return value, true
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64), true
+		default:
+			data, _ := json.Marshal(value)
+			return string(data), true
+		}
+	case "regex":
+		match := e.regex.FindSubmatch(body)
+		if match == nil {
+			return "", false
+		}
+		if len(match) > 1 {
+			return string(match[1]), true
+		}
+		return string(match[0]), true
+	}
+	return "", false
+}
+
+// runHook sends a worker's setup or teardown steps in order, outside of
+// the statistics, stopping at the first step that fails (see
+// doHookRequest). Setup picks the worker's feed row, which it keeps for
+// the run.
+func (g *scenarioGenerator) runHook(client *http.Client, worker int, steps []scenarioRequest, headers map[string]string) error {
+	if g.feed != nil {
+		g.mu.Lock()
+		var err error
+		if g.rows[worker] == nil {
+			g.rows[worker], err = g.feed.next(worker)
+		}
+		g.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	for _, step := range steps {
+		name := step.tmpl.spec.Operation
+		req, err := g.render(withWorker(context.Background(), worker), worker, step, -1)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		header, body, err := doHookRequest(client, req, headers)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		g.extract(worker, step, header, body)
+	}
+	return nil
+}
+
+// VUSetupStats describes the scenario's setup and teardown steps, which
+// every connection or virtual user runs once, outside of the statistics.
+// Workers whose setup failed don't run the scenario.
+type VUSetupStats struct {
+	Workers        int     `json:"workers"`
+	SetupFailed    int64   `json:"setupFailed"`
+	TeardownFailed int64   `json:"teardownFailed"`
+	SetupSeconds   float64 `json:"setupSeconds"`
+}
+
+// hookFailures counts the workers whose setup or teardown failed, and
+// keeps the first error to show as an example. It is safe for
+// concurrent use.
+type hookFailures struct {
+	mu    sync.Mutex
+	count int64
+	first error
+}
+
+func (f *hookFailures) record(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count++
+	if f.first == nil {
+		f.first = err
+	}
+}
+
+// report prints how many of the workers failed the hook, if any
+func (f *hookFailures) report(hook string, workers int) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count > 0 {
+		fmt.Fprintf(os.Stderr, "Scenario %s failed for %d of %d users, e.g. %v\n", hook, f.count, workers, f.first)
+	}
+	return f.count
+}
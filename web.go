@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// webTick is one second of the run as charted by the web UI
+type webTick struct {
+	Elapsed  float64  `json:"elapsed"`
+	Requests int64    `json:"requests"`
+	Errors   int64    `json:"errors"`
+	P50      float64  `json:"p50"`
+	P90      float64  `json:"p90"`
+	P99      float64  `json:"p99"`
+	Notes    []string `json:"notes,omitempty"` // tuning changes
+}
+
+// webError is a failed request in the web UI's pane of recent errors
+type webError struct {
+	Time   string `json:"time"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Class  string `json:"class"`
+	Error  string `json:"error,omitempty"`
+}
+
+// webErrorTail is the number of recent errors the web UI keeps
+const webErrorTail = 50
+
+// webUI serves a live view of the run to browsers (-web): charts of the
+// request rate, latency percentiles and errors per second and a pane of
+// the most recent errors, pushed over the /live WebSocket, and the HTML
+// report once the run is done. It is an EventHandler of the run.
+type webUI struct {
+	NopEventHandler
+	url     string
+	live    *liveHub
+	heatmap *latencyHeatmap
+
+	mu         sync.Mutex
+	second     *histogram // latencies of the current second, in µs
+	lastFailed int64
+	ticks      []webTick // so late browsers see the run so far
+	errors     []webError
+	newErrors  int // since the last tick
+	report     []byte
+}
+
+// startWebUI starts serving the web UI on addr, with the pause and resume
+// part of the control API under /control/ for its pause button
+func startWebUI(addr, uri string, control *runControl) (*webUI, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	ui := &webUI{
+		url:     "http://" + net.JoinHostPort(host, port) + "/",
+		live:    newLiveHub(),
+		heatmap: newLatencyHeatmap(),
+		second:  newLatencyHistogram(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		webPageTemplate.Execute(w, uri)
+	})
+	mux.Handle("/live", ui.live)
+	mux.Handle("/control/", http.StripPrefix("/control", control.handler(false)))
+	mux.HandleFunc("/ticks", func(w http.ResponseWriter, r *http.Request) {
+		ui.mu.Lock()
+		data, _ := json.Marshal(struct {
+			Ticks  []webTick  `json:"ticks"`
+			Errors []webError `json:"errors"`
+			Done   bool       `json:"done"`
+		}{append([]webTick{}, ui.ticks...), append([]webError{}, ui.errors...), ui.report != nil})
+		ui.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+	mux.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		ui.mu.Lock()
+		report := ui.report
+		ui.mu.Unlock()
+		if report == nil {
+			http.Error(w, "the run is still in progress", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(report)
+	})
+	go http.Serve(listener, mux)
+	fmt.Fprintf(os.Stderr, "Watch the run on %s\n", ui.url)
+	return ui, nil
+}
+
+func (ui *webUI) OnRequestDone(s Sample) {
+	ui.heatmap.record(s)
+	if s.Status == 0 {
+		return
+	}
+	ui.mu.Lock()
+	ui.second.record(int64(s.Latency * 1000))
+	ui.mu.Unlock()
+}
+
+// OnError keeps the error for the pane, which is sent with the next tick
+// so that a burst of errors doesn't flood the browsers
+func (ui *webUI) OnError(s Sample) {
+	e := webError{
+		Time:   time.Now().Format("15:04:05.000"),
+		Method: s.Method,
+		URL:    s.URL,
+		Status: s.Status,
+		Class:  s.ErrorClass,
+		Error:  s.Error,
+	}
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.errors = append(ui.errors, e)
+	if len(ui.errors) > webErrorTail {
+		ui.errors = ui.errors[len(ui.errors)-webErrorTail:]
+	}
+	ui.newErrors = min(ui.newErrors+1, webErrorTail)
+}
+
+func (ui *webUI) OnTick(tick Tick) {
+	ui.mu.Lock()
+	t := webTick{
+		Elapsed:  tick.Elapsed.Seconds(),
+		Requests: tick.Requests,
+		Errors:   tick.Failed - ui.lastFailed,
+		P50:      float64(ui.second.valueAtPercentile(50)) / 1000,
+		P90:      float64(ui.second.valueAtPercentile(90)) / 1000,
+		P99:      float64(ui.second.valueAtPercentile(99)) / 1000,
+	}
+	for _, change := range tick.Tuning {
+		t.Notes = append(t.Notes, change.String())
+	}
+	ui.second.reset()
+	ui.lastFailed = tick.Failed
+	ui.ticks = append(ui.ticks, t)
+	errors := append([]webError(nil), ui.errors[len(ui.errors)-ui.newErrors:]...)
+	ui.newErrors = 0
+	ui.mu.Unlock()
+	ui.live.publish(struct {
+		Type   string     `json:"type"`
+		Tick   webTick    `json:"tick"`
+		Errors []webError `json:"errors,omitempty"`
+	}{"tick", t, errors})
+}
+
+func (ui *webUI) OnFinish(result BenchmarkResult) {
+	var report bytes.Buffer
+	if err := renderHTMLReport(&report, result, ui.heatmap); err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering the web report: %v\n", err)
+		return
+	}
+	ui.mu.Lock()
+	ui.report = report.Bytes()
+	ui.mu.Unlock()
+	ui.live.publish(struct {
+		Type string `json:"type"`
+	}{"done"})
+}
+
+// wait keeps the report available until autocannon is interrupted
+func (ui *webUI) wait() {
+	fmt.Fprintf(os.Stderr, "The report is on %s, press Ctrl+C to exit\n", ui.url)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+}
+
+var webPageTemplate = template.Must(template.New("web").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>autocannon: {{.}}</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+canvas { border: 1px solid #ccc; background: #fafafa; margin-bottom: 1.5em; }
+iframe { border: 1px solid #ccc; width: 100%; height: 80vh; }
+.muted { color: #777; }
+.legend span { margin-right: 1em; }
+#errors-tail { font-family: Menlo, Consolas, monospace; font-size: 12px; max-height: 15em; overflow-y: auto; border: 1px solid #ccc; padding: 0.5em; width: 900px; box-sizing: border-box; }
+#errors-tail div { white-space: nowrap; }
+.class { color: #d62728; }
+</style>
+</head>
+<body>
+<h1>{{.}}</h1>
+<p class="muted"><span id="status">Connecting...</span> <button id="pause" hidden>Pause</button></p>
+
+<h2>Requests/sec</h2>
+<canvas id="requests" width="900" height="200"></canvas>
+<h2>Latency</h2>
+<p class="legend"><span style="color:#1f77b4">p50</span><span style="color:#ff7f0e">p90</span><span style="color:#d62728">p99</span></p>
+<canvas id="latency" width="900" height="200"></canvas>
+<h2>Errors/sec</h2>
+<canvas id="errors" width="900" height="200"></canvas>
+<h2>Recent Errors</h2>
+<div id="errors-tail"><span class="muted">None yet.</span></div>
+
+<div id="report"></div>
+
+<script>
+const ticks = [];
+
+function draw(id, series, unit) {
+  const canvas = document.getElementById(id);
+  const ctx = canvas.getContext("2d");
+  const w = canvas.width, h = canvas.height, left = 60, bottom = 20;
+  ctx.clearRect(0, 0, w, h);
+  let max = 0;
+  for (const s of series) for (const t of ticks) max = Math.max(max, s.value(t));
+  max = max > 0 ? max * 1.1 : 1;
+  const end = ticks.length ? ticks[ticks.length - 1].elapsed : 1;
+  const x = (t) => left + (w - left - 10) * t.elapsed / end;
+  const y = (v) => (h - bottom) * (1 - v / max);
+  ctx.fillStyle = "#444";
+  ctx.font = "11px sans-serif";
+  ctx.textAlign = "right";
+  for (let i = 0; i <= 4; i++) {
+    const v = max * i / 4;
+    ctx.fillText(v.toFixed(v < 10 ? 2 : 0) + unit, left - 6, y(v) + 4);
+  }
+  ctx.textAlign = "center";
+  ctx.fillText(end.toFixed(0) + "s", w - 20, h - 5);
+  ctx.textAlign = "left";
+  ctx.setLineDash([4, 4]);
+  for (const t of ticks) {
+    if (!t.notes) continue;
+    ctx.strokeStyle = "#999";
+    ctx.beginPath();
+    ctx.moveTo(x(t), 0);
+    ctx.lineTo(x(t), h - bottom);
+    ctx.stroke();
+    ctx.fillText(t.notes.join(", "), x(t) + 4, 12);
+  }
+  ctx.setLineDash([]);
+  for (const s of series) {
+    ctx.strokeStyle = s.color;
+    ctx.beginPath();
+    ticks.forEach((t, i) => i ? ctx.lineTo(x(t), y(s.value(t))) : ctx.moveTo(x(t), y(s.value(t))));
+    ctx.stroke();
+  }
+}
+
+function render() {
+  draw("requests", [{ color: "#2ca02c", value: (t) => t.requests }], "");
+  draw("latency", [
+    { color: "#1f77b4", value: (t) => t.p50 },
+    { color: "#ff7f0e", value: (t) => t.p90 },
+    { color: "#d62728", value: (t) => t.p99 },
+  ], " ms");
+  draw("errors", [{ color: "#d62728", value: (t) => t.errors }], "");
+  const last = ticks[ticks.length - 1];
+  if (last) {
+    document.getElementById("status").textContent =
+      "Running for " + last.elapsed.toFixed(0) + "s: " + last.requests + " requests/sec, p99 " + last.p99.toFixed(2) + " ms";
+  }
+}
+
+const pause = document.getElementById("pause");
+pause.onclick = () => {
+  fetch(pause.textContent === "Pause" ? "/control/pause" : "/control/resume", { method: "POST" })
+    .then((r) => r.json()).then(showControl);
+};
+
+function showControl(state) {
+  pause.textContent = state.paused ? "Resume" : "Pause";
+  if (state.paused) {
+    document.getElementById("status").textContent = "Paused, no requests are sent.";
+  }
+}
+
+const tail = document.getElementById("errors-tail");
+
+function showErrors(errors) {
+  if (!errors || !errors.length) return;
+  if (tail.querySelector(".muted")) tail.textContent = "";
+  // Newest first, keeping the last 50
+  for (const e of errors) {
+    const line = document.createElement("div");
+    const cls = document.createElement("span");
+    cls.className = "class";
+    cls.textContent = e.class + (e.status ? " " + e.status : "");
+    line.append(e.time + " " + e.method + " " + e.url + " ", cls, e.error ? " " + e.error : "");
+    tail.prepend(line);
+  }
+  while (tail.children.length > 50) tail.lastChild.remove();
+}
+
+function done() {
+  pause.hidden = true;
+  document.getElementById("status").textContent = "Done.";
+  document.getElementById("report").innerHTML = '<h2>Report</h2><iframe src="/report"></iframe>';
+}
+
+fetch("/ticks").then((r) => r.json()).then((state) => {
+  ticks.push(...(state.ticks || []));
+  showErrors(state.errors);
+  render();
+  if (state.done) {
+    done();
+    return;
+  }
+  pause.hidden = false;
+  fetch("/control/status").then((r) => r.json()).then(showControl);
+  const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/live");
+  ws.onmessage = (e) => {
+    const msg = JSON.parse(e.data);
+    if (msg.type === "tick") {
+      ticks.push(msg.tick);
+      showErrors(msg.errors);
+      render();
+      pause.textContent = "Pause";
+    } else if (msg.type === "done") {
+      done();
+    }
+  };
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is the key suffix of the RFC 6455 opening handshake
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// wsMaxFrame bounds the frames read from clients, which only ever send
+// control frames to a metrics stream
+const wsMaxFrame = 1 << 16
+
+// webSocket is the server side of a WebSocket connection. It only sends
+// text messages; frames from the client are read to answer pings and
+// notice the close.
+type webSocket struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // serializes frame writes
+}
+
+// upgradeWebSocket completes the opening handshake and takes over the
+// connection from the http server
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &webSocket{conn: conn, rw: rw}, nil
+}
+
+// headerContains reports whether a comma separated header has token
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame sends a single unfragmented, unmasked frame
+func (ws *webSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	ws.rw.Write(header)
+	ws.rw.Write(payload)
+	return ws.rw.Flush()
+}
+
+// readLoop reads the client's frames until it closes the connection or
+// the connection fails, answering pings and echoing the close
+func (ws *webSocket) readLoop() {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(ws.rw, head[:]); err != nil {
+			return
+		}
+		opcode := head[0] & 0x0f
+		masked := head[1]&0x80 != 0
+		length := uint64(head[1] & 0x7f)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		// Client frames must be masked (RFC 6455 section 5.1)
+		if !masked || length > wsMaxFrame {
+			ws.writeFrame(wsClose, binary.BigEndian.AppendUint16(nil, 1002))
+			return
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+			return
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.rw, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case wsPing:
+			ws.writeFrame(wsPong, payload)
+		case wsClose:
+			ws.writeFrame(wsClose, payload)
+			return
+		}
+	}
+}
+
+func (ws *webSocket) Close() error {
+	return ws.conn.Close()
+}
+
+// liveHub publishes JSON messages to every connected WebSocket client,
+// so dashboards can chart a run as it happens. Clients that fall behind
+// miss messages rather than slowing the run down.
+type liveHub struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{clients: make(map[chan []byte]struct{})}
+}
+
+// publish sends v, marshaled as JSON, to every client
+func (h *liveHub) publish(v any) {
+	message, err := json.Marshal(v)
+	if err != nil {
+		logger.Warn("live message", "error", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket and streams the published
+// messages to it until either side closes the connection
+func (h *liveHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	messages := make(chan []byte, 64)
+	h.mu.Lock()
+	h.clients[messages] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, messages)
+		h.mu.Unlock()
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		ws.readLoop()
+		close(closed)
+	}()
+	for {
+		select {
+		case message := <-messages:
+			if err := ws.writeFrame(wsText, message); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeWebSocket returns a webSocket on one end of an in-memory connection
+// and the other end, as the client
+func pipeWebSocket(t *testing.T) (*webSocket, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return &webSocket{conn: server, rw: bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))}, client
+}
+
+// TestWebSocketWriteFrame checks the frame headers against the examples
+// of RFC 6455 section 5.7, for the three payload length forms
+func TestWebSocketWriteFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		header  []byte
+	}{
+		{"hello", []byte("Hello"), []byte{0x81, 0x05}},
+		{"empty", nil, []byte{0x81, 0x00}},
+		{"125 bytes", bytes.Repeat([]byte("x"), 125), []byte{0x81, 0x7d}},
+		{"256 bytes", bytes.Repeat([]byte("x"), 256), []byte{0x81, 0x7e, 0x01, 0x00}},
+		{"64 KiB", bytes.Repeat([]byte("x"), 65536), []byte{0x81, 0x7f, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, client := pipeWebSocket(t)
+			go ws.writeFrame(wsText, tt.payload)
+			frame := make([]byte, len(tt.header)+len(tt.payload))
+			if _, err := io.ReadFull(client, frame); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(frame[:len(tt.header)], tt.header) {
+				t.Errorf("header % x, want % x", frame[:len(tt.header)], tt.header)
+			}
+			if !bytes.Equal(frame[len(tt.header):], tt.payload) {
+				t.Error("payload differs")
+			}
+		})
+	}
+}
+
+// TestWebSocketReadLoop checks the answers to the client's frames: the
+// masked ping of RFC 6455 section 5.7 gets an unmasked pong, a close is
+// echoed, and an unmasked frame is a protocol error
+func TestWebSocketReadLoop(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []byte
+		want   []byte
+	}{
+		{
+			"ping",
+			[]byte{0x89, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+			[]byte{0x8a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+		},
+		{
+			"close",
+			[]byte{0x88, 0x82, 0x01, 0x02, 0x03, 0x04, 0x03 ^ 0x01, 0xe8 ^ 0x02},
+			[]byte{0x88, 0x02, 0x03, 0xe8},
+		},
+		{
+			"unmasked",
+			[]byte{0x89, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f},
+			[]byte{0x88, 0x02, 0x03, 0xea},
+		},
+		{
+			"too long",
+			append([]byte{0x89, 0xff}, binary.BigEndian.AppendUint64(nil, wsMaxFrame+1)...),
+			[]byte{0x88, 0x02, 0x03, 0xea},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, client := pipeWebSocket(t)
+			done := make(chan struct{})
+			go func() {
+				ws.readLoop()
+				close(done)
+			}()
+			go client.Write(tt.frames)
+			got := make([]byte, len(tt.want))
+			if _, err := io.ReadFull(client, got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("answered % x, want % x", got, tt.want)
+			}
+			if tt.name != "ping" {
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Error("readLoop did not return")
+				}
+			}
+		})
+	}
+}
+
+// TestLiveHub upgrades a connection with the key of the RFC 6455 section
+// 1.3 example and reads a published message
+func TestLiveHub(t *testing.T) {
+	hub := newLiveHub()
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("got %s with accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	// The client is registered once the handshake is done
+	message := []byte(`{"type":"start"}`)
+	for {
+		hub.mu.Lock()
+		registered := len(hub.clients) > 0
+		hub.mu.Unlock()
+		if registered {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	hub.publish(liveMessage{Type: "start"})
+	frame := make([]byte, 2+len(message))
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]byte{0x81, byte(len(message))}, message...); !bytes.Equal(frame, want) {
+		t.Errorf("got frame %q, want %q", frame, want)
+	}
+}
+
+// TestWebSocketUpgradeRejected checks the errors of requests that are not
+// version 13 upgrades
+func TestWebSocketUpgradeRejected(t *testing.T) {
+	server := httptest.NewServer(newLiveHub())
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		version string
+		upgrade string
+		status  int
+	}{
+		{"plain", "", "", http.StatusBadRequest},
+		{"version 8", "8", "websocket", http.StatusUpgradeRequired},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if tt.upgrade != "" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", tt.upgrade)
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			req.Header.Set("Sec-WebSocket-Version", tt.version)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}